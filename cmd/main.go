@@ -1,37 +1,303 @@
 package main
 
 import (
+	"context"
+	"errors"
+	"flag"
+	"fmt"
 	"log"
+	"log/slog"
+	"net"
 	"net/http"
+	"os"
+	"os/signal"
+	"runtime/debug"
+	"syscall"
+	"time"
 
 	"github.com/gorilla/mux"
 
+	"receipt-processor-challenge-jase180/internal/config"
+	"receipt-processor-challenge-jase180/internal/email"
 	"receipt-processor-challenge-jase180/internal/handlers"
+	"receipt-processor-challenge-jase180/internal/parser"
+	rules "receipt-processor-challenge-jase180/internal/services"
 	"receipt-processor-challenge-jase180/internal/store"
 )
 
-// main initializes the in-memory database, sets up routes and starts the server
+// main loads the configuration, initializes the in-memory database, sets up routes and serves until SIGINT or SIGTERM
 func main() {
-	// Initialize in-memory database and handler
+	// Settings from defaults, CONFIG_FILE or -config, environment variables and flags, -help lists them
+	cfg, printConfig, err := config.Load(flag.CommandLine, os.Args[1:], os.Getenv)
+	if err != nil {
+		log.Fatal(err)
+	}
+	if printConfig {
+		if err := cfg.Print(os.Stdout); err != nil {
+			log.Fatal(err)
+		}
+		return
+	}
+
+	// Log through slog in the configured level and format, log.Println calls go through it at info level
+	slog.SetDefault(cfg.Log.Logger(os.Stderr))
+
+	// Initialize in-memory database, the only store backend, and handler
 	db := store.NewMemoryDatabase()
 	handler := handlers.NewReceiptHandler(db)
+	handler.StrictDecoding = cfg.Receipts.StrictDecoding
+	handler.MaxBodyBytes = cfg.Limits.MaxBodyBytes
+	handler.MaxImportBytes = cfg.Limits.MaxImportBytes
+	handler.Version = buildVersion()
+	handler.StoreBackend = cfg.Store.Backend
+	handler.AdminToken = cfg.Admin.Token
+
+	// Load rules configuration (tiers etc.) from a JSON file if given, otherwise keep defaults
+	if cfg.Files.Rules != "" {
+		rulesConfig, err := rules.LoadConfig(cfg.Files.Rules)
+		if err != nil {
+			log.Fatal(err)
+		}
+		handler.Rules = rulesConfig
+	}
+
+	// Load plain-text receipt layouts from a JSON file if given, otherwise the parser uses heuristics only
+	if cfg.Files.ParserTemplates != "" {
+		templates, err := parser.LoadTemplates(cfg.Files.ParserTemplates)
+		if err != nil {
+			log.Fatal(err)
+		}
+		handler.Templates = templates
+	}
+
+	// Replace the default email extractors with a JSON file if given
+	if cfg.Files.EmailExtractors != "" {
+		extractors, err := email.LoadExtractors(cfg.Files.EmailExtractors)
+		if err != nil {
+			log.Fatal(err)
+		}
+		handler.Extractors = extractors
+	}
+
+	// Closed after in-flight requests drain on shutdown. The memory store keeps nothing on disk so it has nothing to flush
+	closers := []func() error{}
+
+	// Local SMTP listener for testing email ingestion, off unless an address is given, keep it on localhost
+	if cfg.Email.SMTPAddr != "" {
+		smtpServer := &email.SMTPServer{Addr: cfg.Email.SMTPAddr, Deliver: handler.DeliverEmail, MaxBytes: cfg.Limits.MaxBodyBytes}
+		closers = append(closers, smtpServer.Close)
+		go func() {
+			log.Println("Running local SMTP listener: " + cfg.Email.SMTPAddr)
+			if err := smtpServer.ListenAndServe(); !errors.Is(err, net.ErrClosed) {
+				log.Fatal(err)
+			}
+		}()
+	}
+
+	// Stop on Ctrl-C or SIGTERM from docker stop, readiness fails for the drain delay then requests in flight get the shutdown timeout
+	ctx, stop := signal.NotifyContext(context.Background(), os.Interrupt, syscall.SIGTERM)
+	defer stop()
+
+	listener, err := net.Listen("tcp", cfg.Server.Addr)
+	if err != nil {
+		log.Fatal(err)
+	}
+	log.Println("Running local server: " + cfg.Server.Addr)
+	server := newServer(cfg.Server, newRouter(handler))
+	if err := serve(ctx, server, listener, time.Duration(cfg.Server.DrainDelay), time.Duration(cfg.Server.ShutdownTimeout), handler.StartShutdown, closers...); err != nil {
+		log.Fatal(err)
+	}
+	log.Println("Server stopped")
+}
 
+// version is the build version, set with go build -ldflags "-X main.version=1.2.3"
+var version string
+
+// buildVersion returns the version set at build time, else the module version or VCS revision Go recorded, else "dev"
+func buildVersion() string {
+	if version != "" {
+		return version
+	}
+	info, ok := debug.ReadBuildInfo()
+	if !ok {
+		return "dev"
+	}
+	if info.Main.Version != "" && info.Main.Version != "(devel)" {
+		return info.Main.Version
+	}
+	revision, modified := "", false
+	for _, setting := range info.Settings {
+		switch setting.Key {
+		case "vcs.revision":
+			revision = setting.Value
+		case "vcs.modified":
+			modified = setting.Value == "true"
+		}
+	}
+	if revision == "" {
+		return "dev"
+	}
+	if len(revision) > 12 {
+		revision = revision[:12]
+	}
+	if modified {
+		revision += "-dirty"
+	}
+	return revision
+}
+
+// newRouter maps every endpoint to its handler, wrapped to count requests and their latency by route
+// The wrapper is outside the router so 404 and 405 responses are counted too, mux middleware only runs on a match
+func newRouter(handler *handlers.ReceiptHandler) http.Handler {
 	// Create Router with gorilla/mux over just using net/http to grab dynamic link ID for GET easily
 	router := mux.NewRouter()
 
+	// Responses in JSON, XML or MessagePack from the Accept header, request bodies follow their Content-Type
+	router.Use(handlers.Negotiate)
+
 	// POST /receipts/process
 	// Accepts Receipt JSON object and stores in memory database
 	// Returns 200 and generated UUID for created receipt if successful
 	// Returns 400 and bad request if unsuccessful
 	router.HandleFunc("/receipts/process", handler.CreateReceiptHandler).Methods(http.MethodPost)
 
+	// POST /receipts/process/text
+	// Accepts the plain text of a printed receipt, parses it and stores it like /receipts/process
+	// Returns 200 with the ID, parsed receipt and per-field confidences, 400 with the parsed receipt if invalid
+	router.HandleFunc("/receipts/process/text", handler.CreateTextReceiptHandler).Methods(http.MethodPost)
+
+	// POST /receipts/process/email
+	// Accepts a raw MIME email of a forwarded e-receipt, extracts the receipt and stores it with the sender
+	// Returns 200 with the ID like /receipts/process/text, 400 if the message or receipt is invalid
+	router.HandleFunc("/receipts/process/email", handler.CreateEmailReceiptHandler).Methods(http.MethodPost)
+
+	// POST /receipts/score
+	// Validates Receipt JSON and returns 200 and the points it would earn without storing it
+	// Add ?breakdown=true for points per rule
+	router.HandleFunc("/receipts/score", handler.ScoreReceiptHandler).Methods(http.MethodPost)
+
+	// POST /receipts/import
+	// Accepts CSV with one row per item grouped by a receipt key column, stores the valid receipts
+	// Returns 200 with the stored receipt IDs and the errors of rows that were not stored
+	router.HandleFunc("/receipts/import", handler.ImportReceiptsHandler).Methods(http.MethodPost)
+
+	// GET /receipts/export.csv
+	// Streams every stored receipt as CSV, one row per item with the receipt's points
+	// Registered before /receipts/{id} so export.csv is not taken as an ID
+	router.HandleFunc("/receipts/export.csv", handler.ExportReceiptsHandler).Methods(http.MethodGet)
+
+	// GET /healthz, /readyz and /status
+	// Liveness is 200 whenever the process answers, readiness is 503 when the store or rules fail or shutdown has begun
+	// Status reports version, uptime, store backend, receipt count and the active rule-set version
+	router.HandleFunc("/healthz", handler.HealthzHandler).Methods(http.MethodGet)
+	router.HandleFunc("/readyz", handler.ReadyzHandler).Methods(http.MethodGet)
+	router.HandleFunc("/status", handler.StatusHandler).Methods(http.MethodGet)
+
+	// GET /metrics
+	// Request counts and latency, validation failures, points per rule, store latency and receipt count for Prometheus
+	router.HandleFunc("/metrics", handler.MetricsHandler).Methods(http.MethodGet)
+
 	// GET /receipts/{id}/points
 	// Returns 200 and points for requested receipt if successful
 	// Returns 400 and bad request if unsuccessful
 	router.HandleFunc("/receipts/{id}/points", handler.GetReceiptHandler).Methods(http.MethodGet)
 
-	// Start the server
-	port := ":8080" // start the server on port 8080 for local development
-	log.Println("Running local server: " + port)
-	log.Fatal(http.ListenAndServe(port, router))
+	// GET /receipts/{id}
+	// Returns 200 and the stored receipt with item categories and award if successful
+	// Returns 400 for a bad ID and 404 if not found
+	router.HandleFunc("/receipts/{id}", handler.GetReceiptDetailsHandler).Methods(http.MethodGet)
+
+	// GET /users/{id}/points
+	// Returns 200 and balance, lifetime points and contributing receipts for user if successful
+	// Returns 400 for bad user ID and 404 if user has no receipts
+	router.HandleFunc("/users/{id}/points", handler.GetUserPointsHandler).Methods(http.MethodGet)
+
+	// GET /users/{id}/ledger
+	// Returns 200 and every ledger entry for user in posting order
+	router.HandleFunc("/users/{id}/ledger", handler.GetUserLedgerHandler).Methods(http.MethodGet)
+
+	// POST /users/{id}/redemptions
+	// Spends user points, returns 200 and the ledger entry with new balance
+	// Returns 403 unless X-User-ID names the user or the admin token is given, 409 if the user does not have enough points
+	router.HandleFunc("/users/{id}/redemptions", handler.CreateRedemptionHandler).Methods(http.MethodPost)
+
+	// /admin endpoints need "Authorization: Bearer <admin token>", 401 without it and 403 if no token is configured
+	admin := router.PathPrefix("/admin").Subrouter()
+	admin.Use(handler.RequireAdmin)
+
+	// POST /admin/users/{id}/adjustments
+	// Adds (or removes with negative points) user points with a reason
+	admin.HandleFunc("/users/{id}/adjustments", handler.CreateAdjustmentHandler).Methods(http.MethodPost)
+
+	// POST /admin/ledger/{id}/reversal
+	// Reverses a ledger entry by posting the opposite entry, returns 409 if already reversed
+	admin.HandleFunc("/ledger/{id}/reversal", handler.ReverseLedgerEntryHandler).Methods(http.MethodPost)
+
+	// POST /admin/simulate
+	// Re-scores stored receipts with current and candidate rules, returns totals, histograms and largest changes
+	admin.HandleFunc("/simulate", handler.SimulateHandler).Methods(http.MethodPost)
+
+	// /campaigns and /campaigns/{id}
+	// CRUD for promotional campaigns that add bonus points to matching receipts submitted while active
+	// Reads are public, writes need the admin token like the /admin routes
+	router.Handle("/campaigns", handler.RequireAdmin(http.HandlerFunc(handler.CreateCampaignHandler))).Methods(http.MethodPost)
+	router.HandleFunc("/campaigns", handler.ListCampaignsHandler).Methods(http.MethodGet)
+	router.HandleFunc("/campaigns/{id}", handler.GetCampaignHandler).Methods(http.MethodGet)
+	router.Handle("/campaigns/{id}", handler.RequireAdmin(http.HandlerFunc(handler.UpdateCampaignHandler))).Methods(http.MethodPut)
+	router.Handle("/campaigns/{id}", handler.RequireAdmin(http.HandlerFunc(handler.DeleteCampaignHandler))).Methods(http.MethodDelete)
+
+	return handler.Instrument(router)
+}
+
+// newServer creates the HTTP server with timeouts so slow clients cannot hold connections forever
+func newServer(cfg config.ServerConfig, router http.Handler) *http.Server {
+	return &http.Server{
+		Addr:              cfg.Addr,
+		Handler:           router,
+		ReadHeaderTimeout: time.Duration(cfg.ReadHeaderTimeout),
+		ReadTimeout:       time.Duration(cfg.ReadTimeout),
+		WriteTimeout:      time.Duration(cfg.WriteTimeout),
+		IdleTimeout:       time.Duration(cfg.IdleTimeout),
+	}
+}
+
+// serve serves HTTP on listener until ctx is done, then calls draining so readiness fails and keeps serving for drainDelay
+// so load balancers see it and stop sending traffic. It then stops accepting connections and waits up to timeout,
+// forever if 0, for requests in flight before dropping what is left. closers run once requests have drained
+func serve(ctx context.Context, server *http.Server, listener net.Listener, drainDelay time.Duration, timeout time.Duration, draining func(), closers ...func() error) error {
+	serveErr := make(chan error, 1)
+	go func() {
+		serveErr <- server.Serve(listener)
+	}()
+
+	select {
+	case err := <-serveErr:
+		return err // listener failed before any shutdown
+	case <-ctx.Done():
+	}
+
+	draining()
+	if drainDelay > 0 {
+		log.Println("Shutting down, failing readiness for " + drainDelay.String() + " before closing the listener")
+		time.Sleep(drainDelay)
+	}
+	log.Println("Shutting down, waiting for requests in flight")
+	shutdownCtx := context.Background()
+	if timeout > 0 {
+		var cancel context.CancelFunc
+		shutdownCtx, cancel = context.WithTimeout(shutdownCtx, timeout)
+		defer cancel()
+	}
+
+	var err error
+	if shutdownErr := server.Shutdown(shutdownCtx); shutdownErr != nil {
+		server.Close() // deadline passed, drop the connections left
+		err = fmt.Errorf("requests still in flight after %s were dropped: %w", timeout, shutdownErr)
+	}
+	for _, closer := range closers {
+		if closeErr := closer(); closeErr != nil {
+			err = errors.Join(err, closeErr)
+		}
+	}
+	return err
 }
@@ -2,16 +2,21 @@ package main
 
 import (
 	"bytes"
+	"context"
 	"encoding/json"
 	"io"
+	"net"
 	"net/http"
 	"net/http/httptest"
+	"strings"
 	"testing"
 	"time"
 
 	"github.com/gorilla/mux"
 
+	"receipt-processor-challenge-jase180/internal/config"
 	"receipt-processor-challenge-jase180/internal/handlers"
+	"receipt-processor-challenge-jase180/internal/metrics/metricstest"
 	"receipt-processor-challenge-jase180/internal/store"
 )
 
@@ -184,3 +189,264 @@ func TestSmoke(t *testing.T) {
 		t.Errorf("Result: %d, want 200", response.StatusCode)
 	}
 }
+
+// startServer serves the routes through serve on a free local port, with a hook seeing each request before the router
+// Returns the server's URL, the cancel that starts shutdown and the channel serve's result arrives on
+func startServer(t *testing.T, handler *handlers.ReceiptHandler, drainDelay time.Duration, shutdownTimeout time.Duration, hook func(*http.Request)) (string, context.CancelFunc, chan error) {
+	t.Helper()
+	listener, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatalf("Error during test listening: %v", err)
+	}
+
+	router := newRouter(handler)
+	hooked := http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		hook(r)
+		router.ServeHTTP(w, r)
+	})
+
+	ctx, cancel := context.WithCancel(context.Background())
+	stopped := make(chan error, 1)
+	go func() {
+		stopped <- serve(ctx, newServer(config.Default().Server, hooked), listener, drainDelay, shutdownTimeout, handler.StartShutdown)
+	}()
+	return "http://" + listener.Addr().String(), cancel, stopped
+}
+
+// TestGracefulShutdown verifies a request in flight when shutdown starts still completes while new connections are refused
+func TestGracefulShutdown(t *testing.T) {
+	db := store.NewMemoryDatabase()
+	handler := handlers.NewReceiptHandler(db)
+	started := make(chan struct{}, 1)
+	url, shutdown, stopped := startServer(t, handler, 0, 5*time.Second, func(*http.Request) { started <- struct{}{} })
+
+	// Send the receipt slowly so it is still being read when shutdown starts
+	receipt := `{"retailer": "Target", "purchaseDate": "2022-01-02", "purchaseTime": "13:13", "total": "1.25",
+		"items": [{"shortDescription": "Pepsi - 12-oz", "price": "1.25"}]}`
+	body, bodyWriter := io.Pipe()
+	responses := make(chan *http.Response, 1)
+	go func() {
+		response, err := http.Post(url+"/receipts/process", "application/json", body)
+		if err != nil {
+			t.Errorf("Request in flight failed: %v", err)
+		}
+		responses <- response
+	}()
+	bodyWriter.Write([]byte(receipt[:40]))
+	<-started
+	shutdown()
+
+	// The listener closes as soon as shutdown starts
+	address := strings.TrimPrefix(url, "http://")
+	deadline := time.Now().Add(2 * time.Second)
+	for {
+		conn, err := net.Dial("tcp", address)
+		if err != nil {
+			break
+		}
+		conn.Close()
+		if time.Now().After(deadline) {
+			t.Fatalf("Server still accepting connections after shutdown started")
+		}
+		time.Sleep(10 * time.Millisecond)
+	}
+
+	// Readiness fails while the request in flight drains
+	ready := httptest.NewRecorder()
+	handler.ReadyzHandler(ready, httptest.NewRequest(http.MethodGet, "/readyz", nil))
+	if ready.Code != http.StatusServiceUnavailable {
+		t.Errorf("Result /readyz status %d during shutdown, want 503", ready.Code)
+	}
+
+	bodyWriter.Write([]byte(receipt[40:]))
+	bodyWriter.Close()
+	response := <-responses
+	if response == nil {
+		t.Fatalf("No response for the request in flight")
+	}
+	defer response.Body.Close()
+	var created map[string]string
+	json.NewDecoder(response.Body).Decode(&created)
+	if response.StatusCode != http.StatusOK || created["id"] == "" {
+		t.Errorf("Result status %d body %v, want 200 with an ID", response.StatusCode, created)
+	}
+	if _, err := db.GetReceiptByID(created["id"]); err != nil {
+		t.Errorf("Receipt from the request in flight was not stored: %v", err)
+	}
+
+	if err := <-stopped; err != nil {
+		t.Errorf("Result shutdown error: %v, want clean shutdown", err)
+	}
+}
+
+// TestDrainDelay verifies /readyz fails while the server still accepts requests during the drain delay, then the listener closes
+func TestDrainDelay(t *testing.T) {
+	url, shutdown, stopped := startServer(t, handlers.NewReceiptHandler(store.NewMemoryDatabase()), 500*time.Millisecond, time.Second,
+		func(*http.Request) {})
+	// No keep-alives, a spare pooled connection never sends a request and Shutdown waits on new connections
+	client := &http.Client{Transport: &http.Transport{DisableKeepAlives: true}}
+	get := func(path string) (int, error) {
+		response, err := client.Get(url + path)
+		if err != nil {
+			return 0, err
+		}
+		response.Body.Close()
+		return response.StatusCode, nil
+	}
+
+	if code, err := get("/readyz"); err != nil || code != http.StatusOK {
+		t.Fatalf("Result /readyz %d %v before shutdown, want 200", code, err)
+	}
+	started := time.Now()
+	shutdown()
+	time.Sleep(50 * time.Millisecond) // let serve see the cancel
+
+	// New connections are still served, readiness tells load balancers to stop sending them
+	if code, err := get("/readyz"); err != nil || code != http.StatusServiceUnavailable {
+		t.Errorf("Result /readyz %d %v during the drain delay, want 503", code, err)
+	}
+	if code, err := get("/healthz"); err != nil || code != http.StatusOK {
+		t.Errorf("Result /healthz %d %v during the drain delay, want 200", code, err)
+	}
+
+	if err := <-stopped; err != nil {
+		t.Errorf("Result shutdown error: %v, want clean shutdown", err)
+	}
+	if elapsed := time.Since(started); elapsed < 500*time.Millisecond {
+		t.Errorf("Result stopped after %v, want at least the drain delay", elapsed)
+	}
+	if _, err := get("/readyz"); err == nil {
+		t.Errorf("Result served after shutdown, want connection refused")
+	}
+}
+
+// TestShutdownDeadline verifies requests still in flight when the shutdown timeout passes are dropped and reported
+func TestShutdownDeadline(t *testing.T) {
+	started := make(chan struct{}, 1)
+	url, shutdown, stopped := startServer(t, handlers.NewReceiptHandler(store.NewMemoryDatabase()), 0, 100*time.Millisecond,
+		func(*http.Request) { started <- struct{}{} })
+
+	// A body that never finishes keeps the request in flight
+	body, bodyWriter := io.Pipe()
+	failed := make(chan error, 1)
+	go func() {
+		response, err := http.Post(url+"/receipts/process", "application/json", body)
+		if err == nil {
+			response.Body.Close()
+		}
+		failed <- err
+	}()
+	bodyWriter.Write([]byte(`{"retailer": `))
+	<-started
+	shutdown()
+
+	select {
+	case err := <-stopped:
+		if err == nil {
+			t.Errorf("Result no shutdown error, want requests dropped")
+		}
+	case <-time.After(5 * time.Second):
+		t.Fatalf("Shutdown did not stop at its deadline")
+	}
+	bodyWriter.Close() // lets the client notice its connection was dropped
+	if err := <-failed; err == nil {
+		t.Errorf("Dropped request succeeded, want connection error")
+	}
+}
+
+// TestMetricsEndpoint verifies the real routes are counted and /metrics scrapes in the Prometheus text format
+func TestMetricsEndpoint(t *testing.T) {
+	url, shutdown, stopped := startServer(t, handlers.NewReceiptHandler(store.NewMemoryDatabase()), 0, time.Second, func(*http.Request) {})
+	defer func() {
+		shutdown()
+		<-stopped
+	}()
+
+	receipt := `{"retailer": "Target", "purchaseDate": "2022-01-02", "purchaseTime": "13:13", "total": "1.25",
+		"items": [{"shortDescription": "Pepsi - 12-oz", "price": "1.25"}]}`
+	response, err := http.Post(url+"/receipts/process", "application/json", strings.NewReader(receipt))
+	if err != nil {
+		t.Fatalf("Failed to send POST: %v", err)
+	}
+	response.Body.Close()
+
+	response, err = http.Get(url + "/metrics")
+	if err != nil {
+		t.Fatalf("Failed to scrape: %v", err)
+	}
+	defer response.Body.Close()
+	samples, err := metricstest.Parse(response.Body)
+	if err != nil {
+		t.Fatalf("Result scrape does not parse: %v", err)
+	}
+	labels := map[string]string{"method": "POST", "route": "/receipts/process", "status": "200"}
+	if value, _ := metricstest.Find(samples, "http_requests_total", labels); value != 1 {
+		t.Errorf("Result requests %v, want 1", value)
+	}
+	if value, _ := metricstest.Find(samples, "store_receipts", nil); value != 1 {
+		t.Errorf("Result stored receipts %v, want 1", value)
+	}
+}
+
+// TestAdminAuth verifies admin endpoints need the admin token and redemptions need the user's own ID
+func TestAdminAuth(t *testing.T) {
+	const token = "0123456789abcdef0123"
+	handler := handlers.NewReceiptHandler(store.NewMemoryDatabase())
+	handler.AdminToken = token
+	router := newRouter(handler)
+	disabled := newRouter(handlers.NewReceiptHandler(store.NewMemoryDatabase()))
+
+	send := func(router http.Handler, method, url, body string, headers map[string]string) int {
+		request := httptest.NewRequest(method, url, strings.NewReader(body))
+		for name, value := range headers {
+			request.Header.Set(name, value)
+		}
+		responseRecorder := httptest.NewRecorder()
+		router.ServeHTTP(responseRecorder, request)
+		return responseRecorder.Code
+	}
+
+	// user-1 has 109 points
+	receipt := `{"retailer": "M&M Corner Market", "purchaseDate": "2022-03-20", "purchaseTime": "14:33", "userId": "user-1",
+		"items": [{"shortDescription": "Gatorade", "price": "2.25"}, {"shortDescription": "Gatorade", "price": "2.25"},
+		{"shortDescription": "Gatorade", "price": "2.25"}, {"shortDescription": "Gatorade", "price": "2.25"}], "total": "9.00"}`
+	if code := send(router, "POST", "/receipts/process", receipt, nil); code != http.StatusOK {
+		t.Fatalf("Result receipt status %d, want 200", code)
+	}
+
+	admin := map[string]string{"Authorization": "Bearer " + token}
+	campaign := `{"name": "Double points", "start": "2022-01-01T00:00:00Z", "end": "2023-01-01T00:00:00Z", "bonus": {"type": "multiplier", "value": 2}}`
+	tests := []struct {
+		name    string
+		router  http.Handler
+		method  string
+		url     string
+		body    string
+		headers map[string]string
+		want    int
+	}{
+		{"Adjustment without token", router, "POST", "/admin/users/user-1/adjustments", `{"points": 5, "reason": "x"}`, nil, http.StatusUnauthorized},
+		{"Adjustment with wrong token", router, "POST", "/admin/users/user-1/adjustments", `{"points": 5, "reason": "x"}`,
+			map[string]string{"Authorization": "Bearer " + token + "x"}, http.StatusUnauthorized},
+		{"Adjustment with token", router, "POST", "/admin/users/user-1/adjustments", `{"points": 5, "reason": "x"}`, admin, http.StatusOK},
+		{"Simulate without token", router, "POST", "/admin/simulate", `{"rules": {}}`, nil, http.StatusUnauthorized},
+		{"Admin disabled without a configured token", disabled, "POST", "/admin/simulate", `{"rules": {}}`, admin, http.StatusForbidden},
+		{"Redemption without user header", router, "POST", "/users/user-1/redemptions", `{"points": 10}`, nil, http.StatusForbidden},
+		{"Redemption for another user", router, "POST", "/users/user-1/redemptions", `{"points": 10}`,
+			map[string]string{"X-User-ID": "user-2"}, http.StatusForbidden},
+		{"Redemption by the user", router, "POST", "/users/user-1/redemptions", `{"points": 10}`, map[string]string{"X-User-ID": "user-1"}, http.StatusOK},
+		{"Redemption by an admin", router, "POST", "/users/user-1/redemptions", `{"points": 10}`, admin, http.StatusOK},
+		{"Campaign create without token", router, "POST", "/campaigns", campaign, nil, http.StatusUnauthorized},
+		{"Campaign create with token", router, "POST", "/campaigns", campaign, admin, http.StatusOK},
+		{"Campaign update without token", router, "PUT", "/campaigns/7fb1377b-b223-49d9-a31a-5a02701dd310", campaign, nil, http.StatusUnauthorized},
+		{"Campaign delete without token", router, "DELETE", "/campaigns/7fb1377b-b223-49d9-a31a-5a02701dd310", "", nil, http.StatusUnauthorized},
+		{"Campaign list without token", router, "GET", "/campaigns", "", nil, http.StatusOK},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if code := send(tt.router, tt.method, tt.url, tt.body, tt.headers); code != tt.want {
+				t.Errorf("Result status %d, want %d", code, tt.want)
+			}
+		})
+	}
+}
@@ -5,4 +5,9 @@ go 1.24.1
 require (
 	github.com/google/uuid v1.6.0
 	github.com/gorilla/mux v1.8.1
+	github.com/rivo/uniseg v0.4.7
+	github.com/vmihailenco/msgpack/v5 v5.4.1
+	golang.org/x/text v0.29.0
 )
+
+require github.com/vmihailenco/tagparser/v2 v2.0.0 // indirect
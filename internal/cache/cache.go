@@ -0,0 +1,66 @@
+// Package cache keeps values compiled from configured sources, e.g. regular expressions and expression programs
+package cache
+
+import (
+	"container/list"
+	"sync"
+)
+
+// Compiled caches values by key, keeping at most size of the most recently used
+// Sources can arrive in requests (simulator candidates) so it is bounded instead of growing for the life of the process
+type Compiled[T any] struct {
+	lock    sync.Mutex
+	size    int
+	entries map[string]*list.Element
+	order   *list.List // Most recently used at the front
+}
+
+// entry is a cached value and its key, so the oldest entry can be removed from the map
+type entry[T any] struct {
+	key   string
+	value T
+}
+
+// NewCompiled creates a cache holding at most size values, at least 1
+func NewCompiled[T any](size int) *Compiled[T] {
+	return &Compiled[T]{size: max(size, 1), entries: map[string]*list.Element{}, order: list.New()}
+}
+
+// Get returns the value cached for key, calling compile on a miss. Errors are returned and not cached
+// compile runs without the lock so a slow compile does not block other keys
+func (c *Compiled[T]) Get(key string, compile func() (T, error)) (T, error) {
+	c.lock.Lock()
+	if element, cached := c.entries[key]; cached {
+		c.order.MoveToFront(element)
+		value := element.Value.(entry[T]).value
+		c.lock.Unlock()
+		return value, nil
+	}
+	c.lock.Unlock()
+
+	value, err := compile()
+	if err != nil {
+		return value, err
+	}
+
+	c.lock.Lock()
+	defer c.lock.Unlock()
+	if element, cached := c.entries[key]; cached {
+		c.order.MoveToFront(element) // compiled concurrently, keep the first so callers share one value
+		return element.Value.(entry[T]).value, nil
+	}
+	c.entries[key] = c.order.PushFront(entry[T]{key: key, value: value})
+	if c.order.Len() > c.size {
+		oldest := c.order.Back()
+		c.order.Remove(oldest)
+		delete(c.entries, oldest.Value.(entry[T]).key)
+	}
+	return value, nil
+}
+
+// Len returns the number of cached values
+func (c *Compiled[T]) Len() int {
+	c.lock.Lock()
+	defer c.lock.Unlock()
+	return c.order.Len()
+}
@@ -0,0 +1,78 @@
+package cache
+
+import (
+	"errors"
+	"strconv"
+	"sync"
+	"testing"
+)
+
+func TestCompiled(t *testing.T) {
+	compiled := NewCompiled[int](2)
+	compiles := 0
+	get := func(key string) (int, error) {
+		return compiled.Get(key, func() (int, error) {
+			compiles++
+			if key == "bad" {
+				return 0, errors.New("bad source")
+			}
+			return strconv.Atoi(key)
+		})
+	}
+
+	tests := []struct {
+		name         string
+		key          string
+		wantErr      bool
+		wantCompiles int // compiles so far
+	}{
+		{"First use compiles", "1", false, 1},
+		{"Second use is cached", "1", false, 1},
+		{"New key compiles", "2", false, 2},
+		{"Errors are not cached", "bad", true, 3},
+		{"Errors compile again", "bad", true, 4},
+		{"Third key evicts least recently used", "3", false, 5},
+		{"Recently used key kept", "2", false, 5},
+		{"Evicted key compiles again", "1", false, 6},
+	}
+	for _, testCase := range tests {
+		t.Run(testCase.name, func(t *testing.T) {
+			value, err := get(testCase.key)
+			if (err != nil) != testCase.wantErr {
+				t.Fatalf("unexpected error, error was %v, want %v", err, testCase.wantErr)
+			}
+			if !testCase.wantErr && strconv.Itoa(value) != testCase.key {
+				t.Errorf("Result was %d; want %s", value, testCase.key)
+			}
+			if compiles != testCase.wantCompiles {
+				t.Errorf("Result was %d compiles; want %d", compiles, testCase.wantCompiles)
+			}
+			if compiled.Len() > 2 {
+				t.Errorf("Result was %d cached values; want at most 2", compiled.Len())
+			}
+		})
+	}
+}
+
+func TestCompiledConcurrency(t *testing.T) {
+	compiled := NewCompiled[int](10)
+
+	// Initiate a waitgroup
+	var waitGroup sync.WaitGroup
+	for i := 0; i < 100; i++ {
+		waitGroup.Add(1)
+		go func(i int) {
+			defer waitGroup.Done()
+			key := strconv.Itoa(i % 20)
+			value, err := compiled.Get(key, func() (int, error) { return strconv.Atoi(key) })
+			if err != nil || strconv.Itoa(value) != key {
+				t.Errorf("Result was %d, %v; want %s", value, err, key)
+			}
+		}(i)
+	}
+	waitGroup.Wait() // this ensures all go routines finish
+
+	if compiled.Len() > 10 {
+		t.Errorf("Result was %d cached values; want at most 10", compiled.Len())
+	}
+}
@@ -0,0 +1,107 @@
+// Package codec converts request and response bodies between JSON and the other formats the API speaks
+// JSON stays the canonical form: XML and MessagePack bodies are converted to JSON before decoding and
+// responses are marshalled to JSON before converting, so every format has the same fields, validation and errors
+package codec
+
+import (
+	"mime"
+	"sort"
+	"strconv"
+	"strings"
+)
+
+// Format is a body format the API can read and write
+type Format string
+
+// Supported formats, JSON is the default
+const (
+	JSON    Format = "json"
+	XML     Format = "xml"
+	MsgPack Format = "msgpack"
+)
+
+// ContentType returns the media type responses in the format are sent with
+func (f Format) ContentType() string {
+	switch f {
+	case XML:
+		return "application/xml; charset=utf-8"
+	case MsgPack:
+		return "application/msgpack"
+	}
+	return "application/json; charset=utf-8"
+}
+
+// mediaTypes maps every accepted media type to its format, MessagePack has no registered type so common names are all accepted
+var mediaTypes = map[string]Format{
+	"application/json":          JSON,
+	"text/json":                 JSON,
+	"application/xml":           XML,
+	"text/xml":                  XML,
+	"application/msgpack":       MsgPack,
+	"application/x-msgpack":     MsgPack,
+	"application/vnd.msgpack":   MsgPack,
+	"application/x-messagepack": MsgPack,
+}
+
+// formatOf returns the format of a media type, also matching structured suffixes like application/problem+json
+func formatOf(mediaType string) (Format, bool) {
+	if format, ok := mediaTypes[mediaType]; ok {
+		return format, true
+	}
+	switch {
+	case strings.HasSuffix(mediaType, "+json"):
+		return JSON, true
+	case strings.HasSuffix(mediaType, "+xml"):
+		return XML, true
+	}
+	return "", false
+}
+
+// RequestFormat returns the format of a request body from its Content-Type
+// Missing and unknown content types are JSON so clients that never set one keep working
+func RequestFormat(contentType string) Format {
+	mediaType, _, err := mime.ParseMediaType(contentType)
+	if err != nil {
+		return JSON
+	}
+	if format, ok := formatOf(strings.ToLower(mediaType)); ok {
+		return format
+	}
+	return JSON
+}
+
+// ResponseFormat picks the response format from an Accept header by quality, ties go to the earlier type
+// Wildcards and headers naming none of the formats get JSON, the API never answers 406 for a body it can send as JSON
+func ResponseFormat(accept string) Format {
+	type candidate struct {
+		format  Format
+		quality float64
+	}
+	candidates := []candidate{}
+	for _, part := range strings.Split(accept, ",") {
+		mediaType, params, err := mime.ParseMediaType(strings.TrimSpace(part))
+		if err != nil {
+			continue
+		}
+		quality := 1.0
+		if q, ok := params["q"]; ok {
+			if parsed, err := strconv.ParseFloat(q, 64); err == nil {
+				quality = parsed
+			}
+		}
+		mediaType = strings.ToLower(mediaType)
+		format, ok := formatOf(mediaType)
+		if mediaType == "*/*" || mediaType == "application/*" {
+			format, ok = JSON, true // wildcards are served the default
+		}
+		if !ok || quality <= 0 {
+			continue
+		}
+		candidates = append(candidates, candidate{format, quality})
+	}
+	if len(candidates) == 0 {
+		return JSON
+	}
+	sort.SliceStable(candidates, func(i, j int) bool { return candidates[i].quality > candidates[j].quality })
+	return candidates[0].format
+}
@@ -0,0 +1,52 @@
+package codec
+
+import "testing"
+
+func TestRequestFormat(t *testing.T) {
+	tests := []struct {
+		contentType string
+		want        Format
+	}{
+		{"", JSON},
+		{"application/json", JSON},
+		{"application/json; charset=utf-8", JSON},
+		{"application/XML; charset=utf-8", XML},
+		{"text/xml", XML},
+		{"application/vnd.pos+xml", XML},
+		{"application/msgpack", MsgPack},
+		{"application/x-msgpack", MsgPack},
+		{"text/plain", JSON},
+		{"not a media type;;", JSON},
+	}
+
+	for _, testCase := range tests {
+		if got := RequestFormat(testCase.contentType); got != testCase.want {
+			t.Errorf("Result RequestFormat(%q): %q, want %q", testCase.contentType, got, testCase.want)
+		}
+	}
+}
+
+func TestResponseFormat(t *testing.T) {
+	tests := []struct {
+		accept string
+		want   Format
+	}{
+		{"", JSON},
+		{"*/*", JSON},
+		{"application/xml", XML},
+		{"application/msgpack", MsgPack},
+		{"application/json, application/xml", JSON},
+		{"application/xml, application/json", XML},
+		{"application/json;q=0.5, application/xml", XML},
+		{"application/xml;q=0.5, */*", JSON},
+		{"application/msgpack;q=0, application/xml;q=0.1", XML},
+		{"text/csv", JSON},
+		{"text/html, application/xhtml+xml, application/xml;q=0.9, */*;q=0.8", XML},
+	}
+
+	for _, testCase := range tests {
+		if got := ResponseFormat(testCase.accept); got != testCase.want {
+			t.Errorf("Result ResponseFormat(%q): %q, want %q", testCase.accept, got, testCase.want)
+		}
+	}
+}
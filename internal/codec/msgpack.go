@@ -0,0 +1,106 @@
+package codec
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"fmt"
+
+	"github.com/vmihailenco/msgpack/v5"
+)
+
+// JSONToMsgPack converts a JSON document to MessagePack, map keys are sorted so the output is stable
+// Whole numbers become integers and the rest floats, as a MessagePack client would expect
+func JSONToMsgPack(data []byte) ([]byte, error) {
+	decoder := json.NewDecoder(bytes.NewReader(data))
+	decoder.UseNumber()
+	var value interface{}
+	if err := decoder.Decode(&value); err != nil {
+		return nil, err
+	}
+
+	var buffer bytes.Buffer
+	encoder := msgpack.NewEncoder(&buffer)
+	encoder.SetSortMapKeys(true)
+	if err := encoder.Encode(msgpackNumbers(value)); err != nil {
+		return nil, err
+	}
+	return buffer.Bytes(), nil
+}
+
+// msgpackNumbers replaces JSON numbers with integers or floats throughout a decoded JSON value
+func msgpackNumbers(value interface{}) interface{} {
+	switch typed := value.(type) {
+	case map[string]interface{}:
+		for key, child := range typed {
+			typed[key] = msgpackNumbers(child)
+		}
+	case []interface{}:
+		for i, child := range typed {
+			typed[i] = msgpackNumbers(child)
+		}
+	case json.Number:
+		if integer, err := typed.Int64(); err == nil {
+			return integer
+		}
+		float, _ := typed.Float64()
+		return float
+	}
+	return value
+}
+
+// MsgPackToJSON converts one MessagePack value to JSON, data after the value is an error
+func MsgPackToJSON(data []byte) ([]byte, error) {
+	reader := bytes.NewReader(data)
+	decoder := msgpack.NewDecoder(reader)
+	var value interface{}
+	if err := decoder.Decode(&value); err != nil {
+		return nil, err
+	}
+	if reader.Len() > 0 {
+		return nil, errors.New("MessagePack has data after the value")
+	}
+
+	value, err := jsonKeys(value)
+	if err != nil {
+		return nil, err
+	}
+	return json.Marshal(value)
+}
+
+// jsonKeys converts maps with non string keys, which MessagePack allows, to the string keyed maps of JSON
+func jsonKeys(value interface{}) (interface{}, error) {
+	switch typed := value.(type) {
+	case map[string]interface{}:
+		for key, child := range typed {
+			converted, err := jsonKeys(child)
+			if err != nil {
+				return nil, err
+			}
+			typed[key] = converted
+		}
+	case map[interface{}]interface{}:
+		object := make(map[string]interface{}, len(typed))
+		for key, child := range typed {
+			name, ok := key.(string)
+			if !ok {
+				return nil, fmt.Errorf("MessagePack map key %v is not a string", key)
+			}
+			converted, err := jsonKeys(child)
+			if err != nil {
+				return nil, err
+			}
+			object[name] = converted
+		}
+		return object, nil
+	case []interface{}:
+		for i, child := range typed {
+			converted, err := jsonKeys(child)
+			if err != nil {
+				return nil, err
+			}
+			typed[i] = converted
+		}
+	}
+	return value, nil
+}
@@ -0,0 +1,65 @@
+package codec
+
+import (
+	"bytes"
+	"fmt"
+	"testing"
+
+	"github.com/vmihailenco/msgpack/v5"
+)
+
+func TestJSONToMsgPack(t *testing.T) {
+	data, err := JSONToMsgPack([]byte(`{"points": 28, "ratio": 1.5, "id": "a", "items": [{"ok": true}], "none": null}`))
+	if err != nil {
+		t.Fatalf("Result error: %v", err)
+	}
+
+	var decoded map[string]interface{}
+	if err := msgpack.Unmarshal(data, &decoded); err != nil {
+		t.Fatalf("Result is not MessagePack: %v", err)
+	}
+	// Whole numbers are integers so clients do not see 28.0
+	if _, isFloat := decoded["points"].(float64); isFloat || fmt.Sprint(decoded["points"]) != "28" {
+		t.Errorf("Result points: %#v, want integer 28", decoded["points"])
+	}
+	if decoded["ratio"] != 1.5 || decoded["id"] != "a" || decoded["none"] != nil {
+		t.Errorf("Result: %#v", decoded)
+	}
+
+	// Map keys are sorted so output is stable
+	again, _ := JSONToMsgPack([]byte(`{"id": "a", "items": [{"ok": true}], "none": null, "points": 28, "ratio": 1.5}`))
+	if !bytes.Equal(data, again) {
+		t.Errorf("Result differs with key order, want stable output")
+	}
+}
+
+func TestMsgPackToJSON(t *testing.T) {
+	receipt, _ := msgpack.Marshal(map[string]interface{}{"retailer": "Target", "items": []interface{}{map[string]interface{}{"price": "6.49"}}, "count": 3})
+	nonStringKey, _ := msgpack.Marshal(map[int]string{1: "a"})
+	withTrailing := append(append([]byte{}, receipt...), 0xc0)
+
+	tests := []struct {
+		name      string
+		data      []byte
+		want      string
+		wantError bool
+	}{
+		{"Map", receipt, `{"count":3,"items":[{"price":"6.49"}],"retailer":"Target"}`, false},
+		{"Non string keys", nonStringKey, "", true},
+		{"Trailing data", withTrailing, "", true},
+		{"Truncated", receipt[:len(receipt)-2], "", true},
+		{"Empty", []byte{}, "", true},
+	}
+
+	for _, testCase := range tests {
+		t.Run(testCase.name, func(t *testing.T) {
+			got, err := MsgPackToJSON(testCase.data)
+			if (err != nil) != testCase.wantError {
+				t.Fatalf("Result error: %v, want error %v", err, testCase.wantError)
+			}
+			if !testCase.wantError && string(got) != testCase.want {
+				t.Errorf("Result: %s, want: %s", got, testCase.want)
+			}
+		})
+	}
+}
@@ -0,0 +1,297 @@
+package codec
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"reflect"
+	"strconv"
+	"strings"
+)
+
+// DecodeIssue is a problem found decoding a JSON body, an error in strict mode and a warning in lenient mode
+type DecodeIssue struct {
+	Field   string `json:"field,omitempty"`  // Path of the field, e.g. items[1].price, empty for the whole body
+	Offset  int64  `json:"offset,omitempty"` // Byte offset from 0 in the body where the problem starts, 0 if unknown
+	Problem string `json:"problem"`          // What is wrong
+}
+
+// Error describes the issue with its field and offset, e.g. `items[0].prce at byte 58: unknown field`
+func (i DecodeIssue) Error() string {
+	switch {
+	case i.Field != "" && i.Offset > 0:
+		return fmt.Sprintf("%s at byte %d: %s", i.Field, i.Offset, i.Problem)
+	case i.Field != "":
+		return i.Field + ": " + i.Problem
+	case i.Offset > 0:
+		return fmt.Sprintf("byte %d: %s", i.Offset, i.Problem)
+	}
+	return i.Problem
+}
+
+// DecodeJSON reads one JSON value from r into v, a pointer, checking it against v's type as it streams in
+// Syntax errors, wrong types and data after the value are always errors. In strict mode fields v does not have and
+// duplicate keys are errors too, otherwise they are returned as warnings and decoded like encoding/json would:
+// unknown fields ignored, field names matched ignoring case and the last duplicate kept.
+// Errors reading r, like *http.MaxBytesError, are returned as they are rather than as a DecodeIssue
+func DecodeJSON(r io.Reader, v interface{}, strict bool) ([]DecodeIssue, error) {
+	target := reflect.TypeOf(v)
+	if target == nil || target.Kind() != reflect.Pointer {
+		return nil, errors.New("codec: JSON target must be a pointer")
+	}
+
+	// The checking pass stops at the first error without reading the rest, the bytes it read are then decoded into v
+	var read bytes.Buffer
+	walker := &jsonWalker{decoder: json.NewDecoder(io.TeeReader(r, &read)), read: &read, strict: strict}
+	walker.decoder.UseNumber() // numbers are range checked when decoding into v
+	if err := walker.value(target.Elem(), ""); err != nil {
+		return nil, walker.issue(err)
+	}
+	end := walker.decoder.InputOffset()
+
+	// Exactly one value per body, anything but white space after it is an error in either mode
+	if _, err := walker.decoder.Token(); err != io.EOF {
+		var issue DecodeIssue
+		if err != nil && !errors.As(walker.issue(err), &issue) {
+			return nil, err // reading the body failed
+		}
+		return nil, DecodeIssue{Offset: walker.start(end), Problem: "unexpected data after the JSON value"}
+	}
+
+	if err := json.Unmarshal(read.Bytes()[:end], v); err != nil {
+		var typeErr *json.UnmarshalTypeError
+		if errors.As(err, &typeErr) {
+			return nil, DecodeIssue{Field: typeErr.Field, Offset: typeErr.Offset, Problem: "cannot use " + typeErr.Value + " as " + typeErr.Type.String()}
+		}
+		return nil, DecodeIssue{Problem: err.Error()} // e.g. a value rejected by a type's UnmarshalJSON
+	}
+	return walker.issues, nil
+}
+
+// jsonWalker checks a JSON stream against the type it is decoded into
+type jsonWalker struct {
+	decoder *json.Decoder
+	read    *bytes.Buffer // Every byte the decoder has read, it reads ahead of the current token
+	strict  bool
+	issues  []DecodeIssue // Warnings found so far in lenient mode
+}
+
+// jsonUnmarshaler is the interface of types that decode themselves, their JSON is not checked
+var jsonUnmarshaler = reflect.TypeOf((*json.Unmarshaler)(nil)).Elem()
+
+// value reads the next value and checks it against target, which is nil for values of any shape
+func (w *jsonWalker) value(target reflect.Type, path string) error {
+	for target != nil && target.Kind() == reflect.Pointer {
+		target = target.Elem()
+	}
+	if target != nil && (target.Kind() == reflect.Interface ||
+		reflect.PointerTo(target).Implements(jsonUnmarshaler) && !reflect.PointerTo(target).Implements(textUnmarshaler)) {
+		target = nil // time.Time decodes itself but is always a string, so it is still checked
+	}
+
+	previous := w.decoder.InputOffset()
+	token, err := w.decoder.Token()
+	if err != nil {
+		return err
+	}
+	if target != nil && token != nil { // null decodes into anything
+		if want, got := expectedKind(target), tokenKind(token); want != got {
+			return w.fail(DecodeIssue{Field: path, Offset: w.start(previous), Problem: "cannot use " + got + " as " + want})
+		}
+		if number, ok := token.(json.Number); ok && !fits(number, target) {
+			return w.fail(DecodeIssue{Field: path, Offset: w.start(previous), Problem: "cannot use number " + number.String() + " as " + target.String()})
+		}
+	}
+
+	switch token {
+	case json.Delim('['):
+		var elem reflect.Type
+		if target != nil {
+			elem = target.Elem()
+		}
+		for i := 0; w.decoder.More(); i++ {
+			if err := w.value(elem, path+"["+strconv.Itoa(i)+"]"); err != nil {
+				return err
+			}
+		}
+	case json.Delim('{'):
+		if err := w.object(target, path); err != nil {
+			return err
+		}
+	default:
+		return nil
+	}
+	_, err = w.decoder.Token() // closing delimiter
+	return err
+}
+
+// object reads the members of an object, struct targets only accept their own JSON fields
+func (w *jsonWalker) object(target reflect.Type, path string) error {
+	var fields map[string]reflect.Type
+	var elem reflect.Type
+	if target != nil && target.Kind() == reflect.Struct {
+		fields = jsonFields(target)
+	} else if target != nil {
+		elem = target.Elem()
+	}
+
+	seen := make(map[string]bool)
+	for w.decoder.More() {
+		previous := w.decoder.InputOffset()
+		token, err := w.decoder.Token()
+		if err != nil {
+			return err
+		}
+		key := token.(string)
+		issue := DecodeIssue{Field: key, Offset: w.start(previous)}
+		if path != "" {
+			issue.Field = path + "." + key
+		}
+
+		if seen[key] {
+			issue.Problem = "duplicate field"
+			if err := w.report(issue, "duplicate field, the last value is used"); err != nil {
+				return err
+			}
+		}
+		seen[key] = true
+
+		child := elem
+		if fields != nil {
+			var known bool
+			if child, known = fields[key]; !known {
+				lenient := "unknown field, ignored"
+				issue.Problem = "unknown field"
+				if name, ok := foldedField(fields, key); ok {
+					child = fields[name]
+					issue.Problem = fmt.Sprintf("unknown field, did you mean %q", name)
+					lenient = fmt.Sprintf("field name differs in case, read as %q", name)
+				}
+				if err := w.report(issue, lenient); err != nil {
+					return err
+				}
+			}
+		}
+		if err := w.value(child, issue.Field); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// report returns the issue as an error in strict mode, otherwise it is recorded as a warning with the lenient problem
+// saying what was done instead
+func (w *jsonWalker) report(issue DecodeIssue, lenient string) error {
+	if w.strict {
+		return w.fail(issue)
+	}
+	issue.Problem = lenient
+	w.issues = append(w.issues, issue)
+	return nil
+}
+
+// walkError carries a DecodeIssue out of the walk so it is not mistaken for a decoder error
+type walkError struct{ issue DecodeIssue }
+
+func (e walkError) Error() string { return e.issue.Error() }
+
+// fail stops the walk with an issue
+func (w *jsonWalker) fail(issue DecodeIssue) error {
+	return walkError{issue}
+}
+
+// start returns where the token after offset begins, skipping white space and separators
+func (w *jsonWalker) start(offset int64) int64 {
+	if offset >= int64(w.read.Len()) {
+		return offset
+	}
+	rest := w.read.Bytes()[offset:]
+	return offset + int64(len(rest)-len(bytes.TrimLeft(rest, " \t\r\n,:")))
+}
+
+// issue turns an error from the decoder into a DecodeIssue, errors reading the body are returned as they are
+func (w *jsonWalker) issue(err error) error {
+	var walkErr walkError
+	var syntaxErr *json.SyntaxError
+	switch {
+	case errors.As(err, &walkErr):
+		return walkErr.issue
+	case errors.As(err, &syntaxErr):
+		offset := syntaxErr.Offset
+		if offset < int64(w.read.Len()) {
+			offset-- // the offset is after the bad byte, unless the body ended
+		}
+		return DecodeIssue{Offset: max(offset, 0), Problem: syntaxErr.Error()}
+	case errors.Is(err, io.EOF), errors.Is(err, io.ErrUnexpectedEOF):
+		return DecodeIssue{Offset: w.decoder.InputOffset(), Problem: "unexpected end of JSON"}
+	}
+	return err
+}
+
+// foldedField returns the field a key matches ignoring case, the way encoding/json matches it
+func foldedField(fields map[string]reflect.Type, key string) (string, bool) {
+	for name := range fields {
+		if strings.EqualFold(name, key) {
+			return name, true
+		}
+	}
+	return "", false
+}
+
+// expectedKind is the kind of JSON value a type decodes from
+func expectedKind(t reflect.Type) string {
+	if reflect.PointerTo(t).Implements(textUnmarshaler) || (t.Kind() == reflect.Slice && t.Elem().Kind() == reflect.Uint8) {
+		return "a string" // time.Time and []byte
+	}
+	return jsonKind(t)
+}
+
+// fits reports whether a number can be stored in a numeric type without overflow or losing its fraction
+func fits(number json.Number, t reflect.Type) bool {
+	switch t.Kind() {
+	case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64:
+		n, err := strconv.ParseInt(number.String(), 10, 64)
+		return err == nil && !reflect.Zero(t).OverflowInt(n)
+	case reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64:
+		n, err := strconv.ParseUint(number.String(), 10, 64)
+		return err == nil && !reflect.Zero(t).OverflowUint(n)
+	}
+	_, err := strconv.ParseFloat(number.String(), t.Bits())
+	return err == nil
+}
+
+// tokenKind is the kind of JSON value a token starts
+func tokenKind(token json.Token) string {
+	switch token.(type) {
+	case string:
+		return "a string"
+	case bool:
+		return "true or false"
+	case float64, json.Number:
+		return "a number"
+	}
+	if token == json.Delim('[') {
+		return "an array"
+	}
+	return "an object"
+}
+
+// jsonKind names a Go type the way a client thinks of JSON values
+func jsonKind(t reflect.Type) string {
+	switch t.Kind() {
+	case reflect.String:
+		return "a string"
+	case reflect.Bool:
+		return "true or false"
+	case reflect.Slice, reflect.Array:
+		return "an array"
+	case reflect.Struct, reflect.Map:
+		return "an object"
+	case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64,
+		reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64, reflect.Float32, reflect.Float64:
+		return "a number"
+	}
+	return t.String()
+}
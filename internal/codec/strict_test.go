@@ -0,0 +1,151 @@
+package codec
+
+import (
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+)
+
+// strictTarget is a small receipt-like type to decode into
+type strictTarget struct {
+	Retailer string    `json:"retailer"`
+	Count    int       `json:"count"`
+	At       time.Time `json:"at"`
+	Items    []struct {
+		Price string `json:"price"`
+	} `json:"items"`
+	Extra map[string]interface{} `json:"extra"`
+}
+
+func TestDecodeJSON(t *testing.T) {
+	tests := []struct {
+		name         string
+		body         string
+		strict       bool
+		wantError    string   // exact error, empty if expect success
+		wantWarnings []string // exact warnings in lenient mode
+	}{
+		{"Valid", `{"retailer": "Target", "count": 2, "at": "2022-01-01T13:01:00Z", "items": [{"price": "1.25"}], "extra": {"a": [1, {"b": null}]}}`, true, "", nil},
+		{"Unknown nested field strict", `{"retailer": "Target", "items": [{"price": "1"}, {"prce": "2"}]}`, true, `items[1].prce at byte 50: unknown field`, nil},
+		{"Unknown nested field lenient", `{"retailer": "Target", "items": [{"price": "1"}, {"prce": "2"}]}`, false, "", []string{`items[1].prce at byte 50: unknown field, ignored`}},
+		{"Field differing in case strict", `{"Retailer": "Target"}`, true, `Retailer at byte 1: unknown field, did you mean "retailer"`, nil},
+		{"Field differing in case lenient", `{"Retailer": "Target"}`, false, "", []string{`Retailer at byte 1: field name differs in case, read as "retailer"`}},
+		{"Duplicate key strict", `{"retailer": "A",` + "\n" + ` "retailer": "B"}`, true, `retailer at byte 19: duplicate field`, nil},
+		{"Duplicate key lenient", `{"retailer": "A", "retailer": "B"}`, false, "", []string{`retailer at byte 18: duplicate field, the last value is used`}},
+		{"Map keys are not fields", `{"extra": {"anything": 1, "anything": 2}}`, false, "", []string{`extra.anything at byte 26: duplicate field, the last value is used`}},
+		{"Wrong type", `{"items": [{"price": 1.25}]}`, false, `items[0].price at byte 21: cannot use a number as a string`, nil},
+		{"Time must be a string", `{"at": 5}`, true, `at at byte 7: cannot use a number as a string`, nil},
+		{"Fraction for an integer", `{"count": 1.5}`, true, `count at byte 10: cannot use number 1.5 as int`, nil},
+		{"Null is allowed", `{"retailer": null, "items": null}`, true, "", nil},
+		{"Number out of range", `{"count": 1e400}`, true, `count at byte 10: cannot use number 1e400 as int`, nil},
+		{"Trailing garbage", `{"retailer": "A"} garbage`, false, `byte 18: unexpected data after the JSON value`, nil},
+		{"Second value", `{"retailer": "A"}{}`, false, `byte 17: unexpected data after the JSON value`, nil},
+		{"Trailing white space", "{\"retailer\": \"A\"}\n\t ", true, "", nil},
+		{"Syntax error", `{"retailer" "A"}`, false, `byte 12: invalid character '"' after object key`, nil},
+		{"Truncated", `{"retailer": "A"`, false, `byte 16: unexpected end of JSON input`, nil},
+		{"Empty", ``, false, `unexpected end of JSON`, nil},
+		{"Not an object", `["retailer"]`, false, `cannot use an array as an object`, nil},
+	}
+
+	for _, testCase := range tests {
+		t.Run(testCase.name, func(t *testing.T) {
+			var target strictTarget
+			warnings, err := DecodeJSON(strings.NewReader(testCase.body), &target, testCase.strict)
+
+			if testCase.wantError != "" {
+				var issue DecodeIssue
+				if !errors.As(err, &issue) || err.Error() != testCase.wantError {
+					t.Fatalf("Result error: %v, want: %s", err, testCase.wantError)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("Result error: %v", err)
+			}
+			if len(warnings) != len(testCase.wantWarnings) {
+				t.Fatalf("Result warnings: %v, want: %v", warnings, testCase.wantWarnings)
+			}
+			for i, warning := range warnings {
+				if warning.Error() != testCase.wantWarnings[i] {
+					t.Errorf("Result warning: %s, want: %s", warning.Error(), testCase.wantWarnings[i])
+				}
+			}
+		})
+	}
+}
+
+// TestXMLToJSONStrict tests XML bodies get the same unknown and duplicate field checks as JSON, with XML offsets
+func TestXMLToJSONStrict(t *testing.T) {
+	items := `<r><retailer>Target</retailer><items><item><price>1</price></item><item><prce>2</prce></item></items></r>`
+	tests := []struct {
+		name         string
+		body         string
+		strict       bool
+		wantError    string   // exact error, empty if expect success
+		wantWarnings []string // exact warnings in lenient mode
+	}{
+		{"Valid", `<r><retailer>Target</retailer><items><item><price>1</price></item></items></r>`, true, "", nil},
+		{"Unknown nested element strict", items, true, `items[1].prce at byte 72: unknown field`, nil},
+		{"Unknown nested element lenient", items, false, "", []string{`items[1].prce at byte 72: unknown field, ignored`}},
+		{"Element differing in case strict", `<r><Retailer>Target</Retailer></r>`, true, `Retailer at byte 3: unknown field`, nil},
+		{"Unknown attribute strict", `<r store="7"><retailer>A</retailer></r>`, true, `store: unknown field`, nil},
+		{"Duplicate element strict", "<r><retailer>A</retailer>\n<retailer>B</retailer></r>", true, `retailer at byte 26: duplicate field`, nil},
+		{"Duplicate element lenient", `<r><retailer>A</retailer><retailer>B</retailer></r>`, false, "", []string{`retailer at byte 25: duplicate field, the last value is used`}},
+	}
+
+	for _, testCase := range tests {
+		t.Run(testCase.name, func(t *testing.T) {
+			_, warnings, err := XMLToJSON([]byte(testCase.body), &strictTarget{}, testCase.strict)
+
+			if testCase.wantError != "" {
+				var issue DecodeIssue
+				if !errors.As(err, &issue) || err.Error() != testCase.wantError {
+					t.Fatalf("Result error: %v, want: %s", err, testCase.wantError)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("Result error: %v", err)
+			}
+			if len(warnings) != len(testCase.wantWarnings) {
+				t.Fatalf("Result warnings: %v, want: %v", warnings, testCase.wantWarnings)
+			}
+			for i, warning := range warnings {
+				if warning.Error() != testCase.wantWarnings[i] {
+					t.Errorf("Result warning: %s, want: %s", warning.Error(), testCase.wantWarnings[i])
+				}
+			}
+		})
+	}
+}
+
+func TestDecodeJSONLenientDecodesLikeEncodingJSON(t *testing.T) {
+	var target strictTarget
+	if _, err := DecodeJSON(strings.NewReader(`{"RETAILER": "A", "retailer": "B", "items": [{"price": "1", "price": "2"}], "unknown": {}}`), &target, false); err != nil {
+		t.Fatalf("Result error: %v", err)
+	}
+	if target.Retailer != "B" || len(target.Items) != 1 || target.Items[0].Price != "2" {
+		t.Errorf("Result: %+v, want the last values kept", target)
+	}
+}
+
+func TestDecodeJSONReadErrors(t *testing.T) {
+	// A body over its size limit is a read error, not bad JSON
+	recorder := httptest.NewRecorder()
+	body := http.MaxBytesReader(recorder, nopCloser{strings.NewReader(`{"retailer": "` + strings.Repeat("a", 100) + `"}`)}, 20)
+
+	var target strictTarget
+	_, err := DecodeJSON(body, &target, true)
+	var tooLarge *http.MaxBytesError
+	if !errors.As(err, &tooLarge) {
+		t.Errorf("Result error: %v, want *http.MaxBytesError", err)
+	}
+}
+
+// nopCloser makes a reader a request body
+type nopCloser struct{ *strings.Reader }
+
+func (nopCloser) Close() error { return nil }
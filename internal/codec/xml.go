@@ -0,0 +1,292 @@
+package codec
+
+import (
+	"bytes"
+	"encoding"
+	"encoding/json"
+	"encoding/xml"
+	"errors"
+	"fmt"
+	"io"
+	"reflect"
+	"regexp"
+	"strconv"
+	"strings"
+)
+
+// XML mirrors the JSON bodies: objects are elements named by their keys, array entries are <item> elements
+// in an element named by the array's key and null values are left out. Keys that are not XML names,
+// like map keys starting with a digit, are written as <entry key="...">
+//
+//	{"id": "7fb1", "items": [{"price": "6.49"}]}  <response><id>7fb1</id><items><item><price>6.49</price></item></items></response>
+
+// regexXMLName matches keys that can be used as element names as they are
+var regexXMLName = regexp.MustCompile(`^[A-Za-z_][A-Za-z0-9_.-]*$`)
+
+// JSONToXML converts a JSON document to XML with the given root element, keeping the order of object keys
+func JSONToXML(data []byte, root string) ([]byte, error) {
+	decoder := json.NewDecoder(bytes.NewReader(data))
+	decoder.UseNumber()
+
+	var buffer bytes.Buffer
+	buffer.WriteString(xml.Header)
+	if err := writeXMLValue(&buffer, decoder, root); err != nil {
+		return nil, err
+	}
+	return buffer.Bytes(), nil
+}
+
+// writeXMLValue writes the next JSON value from the decoder as an element
+func writeXMLValue(buffer *bytes.Buffer, decoder *json.Decoder, name string) error {
+	token, err := decoder.Token()
+	if err != nil {
+		return err
+	}
+	if token == nil {
+		return nil // null values are left out
+	}
+
+	open, end := "<"+name+">", "</"+name+">"
+	if !regexXMLName.MatchString(name) || strings.HasPrefix(strings.ToLower(name), "xml") {
+		var key bytes.Buffer
+		xml.EscapeText(&key, []byte(name))
+		open, end = `<entry key="`+key.String()+`">`, "</entry>"
+	}
+
+	buffer.WriteString(open)
+	switch value := token.(type) {
+	case json.Delim:
+		for decoder.More() {
+			child := "item"
+			if value == '{' {
+				key, err := decoder.Token()
+				if err != nil {
+					return err
+				}
+				child = key.(string)
+			}
+			if err := writeXMLValue(buffer, decoder, child); err != nil {
+				return err
+			}
+		}
+		if _, err := decoder.Token(); err != nil { // closing delimiter
+			return err
+		}
+	case string:
+		xml.EscapeText(buffer, []byte(value))
+	case json.Number:
+		buffer.WriteString(value.String())
+	case bool:
+		buffer.WriteString(strconv.FormatBool(value))
+	}
+	buffer.WriteString(end)
+	return nil
+}
+
+// xmlNode is an element of an XML document read generically before it is matched to a type
+type xmlNode struct {
+	name     string
+	offset   int64 // Byte offset of the element's start tag, attributes share their element's
+	text     strings.Builder
+	children []*xmlNode
+}
+
+// XMLToJSON converts an XML document to JSON for decoding into v, which must be a pointer
+// The document is matched against v's type by JSON field names: struct fields are child elements or attributes,
+// slices take every child element whatever its name and numbers and booleans are parsed from the element text
+// Unknown and repeated elements are checked like DecodeJSON checks fields: errors in strict mode, otherwise
+// returned as warnings with unknown elements ignored and the last repeat kept. Offsets are into the XML
+func XMLToJSON(data []byte, v interface{}, strict bool) ([]byte, []DecodeIssue, error) {
+	target := reflect.TypeOf(v)
+	if target == nil || target.Kind() != reflect.Pointer {
+		return nil, nil, errors.New("codec: XML target must be a pointer")
+	}
+
+	root, err := readXML(data)
+	if err != nil {
+		return nil, nil, err
+	}
+	reader := &xmlReader{strict: strict}
+	value, err := reader.value(root, target.Elem(), "")
+	if err != nil {
+		var walkErr walkError
+		if errors.As(err, &walkErr) {
+			return nil, nil, walkErr.issue
+		}
+		return nil, nil, err
+	}
+	converted, err := json.Marshal(value)
+	if err != nil {
+		return nil, nil, err
+	}
+	return converted, reader.issues, nil
+}
+
+// readXML reads a document into a tree of elements, attributes become child elements
+func readXML(data []byte) (*xmlNode, error) {
+	decoder := xml.NewDecoder(bytes.NewReader(data))
+	var root *xmlNode
+	stack := []*xmlNode{}
+
+	for {
+		offset := decoder.InputOffset()
+		token, err := decoder.Token()
+		if err == io.EOF {
+			break
+		}
+		if err != nil {
+			return nil, err
+		}
+
+		switch element := token.(type) {
+		case xml.StartElement:
+			if root != nil && len(stack) == 0 {
+				return nil, errors.New("XML has more than one root element")
+			}
+			node := &xmlNode{name: element.Name.Local, offset: offset}
+			for _, attribute := range element.Attr {
+				if attribute.Name.Space != "" || attribute.Name.Local == "xmlns" {
+					continue // namespace declarations and qualified attributes like xsi:type
+				}
+				child := &xmlNode{name: attribute.Name.Local, offset: offset}
+				child.text.WriteString(attribute.Value)
+				node.children = append(node.children, child)
+			}
+			if len(stack) > 0 {
+				parent := stack[len(stack)-1]
+				parent.children = append(parent.children, node)
+			} else {
+				root = node
+			}
+			stack = append(stack, node)
+		case xml.EndElement:
+			stack = stack[:len(stack)-1]
+		case xml.CharData:
+			if len(stack) > 0 {
+				stack[len(stack)-1].text.Write(element)
+			}
+		}
+	}
+	if root == nil {
+		return nil, errors.New("XML has no root element")
+	}
+	return root, nil
+}
+
+// textUnmarshaler is the interface of types decoded from a JSON string
+var textUnmarshaler = reflect.TypeOf((*encoding.TextUnmarshaler)(nil)).Elem()
+
+// xmlReader matches an element tree against the type it is decoded into
+type xmlReader struct {
+	strict bool
+	issues []DecodeIssue // Warnings found so far in lenient mode
+}
+
+// report returns the issue as an error in strict mode, otherwise it is recorded as a warning with the lenient problem
+func (x *xmlReader) report(issue DecodeIssue, lenient string) error {
+	if x.strict {
+		return walkError{issue}
+	}
+	issue.Problem = lenient
+	x.issues = append(x.issues, issue)
+	return nil
+}
+
+// value converts an element to the JSON value of a type, path is the element's field path like items[1].price
+func (x *xmlReader) value(node *xmlNode, target reflect.Type, path string) (interface{}, error) {
+	for target.Kind() == reflect.Pointer {
+		target = target.Elem()
+	}
+	text := node.text.String()
+
+	// Types with their own text form, like time.Time, are the element text
+	if reflect.PointerTo(target).Implements(textUnmarshaler) {
+		return text, nil
+	}
+
+	switch target.Kind() {
+	case reflect.Struct:
+		fields := jsonFields(target)
+		object := make(map[string]interface{}, len(node.children))
+		for _, child := range node.children {
+			issue := DecodeIssue{Field: child.name, Offset: child.offset}
+			if path != "" {
+				issue.Field = path + "." + child.name
+			}
+			field, known := fields[child.name]
+			if !known {
+				issue.Problem = "unknown field"
+				if err := x.report(issue, "unknown field, ignored"); err != nil {
+					return nil, err
+				}
+				continue
+			}
+			if _, seen := object[child.name]; seen {
+				issue.Problem = "duplicate field"
+				if err := x.report(issue, "duplicate field, the last value is used"); err != nil {
+					return nil, err
+				}
+			}
+			value, err := x.value(child, field, issue.Field)
+			if err != nil {
+				return nil, err
+			}
+			object[child.name] = value
+		}
+		return object, nil
+	case reflect.Slice, reflect.Array:
+		if target.Elem().Kind() == reflect.Uint8 {
+			return text, nil // []byte is base64 text in JSON
+		}
+		array := make([]interface{}, 0, len(node.children))
+		for i, child := range node.children {
+			value, err := x.value(child, target.Elem(), path+"["+strconv.Itoa(i)+"]")
+			if err != nil {
+				return nil, err
+			}
+			array = append(array, value)
+		}
+		return array, nil
+	case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64,
+		reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64, reflect.Float32, reflect.Float64:
+		number := strings.TrimSpace(text)
+		if _, err := strconv.ParseFloat(number, 64); err != nil {
+			return nil, fmt.Errorf("element %s is not a number", node.name)
+		}
+		return json.Number(number), nil
+	case reflect.Bool:
+		value, err := strconv.ParseBool(strings.TrimSpace(text))
+		if err != nil {
+			return nil, fmt.Errorf("element %s is not true or false", node.name)
+		}
+		return value, nil
+	}
+	return text, nil // strings keep their exact text, white space included
+}
+
+// jsonFields returns the JSON name and type of every field of a struct, including promoted fields
+func jsonFields(target reflect.Type) map[string]reflect.Type {
+	fields := make(map[string]reflect.Type)
+	for i := 0; i < target.NumField(); i++ {
+		field := target.Field(i)
+		name, _, _ := strings.Cut(field.Tag.Get("json"), ",")
+		if name == "-" {
+			continue
+		}
+		// Embedded structs promote their fields even when the struct type itself is unexported, as in encoding/json
+		if field.Anonymous && name == "" && field.Type.Kind() == reflect.Struct {
+			for promoted, fieldType := range jsonFields(field.Type) {
+				fields[promoted] = fieldType
+			}
+			continue
+		}
+		if !field.IsExported() {
+			continue
+		}
+		if name == "" {
+			name = field.Name
+		}
+		fields[name] = field.Type
+	}
+	return fields
+}
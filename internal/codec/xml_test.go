@@ -0,0 +1,125 @@
+package codec
+
+import (
+	"encoding/json"
+	"testing"
+	"time"
+)
+
+// xmlTarget covers the field types receipts and requests use
+type xmlTarget struct {
+	Name     string     `json:"name"`
+	Count    int        `json:"count,omitempty"`
+	Ratio    *float64   `json:"ratio,omitempty"`
+	Enabled  bool       `json:"enabled"`
+	Tags     []string   `json:"tags"`
+	Children []xmlChild `json:"children"`
+	Child    *xmlChild  `json:"child,omitempty"`
+	At       time.Time  `json:"at"`
+	Skipped  string     `json:"-"`
+	xmlEmbedded
+}
+
+type xmlChild struct {
+	Price string `json:"price"`
+}
+
+type xmlEmbedded struct {
+	Promoted string `json:"promoted"`
+}
+
+func TestJSONToXML(t *testing.T) {
+	tests := []struct {
+		name string
+		json string
+		want string
+	}{
+		{"Object keeps key order", `{"points": 28, "id": "a<b"}`, `<response><points>28</points><id>a&lt;b</id></response>`},
+		{"Arrays and nested objects", `{"items": [{"price": "6.49"}, {"price": "1.25"}], "award": {"tier": "Gold", "ok": true}}`,
+			`<response><items><item><price>6.49</price></item><item><price>1.25</price></item></items><award><tier>Gold</tier><ok>true</ok></award></response>`},
+		{"Nulls are left out", `{"a": null, "b": "x"}`, `<response><b>x</b></response>`},
+		{"Keys that are not names", `{"0-10": 3, "category:produce": 1, "xmlns": "x"}`,
+			`<response><entry key="0-10">3</entry><entry key="category:produce">1</entry><entry key="xmlns">x</entry></response>`},
+		{"Top level array", `[1, 2]`, `<response><item>1</item><item>2</item></response>`},
+	}
+
+	for _, testCase := range tests {
+		t.Run(testCase.name, func(t *testing.T) {
+			got, err := JSONToXML([]byte(testCase.json), "response")
+			if err != nil {
+				t.Fatalf("Result error: %v", err)
+			}
+			if want := `<?xml version="1.0" encoding="UTF-8"?>` + "\n" + testCase.want; string(got) != want {
+				t.Errorf("Result:\n%s\nwant:\n%s", got, want)
+			}
+		})
+	}
+
+	if _, err := JSONToXML([]byte(`{"a": `), "response"); err == nil {
+		t.Errorf("Result no error for invalid JSON, want error")
+	}
+}
+
+func TestXMLToJSON(t *testing.T) {
+	tests := []struct {
+		name      string
+		xml       string
+		want      string
+		wantError bool
+	}{
+		{"Fields by JSON name", `<receipt><name>  Café  </name><count> 3 </count><ratio>0.5</ratio><enabled>true</enabled><at>2022-01-01T13:01:00Z</at></receipt>`,
+			`{"at":"2022-01-01T13:01:00Z","count":3,"enabled":true,"name":"  Café  ","ratio":0.5}`, false},
+		{"Slices take every child", `<r><tags><tag>a</tag><t>b</t></tags><children><item><price>1.25</price></item><item price="2.00"/></children></r>`,
+			`{"children":[{"price":"1.25"},{"price":"2.00"}],"tags":["a","b"]}`, false},
+		{"Attributes, promoted fields and unknown elements", `<r xmlns="urn:x" name="n"><promoted>p</promoted><Skipped>s</Skipped><unknown><x/></unknown><child><price>1</price></child></r>`,
+			`{"child":{"price":"1"},"name":"n","promoted":"p"}`, false},
+		{"Bad number", `<r><count>three</count></r>`, "", true},
+		{"Bad boolean", `<r><enabled>yes please</enabled></r>`, "", true},
+		{"Not XML", `{"name": "x"}`, "", true},
+		{"Unclosed element", `<r><name>x</r>`, "", true},
+		{"Two roots", `<r/><r/>`, "", true},
+	}
+
+	for _, testCase := range tests {
+		t.Run(testCase.name, func(t *testing.T) {
+			var target xmlTarget
+			got, _, err := XMLToJSON([]byte(testCase.xml), &target, false)
+			if (err != nil) != testCase.wantError {
+				t.Fatalf("Result error: %v, want error %v", err, testCase.wantError)
+			}
+			if testCase.wantError {
+				return
+			}
+			if string(got) != testCase.want {
+				t.Errorf("Result:\n%s\nwant:\n%s", got, testCase.want)
+			}
+			if err := json.Unmarshal(got, &target); err != nil {
+				t.Errorf("Result does not decode into the target: %v", err)
+			}
+		})
+	}
+}
+
+func TestXMLRoundTrip(t *testing.T) {
+	ratio := 0.25
+	original := xmlTarget{Name: "a & b", Count: 2, Ratio: &ratio, Tags: []string{"x"}, Children: []xmlChild{{Price: "6.49"}}}
+	original.Promoted = "p"
+
+	data, _ := json.Marshal(original)
+	converted, err := JSONToXML(data, "response")
+	if err != nil {
+		t.Fatalf("Result error: %v", err)
+	}
+	back, _, err := XMLToJSON(converted, &xmlTarget{}, true)
+	if err != nil {
+		t.Fatalf("Result error: %v", err)
+	}
+
+	var decoded xmlTarget
+	if err := json.Unmarshal(back, &decoded); err != nil {
+		t.Fatalf("Result error: %v", err)
+	}
+	if decoded.Name != original.Name || decoded.Count != 2 || *decoded.Ratio != ratio || decoded.Children[0].Price != "6.49" || decoded.Promoted != "p" {
+		t.Errorf("Result: %+v, want %+v", decoded, original)
+	}
+}
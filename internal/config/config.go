@@ -0,0 +1,315 @@
+// Package config loads the server settings, layering defaults, a JSON config file, environment variables and flags
+// Later layers win, so a flag overrides the same setting from the environment, which overrides the file
+package config
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"flag"
+	"fmt"
+	"io"
+	"log/slog"
+	"net"
+	"os"
+	"strconv"
+	"time"
+
+	"receipt-processor-challenge-jase180/internal/codec"
+)
+
+// Config is every server setting, field names are the keys of the config file
+type Config struct {
+	Server   ServerConfig   `json:"server"`
+	Limits   LimitsConfig   `json:"limits"`
+	Store    StoreConfig    `json:"store"`
+	Files    FilesConfig    `json:"files"`
+	Email    EmailConfig    `json:"email"`
+	Receipts ReceiptsConfig `json:"receipts"`
+	Admin    AdminConfig    `json:"admin"`
+	Log      LogConfig      `json:"log"`
+}
+
+// ServerConfig is where the HTTP server listens and how long it waits on clients, 0 timeouts wait forever
+type ServerConfig struct {
+	Addr              string   `json:"addr"`              // host:port to listen on, ":8080" for every interface
+	ReadHeaderTimeout Duration `json:"readHeaderTimeout"` // Time to read request headers
+	ReadTimeout       Duration `json:"readTimeout"`       // Time to read a whole request including the body
+	WriteTimeout      Duration `json:"writeTimeout"`      // Time to write a response, long enough for a CSV export
+	IdleTimeout       Duration `json:"idleTimeout"`       // Time a keep-alive connection waits for the next request
+	DrainDelay        Duration `json:"drainDelay"`        // Time /readyz fails before the listener closes on SIGINT or SIGTERM
+	ShutdownTimeout   Duration `json:"shutdownTimeout"`   // Time requests in flight get to finish after the drain delay
+}
+
+// LimitsConfig is the largest request bodies accepted
+type LimitsConfig struct {
+	MaxBodyBytes   int64 `json:"maxBodyBytes"`   // Receipt JSON, XML, MessagePack, text and email bodies
+	MaxImportBytes int64 `json:"maxImportBytes"` // CSV imports
+}
+
+// StoreConfig picks where receipts, users and the ledger are kept
+type StoreConfig struct {
+	Backend string `json:"backend"` // One of Backends
+}
+
+// FilesConfig are the JSON files loaded at startup, empty for the built-in defaults
+type FilesConfig struct {
+	Rules           string `json:"rules"`           // Rules configuration (tiers, limits, custom rules, ...)
+	ParserTemplates string `json:"parserTemplates"` // Plain-text receipt layouts
+	EmailExtractors string `json:"emailExtractors"` // Retailer extractors for emailed receipts, replacing the defaults
+}
+
+// EmailConfig is the local SMTP listener for emailed receipts
+type EmailConfig struct {
+	SMTPAddr string `json:"smtpAddr"` // host:port of the listener, off if empty
+}
+
+// ReceiptsConfig is how receipt bodies are read
+type ReceiptsConfig struct {
+	StrictDecoding bool `json:"strictDecoding"` // Reject unknown and duplicate fields instead of warning
+}
+
+// AdminConfig is who may call the admin endpoints
+type AdminConfig struct {
+	Token string `json:"token"` // Bearer token for /admin and campaign writes, admin endpoints are refused if empty
+}
+
+// minAdminTokenLength is the shortest admin token accepted so a guessable one is not configured by mistake
+const minAdminTokenLength = 16
+
+// LogConfig is how the server logs
+type LogConfig struct {
+	Level  string `json:"level"`  // debug, info, warn or error
+	Format string `json:"format"` // text or json
+}
+
+// Backends are the supported store backends
+var Backends = []string{"memory"}
+
+// Default returns the settings used when nothing else is configured, the values the server always had
+func Default() Config {
+	return Config{
+		Server: ServerConfig{
+			Addr:              ":8080",
+			ReadHeaderTimeout: Duration(5 * time.Second),
+			ReadTimeout:       Duration(30 * time.Second),
+			WriteTimeout:      Duration(2 * time.Minute),
+			IdleTimeout:       Duration(2 * time.Minute),
+			DrainDelay:        Duration(2 * time.Second), // a load balancer probe or two to see /readyz fail
+			ShutdownTimeout:   Duration(6 * time.Second), // with the drain delay within the 10 seconds docker stop waits before killing
+		},
+		Limits: LimitsConfig{MaxBodyBytes: 1 << 20, MaxImportBytes: 10 << 20},
+		Store:  StoreConfig{Backend: "memory"},
+		Log:    LogConfig{Level: "info", Format: "text"},
+	}
+}
+
+// setting is one value of a Config with the config file key, environment variable and flag that set it
+type setting struct {
+	key   string
+	env   string
+	flag  string
+	usage string
+	value flag.Value
+}
+
+// settings lists every setting of c, bound to its fields
+// RULES_FILE, PARSER_TEMPLATES_FILE, EMAIL_EXTRACTORS_FILE and SMTP_ADDR keep the names they had before the config package
+func (c *Config) settings() []setting {
+	return []setting{
+		{"server.addr", "LISTEN_ADDR", "addr", "address to listen on", (*stringValue)(&c.Server.Addr)},
+		{"server.readHeaderTimeout", "READ_HEADER_TIMEOUT", "read-header-timeout", "time to read request headers", &c.Server.ReadHeaderTimeout},
+		{"server.readTimeout", "READ_TIMEOUT", "read-timeout", "time to read a whole request", &c.Server.ReadTimeout},
+		{"server.writeTimeout", "WRITE_TIMEOUT", "write-timeout", "time to write a response", &c.Server.WriteTimeout},
+		{"server.idleTimeout", "IDLE_TIMEOUT", "idle-timeout", "time an idle keep-alive connection is kept", &c.Server.IdleTimeout},
+		{"server.drainDelay", "DRAIN_DELAY", "drain-delay", "time readiness fails before the listener closes on shutdown", &c.Server.DrainDelay},
+		{"server.shutdownTimeout", "SHUTDOWN_TIMEOUT", "shutdown-timeout", "time requests in flight get to finish on shutdown", &c.Server.ShutdownTimeout},
+		{"limits.maxBodyBytes", "MAX_BODY_BYTES", "max-body-bytes", "largest receipt body in bytes", (*int64Value)(&c.Limits.MaxBodyBytes)},
+		{"limits.maxImportBytes", "MAX_IMPORT_BYTES", "max-import-bytes", "largest CSV import in bytes", (*int64Value)(&c.Limits.MaxImportBytes)},
+		{"store.backend", "STORE_BACKEND", "store", "store backend: memory", (*stringValue)(&c.Store.Backend)},
+		{"files.rules", "RULES_FILE", "rules-file", "rules configuration JSON file", (*stringValue)(&c.Files.Rules)},
+		{"files.parserTemplates", "PARSER_TEMPLATES_FILE", "parser-templates-file", "plain-text receipt templates JSON file", (*stringValue)(&c.Files.ParserTemplates)},
+		{"files.emailExtractors", "EMAIL_EXTRACTORS_FILE", "email-extractors-file", "email extractors JSON file", (*stringValue)(&c.Files.EmailExtractors)},
+		{"email.smtpAddr", "SMTP_ADDR", "smtp-addr", "address of the local SMTP listener, off if empty", (*stringValue)(&c.Email.SMTPAddr)},
+		{"receipts.strictDecoding", "STRICT_DECODING", "strict-decoding", "reject unknown and duplicate receipt fields", (*boolValue)(&c.Receipts.StrictDecoding)},
+		{"admin.token", "ADMIN_TOKEN", "admin-token", "bearer token for admin endpoints, refused if empty", (*stringValue)(&c.Admin.Token)},
+		{"log.level", "LOG_LEVEL", "log-level", "log level: debug, info, warn or error", (*stringValue)(&c.Log.Level)},
+		{"log.format", "LOG_FORMAT", "log-format", "log format: text or json", (*stringValue)(&c.Log.Format)},
+	}
+}
+
+// Load builds the effective config from the defaults, the config file, environment variables and the flags in args
+// The config file is named by -config or CONFIG_FILE. Flags are registered on fs, which reports its own parse errors.
+// Returns whether -print-config was given, the config is validated either way
+func Load(fs *flag.FlagSet, args []string, getenv func(string) string) (Config, bool, error) {
+	// Flags are parsed into a scratch config and applied last, after the file and environment
+	// The scratch config has the defaults so -help shows them
+	parsed := Default()
+	for _, s := range parsed.settings() {
+		fs.Var(s.value, s.flag, s.usage+" ("+s.env+")")
+	}
+	configFile := fs.String("config", "", "JSON config file (CONFIG_FILE)")
+	printConfig := fs.Bool("print-config", false, "print the effective config as JSON and exit")
+	if err := fs.Parse(args); err != nil {
+		return Config{}, false, err
+	}
+
+	config := Default()
+	settings := config.settings()
+
+	path := *configFile
+	if path == "" {
+		path = getenv("CONFIG_FILE")
+	}
+	if path != "" {
+		if err := config.loadFile(path); err != nil {
+			return Config{}, false, err
+		}
+	}
+
+	for _, s := range settings {
+		if value := getenv(s.env); value != "" {
+			if err := s.value.Set(value); err != nil {
+				return Config{}, false, fmt.Errorf("environment variable %s: %v", s.env, err)
+			}
+		}
+	}
+
+	var flagErr error
+	fs.Visit(func(f *flag.Flag) {
+		for _, s := range settings {
+			if s.flag == f.Name && flagErr == nil {
+				flagErr = s.value.Set(f.Value.String())
+			}
+		}
+	})
+	if flagErr != nil {
+		return Config{}, false, flagErr
+	}
+
+	if err := config.Validate(); err != nil {
+		return Config{}, false, err
+	}
+	return config, *printConfig, nil
+}
+
+// loadFile reads a JSON config file over c, unknown keys are errors so a typo does not silently keep a default
+func (c *Config) loadFile(path string) error {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return fmt.Errorf("config file: %v", err)
+	}
+	if _, err := codec.DecodeJSON(bytes.NewReader(data), c, true); err != nil {
+		return fmt.Errorf("config file %s: %v", path, err)
+	}
+	return nil
+}
+
+// Validate checks every setting and returns all problems at once, each naming the setting and where it can be set
+func (c Config) Validate() error {
+	var problems []error
+	problem := func(key string, format string, args ...interface{}) {
+		for _, s := range c.settings() {
+			if s.key == key {
+				problems = append(problems, fmt.Errorf("%s (%s, -%s): %s", key, s.env, s.flag, fmt.Sprintf(format, args...)))
+			}
+		}
+	}
+
+	if err := validAddr(c.Server.Addr); err != nil {
+		problem("server.addr", "%v", err)
+	}
+	for _, timeout := range []struct {
+		key   string
+		value Duration
+	}{
+		{"server.readHeaderTimeout", c.Server.ReadHeaderTimeout}, {"server.readTimeout", c.Server.ReadTimeout},
+		{"server.writeTimeout", c.Server.WriteTimeout}, {"server.idleTimeout", c.Server.IdleTimeout},
+		{"server.shutdownTimeout", c.Server.ShutdownTimeout},
+	} {
+		if timeout.value < 0 {
+			problem(timeout.key, "must not be negative, 0 waits forever")
+		}
+	}
+	if c.Server.DrainDelay < 0 {
+		problem("server.drainDelay", "must not be negative, 0 closes the listener at once")
+	}
+	if c.Limits.MaxBodyBytes <= 0 {
+		problem("limits.maxBodyBytes", "must be more than 0")
+	}
+	if c.Limits.MaxImportBytes <= 0 {
+		problem("limits.maxImportBytes", "must be more than 0")
+	}
+	if !contains(Backends, c.Store.Backend) {
+		problem("store.backend", "%q is not one of %v", c.Store.Backend, Backends)
+	}
+	for _, file := range []struct{ key, path string }{
+		{"files.rules", c.Files.Rules}, {"files.parserTemplates", c.Files.ParserTemplates}, {"files.emailExtractors", c.Files.EmailExtractors},
+	} {
+		if file.path != "" {
+			if _, err := os.Stat(file.path); err != nil {
+				problem(file.key, "%v", err)
+			}
+		}
+	}
+	if c.Email.SMTPAddr != "" {
+		if err := validAddr(c.Email.SMTPAddr); err != nil {
+			problem("email.smtpAddr", "%v", err)
+		}
+	}
+	if c.Admin.Token != "" && len(c.Admin.Token) < minAdminTokenLength {
+		problem("admin.token", "must be at least %d characters", minAdminTokenLength)
+	}
+	var level slog.Level
+	if err := level.UnmarshalText([]byte(c.Log.Level)); err != nil {
+		problem("log.level", "%q is not one of debug, info, warn or error", c.Log.Level)
+	}
+	if c.Log.Format != "text" && c.Log.Format != "json" {
+		problem("log.format", "%q is not text or json", c.Log.Format)
+	}
+	return errors.Join(problems...)
+}
+
+// validAddr checks an address is host:port with a port number
+func validAddr(addr string) error {
+	_, port, err := net.SplitHostPort(addr)
+	if err != nil {
+		return fmt.Errorf("%q is not host:port", addr)
+	}
+	if number, err := strconv.Atoi(port); err != nil || number < 0 || number > 65535 {
+		return fmt.Errorf("%q does not have a port number", addr)
+	}
+	return nil
+}
+
+// contains reports whether a list has a value
+func contains(values []string, value string) bool {
+	for _, v := range values {
+		if v == value {
+			return true
+		}
+	}
+	return false
+}
+
+// Print writes the config as indented JSON in the config file format, leaving out the admin token
+func (c Config) Print(w io.Writer) error {
+	c.Admin.Token = ""
+	data, err := json.MarshalIndent(c, "", "  ")
+	if err != nil {
+		return err
+	}
+	_, err = w.Write(append(data, '\n'))
+	return err
+}
+
+// Logger returns a logger writing to w in the configured level and format
+func (c LogConfig) Logger(w io.Writer) *slog.Logger {
+	var level slog.Level
+	level.UnmarshalText([]byte(c.Level)) // checked by Validate, info otherwise
+	options := &slog.HandlerOptions{Level: level}
+	if c.Format == "json" {
+		return slog.New(slog.NewJSONHandler(w, options))
+	}
+	return slog.New(slog.NewTextHandler(w, options))
+}
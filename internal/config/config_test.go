@@ -0,0 +1,164 @@
+package config
+
+import (
+	"bytes"
+	"encoding/json"
+	"flag"
+	"io"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+)
+
+// load runs Load with its own flag set and the given environment
+func load(args []string, env map[string]string) (Config, bool, error) {
+	fs := flag.NewFlagSet("test", flag.ContinueOnError)
+	fs.SetOutput(io.Discard)
+	return Load(fs, args, func(name string) string { return env[name] })
+}
+
+// writeFile writes a file in a test directory and returns its path
+func writeFile(t *testing.T, name string, content string) string {
+	t.Helper()
+	path := filepath.Join(t.TempDir(), name)
+	if err := os.WriteFile(path, []byte(content), 0o600); err != nil {
+		t.Fatalf("Error during test writing file: %v", err)
+	}
+	return path
+}
+
+func TestLoadDefaults(t *testing.T) {
+	config, printConfig, err := load(nil, nil)
+	if err != nil || printConfig {
+		t.Fatalf("Result error %v print %v, want defaults", err, printConfig)
+	}
+	// The defaults keep the server as it was before configuration existed
+	if config.Server.Addr != ":8080" || config.Limits.MaxBodyBytes != 1<<20 || config.Limits.MaxImportBytes != 10<<20 || config.Store.Backend != "memory" {
+		t.Errorf("Result: %+v, want the defaults", config)
+	}
+}
+
+func TestLoadLayers(t *testing.T) {
+	rules := writeFile(t, "rules.json", `{}`)
+	file := writeFile(t, "config.json", `{
+		"server": {"addr": ":9000", "readTimeout": "10s", "writeTimeout": "1m"},
+		"limits": {"maxBodyBytes": 2048},
+		"files": {"rules": "`+rules+`"},
+		"log": {"level": "debug", "format": "json"}
+	}`)
+
+	tests := []struct {
+		name  string
+		args  []string
+		env   map[string]string
+		check func(Config) bool
+	}{
+		{"File over defaults", []string{"-config", file}, nil, func(c Config) bool {
+			return c.Server.Addr == ":9000" && c.Server.ReadTimeout == Duration(10*time.Second) && c.Limits.MaxBodyBytes == 2048 &&
+				c.Limits.MaxImportBytes == 10<<20 && c.Files.Rules == rules && c.Log.Format == "json"
+		}},
+		{"File from CONFIG_FILE", nil, map[string]string{"CONFIG_FILE": file}, func(c Config) bool { return c.Server.Addr == ":9000" }},
+		{"Environment over file", nil, map[string]string{"CONFIG_FILE": file, "LISTEN_ADDR": ":9100", "READ_TIMEOUT": "3s", "STRICT_DECODING": "true"},
+			func(c Config) bool {
+				return c.Server.Addr == ":9100" && c.Server.ReadTimeout == Duration(3*time.Second) && c.Server.WriteTimeout == Duration(time.Minute) &&
+					c.Receipts.StrictDecoding
+			}},
+		{"Flags over environment", []string{"-config=" + file, "-addr", "localhost:9200", "-strict-decoding", "-max-import-bytes", "100"},
+			map[string]string{"LISTEN_ADDR": ":9100", "STRICT_DECODING": "false"}, func(c Config) bool {
+				return c.Server.Addr == "localhost:9200" && c.Receipts.StrictDecoding && c.Limits.MaxImportBytes == 100 && c.Limits.MaxBodyBytes == 2048
+			}},
+		{"Existing environment variables", nil, map[string]string{"RULES_FILE": rules, "SMTP_ADDR": "localhost:2525"}, func(c Config) bool {
+			return c.Files.Rules == rules && c.Email.SMTPAddr == "localhost:2525"
+		}},
+	}
+
+	for _, testCase := range tests {
+		t.Run(testCase.name, func(t *testing.T) {
+			config, _, err := load(testCase.args, testCase.env)
+			if err != nil {
+				t.Fatalf("Result error: %v", err)
+			}
+			if !testCase.check(config) {
+				t.Errorf("Result: %+v", config)
+			}
+		})
+	}
+}
+
+func TestLoadErrors(t *testing.T) {
+	tests := []struct {
+		name      string
+		args      []string
+		env       map[string]string
+		file      string
+		wantError []string // parts of the error
+	}{
+		{"Unknown file key", nil, nil, `{"server": {"adr": ":9000"}}`, []string{"config file", "server.adr at byte 12: unknown field"}},
+		{"File is not JSON", nil, nil, `server: ":9000"`, []string{"config file", "invalid character"}},
+		{"Missing file", []string{"-config", "/no/such/config.json"}, nil, "", []string{"config file", "no such file"}},
+		{"Bad environment value", nil, map[string]string{"MAX_BODY_BYTES": "1MB"}, "", []string{"MAX_BODY_BYTES", `"1MB" is not a whole number`}},
+		{"Bad flag value", []string{"-read-timeout", "soon"}, nil, "", []string{"read-timeout", `"soon" is not a duration`}},
+		{"Unknown flag", []string{"-port", "80"}, nil, "", []string{"-port"}},
+		{"Negative drain delay", nil, map[string]string{"DRAIN_DELAY": "-2s"}, "", []string{"server.drainDelay (DRAIN_DELAY, -drain-delay): must not be negative"}},
+		{"Short admin token", nil, map[string]string{"ADMIN_TOKEN": "secret"}, "", []string{"admin.token (ADMIN_TOKEN, -admin-token): must be at least 16 characters"}},
+		{"Every invalid setting is reported", []string{"-addr", "8080", "-max-body-bytes", "0", "-store", "redis", "-idle-timeout", "-1s",
+			"-rules-file", "/no/such/rules.json", "-log-level", "loud", "-log-format", "xml", "-smtp-addr", "localhost"}, nil, "",
+			[]string{`server.addr (LISTEN_ADDR, -addr): "8080" is not host:port`, "limits.maxBodyBytes (MAX_BODY_BYTES, -max-body-bytes): must be more than 0",
+				`store.backend (STORE_BACKEND, -store): "redis" is not one of [memory]`, "server.idleTimeout (IDLE_TIMEOUT, -idle-timeout): must not be negative",
+				"files.rules (RULES_FILE, -rules-file): stat /no/such/rules.json", `log.level (LOG_LEVEL, -log-level): "loud"`,
+				`log.format (LOG_FORMAT, -log-format): "xml" is not text or json`, `email.smtpAddr (SMTP_ADDR, -smtp-addr): "localhost" is not host:port`}},
+	}
+
+	for _, testCase := range tests {
+		t.Run(testCase.name, func(t *testing.T) {
+			args := testCase.args
+			if testCase.file != "" {
+				args = append([]string{"-config", writeFile(t, "config.json", testCase.file)}, args...)
+			}
+			_, _, err := load(args, testCase.env)
+			if err == nil {
+				t.Fatalf("Result no error, want %v", testCase.wantError)
+			}
+			for _, part := range testCase.wantError {
+				if !strings.Contains(err.Error(), part) {
+					t.Errorf("Result error: %v\nwant it to contain: %s", err, part)
+				}
+			}
+		})
+	}
+}
+
+func TestPrintConfig(t *testing.T) {
+	config, printConfig, err := load([]string{"-print-config", "-write-timeout", "90s"}, map[string]string{"LOG_LEVEL": "warn"})
+	if err != nil || !printConfig {
+		t.Fatalf("Result error %v print %v, want print", err, printConfig)
+	}
+
+	var printed bytes.Buffer
+	if err := config.Print(&printed); err != nil {
+		t.Fatalf("Result error: %v", err)
+	}
+	var fields map[string]map[string]interface{}
+	if err := json.Unmarshal(printed.Bytes(), &fields); err != nil {
+		t.Fatalf("Result is not JSON: %v\n%s", err, printed.String())
+	}
+	if fields["server"]["writeTimeout"] != "1m30s" || fields["log"]["level"] != "warn" {
+		t.Errorf("Result:\n%s", printed.String())
+	}
+
+	// The printed config loads back as a config file unchanged
+	reloaded, _, err := load([]string{"-config", writeFile(t, "printed.json", printed.String())}, nil)
+	if err != nil || reloaded != config {
+		t.Errorf("Result reloaded %+v error %v, want %+v", reloaded, err, config)
+	}
+
+	// The admin token is never printed
+	config.Admin.Token = "0123456789abcdef-secret"
+	printed.Reset()
+	config.Print(&printed)
+	if strings.Contains(printed.String(), "secret") {
+		t.Errorf("Result printed the admin token:\n%s", printed.String())
+	}
+}
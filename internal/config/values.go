@@ -0,0 +1,93 @@
+package config
+
+import (
+	"fmt"
+	"strconv"
+	"time"
+)
+
+// Duration is a time.Duration written like "30s" or "2m" in config files, environment variables and flags
+type Duration time.Duration
+
+// String formats the duration like time.Duration
+func (d Duration) String() string {
+	return time.Duration(d).String()
+}
+
+// Set parses a duration for flags and environment variables
+func (d *Duration) Set(value string) error {
+	parsed, err := time.ParseDuration(value)
+	if err != nil {
+		return fmt.Errorf("%q is not a duration like 30s or 2m", value)
+	}
+	*d = Duration(parsed)
+	return nil
+}
+
+// MarshalText writes the duration as text in JSON
+func (d Duration) MarshalText() ([]byte, error) {
+	return []byte(d.String()), nil
+}
+
+// UnmarshalText reads the duration from text in JSON
+func (d *Duration) UnmarshalText(text []byte) error {
+	return d.Set(string(text))
+}
+
+// stringValue is a string setting as a flag.Value
+type stringValue string
+
+func (s *stringValue) String() string {
+	if s == nil {
+		return ""
+	}
+	return string(*s)
+}
+
+func (s *stringValue) Set(value string) error {
+	*s = stringValue(value)
+	return nil
+}
+
+// int64Value is a whole number setting as a flag.Value
+type int64Value int64
+
+func (i *int64Value) String() string {
+	if i == nil {
+		return "0"
+	}
+	return strconv.FormatInt(int64(*i), 10)
+}
+
+func (i *int64Value) Set(value string) error {
+	parsed, err := strconv.ParseInt(value, 10, 64)
+	if err != nil {
+		return fmt.Errorf("%q is not a whole number", value)
+	}
+	*i = int64Value(parsed)
+	return nil
+}
+
+// boolValue is a true or false setting as a flag.Value, given alone as a flag it is true
+type boolValue bool
+
+func (b *boolValue) String() string {
+	if b == nil {
+		return "false"
+	}
+	return strconv.FormatBool(bool(*b))
+}
+
+func (b *boolValue) Set(value string) error {
+	parsed, err := strconv.ParseBool(value)
+	if err != nil {
+		return fmt.Errorf("%q is not true or false", value)
+	}
+	*b = boolValue(parsed)
+	return nil
+}
+
+// IsBoolFlag lets the flag be given without a value
+func (b *boolValue) IsBoolFlag() bool {
+	return true
+}
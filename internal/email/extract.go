@@ -0,0 +1,150 @@
+package email
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"os"
+	"regexp"
+	"strings"
+
+	"receipt-processor-challenge-jase180/internal/parser"
+)
+
+// Confidence of fields taken from the extractor or the message headers rather than the receipt text
+const (
+	confidenceExtractor = 0.95 // Retailer of the extractor the sender matched
+	confidenceHeader    = 0.4  // Date of the email, usually but not always the purchase date
+)
+
+// Extractor reads receipts from one retailer's emails
+// Messages are matched to an extractor by the original sender of forwarded receipts or else the author
+type Extractor struct {
+	Name     string           `json:"name"`
+	Senders  []string         `json:"senders"`            // Sender addresses or domains, "target.com" also matches "e.target.com"
+	Retailer string           `json:"retailer,omitempty"` // Retailer name of receipts from these senders
+	Template *parser.Template `json:"template,omitempty"` // Layout of the retailer's e-receipts, heuristics if nil
+}
+
+// Extraction is a receipt read from an email, the extractor used if any and the sender it is recorded with
+type Extraction struct {
+	parser.Result
+	Extractor string `json:"extractor,omitempty"` // Name of the extractor the sender matched
+	Sender    string `json:"sender"`              // Address of the message author
+}
+
+// DefaultExtractors fix the retailer for a few well known e-receipt senders, deployments add their own layouts
+func DefaultExtractors() []Extractor {
+	return []Extractor{
+		{Name: "target", Senders: []string{"target.com"}, Retailer: "Target"},
+		{Name: "walgreens", Senders: []string{"walgreens.com"}, Retailer: "Walgreens"},
+		{Name: "walmart", Senders: []string{"walmart.com"}, Retailer: "Walmart"},
+	}
+}
+
+// ValidateExtractors checks every extractor has a unique name, senders and a valid template
+func ValidateExtractors(extractors []Extractor) error {
+	names := make(map[string]bool)
+	for i, extractor := range extractors {
+		if strings.TrimSpace(extractor.Name) == "" {
+			return fmt.Errorf("extractor %d has no name", i)
+		}
+		if names[extractor.Name] {
+			return fmt.Errorf("extractor %q is defined twice", extractor.Name)
+		}
+		names[extractor.Name] = true
+
+		if len(extractor.Senders) == 0 {
+			return fmt.Errorf("extractor %q has no senders", extractor.Name)
+		}
+		for _, sender := range extractor.Senders {
+			if strings.TrimSpace(sender) == "" {
+				return fmt.Errorf("extractor %q has an empty sender", extractor.Name)
+			}
+		}
+		if extractor.Template != nil {
+			if err := extractor.Template.Validate(); err != nil {
+				return fmt.Errorf("extractor %q: %w", extractor.Name, err)
+			}
+		}
+	}
+	return nil
+}
+
+// LoadExtractors reads a JSON array of extractors and validates them
+func LoadExtractors(path string) ([]Extractor, error) {
+	file, err := os.ReadFile(path)
+	if err != nil {
+		return nil, fmt.Errorf("cannot read extractors file: %w", err)
+	}
+
+	var extractors []Extractor
+	if err := json.Unmarshal(file, &extractors); err != nil {
+		return nil, fmt.Errorf("cannot parse extractors file %s: %w", path, err)
+	}
+	if err := ValidateExtractors(extractors); err != nil {
+		return nil, fmt.Errorf("extractors file %s: %w", path, err)
+	}
+	return extractors, nil
+}
+
+// matches reports if an address is one of the extractor's senders or at one of its domains
+func (e Extractor) matches(address string) bool {
+	address = strings.ToLower(address)
+	domain := address[strings.LastIndex(address, "@")+1:]
+	for _, sender := range e.Senders {
+		sender = strings.ToLower(strings.TrimSpace(sender))
+		if address == sender || domain == sender || strings.HasSuffix(domain, "."+sender) {
+			return true
+		}
+	}
+	return false
+}
+
+// Forwarding marker and header lines mail clients put above forwarded messages, removed before parsing
+var regexForwardedHeader = regexp.MustCompile(`(?im)^\s*(?:-+\s*(?:forwarded|original) message\s*-+|begin forwarded message:|(?:from|to|cc|subject|sent):.*)\s*$`)
+
+// Extract reads a receipt from a message with the extractor matching its sender, or the templates and heuristics
+// The first extractor whose senders match wins, its template is tried before the shared templates
+func Extract(message Message, extractors []Extractor, templates []parser.Template) (Extraction, error) {
+	if message.From == nil {
+		return Extraction{}, errors.New("message has no sender")
+	}
+	extraction := Extraction{Sender: strings.ToLower(message.From.Address)}
+
+	sender := message.From
+	if message.OriginalFrom != nil {
+		sender = message.OriginalFrom
+	}
+	var extractor *Extractor
+	for i := range extractors {
+		if extractors[i].matches(sender.Address) {
+			extractor = &extractors[i]
+			break
+		}
+	}
+	if extractor != nil {
+		extraction.Extractor = extractor.Name
+		if extractor.Template != nil {
+			templates = append([]parser.Template{*extractor.Template}, templates...)
+		}
+	}
+
+	text := regexForwardedHeader.ReplaceAllString(message.Body(), "")
+	extraction.Result = parser.Parse(text, templates)
+
+	if extractor != nil && extractor.Retailer != "" {
+		extraction.Receipt.Retailer, extraction.Confidence.Retailer = extractor.Retailer, confidenceExtractor
+	}
+
+	// E-receipts often leave the purchase date and time to the email itself
+	if !message.Date.IsZero() {
+		if extraction.Receipt.PurchaseDate == "" {
+			extraction.Receipt.PurchaseDate, extraction.Confidence.PurchaseDate = message.Date.Format("2006-01-02"), confidenceHeader
+		}
+		if extraction.Receipt.PurchaseTime == "" {
+			extraction.Receipt.PurchaseTime, extraction.Confidence.PurchaseTime = message.Date.Format("15:04"), confidenceHeader
+		}
+	}
+	return extraction, nil
+}
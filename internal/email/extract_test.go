@@ -0,0 +1,114 @@
+package email
+
+import (
+	"net/mail"
+	"testing"
+	"time"
+
+	"receipt-processor-challenge-jase180/internal/parser"
+)
+
+func TestExtract(t *testing.T) {
+	receiptText := "Thanks for shopping!\n01/01/2022 1:01 PM\nMountain Dew 12PK 6.49\nEmils Cheese Pizza 12.25\nTOTAL 18.74"
+	sent := time.Date(2022, 1, 2, 9, 30, 0, 0, time.UTC)
+
+	tests := []struct {
+		name          string
+		message       Message
+		wantExtractor string
+		wantRetailer  string
+		wantDate      string
+		wantTime      string
+		wantSender    string
+		wantError     bool
+	}{
+		{"Direct from retailer subdomain",
+			Message{From: &mail.Address{Address: "Receipts@E.Target.com"}, Text: receiptText},
+			"target", "Target", "2022-01-01", "13:01", "receipts@e.target.com", false},
+		{"Forwarded keeps customer as sender and matches original sender",
+			Message{From: &mail.Address{Address: "jane@example.com"}, OriginalFrom: &mail.Address{Address: "no-reply@walmart.com"}, Text: receiptText},
+			"walmart", "Walmart", "2022-01-01", "13:01", "jane@example.com", false},
+		{"Unknown sender uses heuristics",
+			Message{From: &mail.Address{Address: "jane@example.com"}, Text: "---------- Forwarded message ---------\nFrom: Corner <hi@corner.example>\nSubject: Your receipt\n\nCorner Market\n01/01/2022 1:01 PM\nTOTAL 1.00"},
+			"", "Corner Market", "2022-01-01", "13:01", "jane@example.com", false},
+		{"Email date when the receipt has none",
+			Message{From: &mail.Address{Address: "orders@target.com"}, Date: sent, HTML: "<p>Gatorade</p><table><tr><td>Gatorade</td><td>2.25</td></tr><tr><td>Total</td><td>2.25</td></tr></table>"},
+			"target", "Target", "2022-01-02", "09:30", "orders@target.com", false},
+		{"Domain lookalike does not match",
+			Message{From: &mail.Address{Address: "orders@nottarget.com"}, Text: receiptText},
+			"", "Thanks for shopping!", "2022-01-01", "13:01", "orders@nottarget.com", false},
+		{"No sender", Message{Text: receiptText}, "", "", "", "", "", true},
+	}
+
+	for _, testCase := range tests {
+		t.Run(testCase.name, func(t *testing.T) {
+			extraction, err := Extract(testCase.message, DefaultExtractors(), nil)
+			if (err != nil) != testCase.wantError {
+				t.Fatalf("Result error: %v, want error %v", err, testCase.wantError)
+			}
+			if testCase.wantError {
+				return
+			}
+
+			receipt := extraction.Receipt
+			if extraction.Extractor != testCase.wantExtractor || receipt.Retailer != testCase.wantRetailer || extraction.Sender != testCase.wantSender {
+				t.Errorf("Result extractor %q retailer %q sender %q, want %q %q %q", extraction.Extractor, receipt.Retailer, extraction.Sender,
+					testCase.wantExtractor, testCase.wantRetailer, testCase.wantSender)
+			}
+			if receipt.PurchaseDate != testCase.wantDate || receipt.PurchaseTime != testCase.wantTime {
+				t.Errorf("Result date %q time %q, want %q %q", receipt.PurchaseDate, receipt.PurchaseTime, testCase.wantDate, testCase.wantTime)
+			}
+			if testCase.wantExtractor != "" && extraction.Confidence.Retailer != confidenceExtractor {
+				t.Errorf("Result retailer confidence: %v, want %v", extraction.Confidence.Retailer, confidenceExtractor)
+			}
+		})
+	}
+}
+
+func TestExtractTemplate(t *testing.T) {
+	extractors := []Extractor{{
+		Name:    "corner",
+		Senders: []string{"receipts@corner.example"},
+		Template: &parser.Template{
+			Name:  "corner-email",
+			Match: "Corner Market",
+			Total: `Amount charged: \$(\d+\.\d{2})`,
+		},
+	}}
+	message := Message{From: &mail.Address{Address: "receipts@corner.example"}, Text: "Corner Market\n2022-01-01 13:01\nGatorade 2.25\nAmount charged: $2.25"}
+
+	extraction, err := Extract(message, extractors, nil)
+	if err != nil {
+		t.Fatalf("Result error: %v", err)
+	}
+	if extraction.Template != "corner-email" || extraction.Receipt.Total != "2.25" {
+		t.Errorf("Result template %q total %q, want corner-email and 2.25", extraction.Template, extraction.Receipt.Total)
+	}
+	// Extractor without a retailer leaves the parsed retailer
+	if extraction.Receipt.Retailer != "Corner Market" {
+		t.Errorf("Result retailer: %q, want Corner Market", extraction.Receipt.Retailer)
+	}
+}
+
+func TestValidateExtractors(t *testing.T) {
+	tests := []struct {
+		name       string
+		extractors []Extractor
+		wantError  bool
+	}{
+		{"Defaults", DefaultExtractors(), false},
+		{"No name", []Extractor{{Senders: []string{"a.com"}}}, true},
+		{"No senders", []Extractor{{Name: "a"}}, true},
+		{"Empty sender", []Extractor{{Name: "a", Senders: []string{" "}}}, true},
+		{"Duplicate names", []Extractor{{Name: "a", Senders: []string{"a.com"}}, {Name: "a", Senders: []string{"b.com"}}}, true},
+		{"Invalid template", []Extractor{{Name: "a", Senders: []string{"a.com"}, Template: &parser.Template{Name: "t", Match: "("}}}, true},
+	}
+
+	for _, testCase := range tests {
+		t.Run(testCase.name, func(t *testing.T) {
+			if err := ValidateExtractors(testCase.extractors); (err != nil) != testCase.wantError {
+				t.Errorf("Result error: %v, want error %v", err, testCase.wantError)
+			}
+		})
+	}
+}
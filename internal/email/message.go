@@ -0,0 +1,211 @@
+// Package email reads receipts out of RFC 5322/MIME email messages
+// Messages are decoded into their text and HTML bodies, matched to a retailer extractor by sender and parsed
+// with the plain-text receipt parser. An SMTP listener for local testing delivers messages the same way
+package email
+
+import (
+	"bytes"
+	"encoding/base64"
+	"errors"
+	"fmt"
+	"html"
+	"io"
+	"mime"
+	"mime/multipart"
+	"mime/quotedprintable"
+	"net/mail"
+	"regexp"
+	"strings"
+	"time"
+
+	"golang.org/x/text/encoding/htmlindex"
+)
+
+// maxParts limits how many MIME parts are read so a message cannot nest parts without end
+const maxParts = 100
+
+// Message is the parts of an email message receipts are read from
+type Message struct {
+	From         *mail.Address // Author of the message, for forwarded receipts the customer who forwarded it
+	OriginalFrom *mail.Address // Sender of a forwarded message, from an attached message or the forwarded header block
+	Subject      string
+	Date         time.Time // Date header, zero if missing or unparsable
+	Text         string    // First text/plain body, decoded to UTF-8
+	HTML         string    // First text/html body, decoded to UTF-8
+}
+
+// wordDecoder decodes RFC 2047 encoded headers like "=?UTF-8?Q?Caf=C3=A9?=" in any charset the bodies support
+var wordDecoder = mime.WordDecoder{CharsetReader: charsetReader}
+
+// charsetReader returns a reader converting a charset to UTF-8
+func charsetReader(charset string, input io.Reader) (io.Reader, error) {
+	switch strings.ToLower(charset) {
+	case "", "utf-8", "utf8", "us-ascii":
+		return input, nil
+	}
+	encoding, err := htmlindex.Get(charset)
+	if err != nil {
+		return nil, fmt.Errorf("unsupported charset %q", charset)
+	}
+	return encoding.NewDecoder().Reader(input), nil
+}
+
+// ParseMessage reads a raw RFC 5322 message and decodes its headers and text and HTML bodies
+// Attachments are skipped, an error is returned if the message has neither a text nor an HTML body
+func ParseMessage(raw []byte) (Message, error) {
+	parsed, err := mail.ReadMessage(bytes.NewReader(raw))
+	if err != nil {
+		return Message{}, fmt.Errorf("cannot read message: %w", err)
+	}
+
+	message := Message{From: parseAddress(parsed.Header.Get("From"))}
+	if subject, err := wordDecoder.DecodeHeader(parsed.Header.Get("Subject")); err == nil {
+		message.Subject = subject
+	}
+	if date, err := parsed.Header.Date(); err == nil {
+		message.Date = date
+	}
+
+	parts := 0
+	if err := message.readPart(parsed.Header, parsed.Body, &parts); err != nil {
+		return Message{}, err
+	}
+	if message.Text == "" && message.HTML == "" {
+		return Message{}, errors.New("message has no text or HTML body")
+	}
+	if message.OriginalFrom == nil {
+		if match := regexForwardedFrom.FindStringSubmatch(message.Body()); match != nil {
+			message.OriginalFrom = parseAddress(match[1])
+		}
+	}
+	return message, nil
+}
+
+// Sender line of a forwarded header block, e.g. "From: Target <orders@target.com>" after "Forwarded message"
+var regexForwardedFrom = regexp.MustCompile(`(?ims)forwarded message.*?^\s*From:\s*(.+?)\s*$`)
+
+// parseAddress parses an address header with encoded words, nil if missing or invalid
+func parseAddress(header string) *mail.Address {
+	address, err := (&mail.AddressParser{WordDecoder: &wordDecoder}).Parse(header)
+	if err != nil {
+		return nil
+	}
+	return address
+}
+
+// readPart decodes one MIME part, walking into multipart parts and keeping the first text and HTML bodies
+// Headers are a mail.Header or a part's textproto.MIMEHeader, both canonical maps
+func (m *Message) readPart(header map[string][]string, body io.Reader, parts *int) error {
+	if *parts++; *parts > maxParts {
+		return errors.New("message has too many parts")
+	}
+
+	get := func(key string) string {
+		if values := header[key]; len(values) > 0 {
+			return values[0]
+		}
+		return ""
+	}
+	mediaType, params, err := mime.ParseMediaType(get("Content-Type"))
+	if err != nil {
+		mediaType, params = "text/plain", map[string]string{} // RFC 2045 default
+	}
+
+	// Receipts forwarded as an attachment, the attached message's bodies are the receipt
+	if mediaType == "message/rfc822" {
+		attached, err := mail.ReadMessage(body)
+		if err != nil {
+			return fmt.Errorf("cannot read attached message: %w", err)
+		}
+		if m.OriginalFrom == nil {
+			m.OriginalFrom = parseAddress(attached.Header.Get("From"))
+		}
+		return m.readPart(attached.Header, attached.Body, parts)
+	}
+	if disposition, _, _ := mime.ParseMediaType(get("Content-Disposition")); disposition == "attachment" {
+		return nil
+	}
+
+	if strings.HasPrefix(mediaType, "multipart/") {
+		reader := multipart.NewReader(body, params["boundary"])
+		for {
+			part, err := reader.NextRawPart() // raw so quoted-printable is decoded below like single part messages
+			if err == io.EOF {
+				return nil
+			}
+			if err != nil {
+				return fmt.Errorf("cannot read multipart body: %w", err)
+			}
+			if err := m.readPart(part.Header, part, parts); err != nil {
+				return err
+			}
+		}
+	}
+
+	if mediaType != "text/plain" && mediaType != "text/html" {
+		return nil
+	}
+	if (mediaType == "text/plain" && m.Text != "") || (mediaType == "text/html" && m.HTML != "") {
+		return nil
+	}
+
+	switch strings.ToLower(get("Content-Transfer-Encoding")) {
+	case "quoted-printable":
+		body = quotedprintable.NewReader(body)
+	case "base64":
+		body = base64.NewDecoder(base64.StdEncoding, body) // skips the line breaks base64 bodies wrap with
+	}
+	decoded, err := charsetReader(params["charset"], body)
+	if err != nil {
+		return err
+	}
+	content, err := io.ReadAll(decoded)
+	if err != nil {
+		return fmt.Errorf("cannot decode %s body: %w", mediaType, err)
+	}
+
+	if mediaType == "text/plain" {
+		m.Text = string(content)
+	} else {
+		m.HTML = string(content)
+	}
+	return nil
+}
+
+var (
+	// Elements whose content is never shown
+	regexHiddenHTML = regexp.MustCompile(`(?is)<(script|style|head|title)\b.*?</(?:script|style|head|title)\s*>`)
+	// Tags that end a line of text
+	regexBlockHTML = regexp.MustCompile(`(?i)<br\s*/?>|</?(?:p|div|tr|li|h[1-6]|table|tbody|thead)\b[^>]*>`)
+	// Table cells, kept apart on the same line
+	regexCellHTML = regexp.MustCompile(`(?i)</t[dh]\s*>`)
+	regexTagHTML  = regexp.MustCompile(`(?s)<[^>]*>`)
+	regexSpaces   = regexp.MustCompile(`[ \t\x{00a0}]+`)
+)
+
+// HTMLToText converts an HTML e-receipt to text lines like a printed receipt
+// Block elements and table rows become lines and table cells are separated by spaces, so a row
+// with a description cell and a price cell reads as an item line
+func HTMLToText(body string) string {
+	text := regexHiddenHTML.ReplaceAllString(body, "")
+	text = regexBlockHTML.ReplaceAllString(text, "\n")
+	text = regexCellHTML.ReplaceAllString(text, "  ")
+	text = html.UnescapeString(regexTagHTML.ReplaceAllString(text, ""))
+
+	lines := []string{}
+	for _, line := range strings.Split(text, "\n") {
+		if line = strings.TrimSpace(regexSpaces.ReplaceAllString(line, " ")); line != "" {
+			lines = append(lines, line)
+		}
+	}
+	return strings.Join(lines, "\n")
+}
+
+// Body returns the receipt text of the message, the text body or the HTML body converted to text
+// HTML is preferred when both exist since e-receipts usually put the full receipt in the HTML
+func (m Message) Body() string {
+	if m.HTML != "" {
+		return HTMLToText(m.HTML)
+	}
+	return m.Text
+}
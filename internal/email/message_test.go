@@ -0,0 +1,86 @@
+package email
+
+import (
+	"strings"
+	"testing"
+)
+
+// crlf converts a test message to the CRLF line endings of real messages
+func crlf(message string) []byte {
+	return []byte(strings.ReplaceAll(message, "\n", "\r\n"))
+}
+
+func TestParseMessage(t *testing.T) {
+	tests := []struct {
+		name         string
+		raw          string
+		wantFrom     string
+		wantOriginal string
+		wantSubject  string
+		wantText     string
+		wantHTML     string
+		wantError    bool
+	}{
+		{"Plain text", "From: Jane <jane@example.com>\nSubject: Receipt\nDate: Sat, 01 Jan 2022 13:01:00 -0600\n\nTARGET\nTOTAL 1.00\n",
+			"jane@example.com", "", "Receipt", "TARGET\r\nTOTAL 1.00\r\n", "", false},
+		{"Multipart alternative with quoted-printable and base64",
+			"From: orders@target.com\nSubject: =?UTF-8?Q?Caf=C3=A9_receipt?=\nMIME-Version: 1.0\nContent-Type: multipart/alternative; boundary=\"b1\"\n\n" +
+				"--b1\nContent-Type: text/plain; charset=utf-8\nContent-Transfer-Encoding: quoted-printable\n\nCaf=C3=A9 =\nLatte 4.50\n" +
+				"--b1\nContent-Type: text/html; charset=utf-8\nContent-Transfer-Encoding: base64\n\nPHA+Q2Fmw6k8L3A+\n--b1--\n",
+			"orders@target.com", "", "Café receipt", "Café Latte 4.50", "<p>Café</p>", false},
+		{"Latin-1 charset", "From: a@example.com\nContent-Type: text/plain; charset=iso-8859-1\nContent-Transfer-Encoding: quoted-printable\n\nCaf=E9",
+			"a@example.com", "", "", "Café", "", false},
+		{"Attachments are skipped",
+			"From: a@example.com\nContent-Type: multipart/mixed; boundary=b\n\n--b\nContent-Type: text/plain\n\nbody\n" +
+				"--b\nContent-Type: text/plain\nContent-Disposition: attachment; filename=a.txt\n\nattached\n--b--\n",
+			"a@example.com", "", "", "body", "", false},
+		{"Forwarded as attachment",
+			"From: jane@example.com\nContent-Type: multipart/mixed; boundary=b\n\n--b\nContent-Type: message/rfc822\nContent-Disposition: attachment\n\n" +
+				"From: Walgreens <receipts@e.walgreens.com>\nContent-Type: text/plain\n\nWALGREENS\n--b--\n",
+			"jane@example.com", "receipts@e.walgreens.com", "", "WALGREENS", "", false},
+		{"Forwarded inline", "From: jane@example.com\n\n---------- Forwarded message ---------\nFrom: Target <orders@target.com>\nDate: Sat, Jan 1, 2022\n\nTARGET\n",
+			"jane@example.com", "orders@target.com", "", "---------- Forwarded message ---------\r\nFrom: Target <orders@target.com>\r\nDate: Sat, Jan 1, 2022\r\n\r\nTARGET\r\n", "", false},
+		{"No body", "From: a@example.com\nContent-Type: image/png\n\nxyz", "", "", "", "", "", true},
+		{"Not a message", "just text", "", "", "", "", "", true},
+	}
+
+	for _, testCase := range tests {
+		t.Run(testCase.name, func(t *testing.T) {
+			message, err := ParseMessage(crlf(testCase.raw))
+			if (err != nil) != testCase.wantError {
+				t.Fatalf("Result error: %v, want error %v", err, testCase.wantError)
+			}
+			if testCase.wantError {
+				return
+			}
+
+			if message.From == nil || message.From.Address != testCase.wantFrom {
+				t.Errorf("Result from: %v, want %q", message.From, testCase.wantFrom)
+			}
+			original := ""
+			if message.OriginalFrom != nil {
+				original = message.OriginalFrom.Address
+			}
+			if original != testCase.wantOriginal {
+				t.Errorf("Result original from: %q, want %q", original, testCase.wantOriginal)
+			}
+			if message.Subject != testCase.wantSubject || message.Text != testCase.wantText || message.HTML != testCase.wantHTML {
+				t.Errorf("Result subject %q text %q html %q, want %q %q %q", message.Subject, message.Text, message.HTML,
+					testCase.wantSubject, testCase.wantText, testCase.wantHTML)
+			}
+		})
+	}
+}
+
+func TestHTMLToText(t *testing.T) {
+	body := `<html><head><style>td { color: red }</style></head><body>
+		<h1>Target</h1><p>01/01/2022&nbsp;1:01 PM</p>
+		<table><tr><td>Mountain Dew 12PK</td><td>$6.49</td></tr>
+		<tr><td>Emils Cheese&amp;Pizza</td><td>$12.25</td></tr></table>
+		<div>Total<br>$18.74</div><script>alert(1)</script></body></html>`
+	want := "Target\n01/01/2022 1:01 PM\nMountain Dew 12PK $6.49\nEmils Cheese&Pizza $12.25\nTotal\n$18.74"
+
+	if got := HTMLToText(body); got != want {
+		t.Errorf("Result:\n%s\nwant:\n%s", got, want)
+	}
+}
@@ -0,0 +1,270 @@
+package email
+
+import (
+	"io"
+	"log"
+	"net"
+	"net/textproto"
+	"strings"
+	"sync"
+	"time"
+)
+
+// DeliverFunc handles one message received over SMTP, an error rejects the message with the error as the reason
+type DeliverFunc func(envelopeFrom string, raw []byte) error
+
+// SMTPServer is a minimal SMTP listener for testing email ingestion locally
+// It accepts every recipient, has no authentication or TLS and must not be exposed beyond localhost
+type SMTPServer struct {
+	Addr     string        // Address to listen on, e.g. "localhost:2525"
+	Deliver  DeliverFunc   // Called with every message received
+	MaxBytes int64         // Largest message accepted, 1 MB if 0
+	Timeout  time.Duration // Idle time before a connection is closed, 1 minute if 0
+
+	mutex    sync.Mutex
+	listener net.Listener
+	closed   bool
+	sessions map[net.Conn]bool // Open connections, true while waiting for a command
+	active   sync.WaitGroup    // Sessions in progress, Close waits for them
+}
+
+// ListenAndServe listens on the server's address and serves connections until Close
+func (s *SMTPServer) ListenAndServe() error {
+	listener, err := net.Listen("tcp", s.Addr)
+	if err != nil {
+		return err
+	}
+	return s.Serve(listener)
+}
+
+// Serve accepts connections on the listener until Close, each is served on its own goroutine
+func (s *SMTPServer) Serve(listener net.Listener) error {
+	s.mutex.Lock()
+	if s.closed {
+		s.mutex.Unlock()
+		listener.Close()
+		return net.ErrClosed
+	}
+	s.listener = listener
+	s.mutex.Unlock()
+
+	for {
+		conn, err := listener.Accept()
+		if err != nil {
+			s.mutex.Lock()
+			closed := s.closed
+			s.mutex.Unlock()
+			if closed {
+				return net.ErrClosed
+			}
+			return err
+		}
+
+		// Register under the lock so Close never starts waiting before a session is counted
+		s.mutex.Lock()
+		if s.closed {
+			s.mutex.Unlock()
+			conn.Close()
+			return net.ErrClosed
+		}
+		if s.sessions == nil {
+			s.sessions = make(map[net.Conn]bool)
+		}
+		s.sessions[conn] = false
+		s.active.Add(1)
+		s.mutex.Unlock()
+
+		go func() {
+			defer s.active.Done()
+			s.serveConn(conn)
+		}()
+	}
+}
+
+// Close stops the listener and waits for sessions to end
+// Idle sessions are closed at once, sessions receiving a message finish it before closing
+func (s *SMTPServer) Close() error {
+	s.mutex.Lock()
+	s.closed = true
+	var err error
+	if s.listener != nil {
+		err = s.listener.Close()
+	}
+	for conn, idle := range s.sessions {
+		if idle {
+			conn.SetReadDeadline(time.Now()) // wakes the session waiting for a command
+		}
+	}
+	s.mutex.Unlock()
+
+	s.active.Wait()
+	return err
+}
+
+// waitForCommand marks the session idle so Close can interrupt it, false if the server is closing
+func (s *SMTPServer) waitForCommand(conn net.Conn, idle bool) bool {
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+	if s.closed {
+		return false
+	}
+	s.sessions[conn] = idle
+	return true
+}
+
+// endSession forgets a connection once its session is over
+func (s *SMTPServer) endSession(conn net.Conn) {
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+	delete(s.sessions, conn)
+}
+
+// maxDrain is how many times MaxBytes of an oversize message is read and discarded before giving up
+const maxDrain = 10
+
+// maxBytes returns the configured message size limit or the default
+func (s *SMTPServer) maxBytes() int64 {
+	if s.MaxBytes <= 0 {
+		return 1 << 20 // 1 MB limit like the HTTP endpoints
+	}
+	return s.MaxBytes
+}
+
+// timeout returns the configured idle timeout or the default
+func (s *SMTPServer) timeout() time.Duration {
+	if s.Timeout <= 0 {
+		return time.Minute
+	}
+	return s.Timeout
+}
+
+// serveConn runs one SMTP session, supporting HELO/EHLO, MAIL, RCPT, DATA, RSET, NOOP and QUIT
+func (s *SMTPServer) serveConn(conn net.Conn) {
+	defer s.endSession(conn)
+	defer conn.Close()
+	text := textproto.NewConn(conn)
+	reply := func(code int, message string) bool {
+		conn.SetWriteDeadline(time.Now().Add(s.timeout()))
+		return text.PrintfLine("%d %s", code, message) == nil
+	}
+
+	if !reply(220, "receipt-processor SMTP ready") {
+		return
+	}
+
+	from, mail, recipients := "", false, 0
+	for {
+		// Deadline before marking idle so Close's interrupting deadline is not overwritten
+		conn.SetReadDeadline(time.Now().Add(s.timeout()))
+		if !s.waitForCommand(conn, true) {
+			reply(421, "Shutting down")
+			return
+		}
+		line, err := text.ReadLine()
+		if err != nil {
+			return
+		}
+		if !s.waitForCommand(conn, false) {
+			reply(421, "Shutting down")
+			return
+		}
+		verb, argument, _ := strings.Cut(line, " ")
+		argument = strings.TrimSpace(argument)
+
+		var ok bool
+		switch strings.ToUpper(verb) {
+		case "HELO", "EHLO":
+			from, mail, recipients = "", false, 0
+			ok = reply(250, "receipt-processor")
+		case "MAIL":
+			address, found := pathArgument(argument, "FROM:")
+			if !found {
+				ok = reply(501, "Syntax: MAIL FROM:<address>")
+				break
+			}
+			from, mail, recipients = address, true, 0
+			ok = reply(250, "OK")
+		case "RCPT":
+			if !mail {
+				ok = reply(503, "Need MAIL first")
+				break
+			}
+			if _, found := pathArgument(argument, "TO:"); !found {
+				ok = reply(501, "Syntax: RCPT TO:<address>")
+				break
+			}
+			recipients++
+			ok = reply(250, "OK")
+		case "DATA":
+			if recipients == 0 {
+				ok = reply(503, "Need MAIL and RCPT first")
+				break
+			}
+			if !reply(354, "End data with <CR><LF>.<CR><LF>") {
+				return
+			}
+			ok = s.receive(conn, text, from, reply)
+			from, mail, recipients = "", false, 0
+		case "RSET":
+			from, mail, recipients = "", false, 0
+			ok = reply(250, "OK")
+		case "NOOP":
+			ok = reply(250, "OK")
+		case "QUIT":
+			reply(221, "Bye")
+			return
+		default:
+			ok = reply(502, "Command not implemented")
+		}
+		if !ok {
+			return
+		}
+	}
+}
+
+// receive reads a message after DATA and delivers it, replying with the result
+// Messages over the size limit are read to the end and rejected so the session can continue,
+// up to maxDrain times the limit, past that the connection is dropped instead of reading on
+func (s *SMTPServer) receive(conn net.Conn, text *textproto.Conn, from string, reply func(int, string) bool) bool {
+	conn.SetReadDeadline(time.Now().Add(s.timeout()))
+	data := text.DotReader()
+	raw, err := io.ReadAll(io.LimitReader(data, s.maxBytes()+1))
+	if err != nil {
+		return false
+	}
+	if int64(len(raw)) > s.maxBytes() {
+		limit := s.maxBytes() * maxDrain
+		drained, err := io.Copy(io.Discard, io.LimitReader(data, limit+1))
+		if err != nil {
+			return false
+		}
+		if drained > limit {
+			reply(552, "Message too large, closing connection")
+			return false
+		}
+		return reply(552, "Message too large")
+	}
+
+	if err := s.Deliver(from, raw); err != nil {
+		log.Printf("smtp: rejected message from %q: %v", from, err)
+		return reply(554, "Rejected: "+singleLine(err.Error()))
+	}
+	return reply(250, "OK")
+}
+
+// pathArgument returns the address of a "FROM:<address>" or "TO:<address>" argument, parameters after it are ignored
+func pathArgument(argument string, prefix string) (string, bool) {
+	if len(argument) < len(prefix) || !strings.EqualFold(argument[:len(prefix)], prefix) {
+		return "", false
+	}
+	path := strings.TrimSpace(argument[len(prefix):])
+	if !strings.HasPrefix(path, "<") || !strings.Contains(path, ">") {
+		return "", false
+	}
+	return path[1:strings.Index(path, ">")], true
+}
+
+// singleLine keeps an error on one reply line
+func singleLine(message string) string {
+	return strings.Join(strings.Fields(message), " ")
+}
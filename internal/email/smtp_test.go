@@ -0,0 +1,206 @@
+package email
+
+import (
+	"errors"
+	"net"
+	"net/smtp"
+	"net/textproto"
+	"os"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+)
+
+func TestSMTPServer(t *testing.T) {
+	var mutex sync.Mutex
+	delivered := []string{}
+	server := &SMTPServer{
+		MaxBytes: 1 << 10,
+		Deliver: func(envelopeFrom string, raw []byte) error {
+			if strings.Contains(string(raw), "reject me") {
+				return errors.New("BadRequest: The receipt is invalid.\nNo total")
+			}
+			mutex.Lock()
+			defer mutex.Unlock()
+			delivered = append(delivered, envelopeFrom+"|"+string(raw))
+			return nil
+		},
+	}
+	listener, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatalf("Cannot listen: %v", err)
+	}
+	go server.Serve(listener)
+	defer server.Close()
+	addr := listener.Addr().String()
+
+	tests := []struct {
+		name      string
+		body      string
+		wantError string // part of the error, empty for delivered
+	}{
+		{"Delivered", "From: a@example.com\r\n\r\nTARGET\r\n.leading dot\r\n", ""},
+		{"Rejected by delivery", "From: a@example.com\r\n\r\nreject me\r\n", "554"},
+		{"Too large", "From: a@example.com\r\n\r\n" + strings.Repeat("x", 2<<10) + "\r\n", "552"},
+	}
+
+	for _, testCase := range tests {
+		t.Run(testCase.name, func(t *testing.T) {
+			err := smtp.SendMail(addr, nil, "a@example.com", []string{"receipts@localhost"}, []byte(testCase.body))
+			if testCase.wantError == "" && err != nil {
+				t.Fatalf("Result error: %v, want delivered", err)
+			}
+			if testCase.wantError != "" && (err == nil || !strings.Contains(err.Error(), testCase.wantError)) {
+				t.Fatalf("Result error: %v, want %s", err, testCase.wantError)
+			}
+		})
+	}
+
+	// Only the first message was delivered, dot-stuffed lines are restored and lines end in \n
+	mutex.Lock()
+	defer mutex.Unlock()
+	if len(delivered) != 1 || delivered[0] != "a@example.com|From: a@example.com\n\nTARGET\n.leading dot\n" {
+		t.Errorf("Result delivered: %q, want the first message", delivered)
+	}
+}
+
+func TestPathArgument(t *testing.T) {
+	tests := []struct {
+		name string
+		args string
+		want string
+		ok   bool
+	}{
+		{"Mail", "FROM:<a@example.com>", "a@example.com", true},
+		{"Mail with parameters", "from:<a@example.com> SIZE=100", "a@example.com", true},
+		{"Null sender", "FROM:<>", "", true},
+		{"No brackets", "FROM:a@example.com", "", false},
+		{"Wrong prefix", "TO:<a@example.com>", "", false},
+	}
+
+	for _, testCase := range tests {
+		t.Run(testCase.name, func(t *testing.T) {
+			got, ok := pathArgument(testCase.args, "FROM:")
+			if got != testCase.want || ok != testCase.ok {
+				t.Errorf("Result %q %v, want %q %v", got, ok, testCase.want, testCase.ok)
+			}
+		})
+	}
+}
+
+// helper function that dials the server and runs a session up to DATA, returning the text connection
+func startData(t *testing.T, addr string) (net.Conn, *textproto.Conn) {
+	t.Helper()
+	conn, err := net.Dial("tcp", addr)
+	if err != nil {
+		t.Fatalf("Cannot dial: %v", err)
+	}
+	text := textproto.NewConn(conn)
+	if _, _, err := text.ReadResponse(220); err != nil {
+		t.Fatalf("Result greeting error: %v", err)
+	}
+	for _, command := range []struct {
+		line string
+		code int
+	}{{"HELO test", 250}, {"MAIL FROM:<a@example.com>", 250}, {"RCPT TO:<receipts@localhost>", 250}, {"DATA", 354}} {
+		text.PrintfLine("%s", command.line)
+		if _, _, err := text.ReadResponse(command.code); err != nil {
+			t.Fatalf("Result %s error: %v", command.line, err)
+		}
+	}
+	return conn, text
+}
+
+func TestSMTPServerDropsHugeMessages(t *testing.T) {
+	server := &SMTPServer{MaxBytes: 1 << 10, Deliver: func(string, []byte) error {
+		t.Errorf("Result huge message delivered")
+		return nil
+	}}
+	listener, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatalf("Cannot listen: %v", err)
+	}
+	go server.Serve(listener)
+	defer server.Close()
+
+	// Over 10 times the limit and never ends, the server gives up instead of reading forever
+	conn, text := startData(t, listener.Addr().String())
+	defer conn.Close()
+	go func() {
+		line := strings.Repeat("x", 1000) + "\r\n"
+		for i := 0; i < 100; i++ {
+			if _, err := conn.Write([]byte(line)); err != nil {
+				return
+			}
+		}
+	}()
+
+	conn.SetReadDeadline(time.Now().Add(5 * time.Second))
+	for {
+		if _, err := text.ReadLine(); err != nil {
+			if errors.Is(err, os.ErrDeadlineExceeded) {
+				t.Fatalf("Result connection still open, want it dropped")
+			}
+			return // dropped, possibly before the 552 reply could be read
+		}
+	}
+}
+
+func TestSMTPServerCloseWaitsForSessions(t *testing.T) {
+	release := make(chan struct{})
+	delivered := make(chan string, 1)
+	server := &SMTPServer{Deliver: func(envelopeFrom string, raw []byte) error {
+		<-release
+		delivered <- string(raw)
+		return nil
+	}}
+	listener, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatalf("Cannot listen: %v", err)
+	}
+	go server.Serve(listener)
+	addr := listener.Addr().String()
+
+	// An idle session does not hold up Close
+	idle, err := net.Dial("tcp", addr)
+	if err != nil {
+		t.Fatalf("Cannot dial: %v", err)
+	}
+	defer idle.Close()
+
+	// A message being delivered when Close is called
+	conn, text := startData(t, addr)
+	defer conn.Close()
+	text.PrintfLine("TARGET")
+	text.PrintfLine(".")
+	time.Sleep(50 * time.Millisecond) // message handed to Deliver
+
+	closed := make(chan struct{})
+	go func() {
+		server.Close()
+		close(closed)
+	}()
+	select {
+	case <-closed:
+		t.Fatalf("Result Close returned with a message in progress")
+	case <-time.After(100 * time.Millisecond):
+	}
+
+	close(release)
+	if _, _, err := text.ReadResponse(250); err != nil {
+		t.Errorf("Result message reply error: %v, want 250", err)
+	}
+	if raw := <-delivered; raw != "TARGET\n" {
+		t.Errorf("Result delivered %q, want TARGET", raw)
+	}
+	text.PrintfLine("NOOP")
+	if _, _, err := text.ReadResponse(421); err != nil {
+		t.Errorf("Result next command error: %v, want 421", err)
+	}
+	select {
+	case <-closed:
+	case <-time.After(5 * time.Second):
+		t.Fatalf("Result Close still waiting after the session ended")
+	}
+}
@@ -0,0 +1,43 @@
+package handlers
+
+import (
+	"crypto/subtle"
+	"net/http"
+	"strings"
+
+	"github.com/gorilla/mux"
+)
+
+// isAdmin reports whether the request carries the admin token as "Authorization: Bearer <token>"
+// Always false when no token is configured
+func (h *ReceiptHandler) isAdmin(r *http.Request) bool {
+	token, found := strings.CutPrefix(r.Header.Get("Authorization"), "Bearer ")
+	return h.AdminToken != "" && found && subtle.ConstantTimeCompare([]byte(token), []byte(h.AdminToken)) == 1
+}
+
+// RequireAdmin is middleware that only lets requests with the admin token through
+// With no token configured admin endpoints are refused, so they are never open by accident
+func (h *ReceiptHandler) RequireAdmin(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if h.AdminToken == "" {
+			sendJSON(w, map[string]string{"error": "Forbidden: Admin endpoints are disabled, no admin token is configured"}, http.StatusForbidden) // 403 response
+			return
+		}
+		if !h.isAdmin(r) {
+			w.Header().Set("WWW-Authenticate", "Bearer")
+			sendJSON(w, map[string]string{"error": "Unauthorized: Admin token required"}, http.StatusUnauthorized) // 401 response
+			return
+		}
+		next.ServeHTTP(w, r)
+	})
+}
+
+// helper function that checks the caller acts for the user in the path, the X-User-ID header must name them
+// Admins may act for any user. Sends 403 and returns false otherwise
+func (h *ReceiptHandler) authorizeUser(w http.ResponseWriter, r *http.Request) bool {
+	if r.Header.Get(userIDHeader) == mux.Vars(r)["id"] || h.isAdmin(r) {
+		return true
+	}
+	sendJSON(w, map[string]string{"error": "Forbidden: " + userIDHeader + " does not match the user"}, http.StatusForbidden) // 403 response
+	return false
+}
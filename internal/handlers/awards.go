@@ -0,0 +1,110 @@
+package handlers
+
+import (
+	"strings"
+	"time"
+
+	"receipt-processor-challenge-jase180/internal/models"
+	rules "receipt-processor-challenge-jase180/internal/services"
+)
+
+// prepareReceipt matches the retailer against the registry and classifies the items, the server-set fields
+// awardPoints relies on. Used by both storing and previewing so the preview is the award the receipt would get
+func (h *ReceiptHandler) prepareReceipt(receipt models.Receipt) models.Receipt {
+	return h.Rules.Categories.Classify(h.Rules.Retailers.Canonicalize(receipt))
+}
+
+// awardPoints works out the points for a new receipt, applying the user's tier multiplier, campaign bonuses
+// and finally earning limits. Tier and limits use the user's history before this receipt
+// Only reads the database, callers storing the receipt must hold awardLock until it is stored so
+// concurrent receipts cannot both slip under a limit
+func (h *ReceiptHandler) awardPoints(receipt models.Receipt) models.Award {
+	basePoints := h.Rules.CalculatePoints(receipt)
+	award := models.Award{BasePoints: basePoints, Multiplier: 1, Points: basePoints}
+
+	// Anonymous receipts have no tier
+	if receipt.UserID != "" {
+		tier := h.Rules.Tiers.Evaluate(h.Database.GetLedgerByUser(receipt.UserID), time.Now().UTC())
+		award.Tier = tier.Name
+		award.Multiplier = tier.Multiplier
+		award.Points = tier.ApplyMultiplier(basePoints)
+	}
+
+	// Campaign bonuses are added on top and not multiplied by tier
+	// Matched on the real moment of purchase so the store's zone, or its retailer default, is used
+	award.Campaigns = rules.CampaignBonuses(h.Rules.Timezones.WithZone(receipt), basePoints, h.Database.ListCampaigns(), h.Rules.ItemCounting)
+	for _, bonus := range award.Campaigns {
+		award.Points += bonus.Points
+	}
+
+	// Limits apply to the final amount, per user limits only for receipts with a user
+	award.Points, award.CappedPoints, award.CapReason = h.Rules.Limits.Apply(award.Points, h.limitUsage(receipt))
+	return award
+}
+
+// limitUsage adds up net points already awarded that count towards each limit
+// The retailer limit counts every receipt from the retailer that day, the user limits only the user's receipts
+func (h *ReceiptHandler) limitUsage(receipt models.Receipt) *rules.LimitUsage {
+	usage := &rules.LimitUsage{Anonymous: receipt.UserID == ""}
+
+	for _, other := range h.Database.GetReceiptsByPurchaseDate(receipt.PurchaseDate) {
+		if strings.EqualFold(retailerKey(other), retailerKey(receipt)) {
+			usage.RetailerDay += h.netReceiptPoints(other)
+		}
+	}
+	if usage.Anonymous {
+		return usage
+	}
+
+	purchaseDate, err := time.Parse("2006-01-02", receipt.PurchaseDate)
+	if err != nil {
+		return usage // fail gracefully, validation already rejects bad dates
+	}
+	year, week := purchaseDate.ISOWeek()
+
+	previous, err := h.Database.GetReceiptsByUser(receipt.UserID)
+	if err != nil {
+		return usage // first receipt for user
+	}
+
+	for _, other := range previous {
+		otherDate, err := time.Parse("2006-01-02", other.PurchaseDate)
+		if err != nil {
+			continue
+		}
+		points := h.netReceiptPoints(other)
+
+		if otherYear, otherWeek := otherDate.ISOWeek(); otherYear == year && otherWeek == week {
+			usage.UserWeek += points
+		}
+		if other.PurchaseDate == receipt.PurchaseDate {
+			usage.UserDay += points
+		}
+	}
+	return usage
+}
+
+// retailerKey is the retailer a receipt counts against for limits, the canonical name when it matched the registry
+// so "M&M Corner Market" and "M & M CORNER MKT" share one daily limit
+func retailerKey(receipt models.Receipt) string {
+	if receipt.RetailerCanonical != "" {
+		return receipt.RetailerCanonical
+	}
+	return strings.TrimSpace(receipt.Retailer)
+}
+
+// netReceiptPoints is receiptPoints less any reversal, so refunded receipts stop using up limits
+func (h *ReceiptHandler) netReceiptPoints(receipt models.Receipt) int {
+	if h.Database.IsReceiptReversed(receipt.ID) {
+		return 0
+	}
+	return h.receiptPoints(receipt)
+}
+
+// receiptPoints returns the points awarded at submission, or scores receipts that were stored without an award
+func (h *ReceiptHandler) receiptPoints(receipt models.Receipt) int {
+	if receipt.Award != nil {
+		return receipt.Award.Points
+	}
+	return h.Rules.CalculatePoints(receipt)
+}
@@ -0,0 +1,143 @@
+package handlers
+
+import (
+	"errors"
+	"fmt"
+	"net/http"
+	"strings"
+
+	"github.com/google/uuid"
+	"github.com/gorilla/mux"
+
+	"receipt-processor-challenge-jase180/internal/models"
+)
+
+// CreateCampaignHandler takes a POST request with /campaigns endpoint, validates and stores the campaign
+// Returns 200 and generated UUID like CreateReceiptHandler
+func (h *ReceiptHandler) CreateCampaignHandler(w http.ResponseWriter, r *http.Request) {
+	var campaign models.Campaign
+	if err := decodeJSONBody(w, r, &campaign); err != nil {
+		sendJSON(w, map[string]string{"error": "Invalid JSON"}, http.StatusBadRequest) // 400 response
+		return
+	}
+	if err := validateCampaign(campaign); err != nil {
+		sendJSON(w, map[string]string{"error": err.Error()}, http.StatusBadRequest)
+		return
+	}
+
+	campaign.ID = uuid.New().String()
+	if err := h.Database.AddCampaign(campaign); err != nil {
+		sendJSON(w, map[string]string{"error": "Database failure, could not create campaign"}, http.StatusInternalServerError) // 500 response
+		return
+	}
+
+	sendJSON(w, map[string]string{"id": campaign.ID}, http.StatusOK)
+}
+
+// ListCampaignsHandler takes a GET request with /campaigns endpoint and returns all campaigns
+func (h *ReceiptHandler) ListCampaignsHandler(w http.ResponseWriter, r *http.Request) {
+	sendJSON(w, map[string][]models.Campaign{"campaigns": h.Database.ListCampaigns()}, http.StatusOK)
+}
+
+// GetCampaignHandler takes a GET request with /campaigns/{id} endpoint and returns the campaign
+func (h *ReceiptHandler) GetCampaignHandler(w http.ResponseWriter, r *http.Request) {
+	id, ok := campaignID(w, r)
+	if !ok {
+		return
+	}
+
+	campaign, err := h.Database.GetCampaignByID(id)
+	if err != nil {
+		sendJSON(w, map[string]string{"error": "No campaign found for that ID"}, http.StatusNotFound) // 404 response
+		return
+	}
+	sendJSON(w, campaign, http.StatusOK)
+}
+
+// UpdateCampaignHandler takes a PUT request with /campaigns/{id} endpoint and replaces the campaign
+// Only affects receipts submitted after the update
+func (h *ReceiptHandler) UpdateCampaignHandler(w http.ResponseWriter, r *http.Request) {
+	id, ok := campaignID(w, r)
+	if !ok {
+		return
+	}
+
+	var campaign models.Campaign
+	if err := decodeJSONBody(w, r, &campaign); err != nil {
+		sendJSON(w, map[string]string{"error": "Invalid JSON"}, http.StatusBadRequest) // 400 response
+		return
+	}
+	if err := validateCampaign(campaign); err != nil {
+		sendJSON(w, map[string]string{"error": err.Error()}, http.StatusBadRequest)
+		return
+	}
+
+	campaign.ID = id // ID in path wins over any ID in body
+	if err := h.Database.UpdateCampaign(campaign); err != nil {
+		sendJSON(w, map[string]string{"error": "No campaign found for that ID"}, http.StatusNotFound) // 404 response
+		return
+	}
+	sendJSON(w, campaign, http.StatusOK)
+}
+
+// DeleteCampaignHandler takes a DELETE request with /campaigns/{id} endpoint and removes the campaign
+func (h *ReceiptHandler) DeleteCampaignHandler(w http.ResponseWriter, r *http.Request) {
+	id, ok := campaignID(w, r)
+	if !ok {
+		return
+	}
+
+	if err := h.Database.DeleteCampaign(id); err != nil {
+		sendJSON(w, map[string]string{"error": "No campaign found for that ID"}, http.StatusNotFound) // 404 response
+		return
+	}
+	sendJSON(w, map[string]string{"id": id}, http.StatusOK)
+}
+
+// helper function that reads and checks the {id} campaign route variable, writing the error response if invalid
+func campaignID(w http.ResponseWriter, r *http.Request) (string, bool) {
+	id := mux.Vars(r)["id"]
+	if _, err := uuid.Parse(id); err != nil {
+		sendJSON(w, map[string]string{"error": "BadRequest: Invalid ID format"}, http.StatusBadRequest) // 400 response
+		return "", false
+	}
+	return id, true
+}
+
+// Helper function verifying Campaign has a name, a window and a usable bonus
+func validateCampaign(campaign models.Campaign) error {
+	if strings.TrimSpace(campaign.Name) == "" {
+		return errors.New("BadRequest: The campaign is invalid. Name string is empty")
+	}
+	if campaign.Start.IsZero() || campaign.End.IsZero() {
+		return errors.New("BadRequest: The campaign is invalid. Start and end are required")
+	}
+	if !campaign.End.After(campaign.Start) {
+		return errors.New("BadRequest: The campaign is invalid. End must be after start")
+	}
+	for _, matcher := range append(append([]string{}, campaign.Retailers...), campaign.Items...) {
+		if strings.TrimSpace(matcher) == "" {
+			return errors.New("BadRequest: The campaign is invalid. Matcher string is empty")
+		}
+	}
+
+	switch campaign.Bonus.Type {
+	case models.BonusFlat, models.BonusPerItem:
+		if campaign.Bonus.Value <= 0 {
+			return errors.New("BadRequest: The campaign is invalid. Bonus points must be positive")
+		}
+		if campaign.Bonus.Value > models.MaxBonusPoints {
+			return fmt.Errorf("BadRequest: The campaign is invalid. Bonus points must be at most %d", models.MaxBonusPoints)
+		}
+	case models.BonusMultiplier:
+		if campaign.Bonus.Value <= 1 {
+			return errors.New("BadRequest: The campaign is invalid. Bonus multiplier must be greater than 1")
+		}
+		if campaign.Bonus.Value > models.MaxBonusMultiplier {
+			return fmt.Errorf("BadRequest: The campaign is invalid. Bonus multiplier must be at most %d", models.MaxBonusMultiplier)
+		}
+	default:
+		return errors.New("BadRequest: The campaign is invalid. Bonus type must be flat, multiplier or perItem")
+	}
+	return nil
+}
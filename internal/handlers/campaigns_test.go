@@ -0,0 +1,106 @@
+package handlers
+
+import (
+	"encoding/json"
+	"net/http"
+	"testing"
+
+	"github.com/google/uuid"
+
+	"receipt-processor-challenge-jase180/internal/store"
+)
+
+func TestCreateCampaignHandler(t *testing.T) {
+	db := store.NewMemoryDatabase()
+	handler := NewReceiptHandler(db)
+
+	tests := []struct {
+		name         string
+		body         string
+		responseCode int // corresponding response codes
+	}{
+		{"Valid campaign", `{"name": "Gatorade bonus", "start": "2022-03-01T00:00:00Z", "end": "2022-04-01T00:00:00Z",
+			"items": ["Gatorade"], "bonus": {"type": "flat", "value": 100}}`, http.StatusOK},
+		{"Missing name", `{"start": "2022-03-01T00:00:00Z", "end": "2022-04-01T00:00:00Z", "bonus": {"type": "flat", "value": 100}}`, http.StatusBadRequest},
+		{"End before start", `{"name": "Backwards", "start": "2022-04-01T00:00:00Z", "end": "2022-03-01T00:00:00Z",
+			"bonus": {"type": "flat", "value": 100}}`, http.StatusBadRequest},
+		{"Unknown bonus type", `{"name": "Gift", "start": "2022-03-01T00:00:00Z", "end": "2022-04-01T00:00:00Z",
+			"bonus": {"type": "gift", "value": 100}}`, http.StatusBadRequest},
+		{"Multiplier that reduces points", `{"name": "Half", "start": "2022-03-01T00:00:00Z", "end": "2022-04-01T00:00:00Z",
+			"bonus": {"type": "multiplier", "value": 0.5}}`, http.StatusBadRequest},
+		{"Empty matcher", `{"name": "Empty", "start": "2022-03-01T00:00:00Z", "end": "2022-04-01T00:00:00Z",
+			"retailers": [" "], "bonus": {"type": "flat", "value": 100}}`, http.StatusBadRequest},
+		{"Bonus points above maximum", `{"name": "Huge", "start": "2022-03-01T00:00:00Z", "end": "2022-04-01T00:00:00Z",
+			"bonus": {"type": "flat", "value": 1e300}}`, http.StatusBadRequest},
+		{"Multiplier above maximum", `{"name": "Huge", "start": "2022-03-01T00:00:00Z", "end": "2022-04-01T00:00:00Z",
+			"bonus": {"type": "multiplier", "value": 101}}`, http.StatusBadRequest},
+		{"Bad date", `{"name": "Bad", "start": "2022-03-01", "end": "2022-04-01", "bonus": {"type": "flat", "value": 100}}`, http.StatusBadRequest},
+	}
+
+	for _, testCase := range tests {
+		t.Run(testCase.name, func(t *testing.T) {
+			responseRecorder := sendToHandler(handler.CreateCampaignHandler, "POST", testCase.body, nil)
+			if responseRecorder.Code != testCase.responseCode {
+				t.Errorf("Result status: %d, want: %d", responseRecorder.Code, testCase.responseCode)
+			}
+		})
+	}
+}
+
+// TestCampaignLifecycle tests campaign CRUD through handlers and that active campaigns add to receipt points
+func TestCampaignLifecycle(t *testing.T) {
+	db := store.NewMemoryDatabase()
+	handler := NewReceiptHandler(db)
+
+	// Create "+100 points for any receipt containing Gatorade" during March 2022
+	created := sendToHandler(handler.CreateCampaignHandler, "POST", `{"name": "Gatorade bonus",
+		"start": "2022-03-01T00:00:00Z", "end": "2022-04-01T00:00:00Z", "items": ["Gatorade"], "bonus": {"type": "flat", "value": 100}}`, nil)
+	var createResponse map[string]string
+	json.Unmarshal(created.Body.Bytes(), &createResponse)
+	campaign := map[string]string{"id": createResponse["id"]}
+
+	// M&M README receipt is 109 plus 100 bonus
+	submitUserReceipt(t, handler, "user-1")
+	var points UserPointsResponse
+	json.Unmarshal(sendToHandler(handler.GetUserPointsHandler, "GET", "", map[string]string{"id": "user-1"}).Body.Bytes(), &points)
+	if points.Balance != 209 {
+		t.Fatalf("Result balance %d, want 209", points.Balance)
+	}
+
+	tests := []struct {
+		name         string
+		handlerFunc  http.HandlerFunc
+		method       string
+		body         string
+		vars         map[string]string
+		responseCode int // corresponding response codes
+	}{
+		{"Get campaign", handler.GetCampaignHandler, "GET", "", campaign, http.StatusOK},
+		{"List campaigns", handler.ListCampaignsHandler, "GET", "", nil, http.StatusOK},
+		{"Update campaign", handler.UpdateCampaignHandler, "PUT", `{"name": "Gatorade double",
+			"start": "2022-03-01T00:00:00Z", "end": "2022-04-01T00:00:00Z", "items": ["Gatorade"], "bonus": {"type": "multiplier", "value": 2}}`, campaign, http.StatusOK},
+		{"Update invalid campaign", handler.UpdateCampaignHandler, "PUT", `{"name": ""}`, campaign, http.StatusBadRequest},
+		{"Delete campaign", handler.DeleteCampaignHandler, "DELETE", "", campaign, http.StatusOK},
+		{"Get deleted campaign", handler.GetCampaignHandler, "GET", "", campaign, http.StatusNotFound},
+		{"Delete deleted campaign", handler.DeleteCampaignHandler, "DELETE", "", campaign, http.StatusNotFound},
+		{"Update no such campaign", handler.UpdateCampaignHandler, "PUT", `{"name": "Gone",
+			"start": "2022-03-01T00:00:00Z", "end": "2022-04-01T00:00:00Z", "bonus": {"type": "flat", "value": 1}}`, map[string]string{"id": uuid.NewString()}, http.StatusNotFound},
+		{"Invalid ID", handler.GetCampaignHandler, "GET", "", map[string]string{"id": "ABCDEFG"}, http.StatusBadRequest},
+	}
+
+	for _, testCase := range tests {
+		t.Run(testCase.name, func(t *testing.T) {
+			responseRecorder := sendToHandler(testCase.handlerFunc, testCase.method, testCase.body, testCase.vars)
+			if responseRecorder.Code != testCase.responseCode {
+				t.Errorf("Result status: %d, want: %d", responseRecorder.Code, testCase.responseCode)
+			}
+		})
+	}
+
+	// Deleted campaign no longer applies but the earlier receipt keeps its bonus
+	submitUserReceipt(t, handler, "user-1")
+	json.Unmarshal(sendToHandler(handler.GetUserPointsHandler, "GET", "", map[string]string{"id": "user-1"}).Body.Bytes(), &points)
+	if points.Balance != 318 {
+		t.Fatalf("Result balance %d, want 318", points.Balance)
+	}
+}
@@ -0,0 +1,297 @@
+package handlers
+
+import (
+	"encoding/csv"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"strconv"
+	"strings"
+	"unicode/utf8"
+
+	"receipt-processor-challenge-jase180/internal/models"
+)
+
+// csvExportColumns is the header of GET /receipts/export.csv, one row per item
+// Column names match the receipt JSON fields so an export can be imported again
+var csvExportColumns = []string{"id", "retailer", "purchaseDate", "purchaseTime", "total", "currency", "userId", "points",
+	"shortDescription", "price", "quantity", "unitPrice", "category"}
+
+// csvImportFields are the receipt fields POST /receipts/import reads and their default column, the key groups rows into receipts
+var csvImportFields = map[string]string{
+	"key": "id", "retailer": "retailer", "purchaseDate": "purchaseDate", "purchaseTime": "purchaseTime", "total": "total",
+	"currency": "currency", "userId": "userId", "shortDescription": "shortDescription", "price": "price",
+	"quantity": "quantity", "unitPrice": "unitPrice",
+}
+
+// csvRequiredFields must have a column in every import
+var csvRequiredFields = []string{"key", "retailer", "purchaseDate", "purchaseTime", "total", "shortDescription", "price"}
+
+// csvReceiptFields are the receipt-level fields rows of the same receipt must agree on
+var csvReceiptFields = []string{"retailer", "purchaseDate", "purchaseTime", "total", "currency"}
+
+// ImportError is a problem with rows of an import, rows are CSV line numbers with the header on line 1
+type ImportError struct {
+	Rows  []int  `json:"rows"`
+	Key   string `json:"key,omitempty"` // Receipt key of the rows, empty if the rows have none
+	Error string `json:"error"`
+}
+
+// ImportedReceipt is a receipt stored by an import
+type ImportedReceipt struct {
+	Key string `json:"key"` // Receipt key in the CSV
+	ID  string `json:"id"`  // Generated receipt ID
+}
+
+// ImportResponse is the response for POST /receipts/import, receipts with errors are skipped and the rest stored
+type ImportResponse struct {
+	Imported int               `json:"imported"`
+	Failed   int               `json:"failed"`
+	Receipts []ImportedReceipt `json:"receipts"`
+	Errors   []ImportError     `json:"errors,omitempty"`
+}
+
+// csvReceipt is a receipt being assembled from its rows
+type csvReceipt struct {
+	key     string
+	receipt models.Receipt
+	rows    []int
+	err     string // First problem found in the rows, the receipt is not stored if set
+}
+
+// ImportReceiptsHandler takes a CSV of receipts with one row per item, grouped into receipts by a key column
+// Query options: column.<field>=<header> maps a field to another column (field names as in csvImportFields)
+// and delimiter sets the separator, e.g. ";" for spreadsheets in comma decimal locales
+// Every receipt is validated like POST /receipts/process, valid receipts are stored and the rest reported
+func (h *ReceiptHandler) ImportReceiptsHandler(w http.ResponseWriter, r *http.Request) {
+	// Size limiting to prevent DoS and resource exhaustion, larger than single receipts for bulk files
+	r.Body = http.MaxBytesReader(w, r.Body, h.MaxImportBytes) // 10 MB unless configured
+	defer r.Body.Close()
+
+	mapping, delimiter, err := csvImportOptions(r)
+	if err != nil {
+		sendJSON(w, map[string]string{"error": "BadRequest: " + err.Error()}, http.StatusBadRequest)
+		return
+	}
+
+	reader := csv.NewReader(r.Body)
+	reader.Comma = delimiter
+	reader.TrimLeadingSpace = true
+	reader.ReuseRecord = true
+
+	header, err := reader.Read()
+	if err != nil {
+		sendJSON(w, map[string]string{"error": "BadRequest: Cannot read CSV header"}, http.StatusBadRequest)
+		return
+	}
+	columns, err := csvColumnIndexes(header, mapping)
+	if err != nil {
+		sendJSON(w, map[string]string{"error": "BadRequest: " + err.Error()}, http.StatusBadRequest)
+		return
+	}
+
+	response := ImportResponse{Receipts: []ImportedReceipt{}}
+	receipts := []*csvReceipt{}
+	byKey := make(map[string]*csvReceipt)
+	for {
+		record, err := reader.Read()
+		if err == io.EOF {
+			break
+		}
+		if err != nil {
+			var parseErr *csv.ParseError
+			if !errors.As(err, &parseErr) {
+				sendJSON(w, map[string]string{"error": "Invalid request body"}, http.StatusBadRequest)
+				return
+			}
+			response.Errors = append(response.Errors, ImportError{Rows: []int{parseErr.Line}, Error: "Invalid CSV row: " + parseErr.Err.Error()})
+			continue
+		}
+		row, _ := reader.FieldPos(0) // every row has the header's column count so column 0 exists
+
+		value := func(field string) string {
+			if i, ok := columns[field]; ok {
+				return csvUnescape(strings.TrimSpace(record[i]))
+			}
+			return ""
+		}
+		key := value("key")
+		if key == "" {
+			response.Errors = append(response.Errors, ImportError{Rows: []int{row}, Error: "Row has no receipt key"})
+			continue
+		}
+
+		current, exists := byKey[key]
+		if !exists {
+			current = &csvReceipt{key: key, receipt: models.Receipt{
+				Retailer:     value("retailer"),
+				PurchaseDate: value("purchaseDate"),
+				PurchaseTime: value("purchaseTime"),
+				Total:        value("total"),
+				Currency:     value("currency"),
+				UserID:       value("userId"),
+			}}
+			if current.receipt.UserID == "" {
+				current.receipt.UserID = strings.TrimSpace(r.Header.Get(userIDHeader))
+			}
+			byKey[key] = current
+			receipts = append(receipts, current)
+		} else if current.err == "" {
+			// Receipt fields may be left blank after the first row but must not disagree with it
+			first := map[string]string{"retailer": current.receipt.Retailer, "purchaseDate": current.receipt.PurchaseDate,
+				"purchaseTime": current.receipt.PurchaseTime, "total": current.receipt.Total, "currency": current.receipt.Currency}
+			for _, field := range csvReceiptFields {
+				if got, want := value(field), first[field]; got != "" && got != want {
+					current.err = fmt.Sprintf("Row %d has %s %q but the receipt's first row has %q", row, field, got, want)
+					break
+				}
+			}
+		}
+
+		current.rows = append(current.rows, row)
+		current.receipt.Items = append(current.receipt.Items, models.Item{
+			ShortDescription: value("shortDescription"),
+			Price:            value("price"),
+			Quantity:         value("quantity"),
+			UnitPrice:        value("unitPrice"),
+		})
+	}
+
+	for _, current := range receipts {
+		if current.err == "" {
+			if err := h.validate(current.receipt); err != nil {
+				current.err = err.Error()
+			}
+		}
+		if current.err != "" {
+			response.Failed++
+			response.Errors = append(response.Errors, ImportError{Rows: current.rows, Key: current.key, Error: current.err})
+			continue
+		}
+
+		stored, err := h.storeReceipt(current.receipt)
+		if err != nil {
+			sendJSON(w, map[string]string{"error": err.Error()}, http.StatusInternalServerError) // 500 response
+			return
+		}
+		response.Imported++
+		response.Receipts = append(response.Receipts, ImportedReceipt{Key: current.key, ID: stored.ID})
+	}
+
+	sendJSON(w, response, http.StatusOK)
+}
+
+// helper function that reads the import query options, the field to column mapping and the delimiter
+func csvImportOptions(r *http.Request) (map[string]string, rune, error) {
+	mapping := make(map[string]string, len(csvImportFields))
+	for field, column := range csvImportFields {
+		mapping[field] = column
+	}
+
+	query := r.URL.Query()
+	for name, values := range query {
+		field, found := strings.CutPrefix(name, "column.")
+		if !found {
+			continue
+		}
+		if _, known := csvImportFields[field]; !known {
+			return nil, 0, fmt.Errorf("Unknown import field %q", field)
+		}
+		if column := strings.TrimSpace(values[0]); column != "" {
+			mapping[field] = column
+		}
+	}
+
+	delimiter := ','
+	if value := query.Get("delimiter"); value != "" {
+		if value == `\t` || value == "tab" {
+			value = "\t"
+		}
+		delimiter, _ = utf8.DecodeRuneInString(value)
+		if utf8.RuneCountInString(value) != 1 || delimiter == '"' || delimiter == '\r' || delimiter == '\n' || delimiter == utf8.RuneError {
+			return nil, 0, errors.New("Delimiter must be a single character other than a quote or line break")
+		}
+	}
+	return mapping, delimiter, nil
+}
+
+// helper function that finds the column index of every mapped field, headers match ignoring case and spaces
+func csvColumnIndexes(header []string, mapping map[string]string) (map[string]int, error) {
+	positions := make(map[string]int, len(header))
+	for i, column := range header {
+		column = strings.ToLower(strings.TrimSpace(strings.TrimPrefix(column, "\ufeff"))) // spreadsheets often start with a byte order mark
+		if _, duplicate := positions[column]; !duplicate {
+			positions[column] = i
+		}
+	}
+
+	columns := make(map[string]int)
+	for field, column := range mapping {
+		if i, ok := positions[strings.ToLower(column)]; ok {
+			columns[field] = i
+		}
+	}
+	for _, field := range csvRequiredFields {
+		if _, ok := columns[field]; !ok {
+			return nil, fmt.Errorf("CSV has no %q column for %s", mapping[field], field)
+		}
+	}
+	return columns, nil
+}
+
+// ExportReceiptsHandler streams every stored receipt as CSV, one row per item with the receipt's points
+// Receipts are read one at a time so the export never holds more than one receipt in memory
+func (h *ReceiptHandler) ExportReceiptsHandler(w http.ResponseWriter, r *http.Request) {
+	w.Header().Set("Content-Type", "text/csv; charset=utf-8")
+	w.Header().Set("Content-Disposition", `attachment; filename="receipts.csv"`)
+	w.WriteHeader(http.StatusOK)
+
+	writer := csv.NewWriter(w)
+	flusher, canFlush := w.(http.Flusher)
+	if err := writer.Write(csvExportColumns); err != nil {
+		return
+	}
+
+	for i, id := range h.Database.ListReceiptIDs() {
+		receipt, err := h.Database.GetReceiptByID(id)
+		if err != nil {
+			continue
+		}
+		points := strconv.Itoa(h.receiptPoints(receipt))
+
+		for _, item := range receipt.Items {
+			record := []string{receipt.ID, csvEscape(receipt.Retailer), receipt.PurchaseDate, receipt.PurchaseTime, receipt.Total,
+				receipt.Currency, csvEscape(receipt.UserID), points, csvEscape(item.ShortDescription), item.Price, item.Quantity,
+				item.UnitPrice, item.Category}
+			if err := writer.Write(record); err != nil {
+				return // client went away
+			}
+		}
+
+		// Send rows as they are written rather than buffering the whole export
+		if (i+1)%100 == 0 {
+			writer.Flush()
+			if canFlush {
+				flusher.Flush()
+			}
+		}
+	}
+	writer.Flush()
+}
+
+// csvEscape stops spreadsheets running text as a formula by prefixing values starting with a formula character with '
+func csvEscape(value string) string {
+	if value != "" && strings.ContainsRune("=+-@\t\r", rune(value[0])) {
+		return "'" + value
+	}
+	return value
+}
+
+// csvUnescape reverses csvEscape so exported files import unchanged
+func csvUnescape(value string) string {
+	if len(value) > 1 && value[0] == '\'' && strings.ContainsRune("=+-@\t\r", rune(value[1])) {
+		return value[1:]
+	}
+	return value
+}
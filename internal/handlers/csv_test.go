@@ -0,0 +1,179 @@
+package handlers
+
+import (
+	"encoding/csv"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"receipt-processor-challenge-jase180/internal/store"
+)
+
+// importCSV posts a CSV to the import handler and returns the status and decoded response
+func importCSV(t *testing.T, handler *ReceiptHandler, url string, body string) (int, ImportResponse) {
+	t.Helper()
+	request := httptest.NewRequest("POST", url, strings.NewReader(body))
+	request.Header.Set("Content-Type", "text/csv")
+	responseRecorder := httptest.NewRecorder()
+	handler.ImportReceiptsHandler(responseRecorder, request)
+
+	var response ImportResponse
+	if responseRecorder.Code == http.StatusOK {
+		if err := json.Unmarshal(responseRecorder.Body.Bytes(), &response); err != nil {
+			t.Fatalf("Error during test parsing result JSON: %v", err)
+		}
+	}
+	return responseRecorder.Code, response
+}
+
+func TestImportReceiptsHandler(t *testing.T) {
+	// Target README receipt is 28 points, Walgreens morning receipt is 15 points
+	readme := "id,retailer,purchaseDate,purchaseTime,total,shortDescription,price\n" +
+		"t1,Target,2022-01-01,13:01,35.35,Mountain Dew 12PK,6.49\n" +
+		"t1,,,,,Emils Cheese Pizza,12.25\n" +
+		"w1,Walgreens,2022-01-02,08:13,2.65,Pepsi - 12-oz,1.25\n" +
+		"t1,Target,2022-01-01,13:01,35.35,Knorr Creamy Chicken,1.26\n" +
+		"t1,Target,2022-01-01,13:01,35.35,Doritos Nacho Cheese,3.35\n" +
+		"t1,Target,2022-01-01,13:01,35.35,\"   Klarbrunn 12-PK 12 FL OZ  \",12.00\n" +
+		"w1,Walgreens,2022-01-02,08:13,2.65,Dasani,1.40\n"
+
+	tests := []struct {
+		name         string
+		url          string
+		body         string
+		responseCode int
+		wantImported int
+		wantFailed   int
+		wantErrRows  [][]int // rows of each error
+	}{
+		{"Rows grouped by key in any order", "/receipts/import", readme, http.StatusOK, 2, 0, nil},
+		{"Mapped columns and semicolons",
+			"/receipts/import?column.key=Receipt%20No&column.retailer=Store&column.total=Amount&delimiter=%3B",
+			"\ufeffReceipt No;Store;purchaseDate;purchaseTime;Amount;shortDescription;price\n1;Target;2022-01-01;13:01;1.25;Pepsi;1.25\n",
+			http.StatusOK, 1, 0, nil},
+		{"Invalid receipt reports its rows",
+			"/receipts/import",
+			"id,retailer,purchaseDate,purchaseTime,total,shortDescription,price\na,Target,2022-01-01,13:01,1.25,Pepsi,1.25\nb,Target,2022-13-01,13:01,1.25,Pepsi,1.25\nb,,,,,Dasani,1.40\n",
+			http.StatusOK, 1, 1, [][]int{{3, 4}}},
+		{"Conflicting receipt fields",
+			"/receipts/import",
+			"id,retailer,purchaseDate,purchaseTime,total,shortDescription,price\na,Target,2022-01-01,13:01,2.65,Pepsi,1.25\na,Walmart,,,,Dasani,1.40\n",
+			http.StatusOK, 0, 1, [][]int{{2, 3}}},
+		{"Bad rows and missing keys",
+			"/receipts/import",
+			"id,retailer,purchaseDate,purchaseTime,total,shortDescription,price\na,Target,2022-01-01,13:01,1.25,Pepsi,1.25\n,Target,2022-01-01,13:01,1.25,Pepsi,1.25\nb,Target\n",
+			http.StatusOK, 1, 0, [][]int{{3}, {4}}},
+		{"Missing required column", "/receipts/import", "id,retailer\na,Target\n", http.StatusBadRequest, 0, 0, nil},
+		{"Unknown mapped field", "/receipts/import?column.points=Points", readme, http.StatusBadRequest, 0, 0, nil},
+		{"Bad delimiter", "/receipts/import?delimiter=%22", readme, http.StatusBadRequest, 0, 0, nil},
+		{"Empty body", "/receipts/import", "", http.StatusBadRequest, 0, 0, nil},
+	}
+
+	for _, testCase := range tests {
+		t.Run(testCase.name, func(t *testing.T) {
+			db := store.NewMemoryDatabase()
+			handler := NewReceiptHandler(db)
+
+			code, response := importCSV(t, handler, testCase.url, testCase.body)
+			if code != testCase.responseCode {
+				t.Fatalf("Result status: %d, want: %d", code, testCase.responseCode)
+			}
+			if response.Imported != testCase.wantImported || response.Failed != testCase.wantFailed {
+				t.Errorf("Result imported %d failed %d, want %d and %d", response.Imported, response.Failed, testCase.wantImported, testCase.wantFailed)
+			}
+			if len(response.Errors) != len(testCase.wantErrRows) {
+				t.Fatalf("Result errors: %+v, want rows %v", response.Errors, testCase.wantErrRows)
+			}
+			for i, importErr := range response.Errors {
+				if len(importErr.Rows) != len(testCase.wantErrRows[i]) || importErr.Rows[0] != testCase.wantErrRows[i][0] {
+					t.Errorf("Result error rows: %v, want %v", importErr.Rows, testCase.wantErrRows[i])
+				}
+			}
+			if receipts := db.ListReceipts(); len(receipts) != testCase.wantImported {
+				t.Errorf("Result %d stored receipts, want %d", len(receipts), testCase.wantImported)
+			}
+		})
+	}
+}
+
+func TestImportReceiptsPoints(t *testing.T) {
+	db := store.NewMemoryDatabase()
+	handler := NewReceiptHandler(db)
+
+	body := "id,retailer,purchaseDate,purchaseTime,total,shortDescription,price,userId\n" +
+		"t1,Target,2022-01-01,13:01,35.35,Mountain Dew 12PK,6.49,user-1\n" +
+		"t1,Target,2022-01-01,13:01,35.35,Emils Cheese Pizza,12.25,\n" +
+		"t1,Target,2022-01-01,13:01,35.35,Knorr Creamy Chicken,1.26,\n" +
+		"t1,Target,2022-01-01,13:01,35.35,Doritos Nacho Cheese,3.35,\n" +
+		"t1,Target,2022-01-01,13:01,35.35,   Klarbrunn 12-PK 12 FL OZ  ,12.00,\n"
+	_, response := importCSV(t, handler, "/receipts/import", body)
+	if len(response.Receipts) != 1 {
+		t.Fatalf("Result receipts: %+v, want 1", response.Receipts)
+	}
+
+	// Imported receipts are awarded and credited like submitted receipts
+	receipt, err := db.GetReceiptByID(response.Receipts[0].ID)
+	if err != nil || receipt.Award == nil || receipt.Award.Points != 28 || len(receipt.Items) != 5 {
+		t.Fatalf("Result receipt: %+v, want 5 items and 28 points", receipt)
+	}
+	if balance, _ := db.GetUserBalance("user-1"); balance != 28 {
+		t.Errorf("Result balance: %d, want 28", balance)
+	}
+}
+
+func TestExportReceiptsHandler(t *testing.T) {
+	db := store.NewMemoryDatabase()
+	handler := NewReceiptHandler(db)
+
+	body := "id,retailer,purchaseDate,purchaseTime,total,shortDescription,price\n" +
+		"a,\"=HYPERLINK(\"\"x\"\")\",2022-01-01,13:01,2.65,Pepsi - 12-oz,1.25\n" +
+		"a,,,,,Dasani,1.40\n" +
+		"b,Target,2022-01-02,08:13,1.25,Pepsi,1.25\n"
+	if _, response := importCSV(t, handler, "/receipts/import", body); response.Imported != 2 {
+		t.Fatalf("Result imported: %+v, want 2", response)
+	}
+
+	request := httptest.NewRequest("GET", "/receipts/export.csv", nil)
+	responseRecorder := httptest.NewRecorder()
+	handler.ExportReceiptsHandler(responseRecorder, request)
+
+	if responseRecorder.Code != http.StatusOK || !strings.HasPrefix(responseRecorder.Header().Get("Content-Type"), "text/csv") {
+		t.Fatalf("Result status %d content type %q, want 200 text/csv", responseRecorder.Code, responseRecorder.Header().Get("Content-Type"))
+	}
+	exported := responseRecorder.Body.String()
+	records, err := csv.NewReader(strings.NewReader(exported)).ReadAll()
+	if err != nil {
+		t.Fatalf("Result is not CSV: %v", err)
+	}
+	if len(records) != 4 || strings.Join(records[0], ",") != strings.Join(csvExportColumns, ",") {
+		t.Fatalf("Result records: %v, want header and 3 item rows", records)
+	}
+
+	// Formulas are escaped for spreadsheets and every row has the receipt's points
+	for _, record := range records[1:] {
+		receipt, err := db.GetReceiptByID(record[0])
+		if err != nil {
+			t.Fatalf("Result row %v has unknown receipt ID", record)
+		}
+		if record[7] == "" || record[7] != strings.TrimSpace(record[7]) {
+			t.Errorf("Result row %v has no points", record)
+		}
+		if strings.HasPrefix(receipt.Retailer, "=") && record[1] != "'"+receipt.Retailer {
+			t.Errorf("Result retailer cell %q, want formula escaped", record[1])
+		}
+	}
+
+	// Export imports again unchanged
+	if _, response := importCSV(t, handler, "/receipts/import", exported); response.Imported != 2 || response.Failed != 0 {
+		t.Errorf("Result re-import: %+v, want 2 imported", response)
+	}
+	retailers := map[string]bool{}
+	for _, receipt := range db.ListReceipts() {
+		retailers[receipt.Retailer] = true
+	}
+	if !retailers[`=HYPERLINK("x")`] || len(retailers) != 2 {
+		t.Errorf("Result retailers: %v, want the original names", retailers)
+	}
+}
@@ -0,0 +1,89 @@
+package handlers
+
+import (
+	"errors"
+	"io"
+	"net/http"
+	"net/mail"
+	"strings"
+
+	"receipt-processor-challenge-jase180/internal/email"
+)
+
+// EmailReceiptResponse is the response for POST /receipts/process/email, the text receipt response
+// with the extractor the sender matched and the sender the receipt is recorded with
+type EmailReceiptResponse struct {
+	TextReceiptResponse
+	Extractor string `json:"extractor,omitempty"` // Retailer extractor used, empty if only templates and heuristics were used
+	Sender    string `json:"sender,omitempty"`    // Address of the message author
+}
+
+// CreateEmailReceiptHandler takes a raw RFC 5322/MIME email message of a forwarded e-receipt and processes it
+// like CreateTextReceiptHandler, the receipt records the sender and the user comes from the X-User-ID header
+func (h *ReceiptHandler) CreateEmailReceiptHandler(w http.ResponseWriter, r *http.Request) {
+	// Size limiting to prevent DoS and resource exhaustion
+	r.Body = http.MaxBytesReader(w, r.Body, h.MaxBodyBytes) // 1 MB unless configured
+	defer r.Body.Close()
+
+	raw, err := io.ReadAll(r.Body)
+	if err != nil {
+		sendJSON(w, map[string]string{"error": "Invalid request body"}, http.StatusBadRequest)
+		return
+	}
+
+	response, code := h.ingestEmail(raw, "", strings.TrimSpace(r.Header.Get(userIDHeader)))
+	if response.Sender == "" {
+		sendJSON(w, map[string]string{"error": response.Error}, code) // message could not be read, nothing was parsed
+		return
+	}
+	sendJSON(w, response, code)
+}
+
+// DeliverEmail stores the receipt in a message received by the SMTP listener
+// The envelope sender stands in for a missing From header, rejected messages return the reason
+func (h *ReceiptHandler) DeliverEmail(envelopeFrom string, raw []byte) error {
+	response, code := h.ingestEmail(raw, envelopeFrom, "")
+	if code != http.StatusOK {
+		return errors.New(response.Error)
+	}
+	return nil
+}
+
+// helper function that parses, extracts, validates and stores an emailed receipt, returning the response and status code
+func (h *ReceiptHandler) ingestEmail(raw []byte, envelopeFrom string, userID string) (EmailReceiptResponse, int) {
+	response := EmailReceiptResponse{}
+
+	message, err := email.ParseMessage(raw)
+	if err != nil {
+		response.Error = "BadRequest: Invalid email message. " + err.Error()
+		return response, http.StatusBadRequest
+	}
+	if message.From == nil && envelopeFrom != "" {
+		message.From = &mail.Address{Address: envelopeFrom}
+	}
+
+	extraction, err := email.Extract(message, h.Extractors, h.Templates)
+	if err != nil {
+		response.Error = "BadRequest: Invalid email message. " + err.Error()
+		return response, http.StatusBadRequest
+	}
+
+	receipt := extraction.Receipt
+	receipt.UserID = userID
+	receipt.Sender = extraction.Sender
+
+	response.Extractor, response.Sender = extraction.Extractor, extraction.Sender
+	response.Template, response.Receipt, response.Confidence = extraction.Template, receipt, extraction.Confidence
+	if err := h.validate(receipt); err != nil {
+		response.Error = err.Error()
+		return response, http.StatusBadRequest
+	}
+
+	stored, err := h.storeReceipt(receipt)
+	if err != nil {
+		response.Error = err.Error()
+		return response, http.StatusInternalServerError
+	}
+	response.ID, response.Receipt = stored.ID, stored
+	return response, http.StatusOK
+}
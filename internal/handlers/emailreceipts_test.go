@@ -0,0 +1,120 @@
+package handlers
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"receipt-processor-challenge-jase180/internal/store"
+)
+
+// forwardedTarget is the Target README receipt (28 points) as an HTML e-receipt forwarded by a customer
+const forwardedTarget = "From: Jane Doe <jane@example.com>\r\n" +
+	"Subject: Fwd: Your Target receipt\r\n" +
+	"MIME-Version: 1.0\r\n" +
+	"Content-Type: multipart/alternative; boundary=\"b\"\r\n\r\n" +
+	"--b\r\nContent-Type: text/plain\r\n\r\nSee HTML\r\n" +
+	"--b\r\nContent-Type: text/html; charset=utf-8\r\n\r\n" +
+	"<p>---------- Forwarded message ---------<br>From: Target &lt;orders@oe.target.com&gt;</p>" +
+	"<p>Thanks for shopping!</p><p>01/01/2022 1:01 PM</p><table>" +
+	"<tr><td>Mountain Dew 12PK</td><td>$6.49</td></tr><tr><td>Emils Cheese Pizza</td><td>$12.25</td></tr>" +
+	"<tr><td>Knorr Creamy Chicken</td><td>$1.26</td></tr><tr><td>Doritos Nacho Cheese</td><td>$3.35</td></tr>" +
+	"<tr><td>Klarbrunn 12-PK 12 FL OZ</td><td>$12.00</td></tr><tr><td>Total</td><td>$35.35</td></tr></table>\r\n" +
+	"--b--\r\n"
+
+func TestCreateEmailReceiptHandler(t *testing.T) {
+	tests := []struct {
+		name          string
+		body          string
+		responseCode  int
+		wantExtractor string
+		wantPoints    int
+	}{
+		{"Forwarded HTML e-receipt", forwardedTarget, http.StatusOK, "target", 28},
+		{"Receipt without total", "From: jane@example.com\r\n\r\nTarget\r\n01/01/2022 1:01 PM\r\n", http.StatusBadRequest, "", 0},
+		{"Not an email", "hello", http.StatusBadRequest, "", 0},
+	}
+
+	for _, testCase := range tests {
+		t.Run(testCase.name, func(t *testing.T) {
+			db := store.NewMemoryDatabase()
+			handler := NewReceiptHandler(db)
+
+			request := httptest.NewRequest("POST", "/receipts/process/email", strings.NewReader(testCase.body))
+			request.Header.Set("Content-Type", "message/rfc822")
+			request.Header.Set(userIDHeader, "user-1")
+			responseRecorder := httptest.NewRecorder()
+			handler.CreateEmailReceiptHandler(responseRecorder, request)
+
+			if responseRecorder.Code != testCase.responseCode {
+				t.Fatalf("Result status: %d, want: %d, body: %s", responseRecorder.Code, testCase.responseCode, responseRecorder.Body.String())
+			}
+
+			var response EmailReceiptResponse
+			if err := json.Unmarshal(responseRecorder.Body.Bytes(), &response); err != nil {
+				t.Fatalf("Error during test parsing result JSON: %v", err)
+			}
+			if testCase.responseCode != http.StatusOK {
+				if response.Error == "" {
+					t.Errorf("Result has no error, want one")
+				}
+				if receipts := db.ListReceipts(); len(receipts) != 0 {
+					t.Errorf("Result %d stored receipts, want 0", len(receipts))
+				}
+				return
+			}
+
+			stored, err := db.GetReceiptByID(response.ID)
+			if err != nil {
+				t.Fatalf("Result receipt %q not stored: %v", response.ID, err)
+			}
+			if response.Extractor != testCase.wantExtractor || stored.Retailer != "Target" {
+				t.Errorf("Result extractor %q retailer %q, want %q and Target", response.Extractor, stored.Retailer, testCase.wantExtractor)
+			}
+			if stored.Sender != "jane@example.com" || stored.UserID != "user-1" {
+				t.Errorf("Result sender %q user %q, want jane@example.com and user-1", stored.Sender, stored.UserID)
+			}
+			if stored.Award == nil || stored.Award.Points != testCase.wantPoints {
+				t.Errorf("Result award: %+v, want %d points", stored.Award, testCase.wantPoints)
+			}
+		})
+	}
+}
+
+func TestDeliverEmail(t *testing.T) {
+	db := store.NewMemoryDatabase()
+	handler := NewReceiptHandler(db)
+
+	// No From header, the envelope sender is recorded
+	raw := []byte("Subject: receipt\r\n\r\nTarget\r\n01/01/2022 1:01 PM\r\nGatorade 2.25\r\nTOTAL 2.25\r\n")
+	if err := handler.DeliverEmail("orders@target.com", raw); err != nil {
+		t.Fatalf("Result error: %v, want delivered", err)
+	}
+	receipts := db.ListReceipts()
+	if len(receipts) != 1 || receipts[0].Sender != "orders@target.com" || receipts[0].UserID != "" {
+		t.Errorf("Result receipts: %+v, want one from orders@target.com without a user", receipts)
+	}
+
+	// Invalid receipts are rejected with the validation error
+	if err := handler.DeliverEmail("orders@target.com", []byte("Subject: receipt\r\n\r\nnothing here\r\n")); err == nil || !strings.Contains(err.Error(), "BadRequest") {
+		t.Errorf("Result error: %v, want the validation error", err)
+	}
+}
+
+func TestSenderNotTakenFromJSON(t *testing.T) {
+	db := store.NewMemoryDatabase()
+	handler := NewReceiptHandler(db)
+
+	body := `{"retailer": "Target", "purchaseDate": "2022-01-01", "purchaseTime": "13:01", "sender": "spoof@example.com",
+		"items": [{"shortDescription": "Gatorade", "price": "2.25"}], "total": "2.25"}`
+	request := httptest.NewRequest("POST", "/receipts/process", strings.NewReader(body))
+	responseRecorder := httptest.NewRecorder()
+	handler.CreateReceiptHandler(responseRecorder, request)
+
+	receipts := db.ListReceipts()
+	if responseRecorder.Code != http.StatusOK || len(receipts) != 1 || receipts[0].Sender != "" {
+		t.Errorf("Result status %d receipts %+v, want stored without a sender", responseRecorder.Code, receipts)
+	}
+}
@@ -1,52 +1,123 @@
 package handlers
 
 import (
+	"bytes"
 	"encoding/json"
 	"errors"
+	"fmt"
 	"io"
+	"math"
 	"net/http"
 	"regexp"
+	"strconv"
 	"strings"
+	"sync"
+	"sync/atomic"
 	"time"
 
 	"github.com/google/uuid"
 	"github.com/gorilla/mux"
 
+	"receipt-processor-challenge-jase180/internal/codec"
+	"receipt-processor-challenge-jase180/internal/email"
 	"receipt-processor-challenge-jase180/internal/models"
+	"receipt-processor-challenge-jase180/internal/parser"
 	rules "receipt-processor-challenge-jase180/internal/services"
 	"receipt-processor-challenge-jase180/internal/store"
 )
 
-// A struct that creates connection to database
+// userIDHeader is the request header used to tie a receipt to a user when the body has no userId
+const userIDHeader = "X-User-ID"
+
+// regexUserID matches user IDs, any non white space string like the "^\\S+$" ID pattern in api.yml
+var regexUserID = regexp.MustCompile(`^\S+$`)
+
+// A struct that creates connection to database and holds the rules configuration for scoring
 type ReceiptHandler struct {
-	Database *store.MemoryDatabase
+	Database   *store.MemoryDatabase
+	Rules      rules.Config
+	Templates  []parser.Template // Layouts for parsing plain-text receipts, heuristics only if empty
+	Extractors []email.Extractor // Retailer extractors for emailed receipts
+	// Rejects unknown and duplicate receipt fields rather than warning about them, ?strict= overrides it per request
+	StrictDecoding bool
+	MaxBodyBytes   int64  // Largest receipt body of any format, text or email
+	MaxImportBytes int64  // Largest CSV import
+	Version        string // Build version reported by /status
+	StoreBackend   string // Store backend name reported by /status
+	AdminToken     string // Bearer token for admin endpoints, refused if empty
+
+	awardLock    sync.Mutex  // serializes awarding and storing receipts so limits and tiers see every earlier receipt
+	started      time.Time   // when the handler was created, for uptime
+	shuttingDown atomic.Bool // set when graceful shutdown begins so readiness fails
+	metrics      *serverMetrics
 }
 
-// NewReceiptHandler creates a new handler that connects to existing database
+// NewReceiptHandler creates a new handler that connects to existing database with the default rules configuration, extractors and limits
+// The database reports its operation latency to the handler's metrics
 // Panic because database is critical.  Error less preferred because webservice requires database
 func NewReceiptHandler(db *store.MemoryDatabase) *ReceiptHandler {
 	if db == nil {
 		panic("Database does not exist.  Cannot initialize.")
 	}
-	return &ReceiptHandler{Database: db}
+	h := &ReceiptHandler{Database: db, Rules: rules.DefaultConfig(), Extractors: email.DefaultExtractors(),
+		MaxBodyBytes: 1 << 20, MaxImportBytes: 10 << 20, Version: "dev", StoreBackend: "memory", started: time.Now(),
+		metrics: newServerMetrics(db)}
+	db.Observe = h.metrics.observeStore
+	return h
+}
+
+// ReceiptResponse is the response for POST /receipts/process
+type ReceiptResponse struct {
+	ID       string              `json:"id"`                 // Generated receipt ID
+	Warnings []codec.DecodeIssue `json:"warnings,omitempty"` // Unknown and duplicate fields that were let through, never in strict mode
+}
+
+// PointsResponse is the response for GET /receipts/{id}/points, cap fields only appear when points were capped
+type PointsResponse struct {
+	Points       int    `json:"points"`                 // Points awarded to the receipt
+	CappedPoints int    `json:"cappedPoints,omitempty"` // Points removed by earning limits
+	CapReason    string `json:"capReason,omitempty"`    // Limit that capped the points
 }
 
 // helper function that takes errors and encode it into a JSON
+// Sent as XML or MessagePack instead when the Negotiate middleware picked one from the Accept header,
+// converted from the JSON so every format has the same fields
 func sendJSON(w http.ResponseWriter, message interface{}, code int) {
-	w.Header().Set("Content-Type", "application/json; charset=utf-8")
-	w.WriteHeader(code)
+	format := responseFormat(w)
 
 	// Marshal the message first to catch errors and avoid sending faulty JSON
 	jsonMessage, err := json.Marshal(message)
+	if err == nil {
+		switch format {
+		case codec.XML:
+			jsonMessage, err = codec.JSONToXML(jsonMessage, "response")
+		case codec.MsgPack:
+			jsonMessage, err = codec.JSONToMsgPack(jsonMessage)
+		}
+	}
 	if err != nil {
 		http.Error(w, `{"message": "JSON marshaling error"}`, http.StatusInternalServerError)
 		return
 	}
 
+	w.Header().Set("Content-Type", format.ContentType())
+	w.WriteHeader(code)
+
 	// Write the JSON response
 	w.Write(jsonMessage)
 }
 
+// helper function that decodes a small JSON request body into v, used by endpoints other than receipt submission
+// Limits size like CreateReceiptHandler and rejects unknown fields since these bodies are simple commands
+func decodeJSONBody(w http.ResponseWriter, r *http.Request, v interface{}) error {
+	r.Body = http.MaxBytesReader(w, r.Body, 1<<16) // 64 KB limit, bodies are a few fields
+	defer r.Body.Close()
+
+	decoder := json.NewDecoder(r.Body)
+	decoder.DisallowUnknownFields()
+	return decoder.Decode(v)
+}
+
 // GetReceiptHandler takes a GET request with /receipts/{id}/points endpoint, where dynamic id is a UUID for a receipt
 // Validates JSON format, ID format, and if ID is in database
 func (h *ReceiptHandler) GetReceiptHandler(w http.ResponseWriter, r *http.Request) {
@@ -74,67 +145,179 @@ func (h *ReceiptHandler) GetReceiptHandler(w http.ResponseWriter, r *http.Reques
 		return
 	}
 
-	// Calculate points by calling rules.go
-	points := rules.CalculatePoints(receipt)
+	// Points awarded at submission, or calculated by calling rules.go for receipts without an award
+	response := PointsResponse{Points: h.receiptPoints(receipt)}
 
-	// Create calculated points response
-	response := map[string]int{
-		"points": points,
+	// Explain any reduction from earning limits so support can answer why
+	if receipt.Award != nil {
+		response.CappedPoints = receipt.Award.CappedPoints
+		response.CapReason = receipt.Award.CapReason
 	}
 
 	// Set status to 200 OK meaning success and send
 	sendJSON(w, response, http.StatusOK)
 }
 
+// GetReceiptDetailsHandler takes a GET request with /receipts/{id} endpoint and returns the stored receipt
+// Includes the item categories and award assigned at submission, validates like GetReceiptHandler
+func (h *ReceiptHandler) GetReceiptDetailsHandler(w http.ResponseWriter, r *http.Request) {
+	id := mux.Vars(r)["id"]
+
+	// Check valid UUID format (generated from google/uuid)
+	if _, err := uuid.Parse(id); err != nil {
+		sendJSON(w, map[string]string{"error": "BadRequest: Invalid ID format"}, http.StatusBadRequest)
+		return
+	}
+
+	// Look up ID and raise error if no ID found
+	receipt, err := h.Database.GetReceiptByID(id)
+	if err != nil {
+		sendJSON(w, map[string]string{"error": "No receipt found for that ID"}, http.StatusNotFound) // 404 response
+		return
+	}
+
+	sendJSON(w, receipt, http.StatusOK)
+}
+
 // CreateReceiptHandler validates incoming POST JSON object and writes to in memory database
 // Validations include JSON, receipt structure, DDoS and resource exhaustion prevention
 // Assumptions: Identical duplicate receipts allowed
 func (h *ReceiptHandler) CreateReceiptHandler(w http.ResponseWriter, r *http.Request) {
-	// Size limiting to prevent DoS and resource exhaustion
-	r.Body = http.MaxBytesReader(w, r.Body, 1<<20) // 1 MB limit
-	defer r.Body.Close()                           // Proper clean up
+	receipt, warnings, ok := h.decodeReceipt(w, r)
+	if !ok {
+		return
+	}
 
-	// Read the request body before decoding to debug
-	bodyBytes, err := io.ReadAll(r.Body)
+	receipt, err := h.storeReceipt(receipt)
 	if err != nil {
-		sendJSON(w, map[string]string{"error": "Invalid request body"}, http.StatusBadRequest)
+		sendJSON(w, map[string]string{"error": err.Error()}, http.StatusInternalServerError) // 500 response
 		return
 	}
 
+	// Create new receipt ID response
+	response := ReceiptResponse{ID: receipt.ID, Warnings: warnings}
+
+	// Set status to 200 OK meaning success and send
+	sendJSON(w, response, http.StatusOK)
+}
+
+// helper function that gives a valid receipt its ID, canonical retailer, categories and award, stores it and credits the user
+// Shared by every endpoint that submits receipts, errors are database failures
+func (h *ReceiptHandler) storeReceipt(receipt models.Receipt) (models.Receipt, error) {
+	// Hold award lock from awarding points until the points are credited
+	h.awardLock.Lock()
+	defer h.awardLock.Unlock()
+
+	// Generate new UUID for receipt, match retailer, classify items and award points, now receipt model struct completely filled
+	receipt.ID = uuid.New().String()
+	receipt = h.prepareReceipt(receipt)
+	award := h.awardPoints(receipt)
+	receipt.Award = &award
+
+	// Add receipt to memory database, crediting its user in the same operation so a ledger failure stores nothing
+	var err error
+	if receipt.UserID == "" {
+		err = h.Database.AddReceipt(receipt)
+	} else {
+		_, err = h.Database.AddReceiptWithEarning(receipt, models.LedgerEntry{
+			ID:        uuid.New().String(),
+			UserID:    receipt.UserID,
+			Type:      models.LedgerEarn,
+			Points:    award.Points,
+			ReceiptID: receipt.ID,
+			CreatedAt: time.Now().UTC(),
+		})
+	}
+	if err != nil {
+		return models.Receipt{}, errors.New("Database failure, could not create receipt")
+	}
+	h.recordRulePoints(h.Rules.CalculateBreakdown(receipt))
+	return receipt, nil
+}
+
+// helper function that reads, decodes and validates a receipt from the request body, writing the error response if invalid
+// Shared by every endpoint that accepts a receipt so they all validate the same way
+// Returns the decoding warnings for the response, unknown and duplicate fields are errors instead in strict mode
+func (h *ReceiptHandler) decodeReceipt(w http.ResponseWriter, r *http.Request) (models.Receipt, []codec.DecodeIssue, bool) {
+	// Size limiting to prevent DoS and resource exhaustion
+	r.Body = http.MaxBytesReader(w, r.Body, h.MaxBodyBytes) // 1 MB unless configured
+	defer r.Body.Close()                                    // Proper clean up
+
+	// Strict decoding is the handler's setting unless the request asks with ?strict=true or false
+	strict := h.StrictDecoding
+	if value, err := strconv.ParseBool(r.URL.Query().Get("strict")); err == nil {
+		strict = value
+	}
+
 	// Create empty receipt struct
 	var receipt models.Receipt
 
-	// Unmarshal JSON into the Receipt struct, only ID missing now, error if invalid JSON
-	err = json.Unmarshal(bodyBytes, &receipt)
-	if err != nil {
-		sendJSON(w, map[string]string{"error": "Invalid JSON"}, http.StatusBadRequest) // 400 response
-		return
+	// JSON streams straight from the body, XML and MessagePack bodies are converted to JSON first
+	// so every format decodes and validates the same way
+	// XML is checked for unknown and repeated elements while converting, with offsets into the XML
+	body, format := io.Reader(r.Body), "JSON"
+	var formatWarnings []codec.DecodeIssue
+	if codec.RequestFormat(r.Header.Get("Content-Type")) != codec.JSON {
+		bodyBytes, err := io.ReadAll(r.Body)
+		if err != nil {
+			sendJSON(w, map[string]string{"error": "Invalid request body"}, http.StatusBadRequest)
+			return models.Receipt{}, nil, false
+		}
+		bodyBytes, format, formatWarnings, err = requestJSON(r, bodyBytes, &receipt, strict)
+		var issue codec.DecodeIssue
+		if errors.As(err, &issue) {
+			sendJSON(w, map[string]string{"error": "Invalid " + format + ": " + issue.Error()}, http.StatusBadRequest) // 400 response
+			return models.Receipt{}, nil, false
+		}
+		if err != nil {
+			sendJSON(w, map[string]string{"error": "Invalid " + format}, http.StatusBadRequest) // 400 response
+			return models.Receipt{}, nil, false
+		}
+		body = bytes.NewReader(bodyBytes)
 	}
 
-	// Validate JSON contains required fields using helper function
-	if err := validateReceipt(receipt); err != nil {
-		sendJSON(w, map[string]string{"error": err.Error()}, http.StatusBadRequest) // 400
-		return
+	// Decode into the Receipt struct, only ID missing now, error with the field and byte offset if invalid
+	warnings, err := codec.DecodeJSON(body, &receipt, strict)
+	var issue codec.DecodeIssue
+	if errors.As(err, &issue) {
+		if format != "JSON" {
+			issue.Offset = 0 // offsets are into the converted JSON, meaningless to the client
+		}
+		sendJSON(w, map[string]string{"error": "Invalid " + format + ": " + issue.Error()}, http.StatusBadRequest) // 400 response
+		return models.Receipt{}, nil, false
+	}
+	if err != nil {
+		sendJSON(w, map[string]string{"error": "Invalid request body"}, http.StatusBadRequest)
+		return models.Receipt{}, nil, false
+	}
+	if format != "JSON" {
+		for i := range warnings {
+			warnings[i].Offset = 0
+		}
+		warnings = append(formatWarnings, warnings...)
 	}
 
-	// Generate new UUID for receipt, now receipt model struct completely filled
-	newID := uuid.New().String()
-	receipt.ID = newID
+	// ID, award, canonical retailer, sender and item categories are set by the server, never taken from the client
+	receipt.ID = ""
+	receipt.Award = nil
+	receipt.RetailerCanonical = ""
+	receipt.Sender = ""
+	for i := range receipt.Items {
+		receipt.Items[i].Category = ""
+	}
 
-	// Add receipt to memory database, and error if failure
-	createErr := h.Database.AddReceipt(receipt)
-	if createErr != nil {
-		sendJSON(w, map[string]string{"error": "Database failure, could not create receipt"}, http.StatusInternalServerError) // 500 response
-		return
+	// Fall back to header-derived identity when body has no userId, body wins if both given
+	if receipt.UserID == "" {
+		receipt.UserID = strings.TrimSpace(r.Header.Get(userIDHeader))
 	}
 
-	// Create new receipt ID response
-	response := map[string]string{
-		"id": newID,
+	// Validate JSON contains required fields using helper function
+	if err := h.validate(receipt); err != nil {
+		sendJSON(w, map[string]string{"error": err.Error()}, http.StatusBadRequest) // 400
+		return models.Receipt{}, nil, false
 	}
 
-	// Set status to 200 OK meaning success and send
-	sendJSON(w, response, http.StatusOK)
+	return receipt, warnings, true
 }
 
 // Helper function verifying Receipt structure and data type fits openAPI
@@ -157,6 +340,16 @@ func validateReceipt(receipt models.Receipt) error {
 	if strings.TrimSpace(receipt.Total) == "" {
 		return errors.New("BadRequest: The receipt is invalid. Total string is empty")
 	}
+	//check if optional userId has no white space, same pattern as api.yml IDs
+	if receipt.UserID != "" && !regexUserID.MatchString(receipt.UserID) {
+		return errors.New("BadRequest: The receipt is invalid. User ID format is incorrect")
+	}
+	//check if optional timezone is an IANA zone or UTC offset
+	if receipt.Timezone != "" {
+		if _, err := rules.LoadZone(receipt.Timezone); err != nil {
+			return errors.New("BadRequest: The receipt is invalid. Timezone is not an IANA zone or UTC offset")
+		}
+	}
 	//check if item has at least 1 item
 	if len(receipt.Items) == 0 {
 		return errors.New("BadRequest: The receipt is invalid. no items found")
@@ -182,20 +375,147 @@ func validateReceipt(receipt models.Receipt) error {
 		return errors.New("BadRequest: The receipt is invalid. Receipt time format is incorrect")
 	}
 
-	// Check Total format - 2 digits, non negative (assume 0 dollars allowed)
-	var regexTotalDollar = regexp.MustCompile(`^\d+\.\d{2}$`)
-	if !regexTotalDollar.MatchString(receipt.Total) {
-		return errors.New("BadRequest: The receipt is invalid. Receipt Total format is incorrect")
+	// Check optional currency is an ISO 4217 code, amounts without one are dollars
+	if _, ok := rules.MinorUnits(receipt.Currency); !ok {
+		return errors.New("BadRequest: The receipt is invalid. Currency is not an ISO 4217 code")
 	}
 
-	// Check item.Price format - 2 digits, non negative (assume 0 dollars allowed)
-	var regexItemPriceDollar = regexp.MustCompile(`^\d+\.\d{2}$`)
+	// Check Total format - digits of the currency's minor units, 2 for dollars, non negative (assume 0 dollars allowed)
+	if _, err := parseAmount(receipt.Total, receipt.Currency, "Receipt Total"); err != nil {
+		return err
+	}
+
+	// Check item.Price format - same precision as total, non negative (assume 0 dollars allowed)
 	for _, item := range receipt.Items {
-		if !regexItemPriceDollar.MatchString(item.Price) {
-			return errors.New("BadRequest: The receipt is invalid. Item price format is incorrect")
+		if _, err := parseAmount(item.Price, receipt.Currency, "Item price"); err != nil {
+			return err
 		}
 	}
 
+	// Optional finance fields, absent in api.yml payloads
+	if err := validateReceiptDetails(receipt); err != nil {
+		return err
+	}
+
 	// If no errors
 	return nil
 }
+
+// regexQuantity is a positive quantity, whole or up to 3 decimals for weighed items
+var regexQuantity = regexp.MustCompile(`^\d+(\.\d{1,3})?$`)
+
+// Largest amount in minor units, e.g. $10,000,000.00, and largest item quantity accepted
+// Keeps sums of amounts and quantity thousandths times unit price well inside int
+const (
+	maxAmountMinor  = 1_000_000_000
+	maxItemQuantity = 1_000_000
+)
+
+// parseAmount converts an amount with the currency's precision to minor units, rejecting amounts above maxAmountMinor
+// field names the amount in the error, e.g. "Tip"
+func parseAmount(amount string, currency string, field string) (int, error) {
+	if !rules.ValidAmount(amount, currency) {
+		return 0, fmt.Errorf("BadRequest: The receipt is invalid. %s format is incorrect", field)
+	}
+	minor, err := strconv.ParseInt(strings.Replace(amount, ".", "", 1), 10, 64)
+	if err != nil || minor > maxAmountMinor {
+		return 0, fmt.Errorf("BadRequest: The receipt is invalid. %s is above the maximum amount", field)
+	}
+	return int(minor), nil
+}
+
+// Payment methods accepted in paymentMethod
+var paymentMethods = map[string]bool{
+	models.PaymentCash: true, models.PaymentCredit: true, models.PaymentDebit: true,
+	models.PaymentGiftCard: true, models.PaymentMobile: true, models.PaymentOther: true,
+}
+
+// Helper function verifying the optional subtotal, tax, discount, tip, payment, location and item quantity fields
+// Amounts use the receipt currency's precision. When a subtotal is given the receipt must reconcile: subtotal + taxes + tip - discounts = total
+// When a unit price is given the item price must be quantity (1 if missing) times unit price, rounded to the minor unit
+func validateReceiptDetails(receipt models.Receipt) error {
+	adjustments := 0
+	if receipt.Tip != "" {
+		tip, err := parseAmount(receipt.Tip, receipt.Currency, "Tip")
+		if err != nil {
+			return err
+		}
+		adjustments += tip
+	}
+	for _, tax := range receipt.Taxes {
+		if strings.TrimSpace(tax.Name) == "" {
+			return errors.New("BadRequest: The receipt is invalid. Tax name string is empty")
+		}
+		amount, err := parseAmount(tax.Amount, receipt.Currency, "Tax amount")
+		if err != nil {
+			return err
+		}
+		adjustments += amount
+	}
+	for _, discount := range receipt.Discounts {
+		if strings.TrimSpace(discount.Description) == "" {
+			return errors.New("BadRequest: The receipt is invalid. Discount description string is empty")
+		}
+		amount, err := parseAmount(discount.Amount, receipt.Currency, "Discount amount")
+		if err != nil {
+			return err
+		}
+		adjustments -= amount
+	}
+	if receipt.Subtotal != "" {
+		subtotal, err := parseAmount(receipt.Subtotal, receipt.Currency, "Subtotal")
+		if err != nil {
+			return err
+		}
+		total, err := parseAmount(receipt.Total, receipt.Currency, "Receipt Total")
+		if err != nil {
+			return err
+		}
+		if subtotal+adjustments != total {
+			return errors.New("BadRequest: The receipt is invalid. Subtotal, taxes, discounts and tip do not add up to total")
+		}
+	}
+
+	if receipt.PaymentMethod != "" && !paymentMethods[receipt.PaymentMethod] {
+		return errors.New("BadRequest: The receipt is invalid. Payment method is not recognized")
+	}
+
+	if location := receipt.Location; location != nil {
+		if (location.Latitude == nil) != (location.Longitude == nil) {
+			return errors.New("BadRequest: The receipt is invalid. Location needs both latitude and longitude")
+		}
+		if location.Latitude != nil && (*location.Latitude < -90 || *location.Latitude > 90 || *location.Longitude < -180 || *location.Longitude > 180) {
+			return errors.New("BadRequest: The receipt is invalid. Location coordinates are out of range")
+		}
+	}
+
+	for _, item := range receipt.Items {
+		// Quantity in thousandths so weighed quantities multiply exactly
+		quantityThousandths := 1000
+		if item.Quantity != "" {
+			quantity, err := strconv.ParseFloat(item.Quantity, 64)
+			if !regexQuantity.MatchString(item.Quantity) || err != nil || quantity <= 0 {
+				return errors.New("BadRequest: The receipt is invalid. Item quantity format is incorrect")
+			}
+			if quantity > maxItemQuantity {
+				return errors.New("BadRequest: The receipt is invalid. Item quantity is above the maximum quantity")
+			}
+			quantityThousandths = int(math.Round(quantity * 1000))
+		}
+		if item.UnitPrice == "" {
+			continue
+		}
+		unitPrice, err := parseAmount(item.UnitPrice, receipt.Currency, "Item unit price")
+		if err != nil {
+			return err
+		}
+		price, err := parseAmount(item.Price, receipt.Currency, "Item price")
+		if err != nil {
+			return err
+		}
+		if (quantityThousandths*unitPrice+500)/1000 != price {
+			return errors.New("BadRequest: The receipt is invalid. Item price is not quantity times unit price")
+		}
+	}
+	return nil
+}
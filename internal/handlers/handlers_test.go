@@ -5,12 +5,14 @@ import (
 	"encoding/json"
 	"net/http"
 	"net/http/httptest"
+	"strings"
 	"testing"
 
 	"github.com/google/uuid"
 	"github.com/gorilla/mux"
 
 	"receipt-processor-challenge-jase180/internal/models"
+	rules "receipt-processor-challenge-jase180/internal/services"
 	"receipt-processor-challenge-jase180/internal/store"
 )
 
@@ -280,6 +282,260 @@ func TestCreateReceiptHandler(t *testing.T) {
 			},
 			responseCode: http.StatusBadRequest,
 			wantID:       false,
+		}, {
+			name: "Bad user ID format (white space)",
+			receipt: models.Receipt{
+				Retailer:     "Target",
+				PurchaseDate: "2022-01-01",
+				PurchaseTime: "13:01",
+				Items: []models.Item{
+					{ShortDescription: "Mountain Dew 12PK", Price: "6.49"},
+				},
+				Total:  "6.49",
+				UserID: "user 1", // white space here
+			},
+			responseCode: http.StatusBadRequest,
+			wantID:       false,
+		}, {
+			name: "Bad timezone",
+			receipt: models.Receipt{
+				Retailer:     "Target",
+				PurchaseDate: "2022-01-01",
+				PurchaseTime: "13:01",
+				Items: []models.Item{
+					{ShortDescription: "Mountain Dew 12PK", Price: "6.49"},
+				},
+				Total:    "6.49",
+				Timezone: "Mars/Olympus_Mons", // not a zone here
+			},
+			responseCode: http.StatusBadRequest,
+			wantID:       false,
+		}, {
+			name: "Valid timezone offset",
+			receipt: models.Receipt{
+				Retailer:     "Target",
+				PurchaseDate: "2022-01-01",
+				PurchaseTime: "13:01",
+				Items: []models.Item{
+					{ShortDescription: "Mountain Dew 12PK", Price: "6.49"},
+				},
+				Total:    "6.49",
+				Timezone: "-05:00",
+			},
+			responseCode: http.StatusOK,
+			wantID:       true,
+		}, {
+			name: "Valid finance fields",
+			receipt: models.Receipt{
+				Retailer:     "Target",
+				PurchaseDate: "2022-01-01",
+				PurchaseTime: "13:01",
+				Items: []models.Item{
+					{ShortDescription: "Mountain Dew 12PK", Price: "6.49"},
+				},
+				Total:         "7.50",
+				Subtotal:      "6.49",
+				Taxes:         []models.TaxLine{{Name: "Sales tax", Amount: "0.51"}},
+				Discounts:     []models.Discount{{Description: "Coupon", Amount: "0.50"}},
+				Tip:           "1.00",
+				PaymentMethod: models.PaymentDebit,
+				Currency:      "USD",
+				Location:      &models.Location{Address: "1 Main St", StoreNumber: "1234"},
+			},
+			responseCode: http.StatusOK,
+			wantID:       true,
+		}, {
+			name: "Finance fields do not add up",
+			receipt: models.Receipt{
+				Retailer:     "Target",
+				PurchaseDate: "2022-01-01",
+				PurchaseTime: "13:01",
+				Items: []models.Item{
+					{ShortDescription: "Mountain Dew 12PK", Price: "6.49"},
+				},
+				Total:    "7.50",
+				Subtotal: "6.49",
+				Taxes:    []models.TaxLine{{Name: "Sales tax", Amount: "0.52"}}, // off by a cent here
+			},
+			responseCode: http.StatusBadRequest,
+			wantID:       false,
+		}, {
+			name: "Bad payment method",
+			receipt: models.Receipt{
+				Retailer:     "Target",
+				PurchaseDate: "2022-01-01",
+				PurchaseTime: "13:01",
+				Items: []models.Item{
+					{ShortDescription: "Mountain Dew 12PK", Price: "6.49"},
+				},
+				Total:         "7.50",
+				PaymentMethod: "bitcoin",
+			},
+			responseCode: http.StatusBadRequest,
+			wantID:       false,
+		}, {
+			name: "Bad discount amount",
+			receipt: models.Receipt{
+				Retailer:     "Target",
+				PurchaseDate: "2022-01-01",
+				PurchaseTime: "13:01",
+				Items: []models.Item{
+					{ShortDescription: "Mountain Dew 12PK", Price: "6.49"},
+				},
+				Total:     "7.50",
+				Discounts: []models.Discount{{Description: "Coupon", Amount: "-0.50"}},
+			},
+			responseCode: http.StatusBadRequest,
+			wantID:       false,
+		}, {
+			name: "Latitude without longitude",
+			receipt: models.Receipt{
+				Retailer:     "Target",
+				PurchaseDate: "2022-01-01",
+				PurchaseTime: "13:01",
+				Items: []models.Item{
+					{ShortDescription: "Mountain Dew 12PK", Price: "6.49"},
+				},
+				Total:    "7.50",
+				Location: &models.Location{Latitude: new(float64)},
+			},
+			responseCode: http.StatusBadRequest,
+			wantID:       false,
+		}, {
+			name: "Valid quantity and unit price",
+			receipt: models.Receipt{
+				Retailer:     "Target",
+				PurchaseDate: "2022-01-01",
+				PurchaseTime: "13:01",
+				Items: []models.Item{
+					{ShortDescription: "Gatorade", Price: "6.75", Quantity: "3", UnitPrice: "2.25"},
+				},
+				Total: "6.75",
+			},
+			responseCode: http.StatusOK,
+			wantID:       true,
+		}, {
+			name: "Weighed quantity rounds to the cent",
+			receipt: models.Receipt{
+				Retailer:     "Target",
+				PurchaseDate: "2022-01-01",
+				PurchaseTime: "13:01",
+				Items: []models.Item{
+					{ShortDescription: "Bananas", Price: "0.94", Quantity: "1.25", UnitPrice: "0.75"},
+				},
+				Total: "0.94",
+			},
+			responseCode: http.StatusOK,
+			wantID:       true,
+		}, {
+			name: "Price is not quantity times unit price",
+			receipt: models.Receipt{
+				Retailer:     "Target",
+				PurchaseDate: "2022-01-01",
+				PurchaseTime: "13:01",
+				Items: []models.Item{
+					{ShortDescription: "Gatorade", Price: "2.25", Quantity: "3", UnitPrice: "2.25"},
+				},
+				Total: "2.25",
+			},
+			responseCode: http.StatusBadRequest,
+			wantID:       false,
+		}, {
+			name: "Bad quantity",
+			receipt: models.Receipt{
+				Retailer:     "Target",
+				PurchaseDate: "2022-01-01",
+				PurchaseTime: "13:01",
+				Items: []models.Item{
+					{ShortDescription: "Gatorade", Price: "2.25", Quantity: "0"},
+				},
+				Total: "2.25",
+			},
+			responseCode: http.StatusBadRequest,
+			wantID:       false,
+		}, {
+			name: "Quantity above the maximum",
+			receipt: models.Receipt{
+				Retailer:     "Target",
+				PurchaseDate: "2022-01-01",
+				PurchaseTime: "13:01",
+				Items: []models.Item{
+					{ShortDescription: "Gatorade", Price: "0.00", Quantity: "1000000000000000000000000", UnitPrice: "0.00"}, // would overflow thousandths
+				},
+				Total: "0.00",
+			},
+			responseCode: http.StatusBadRequest,
+			wantID:       false,
+		}, {
+			name: "Discount above the maximum amount",
+			receipt: models.Receipt{
+				Retailer:     "Target",
+				PurchaseDate: "2022-01-01",
+				PurchaseTime: "13:01",
+				Items: []models.Item{
+					{ShortDescription: "Mountain Dew 12PK", Price: "6.49"},
+				},
+				Total:     "6.49",
+				Subtotal:  "6.49",
+				Discounts: []models.Discount{{Description: "Coupon", Amount: "99999999999999999999999.00"}}, // not read as 0
+			},
+			responseCode: http.StatusBadRequest,
+			wantID:       false,
+		}, {
+			name: "Total above the maximum amount",
+			receipt: models.Receipt{
+				Retailer:     "Target",
+				PurchaseDate: "2022-01-01",
+				PurchaseTime: "13:01",
+				Items: []models.Item{
+					{ShortDescription: "Mountain Dew 12PK", Price: "6.49"},
+				},
+				Total: "10000000.01",
+			},
+			responseCode: http.StatusBadRequest,
+			wantID:       false,
+		}, {
+			name: "Yen without minor units",
+			receipt: models.Receipt{
+				Retailer:     "FamilyMart",
+				PurchaseDate: "2022-01-01",
+				PurchaseTime: "13:01",
+				Items: []models.Item{
+					{ShortDescription: "Onigiri", Price: "150"},
+				},
+				Total:    "150",
+				Currency: "JPY",
+			},
+			responseCode: http.StatusOK,
+			wantID:       true,
+		}, {
+			name: "Yen with cents",
+			receipt: models.Receipt{
+				Retailer:     "FamilyMart",
+				PurchaseDate: "2022-01-01",
+				PurchaseTime: "13:01",
+				Items: []models.Item{
+					{ShortDescription: "Onigiri", Price: "150.00"},
+				},
+				Total:    "150.00",
+				Currency: "JPY",
+			},
+			responseCode: http.StatusBadRequest,
+			wantID:       false,
+		}, {
+			name: "Unknown currency",
+			receipt: models.Receipt{
+				Retailer:     "FamilyMart",
+				PurchaseDate: "2022-01-01",
+				PurchaseTime: "13:01",
+				Items: []models.Item{
+					{ShortDescription: "Onigiri", Price: "1.50"},
+				},
+				Total:    "1.50",
+				Currency: "XYZ",
+			},
+			responseCode: http.StatusBadRequest,
+			wantID:       false,
 		},
 	}
 
@@ -412,3 +668,183 @@ func TestGetReceiptHandler(t *testing.T) {
 	}
 
 }
+
+func TestGetReceiptDetailsHandler(t *testing.T) {
+	handler := NewReceiptHandler(store.NewMemoryDatabase())
+
+	// Submit the README Target receipt, client categories are ignored
+	body := `{"retailer": "Target", "purchaseDate": "2022-01-01", "purchaseTime": "13:01", "total": "35.35", "items": [
+		{"shortDescription": "Mountain Dew 12PK", "price": "6.49", "category": "household"},
+		{"shortDescription": "Emils Cheese Pizza", "price": "12.25"},
+		{"shortDescription": "Knorr Creamy Chicken", "price": "1.26"},
+		{"shortDescription": "Doritos Nacho Cheese", "price": "3.35"},
+		{"shortDescription": "   Klarbrunn 12-PK 12 FL OZ  ", "price": "12.00"}]}`
+	created := sendToHandler(handler.CreateReceiptHandler, http.MethodPost, body, nil)
+	if created.Code != http.StatusOK {
+		t.Fatalf("Result status: %d, want: %d", created.Code, http.StatusOK)
+	}
+	var createdResponse map[string]string
+	json.Unmarshal(created.Body.Bytes(), &createdResponse)
+
+	tests := []struct {
+		name           string
+		receiptID      string
+		responseCode   int
+		wantCategories []string // categories of the items in order if expect success
+	}{
+		{"Valid ID and receipt", createdResponse["id"], http.StatusOK, []string{"beverages", "frozen", "pantry", "snacks", "beverages"}},
+		{"Valid ID and no such receipt", uuid.NewString(), http.StatusNotFound, nil},
+		{"Invalid ID", "ABCDEFG", http.StatusBadRequest, nil},
+	}
+	for _, testCase := range tests {
+		t.Run(testCase.name, func(t *testing.T) {
+			response := sendToHandler(handler.GetReceiptDetailsHandler, http.MethodGet, "", map[string]string{"id": testCase.receiptID})
+			if response.Code != testCase.responseCode {
+				t.Fatalf("Result status: %d, want: %d", response.Code, testCase.responseCode)
+			}
+			if testCase.wantCategories == nil {
+				return
+			}
+
+			var receipt models.Receipt
+			if err := json.Unmarshal(response.Body.Bytes(), &receipt); err != nil {
+				t.Fatalf("Error during test parsing successful result JSON: %v", err)
+			}
+			if receipt.ID != testCase.receiptID || receipt.Award == nil {
+				t.Errorf("Result was receipt %q with award %v; want receipt %q with award", receipt.ID, receipt.Award, testCase.receiptID)
+			}
+			for i, item := range receipt.Items {
+				if item.Category != testCase.wantCategories[i] {
+					t.Errorf("Item %q category was %q; want %q", item.ShortDescription, item.Category, testCase.wantCategories[i])
+				}
+			}
+		})
+	}
+}
+
+func TestCanonicalRetailerOnReceipts(t *testing.T) {
+	handler := NewReceiptHandler(store.NewMemoryDatabase())
+	handler.Rules.Retailers.Registry = []rules.Retailer{{Name: "M&M Corner Market"}}
+
+	tests := []struct {
+		name          string
+		retailer      string
+		wantCanonical string
+	}{
+		{"Registry spelling", "M & M CORNER MKT", "M&M Corner Market"},
+		{"Client canonical ignored", "Costco", ""},
+	}
+	for _, testCase := range tests {
+		t.Run(testCase.name, func(t *testing.T) {
+			body := `{"retailer": "` + testCase.retailer + `", "retailerCanonical": "Target", "purchaseDate": "2022-01-01",
+				"purchaseTime": "13:01", "total": "6.49", "items": [{"shortDescription": "Mountain Dew 12PK", "price": "6.49"}]}`
+			created := sendToHandler(handler.CreateReceiptHandler, http.MethodPost, body, nil)
+			var createdResponse map[string]string
+			json.Unmarshal(created.Body.Bytes(), &createdResponse)
+
+			receipt, err := handler.Database.GetReceiptByID(createdResponse["id"])
+			if err != nil {
+				t.Fatalf("Receipt was not stored: %v", err)
+			}
+			if receipt.Retailer != testCase.retailer || receipt.RetailerCanonical != testCase.wantCanonical {
+				t.Errorf("Result was %q and %q; want %q and %q", receipt.Retailer, receipt.RetailerCanonical, testCase.retailer, testCase.wantCanonical)
+			}
+		})
+	}
+}
+
+func TestCreateReceiptDecoding(t *testing.T) {
+	// Pepsi receipt from the README with a typo or duplicate spliced in at the start
+	receipt := `"purchaseDate": "2022-01-02", "purchaseTime": "08:13", "total": "1.25", "items": [{"shortDescription": "Pepsi - 12-oz", "price": "1.25"}]}`
+	xmlReceipt := `<purchaseDate>2022-01-02</purchaseDate><purchaseTime>08:13</purchaseTime><total>1.25</total>` +
+		`<items><item><shortDescription>Pepsi - 12-oz</shortDescription><price>1.25</price></item></items></receipt>`
+
+	tests := []struct {
+		name         string
+		strict       bool
+		url          string
+		body         string
+		responseCode int
+		wantError    string // exact error, empty if expect success
+		wantWarnings int
+	}{
+		{"Lenient warns about unknown field", false, "/receipts/process", `{"retailer": "Walgreens", "store": 7, ` + receipt, http.StatusOK, "", 1},
+		{"Strict rejects unknown field", true, "/receipts/process", `{"retailer": "Walgreens", "store": 7, ` + receipt, http.StatusBadRequest,
+			"Invalid JSON: store at byte 26: unknown field", 0},
+		{"Strict per request", false, "/receipts/process?strict=true", `{"retailer": "Walgreens", "retailer": "Target", ` + receipt, http.StatusBadRequest,
+			"Invalid JSON: retailer at byte 26: duplicate field", 0},
+		{"Lenient per request", true, "/receipts/process?strict=false", `{"retailer": "Walgreens", "Retailer": "Target", ` + receipt, http.StatusOK, "", 1},
+		{"Typo in field case", true, "/receipts/process", `{"retailer": "Walgreens", "purchasedate": "2022-01-02", ` + receipt[29:], http.StatusBadRequest,
+			`Invalid JSON: purchasedate at byte 26: unknown field, did you mean "purchaseDate"`, 0},
+		{"Trailing data in either mode", false, "/receipts/process", `{"retailer": "Walgreens", ` + receipt + ` {}`, http.StatusBadRequest,
+			"Invalid JSON: byte 165: unexpected data after the JSON value", 0},
+		{"Wrong type", false, "/receipts/process", `{"retailer": "Walgreens", ` + strings.Replace(receipt, `"1.25"}]`, `1.25}]`, 1), http.StatusBadRequest,
+			"Invalid JSON: items[0].price at byte 155: cannot use a number as a string", 0},
+		{"Clean receipt has no warnings", true, "/receipts/process", `{"retailer": "Walgreens", ` + receipt, http.StatusOK, "", 0},
+		{"Strict rejects unknown XML element", true, "/receipts/process", `<receipt><retailer>Walgreens</retailer><bogus>1</bogus>` + xmlReceipt, http.StatusBadRequest,
+			"Invalid XML: bogus at byte 39: unknown field", 0},
+		{"Strict rejects repeated XML element", true, "/receipts/process", `<receipt><retailer>Walgreens</retailer><retailer>Target</retailer>` + xmlReceipt, http.StatusBadRequest,
+			"Invalid XML: retailer at byte 39: duplicate field", 0},
+		{"Lenient warns about XML elements", false, "/receipts/process", `<receipt><retailer>Walgreens</retailer><bogus>1</bogus><retailer>Target</retailer>` + xmlReceipt,
+			http.StatusOK, "", 2},
+	}
+
+	for _, testCase := range tests {
+		t.Run(testCase.name, func(t *testing.T) {
+			handler := NewReceiptHandler(store.NewMemoryDatabase())
+			handler.StrictDecoding = testCase.strict
+
+			request := httptest.NewRequest("POST", testCase.url, strings.NewReader(testCase.body))
+			if strings.HasPrefix(testCase.body, "<") {
+				request.Header.Set("Content-Type", "application/xml")
+			}
+			responseRecorder := httptest.NewRecorder()
+			handler.CreateReceiptHandler(responseRecorder, request)
+
+			if responseRecorder.Code != testCase.responseCode {
+				t.Fatalf("Result status: %d, want: %d (%s)", responseRecorder.Code, testCase.responseCode, responseRecorder.Body.String())
+			}
+			if testCase.wantError != "" {
+				var response map[string]string
+				json.Unmarshal(responseRecorder.Body.Bytes(), &response)
+				if response["error"] != testCase.wantError {
+					t.Errorf("Result error: %q, want: %q", response["error"], testCase.wantError)
+				}
+				return
+			}
+
+			var response ReceiptResponse
+			if err := json.Unmarshal(responseRecorder.Body.Bytes(), &response); err != nil {
+				t.Fatalf("Error during test parsing successful result JSON: %v", err)
+			}
+			if response.ID == "" || len(response.Warnings) != testCase.wantWarnings {
+				t.Errorf("Result: %+v, want an ID and %d warnings", response, testCase.wantWarnings)
+			}
+		})
+	}
+}
+
+func TestCreateReceiptBodyLimit(t *testing.T) {
+	body := `{"retailer": "Walgreens", "purchaseDate": "2022-01-02", "purchaseTime": "08:13", "total": "1.25", "items": [{"shortDescription": "Pepsi - 12-oz", "price": "1.25"}]}`
+
+	tests := []struct {
+		name         string
+		maxBodyBytes int64
+		responseCode int
+	}{
+		{"Within the limit", int64(len(body)), http.StatusOK},
+		{"Over the limit", int64(len(body)) - 1, http.StatusBadRequest},
+	}
+	for _, testCase := range tests {
+		t.Run(testCase.name, func(t *testing.T) {
+			handler := NewReceiptHandler(store.NewMemoryDatabase())
+			handler.MaxBodyBytes = testCase.maxBodyBytes
+
+			responseRecorder := httptest.NewRecorder()
+			handler.CreateReceiptHandler(responseRecorder, httptest.NewRequest("POST", "/receipts/process", strings.NewReader(body)))
+			if responseRecorder.Code != testCase.responseCode {
+				t.Errorf("Result status: %d, want: %d (%s)", responseRecorder.Code, testCase.responseCode, responseRecorder.Body.String())
+			}
+		})
+	}
+}
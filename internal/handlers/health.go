@@ -0,0 +1,92 @@
+package handlers
+
+import (
+	"net/http"
+	"time"
+)
+
+// ReadinessResponse is the response for GET /readyz, every check is "ok" or what is wrong
+type ReadinessResponse struct {
+	Status string            `json:"status"` // "ready" or "not ready"
+	Checks map[string]string `json:"checks"` // store, rules and shutdown
+}
+
+// StatusResponse is the response for GET /status
+type StatusResponse struct {
+	Version       string      `json:"version"`       // Build version of the server
+	StartedAt     time.Time   `json:"startedAt"`     // When the handler was created
+	Uptime        string      `json:"uptime"`        // Time since StartedAt, e.g. "3h2m1s"
+	UptimeSeconds int64       `json:"uptimeSeconds"` // Uptime for machines
+	Ready         bool        `json:"ready"`         // Same as /readyz
+	ShuttingDown  bool        `json:"shuttingDown"`  // Graceful shutdown has begun
+	Store         StoreStatus `json:"store"`
+	Rules         RulesStatus `json:"rules"`
+}
+
+// StoreStatus is the store part of StatusResponse
+type StoreStatus struct {
+	Backend  string `json:"backend"`  // Store backend from the config
+	Receipts int    `json:"receipts"` // Number of stored receipts
+}
+
+// RulesStatus is the rules part of StatusResponse
+type RulesStatus struct {
+	Version string `json:"version"` // Content hash of the active rule set, see rules.Config.Version
+}
+
+// StartShutdown marks the server as shutting down so /readyz fails while requests in flight drain
+func (h *ReceiptHandler) StartShutdown() {
+	h.shuttingDown.Store(true)
+}
+
+// HealthzHandler takes a GET request with /healthz endpoint, 200 whenever the process is up to answer
+func (h *ReceiptHandler) HealthzHandler(w http.ResponseWriter, r *http.Request) {
+	sendJSON(w, map[string]string{"status": "ok"}, http.StatusOK)
+}
+
+// ReadyzHandler takes a GET request with /readyz endpoint, 200 if the server should get traffic and 503 otherwise
+// Ready when the store answers, the rules are valid and graceful shutdown has not begun
+func (h *ReceiptHandler) ReadyzHandler(w http.ResponseWriter, r *http.Request) {
+	response := h.readiness()
+	code := http.StatusOK
+	if response.Status != "ready" {
+		code = http.StatusServiceUnavailable
+	}
+	sendJSON(w, response, code)
+}
+
+// StatusHandler takes a GET request with /status endpoint and reports version, uptime, store and rules for operators
+func (h *ReceiptHandler) StatusHandler(w http.ResponseWriter, r *http.Request) {
+	uptime := time.Since(h.started)
+	response := StatusResponse{
+		Version:       h.Version,
+		StartedAt:     h.started.UTC(),
+		Uptime:        uptime.Round(time.Second).String(),
+		UptimeSeconds: int64(uptime.Seconds()),
+		Ready:         h.readiness().Status == "ready",
+		ShuttingDown:  h.shuttingDown.Load(),
+		Store:         StoreStatus{Backend: h.StoreBackend, Receipts: h.Database.CountReceipts()},
+		Rules:         RulesStatus{Version: h.Rules.Version()},
+	}
+	sendJSON(w, response, http.StatusOK)
+}
+
+// helper function that runs the readiness checks
+func (h *ReceiptHandler) readiness() ReadinessResponse {
+	response := ReadinessResponse{Status: "ready", Checks: map[string]string{"store": "ok", "rules": "ok", "shutdown": "ok"}}
+	if err := h.Database.Ping(); err != nil {
+		response.Checks["store"] = err.Error()
+	}
+	if err := h.Rules.Validate(); err != nil {
+		response.Checks["rules"] = err.Error()
+	}
+	if h.shuttingDown.Load() {
+		response.Checks["shutdown"] = "shutting down"
+	}
+	for _, result := range response.Checks {
+		if result != "ok" {
+			response.Status = "not ready"
+		}
+	}
+	return response
+}
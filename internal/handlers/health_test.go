@@ -0,0 +1,114 @@
+package handlers
+
+import (
+	"encoding/json"
+	"net/http"
+	"strings"
+	"testing"
+
+	"receipt-processor-challenge-jase180/internal/store"
+)
+
+func TestHealthzHandler(t *testing.T) {
+	handler := NewReceiptHandler(&store.MemoryDatabase{}) // even an unusable store is alive
+	handler.StartShutdown()
+
+	responseRecorder := sendToHandler(handler.HealthzHandler, "GET", "", nil)
+	if responseRecorder.Code != http.StatusOK || !strings.Contains(responseRecorder.Body.String(), `"ok"`) {
+		t.Errorf("Result status %d body %s, want 200 ok", responseRecorder.Code, responseRecorder.Body.String())
+	}
+}
+
+func TestReadyzHandler(t *testing.T) {
+	tests := []struct {
+		name        string
+		setup       func() *ReceiptHandler
+		expectCode  int
+		expectCheck string // check that is not "ok", empty when ready
+	}{
+		{
+			name:       "ready",
+			setup:      func() *ReceiptHandler { return NewReceiptHandler(store.NewMemoryDatabase()) },
+			expectCode: http.StatusOK,
+		},
+		{
+			name: "shutting down",
+			setup: func() *ReceiptHandler {
+				handler := NewReceiptHandler(store.NewMemoryDatabase())
+				handler.StartShutdown()
+				return handler
+			},
+			expectCode:  http.StatusServiceUnavailable,
+			expectCheck: "shutdown",
+		},
+		{
+			name:        "store not initialized",
+			setup:       func() *ReceiptHandler { return NewReceiptHandler(&store.MemoryDatabase{}) },
+			expectCode:  http.StatusServiceUnavailable,
+			expectCheck: "store",
+		},
+		{
+			name: "invalid rules",
+			setup: func() *ReceiptHandler {
+				handler := NewReceiptHandler(store.NewMemoryDatabase())
+				handler.Rules.CountMode = "bytes"
+				return handler
+			},
+			expectCode:  http.StatusServiceUnavailable,
+			expectCheck: "rules",
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			responseRecorder := sendToHandler(tt.setup().ReadyzHandler, "GET", "", nil)
+			if responseRecorder.Code != tt.expectCode {
+				t.Fatalf("Result status %d, want %d", responseRecorder.Code, tt.expectCode)
+			}
+			var response ReadinessResponse
+			if err := json.NewDecoder(responseRecorder.Body).Decode(&response); err != nil {
+				t.Fatalf("Result is not JSON: %v", err)
+			}
+			for check, result := range response.Checks {
+				if (check == tt.expectCheck) == (result == "ok") {
+					t.Errorf("Result check %s: %q", check, result)
+				}
+			}
+			if (tt.expectCheck == "") != (response.Status == "ready") {
+				t.Errorf("Result status %q", response.Status)
+			}
+		})
+	}
+}
+
+func TestStatusHandler(t *testing.T) {
+	db := store.NewMemoryDatabase()
+	handler := NewReceiptHandler(db)
+	handler.Version = "1.2.3"
+	submitUserReceipt(t, handler, "user-1")
+	submitUserReceipt(t, handler, "user-1")
+
+	responseRecorder := sendToHandler(handler.StatusHandler, "GET", "", nil)
+	var response StatusResponse
+	if err := json.NewDecoder(responseRecorder.Body).Decode(&response); err != nil {
+		t.Fatalf("Result is not JSON: %v", err)
+	}
+	if responseRecorder.Code != http.StatusOK || response.Version != "1.2.3" || !response.Ready || response.ShuttingDown {
+		t.Errorf("Result status %d: %+v, want 200 ready version 1.2.3", responseRecorder.Code, response)
+	}
+	if response.Store != (StoreStatus{Backend: "memory", Receipts: 2}) {
+		t.Errorf("Result store: %+v, want memory with 2 receipts", response.Store)
+	}
+	if response.Rules.Version != handler.Rules.Version() || response.StartedAt.IsZero() {
+		t.Errorf("Result rules version %q started %v, want %q", response.Rules.Version, response.StartedAt, handler.Rules.Version())
+	}
+
+	// Changing the rules changes the reported version, shutdown makes it not ready
+	handler.Rules.CountMode = "runes"
+	handler.StartShutdown()
+	responseRecorder = sendToHandler(handler.StatusHandler, "GET", "", nil)
+	json.NewDecoder(responseRecorder.Body).Decode(&response)
+	if response.Rules.Version == NewReceiptHandler(db).Rules.Version() || response.Ready || !response.ShuttingDown {
+		t.Errorf("Result after changes: %+v, want new rules version and shutting down", response)
+	}
+}
@@ -0,0 +1,179 @@
+package handlers
+
+import (
+	"errors"
+	"net/http"
+	"strings"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/gorilla/mux"
+
+	"receipt-processor-challenge-jase180/internal/models"
+	"receipt-processor-challenge-jase180/internal/store"
+)
+
+// RedemptionRequest is the body for POST /users/{id}/redemptions
+type RedemptionRequest struct {
+	Points    int    `json:"points"`    // Points to spend, must be positive
+	Reference string `json:"reference"` // Optional reward or order reference
+}
+
+// AdjustmentRequest is the body for POST /admin/users/{id}/adjustments
+type AdjustmentRequest struct {
+	Points int    `json:"points"` // Points to add, negative to remove, non zero
+	Reason string `json:"reason"` // Required explanation for the audit trail
+}
+
+// ReversalRequest is the body for POST /admin/ledger/{id}/reversal
+type ReversalRequest struct {
+	Reason string `json:"reason"` // Required explanation for the audit trail
+}
+
+// LedgerEntryResponse is the response for a posted ledger entry with the user's new balance
+type LedgerEntryResponse struct {
+	Entry   models.LedgerEntry `json:"entry"`   // Entry as posted with accounts filled in
+	Balance int                `json:"balance"` // User balance after the entry
+}
+
+// UserLedgerResponse is the response for GET /users/{id}/ledger
+type UserLedgerResponse struct {
+	UserID  string               `json:"userId"`  // User the entries belong to
+	Balance int                  `json:"balance"` // Current user balance
+	Entries []models.LedgerEntry `json:"entries"` // Entries in posting order
+}
+
+// CreateRedemptionHandler takes a POST request with /users/{id}/redemptions endpoint and spends user points
+// Balance check and debit happen atomically in the store so concurrent redemptions cannot overspend
+// Only the user named by the X-User-ID header, or an admin, may spend their points
+func (h *ReceiptHandler) CreateRedemptionHandler(w http.ResponseWriter, r *http.Request) {
+	if !h.authorizeUser(w, r) {
+		return
+	}
+	userID, ok := h.lookupUser(w, r)
+	if !ok {
+		return
+	}
+
+	var request RedemptionRequest
+	if err := decodeJSONBody(w, r, &request); err != nil {
+		sendJSON(w, map[string]string{"error": "Invalid JSON"}, http.StatusBadRequest) // 400 response
+		return
+	}
+	if request.Points <= 0 {
+		sendJSON(w, map[string]string{"error": "BadRequest: Redemption points must be positive"}, http.StatusBadRequest)
+		return
+	}
+
+	h.postLedgerEntry(w, models.LedgerEntry{
+		UserID:    userID,
+		Type:      models.LedgerRedemption,
+		Points:    request.Points,
+		Reference: strings.TrimSpace(request.Reference),
+	})
+}
+
+// CreateAdjustmentHandler takes a POST request with /admin/users/{id}/adjustments endpoint to correct a balance
+func (h *ReceiptHandler) CreateAdjustmentHandler(w http.ResponseWriter, r *http.Request) {
+	userID, ok := h.lookupUser(w, r)
+	if !ok {
+		return
+	}
+
+	var request AdjustmentRequest
+	if err := decodeJSONBody(w, r, &request); err != nil {
+		sendJSON(w, map[string]string{"error": "Invalid JSON"}, http.StatusBadRequest) // 400 response
+		return
+	}
+	if request.Points == 0 || strings.TrimSpace(request.Reason) == "" {
+		sendJSON(w, map[string]string{"error": "BadRequest: Adjustment needs non zero points and a reason"}, http.StatusBadRequest)
+		return
+	}
+
+	h.postLedgerEntry(w, models.LedgerEntry{
+		UserID:    userID,
+		Type:      models.LedgerAdjustment,
+		Points:    request.Points,
+		Reference: strings.TrimSpace(request.Reason),
+	})
+}
+
+// ReverseLedgerEntryHandler takes a POST request with /admin/ledger/{id}/reversal endpoint
+// Posts a new entry moving the points back, the original entry is kept and marked as reversed
+func (h *ReceiptHandler) ReverseLedgerEntryHandler(w http.ResponseWriter, r *http.Request) {
+	id := mux.Vars(r)["id"]
+	if _, err := uuid.Parse(id); err != nil {
+		sendJSON(w, map[string]string{"error": "BadRequest: Invalid ID format"}, http.StatusBadRequest) // 400 response
+		return
+	}
+
+	var request ReversalRequest
+	if err := decodeJSONBody(w, r, &request); err != nil {
+		sendJSON(w, map[string]string{"error": "Invalid JSON"}, http.StatusBadRequest) // 400 response
+		return
+	}
+	if strings.TrimSpace(request.Reason) == "" {
+		sendJSON(w, map[string]string{"error": "BadRequest: Reversal needs a reason"}, http.StatusBadRequest)
+		return
+	}
+
+	h.postLedgerEntry(w, models.LedgerEntry{
+		Type:       models.LedgerReversal,
+		ReversalOf: id,
+		Reference:  strings.TrimSpace(request.Reason),
+	})
+}
+
+// GetUserLedgerHandler takes a GET request with /users/{id}/ledger endpoint and returns the user's entries
+func (h *ReceiptHandler) GetUserLedgerHandler(w http.ResponseWriter, r *http.Request) {
+	userID, ok := h.lookupUser(w, r)
+	if !ok {
+		return
+	}
+
+	balance, _ := h.Database.GetUserBalance(userID)
+	sendJSON(w, UserLedgerResponse{
+		UserID:  userID,
+		Balance: balance,
+		Entries: h.Database.GetLedgerByUser(userID),
+	}, http.StatusOK)
+}
+
+// helper function that reads and checks the {id} user route variable, writing the error response if invalid
+func (h *ReceiptHandler) lookupUser(w http.ResponseWriter, r *http.Request) (string, bool) {
+	id := mux.Vars(r)["id"]
+	if !regexUserID.MatchString(id) {
+		sendJSON(w, map[string]string{"error": "BadRequest: Invalid user ID format"}, http.StatusBadRequest) // 400 response
+		return "", false
+	}
+	if _, err := h.Database.GetUserByID(id); err != nil {
+		sendJSON(w, map[string]string{"error": "No user found for that ID"}, http.StatusNotFound) // 404 response
+		return "", false
+	}
+	return id, true
+}
+
+// helper function that posts an entry and maps ledger errors to responses
+func (h *ReceiptHandler) postLedgerEntry(w http.ResponseWriter, entry models.LedgerEntry) {
+	entry.ID = uuid.New().String()
+	entry.CreatedAt = time.Now().UTC()
+
+	posted, err := h.Database.PostLedgerEntry(entry)
+	switch {
+	case errors.Is(err, store.ErrInsufficientPoints):
+		sendJSON(w, map[string]string{"error": "Conflict: Not enough points for that user"}, http.StatusConflict) // 409 response
+		return
+	case errors.Is(err, store.ErrEntryNotInLedger):
+		sendJSON(w, map[string]string{"error": "No ledger entry found for that ID"}, http.StatusNotFound) // 404 response
+		return
+	case errors.Is(err, store.ErrEntryAlreadyReversed), errors.Is(err, store.ErrEntryIsReversal):
+		sendJSON(w, map[string]string{"error": "Conflict: " + err.Error()}, http.StatusConflict) // 409 response
+		return
+	case err != nil:
+		sendJSON(w, map[string]string{"error": "Database failure, could not post ledger entry"}, http.StatusInternalServerError) // 500 response
+		return
+	}
+
+	balance, _ := h.Database.GetUserBalance(posted.UserID)
+	sendJSON(w, LedgerEntryResponse{Entry: posted, Balance: balance}, http.StatusOK)
+}
@@ -0,0 +1,159 @@
+package handlers
+
+import (
+	"bytes"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"sync"
+	"testing"
+
+	"github.com/gorilla/mux"
+
+	"receipt-processor-challenge-jase180/internal/store"
+)
+
+// helper function that sends a request with route variables to a handler function and returns the recorder
+// helper function that calls a handler with the X-User-ID header set, as the user would
+func asUser(handlerFunc http.HandlerFunc, userID string) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		r.Header.Set(userIDHeader, userID)
+		handlerFunc(w, r)
+	}
+}
+
+func sendToHandler(handlerFunc http.HandlerFunc, method string, body string, vars map[string]string) *httptest.ResponseRecorder {
+	request := httptest.NewRequest(method, "/", bytes.NewReader([]byte(body)))
+	request = mux.SetURLVars(request, vars)
+	responseRecorder := httptest.NewRecorder()
+	handlerFunc(responseRecorder, request)
+	return responseRecorder
+}
+
+// helper function that submits the M&M README receipt (109 points) for a user
+func submitUserReceipt(t *testing.T, handler *ReceiptHandler, userID string) {
+	t.Helper()
+	body := `{"retailer": "M&M Corner Market", "purchaseDate": "2022-03-20", "purchaseTime": "14:33", "userId": "` + userID + `",
+		"items": [
+			{"shortDescription": "Gatorade", "price": "2.25"},
+			{"shortDescription": "Gatorade", "price": "2.25"},
+			{"shortDescription": "Gatorade", "price": "2.25"},
+			{"shortDescription": "Gatorade", "price": "2.25"}
+		], "total": "9.00"}`
+	responseRecorder := sendToHandler(handler.CreateReceiptHandler, "POST", body, nil)
+	if responseRecorder.Code != http.StatusOK {
+		t.Fatalf("Result status: %d, want: %d", responseRecorder.Code, http.StatusOK)
+	}
+}
+
+func TestLedgerHandlers(t *testing.T) {
+	// Initialize database and handler, user-1 has 109 points
+	db := store.NewMemoryDatabase()
+	handler := NewReceiptHandler(db)
+	submitUserReceipt(t, handler, "user-1")
+
+	user := map[string]string{"id": "user-1"}
+
+	tests := []struct {
+		name         string
+		handlerFunc  http.HandlerFunc
+		body         string
+		vars         map[string]string
+		responseCode int // corresponding response codes
+		wantBalance  int // balance after entry if expect success
+	}{
+		{"Redeem within balance", asUser(handler.CreateRedemptionHandler, "user-1"), `{"points": 100, "reference": "coffee"}`, user, http.StatusOK, 9},
+		{"Redeem over balance", asUser(handler.CreateRedemptionHandler, "user-1"), `{"points": 10}`, user, http.StatusConflict, 0},
+		{"Redeem zero", asUser(handler.CreateRedemptionHandler, "user-1"), `{"points": 0}`, user, http.StatusBadRequest, 0},
+		{"Redeem unknown field", asUser(handler.CreateRedemptionHandler, "user-1"), `{"point": 5}`, user, http.StatusBadRequest, 0},
+		{"Redeem no such user", asUser(handler.CreateRedemptionHandler, "user-2"), `{"points": 5}`, map[string]string{"id": "user-2"}, http.StatusNotFound, 0},
+		{"Redeem for another user", asUser(handler.CreateRedemptionHandler, "user-2"), `{"points": 5}`, user, http.StatusForbidden, 0},
+		{"Adjust up", handler.CreateAdjustmentHandler, `{"points": 11, "reason": "support ticket"}`, user, http.StatusOK, 20},
+		{"Adjust down", handler.CreateAdjustmentHandler, `{"points": -5, "reason": "support ticket"}`, user, http.StatusOK, 15},
+		{"Adjust without reason", handler.CreateAdjustmentHandler, `{"points": 5}`, user, http.StatusBadRequest, 0},
+		{"Reverse bad ID", handler.ReverseLedgerEntryHandler, `{"reason": "mistake"}`, map[string]string{"id": "ABCDEFG"}, http.StatusBadRequest, 0},
+	}
+
+	for _, testCase := range tests {
+		t.Run(testCase.name, func(t *testing.T) {
+			responseRecorder := sendToHandler(testCase.handlerFunc, "POST", testCase.body, testCase.vars)
+			if responseRecorder.Code != testCase.responseCode {
+				t.Fatalf("Result status: %d, want: %d", responseRecorder.Code, testCase.responseCode)
+			}
+			if testCase.responseCode != http.StatusOK {
+				return
+			}
+
+			var response LedgerEntryResponse
+			if err := json.Unmarshal(responseRecorder.Body.Bytes(), &response); err != nil {
+				t.Fatalf("Error during test parsing successful result JSON: %v", err)
+			}
+			if response.Balance != testCase.wantBalance {
+				t.Errorf("Result balance: %d, want: %d", response.Balance, testCase.wantBalance)
+			}
+		})
+	}
+
+	// Reverse the 100 point redemption from the ledger, then reversing again conflicts
+	ledgerRecorder := sendToHandler(handler.GetUserLedgerHandler, "GET", "", user)
+	var ledger UserLedgerResponse
+	if err := json.Unmarshal(ledgerRecorder.Body.Bytes(), &ledger); err != nil {
+		t.Fatalf("Error during test parsing successful result JSON: %v", err)
+	}
+	if len(ledger.Entries) != 4 {
+		t.Fatalf("Result %d ledger entries, want 4", len(ledger.Entries))
+	}
+	redemption := map[string]string{"id": ledger.Entries[1].ID}
+
+	if code := sendToHandler(handler.ReverseLedgerEntryHandler, "POST", `{"reason": "order cancelled"}`, redemption).Code; code != http.StatusOK {
+		t.Fatalf("Result status: %d, want: %d", code, http.StatusOK)
+	}
+	if code := sendToHandler(handler.ReverseLedgerEntryHandler, "POST", `{"reason": "order cancelled"}`, redemption).Code; code != http.StatusConflict {
+		t.Fatalf("Result status: %d, want: %d", code, http.StatusConflict)
+	}
+
+	// Points endpoint reflects the ledger
+	var points UserPointsResponse
+	json.Unmarshal(sendToHandler(handler.GetUserPointsHandler, "GET", "", user).Body.Bytes(), &points)
+	if points.Balance != 115 || points.LifetimePoints != 109 {
+		t.Errorf("Result balance %d lifetime %d, want 115 and 109", points.Balance, points.LifetimePoints)
+	}
+}
+
+// Tests concurrent redemption requests through the handler never overspend
+func TestCreateRedemptionHandlerConcurrency(t *testing.T) {
+	db := store.NewMemoryDatabase()
+	handler := NewReceiptHandler(db)
+	submitUserReceipt(t, handler, "user-1") // 109 points
+
+	// Initiate a waitgroup
+	var waitGroup sync.WaitGroup
+	numConcurrentTasks := 50 // 50 redemptions of 10 against 109 points, only 10 can succeed
+
+	codes := make(chan int, numConcurrentTasks)
+	for i := 0; i < numConcurrentTasks; i++ {
+		waitGroup.Add(1)
+		go func() {
+			defer waitGroup.Done()
+			codes <- sendToHandler(asUser(handler.CreateRedemptionHandler, "user-1"), "POST", `{"points": 10}`, map[string]string{"id": "user-1"}).Code
+		}()
+	}
+	waitGroup.Wait() // this ensures all go routines finish
+	close(codes)
+
+	successes := 0
+	for code := range codes {
+		switch code {
+		case http.StatusOK:
+			successes++
+		case http.StatusConflict:
+		default:
+			t.Errorf("Result status: %d, want 200 or 409", code)
+		}
+	}
+
+	balance, _ := db.GetUserBalance("user-1")
+	if successes != 10 || balance != 9 {
+		t.Fatalf("Result %d successful redemptions and balance %d; want 10 and 9", successes, balance)
+	}
+}
@@ -0,0 +1,145 @@
+package handlers
+
+import (
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/gorilla/mux"
+
+	"receipt-processor-challenge-jase180/internal/metrics"
+	"receipt-processor-challenge-jase180/internal/models"
+	rules "receipt-processor-challenge-jase180/internal/services"
+	"receipt-processor-challenge-jase180/internal/store"
+)
+
+// validationPrefix starts every validateReceipt error, left out of the reason label
+const validationPrefix = "BadRequest: The receipt is invalid. "
+
+// serverMetrics are the metrics a handler records, scraped from GET /metrics
+type serverMetrics struct {
+	registry           *metrics.Registry
+	requests           *metrics.Counter   // by method, route and status
+	requestDuration    *metrics.Histogram // by method, route and status
+	validationFailures *metrics.Counter   // by reason
+	rulePoints         *metrics.Counter   // by rule
+	storeDuration      *metrics.Histogram // by operation
+}
+
+// newServerMetrics registers the handler's metrics, the receipt count is read from db on each scrape
+func newServerMetrics(db *store.MemoryDatabase) *serverMetrics {
+	registry := metrics.NewRegistry()
+	m := &serverMetrics{
+		registry: registry,
+		requests: registry.NewCounter("http_requests_total",
+			"HTTP requests served by route template and status code.", "method", "route", "status"),
+		requestDuration: registry.NewHistogram("http_request_duration_seconds",
+			"Time to serve HTTP requests by route template and status code.", metrics.DefaultBuckets, "method", "route", "status"),
+		validationFailures: registry.NewCounter("receipt_validation_failures_total",
+			"Receipts rejected by validation, by the reason in the error.", "reason"),
+		rulePoints: registry.NewCounter("receipt_rule_points_total",
+			"Points from each rule in CalculatePoints for stored receipts, before tier, campaigns and limits.", "rule"),
+		storeDuration: registry.NewHistogram("store_operation_duration_seconds",
+			"Time taken by store operations including lock waits.", metrics.StoreBuckets, "operation"),
+	}
+	registry.NewGaugeFunc("store_receipts", "Receipts in the store.", func() float64 { return float64(db.CountReceipts()) })
+	return m
+}
+
+// observeStore records a store operation, set as the store's Observe
+func (m *serverMetrics) observeStore(operation string, elapsed time.Duration) {
+	m.storeDuration.Observe(elapsed.Seconds(), operation)
+}
+
+// MetricsHandler takes a GET request with /metrics endpoint and writes every metric in the Prometheus text format
+func (h *ReceiptHandler) MetricsHandler(w http.ResponseWriter, r *http.Request) {
+	w.Header().Set("Content-Type", metrics.ContentType)
+	h.metrics.registry.WriteText(w) // nothing to do if the scraper went away
+}
+
+// statusWriter remembers the status code a handler sent
+type statusWriter struct {
+	http.ResponseWriter
+	status int
+}
+
+func (s *statusWriter) WriteHeader(code int) {
+	if s.status == 0 {
+		s.status = code
+	}
+	s.ResponseWriter.WriteHeader(code)
+}
+
+func (s *statusWriter) Write(b []byte) (int, error) {
+	if s.status == 0 {
+		s.status = http.StatusOK
+	}
+	return s.ResponseWriter.Write(b)
+}
+
+// Flush passes flushes through so streaming responses like the CSV export still stream
+func (s *statusWriter) Flush() {
+	if s.status == 0 {
+		s.status = http.StatusOK
+	}
+	if flusher, ok := s.ResponseWriter.(http.Flusher); ok {
+		flusher.Flush()
+	}
+}
+
+// Unwrap lets http.ResponseController reach the underlying writer
+func (s *statusWriter) Unwrap() http.ResponseWriter {
+	return s.ResponseWriter
+}
+
+// instrumentedMethods are the methods counted under their own name, others are "other" so clients cannot add series
+var instrumentedMethods = map[string]bool{
+	http.MethodGet: true, http.MethodPost: true, http.MethodPut: true, http.MethodDelete: true,
+	http.MethodHead: true, http.MethodOptions: true, http.MethodPatch: true,
+}
+
+// Instrument wraps the router to count requests and their latency by method, route template and status code
+// Routes are labelled with their template like /receipts/{id} so IDs do not become separate series,
+// requests matching no route, 404 or 405, are labelled "unmatched"
+func (h *ReceiptHandler) Instrument(router *mux.Router) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		start := time.Now()
+		recorder := &statusWriter{ResponseWriter: w}
+		router.ServeHTTP(recorder, r)
+
+		route := "unmatched"
+		var match mux.RouteMatch
+		if router.Match(r, &match) && match.Route != nil {
+			if template, err := match.Route.GetPathTemplate(); err == nil {
+				route = template
+			}
+		}
+		method := r.Method
+		if !instrumentedMethods[method] {
+			method = "other"
+		}
+		if recorder.status == 0 {
+			recorder.status = http.StatusOK // nothing written is an empty 200
+		}
+		status := strconv.Itoa(recorder.status)
+		h.metrics.requests.Inc(method, route, status)
+		h.metrics.requestDuration.Observe(time.Since(start).Seconds(), method, route, status)
+	})
+}
+
+// validate runs validateReceipt and counts failures by reason
+func (h *ReceiptHandler) validate(receipt models.Receipt) error {
+	err := validateReceipt(receipt)
+	if err != nil {
+		h.metrics.validationFailures.Inc(strings.TrimPrefix(err.Error(), validationPrefix))
+	}
+	return err
+}
+
+// recordRulePoints counts the points each rule gave a stored receipt
+func (h *ReceiptHandler) recordRulePoints(breakdown []rules.RulePoints) {
+	for _, rule := range breakdown {
+		h.metrics.rulePoints.Add(float64(rule.Points), rule.Rule)
+	}
+}
@@ -0,0 +1,149 @@
+package handlers
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/gorilla/mux"
+
+	"receipt-processor-challenge-jase180/internal/metrics"
+	"receipt-processor-challenge-jase180/internal/metrics/metricstest"
+	"receipt-processor-challenge-jase180/internal/store"
+)
+
+// helper function that scrapes /metrics through the handler and parses the output
+func scrapeMetrics(t *testing.T, handler http.Handler) []metricstest.Sample {
+	t.Helper()
+	responseRecorder := httptest.NewRecorder()
+	handler.ServeHTTP(responseRecorder, httptest.NewRequest("GET", "/metrics", nil))
+	if responseRecorder.Code != http.StatusOK || responseRecorder.Header().Get("Content-Type") != metrics.ContentType {
+		t.Fatalf("Result scrape status %d content type %q, want 200 %q", responseRecorder.Code, responseRecorder.Header().Get("Content-Type"), metrics.ContentType)
+	}
+	samples, err := metricstest.Parse(responseRecorder.Body)
+	if err != nil {
+		t.Fatalf("Result scrape does not parse: %v", err)
+	}
+	return samples
+}
+
+func TestMetricsHandler(t *testing.T) {
+	db := store.NewMemoryDatabase()
+	handler := NewReceiptHandler(db)
+	routes := mux.NewRouter()
+	routes.Use(Negotiate)
+	routes.HandleFunc("/receipts/process", handler.CreateReceiptHandler).Methods("POST")
+	routes.HandleFunc("/receipts/{id}/points", handler.GetReceiptHandler).Methods("GET")
+	routes.HandleFunc("/metrics", handler.MetricsHandler).Methods("GET")
+	router := handler.Instrument(routes)
+
+	send := func(method, url, body string) *httptest.ResponseRecorder {
+		responseRecorder := httptest.NewRecorder()
+		router.ServeHTTP(responseRecorder, httptest.NewRequest(method, url, strings.NewReader(body)))
+		return responseRecorder
+	}
+
+	// README Target receipt, 28 points
+	target := `{"retailer": "Target", "purchaseDate": "2022-01-01", "purchaseTime": "13:01", "total": "35.35", "items": [
+		{"shortDescription": "Mountain Dew 12PK", "price": "6.49"}, {"shortDescription": "Emils Cheese Pizza", "price": "12.25"},
+		{"shortDescription": "Knorr Creamy Chicken", "price": "1.26"}, {"shortDescription": "Doritos Nacho Cheese", "price": "3.35"},
+		{"shortDescription": "   Klarbrunn 12-PK 12 FL OZ  ", "price": "12.00"}]}`
+	var created ReceiptResponse
+	json.NewDecoder(send("POST", "/receipts/process", target).Body).Decode(&created)
+	send("POST", "/receipts/process", strings.Replace(target, `"Target"`, `" "`, 1))
+	send("POST", "/receipts/process", strings.Replace(target, `"Target"`, `""`, 1))
+	send("POST", "/receipts/process", strings.Replace(target, `"13:01"`, `"1pm"`, 1))
+	send("GET", "/receipts/"+created.ID+"/points", "")
+	send("GET", "/receipts/"+created.ID+"/points", "")
+
+	samples := scrapeMetrics(t, router)
+	tests := []struct {
+		name   string
+		metric string
+		labels map[string]string
+		expect float64
+	}{
+		{name: "stored receipt", metric: "http_requests_total",
+			labels: map[string]string{"method": "POST", "route": "/receipts/process", "status": "200"}, expect: 1},
+		{name: "rejected receipts", metric: "http_requests_total",
+			labels: map[string]string{"method": "POST", "route": "/receipts/process", "status": "400"}, expect: 3},
+		{name: "points by route template not ID", metric: "http_requests_total",
+			labels: map[string]string{"method": "GET", "route": "/receipts/{id}/points", "status": "200"}, expect: 2},
+		{name: "latency count matches requests", metric: "http_request_duration_seconds_count",
+			labels: map[string]string{"route": "/receipts/process", "status": "400"}, expect: 3},
+		{name: "latency +Inf bucket", metric: "http_request_duration_seconds_bucket",
+			labels: map[string]string{"route": "/receipts/{id}/points", "le": "+Inf"}, expect: 2},
+		{name: "empty retailer", metric: "receipt_validation_failures_total",
+			labels: map[string]string{"reason": "Retailer string is empty"}, expect: 2},
+		{name: "bad time", metric: "receipt_validation_failures_total",
+			labels: map[string]string{"reason": "Receipt time format is incorrect"}, expect: 1},
+		{name: "retailer name rule", metric: "receipt_rule_points_total",
+			labels: map[string]string{"rule": "retailerName"}, expect: 6},
+		{name: "item description rule", metric: "receipt_rule_points_total",
+			labels: map[string]string{"rule": "itemDescription"}, expect: 6},
+		{name: "receipt count", metric: "store_receipts", expect: 1},
+		{name: "store adds", metric: "store_operation_duration_seconds_count",
+			labels: map[string]string{"operation": "AddReceipt"}, expect: 1},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if value, ok := metricstest.Find(samples, tt.metric, tt.labels); !ok || value != tt.expect {
+				t.Errorf("Result %s%v: %v (found %v), want %v", tt.metric, tt.labels, value, ok, tt.expect)
+			}
+		})
+	}
+
+	// Rule points add up to the points the receipt was awarded, scoring without storing adds nothing
+	if total := metricstest.Sum(samples, "receipt_rule_points_total", nil); total != 28 {
+		t.Errorf("Result total rule points %v, want 28", total)
+	}
+	sendToHandler(handler.ScoreReceiptHandler, "POST", target, nil)
+	if total := metricstest.Sum(scrapeMetrics(t, router), "receipt_rule_points_total", nil); total != 28 {
+		t.Errorf("Result total rule points after scoring %v, want 28", total)
+	}
+}
+
+func TestInstrumentStatus(t *testing.T) {
+	handler := NewReceiptHandler(store.NewMemoryDatabase())
+	routes := mux.NewRouter()
+	routes.HandleFunc("/empty", func(w http.ResponseWriter, r *http.Request) {}).Methods("GET")
+	routes.HandleFunc("/teapot", func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusTeapot)
+		w.WriteHeader(http.StatusOK) // superfluous, the first status is what was sent
+	}).Methods("GET")
+	routes.HandleFunc("/metrics", handler.MetricsHandler).Methods("GET")
+	router := handler.Instrument(routes)
+
+	for _, request := range []struct{ method, url string }{
+		{"GET", "/empty"}, {"GET", "/teapot"}, {"GET", "/nowhere"}, {"DELETE", "/empty"}, {"BREW", "/teapot"}, {"PURGE", "/teapot"},
+	} {
+		router.ServeHTTP(httptest.NewRecorder(), httptest.NewRequest(request.method, request.url, nil))
+	}
+
+	samples := scrapeMetrics(t, router)
+	tests := []struct {
+		name   string
+		labels map[string]string
+		expect float64
+	}{
+		{name: "empty response", labels: map[string]string{"method": "GET", "route": "/empty", "status": "200"}, expect: 1},
+		{name: "first status", labels: map[string]string{"method": "GET", "route": "/teapot", "status": "418"}, expect: 1},
+		{name: "not found", labels: map[string]string{"method": "GET", "route": "unmatched", "status": "404"}, expect: 1},
+		{name: "wrong method", labels: map[string]string{"method": "DELETE", "route": "unmatched", "status": "405"}, expect: 1},
+		{name: "unknown methods", labels: map[string]string{"method": "other", "route": "unmatched", "status": "405"}, expect: 2},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if value, ok := metricstest.Find(samples, "http_requests_total", tt.labels); !ok || value != tt.expect {
+				t.Errorf("Result %v: %v (found %v), want %v", tt.labels, value, ok, tt.expect)
+			}
+		})
+	}
+	for _, sample := range samples {
+		if sample.Labels["route"] == "/nowhere" || sample.Labels["method"] == "BREW" {
+			t.Errorf("Result client input as a label: %v", sample)
+		}
+	}
+}
@@ -0,0 +1,56 @@
+package handlers
+
+import (
+	"net/http"
+
+	"receipt-processor-challenge-jase180/internal/codec"
+)
+
+// negotiatedWriter carries the response format picked from the Accept header to sendJSON
+type negotiatedWriter struct {
+	http.ResponseWriter
+	format codec.Format
+}
+
+// Flush passes flushes through so streaming responses like the CSV export still stream
+func (n *negotiatedWriter) Flush() {
+	if flusher, ok := n.ResponseWriter.(http.Flusher); ok {
+		flusher.Flush()
+	}
+}
+
+// Unwrap lets http.ResponseController reach the underlying writer
+func (n *negotiatedWriter) Unwrap() http.ResponseWriter {
+	return n.ResponseWriter
+}
+
+// Negotiate is middleware that picks the response format from the Accept header for sendJSON
+// JSON unless the client prefers XML or MessagePack, handlers writing other content types are unaffected
+func Negotiate(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Add("Vary", "Accept")
+		next.ServeHTTP(&negotiatedWriter{ResponseWriter: w, format: codec.ResponseFormat(r.Header.Get("Accept"))}, r)
+	})
+}
+
+// responseFormat returns the format negotiated for a response, JSON if the handler was called without Negotiate
+func responseFormat(w http.ResponseWriter) codec.Format {
+	if negotiated, ok := w.(*negotiatedWriter); ok {
+		return negotiated.format
+	}
+	return codec.JSON
+}
+
+// helper function that converts a request body in the request's Content-Type format to JSON for decoding into v
+// Returns the name of the format for error messages and any XML decode warnings, JSON bodies are returned as they are
+func requestJSON(r *http.Request, body []byte, v interface{}, strict bool) ([]byte, string, []codec.DecodeIssue, error) {
+	switch codec.RequestFormat(r.Header.Get("Content-Type")) {
+	case codec.XML:
+		converted, warnings, err := codec.XMLToJSON(body, v, strict)
+		return converted, "XML", warnings, err
+	case codec.MsgPack:
+		converted, err := codec.MsgPackToJSON(body)
+		return converted, "MessagePack", nil, err
+	}
+	return body, "JSON", nil, nil
+}
@@ -0,0 +1,178 @@
+package handlers
+
+import (
+	"bytes"
+	"encoding/json"
+	"encoding/xml"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/gorilla/mux"
+	"github.com/vmihailenco/msgpack/v5"
+
+	"receipt-processor-challenge-jase180/internal/store"
+)
+
+// readmeReceiptXML is the Target README receipt as XML, 28 points
+const readmeReceiptXML = `<receipt>
+  <retailer>Target</retailer>
+  <purchaseDate>2022-01-01</purchaseDate>
+  <purchaseTime>13:01</purchaseTime>
+  <items>
+    <item><shortDescription>Mountain Dew 12PK</shortDescription><price>6.49</price></item>
+    <item><shortDescription>Emils Cheese Pizza</shortDescription><price>12.25</price></item>
+    <item><shortDescription>Knorr Creamy Chicken</shortDescription><price>1.26</price></item>
+    <item><shortDescription>Doritos Nacho Cheese</shortDescription><price>3.35</price></item>
+    <item shortDescription="   Klarbrunn 12-PK 12 FL OZ  " price="12.00"/>
+  </items>
+  <total>35.35</total>
+</receipt>`
+
+// negotiatedRequest sends a request through the Negotiate middleware and returns the recorded response
+func negotiatedRequest(handler http.HandlerFunc, request *http.Request) *httptest.ResponseRecorder {
+	responseRecorder := httptest.NewRecorder()
+	Negotiate(handler).ServeHTTP(responseRecorder, request)
+	return responseRecorder
+}
+
+// decodeNegotiated decodes a response body in the format of its Content-Type
+func decodeNegotiated(t *testing.T, responseRecorder *httptest.ResponseRecorder) map[string]string {
+	t.Helper()
+	response := map[string]string{}
+	body := responseRecorder.Body.Bytes()
+	var err error
+	switch contentType := responseRecorder.Header().Get("Content-Type"); {
+	case strings.HasPrefix(contentType, "application/xml"):
+		var document struct {
+			ID     string `xml:"id"`
+			Points string `xml:"points"`
+			Error  string `xml:"error"`
+		}
+		err = xml.Unmarshal(body, &document)
+		response["id"], response["points"], response["error"] = document.ID, document.Points, document.Error
+	case contentType == "application/msgpack":
+		var document map[string]interface{}
+		err = msgpack.Unmarshal(body, &document)
+		for key, value := range document {
+			encoded, _ := json.Marshal(value)
+			response[key] = strings.Trim(string(encoded), `"`)
+		}
+	default:
+		var document map[string]interface{}
+		err = json.Unmarshal(body, &document)
+		for key, value := range document {
+			encoded, _ := json.Marshal(value)
+			response[key] = strings.Trim(string(encoded), `"`)
+		}
+	}
+	if err != nil {
+		t.Fatalf("Error during test parsing %s result: %v", responseRecorder.Header().Get("Content-Type"), err)
+	}
+	return response
+}
+
+func TestNegotiatedCreateReceipt(t *testing.T) {
+	readmeJSON := readmeReceiptValue()
+	readmeMsgPack, _ := msgpack.Marshal(readmeJSON)
+	invalidMsgPack, _ := msgpack.Marshal(map[string]interface{}{"retailer": "Target", "purchaseDate": "2022-13-01"})
+
+	tests := []struct {
+		name            string
+		contentType     string
+		accept          string
+		body            []byte
+		responseCode    int
+		wantContentType string
+		wantError       string // exact error, empty if expect success
+	}{
+		{"XML in and out", "application/xml", "application/xml", []byte(readmeReceiptXML), http.StatusOK, "application/xml", ""},
+		{"XML in, JSON out", "text/xml; charset=utf-8", "", []byte(readmeReceiptXML), http.StatusOK, "application/json", ""},
+		{"MessagePack in and out", "application/msgpack", "application/x-msgpack", readmeMsgPack, http.StatusOK, "application/msgpack", ""},
+		{"JSON in, XML preferred", "application/json", "application/json;q=0.5, application/xml", mustJSON(t, readmeJSON), http.StatusOK, "application/xml", ""},
+		{"Malformed XML", "application/xml", "application/xml", []byte("<receipt><retailer>Target</receipt>"), http.StatusBadRequest, "application/xml", "Invalid XML"},
+		{"Malformed MessagePack", "application/msgpack", "application/msgpack", []byte{0xc1}, http.StatusBadRequest, "application/msgpack", "Invalid MessagePack"},
+		{"Invalid XML receipt", "application/xml", "", []byte(strings.Replace(readmeReceiptXML, "<total>35.35</total>", "<total>35.3</total>", 1)),
+			http.StatusBadRequest, "application/json", "BadRequest: The receipt is invalid. Receipt Total format is incorrect"},
+		{"Invalid MessagePack receipt", "application/msgpack", "application/msgpack", invalidMsgPack, http.StatusBadRequest, "application/msgpack", ""},
+	}
+
+	for _, testCase := range tests {
+		t.Run(testCase.name, func(t *testing.T) {
+			handler := NewReceiptHandler(store.NewMemoryDatabase())
+
+			request := httptest.NewRequest("POST", "/receipts/process", bytes.NewReader(testCase.body))
+			request.Header.Set("Content-Type", testCase.contentType)
+			if testCase.accept != "" {
+				request.Header.Set("Accept", testCase.accept)
+			}
+			responseRecorder := negotiatedRequest(handler.CreateReceiptHandler, request)
+
+			if responseRecorder.Code != testCase.responseCode {
+				t.Fatalf("Result status: %d, want: %d (%s)", responseRecorder.Code, testCase.responseCode, responseRecorder.Body.String())
+			}
+			if contentType := responseRecorder.Header().Get("Content-Type"); !strings.HasPrefix(contentType, testCase.wantContentType) {
+				t.Errorf("Result content type: %q, want %q", contentType, testCase.wantContentType)
+			}
+			if vary := responseRecorder.Header().Get("Vary"); vary != "Accept" {
+				t.Errorf("Result Vary: %q, want Accept", vary)
+			}
+
+			response := decodeNegotiated(t, responseRecorder)
+			if testCase.responseCode == http.StatusOK {
+				if response["id"] == "" {
+					t.Errorf("Result has no id: %s", responseRecorder.Body.String())
+				}
+				return
+			}
+			if response["error"] == "" || (testCase.wantError != "" && response["error"] != testCase.wantError) {
+				t.Errorf("Result error: %q, want %q", response["error"], testCase.wantError)
+			}
+		})
+	}
+}
+
+func TestNegotiatedPointsMatchJSON(t *testing.T) {
+	db := store.NewMemoryDatabase()
+	handler := NewReceiptHandler(db)
+
+	request := httptest.NewRequest("POST", "/receipts/process", strings.NewReader(readmeReceiptXML))
+	request.Header.Set("Content-Type", "application/xml")
+	id := decodeNegotiated(t, negotiatedRequest(handler.CreateReceiptHandler, request))["id"]
+
+	// The same receipt as XML scores the README's 28 points in every response format
+	for _, accept := range []string{"application/json", "application/xml", "application/msgpack"} {
+		request := httptest.NewRequest("GET", "/receipts/"+id+"/points", nil)
+		request.Header.Set("Accept", accept)
+		request = mux.SetURLVars(request, map[string]string{"id": id})
+
+		if points := decodeNegotiated(t, negotiatedRequest(handler.GetReceiptHandler, request))["points"]; points != "28" {
+			t.Errorf("Result points as %s: %q, want 28", accept, points)
+		}
+	}
+}
+
+// readmeReceiptValue returns the README receipt as a generic value for encoding to JSON and MessagePack
+func readmeReceiptValue() map[string]interface{} {
+	return map[string]interface{}{
+		"retailer": "Target", "purchaseDate": "2022-01-01", "purchaseTime": "13:01", "total": "35.35",
+		"items": []interface{}{
+			map[string]interface{}{"shortDescription": "Mountain Dew 12PK", "price": "6.49"},
+			map[string]interface{}{"shortDescription": "Emils Cheese Pizza", "price": "12.25"},
+			map[string]interface{}{"shortDescription": "Knorr Creamy Chicken", "price": "1.26"},
+			map[string]interface{}{"shortDescription": "Doritos Nacho Cheese", "price": "3.35"},
+			map[string]interface{}{"shortDescription": "   Klarbrunn 12-PK 12 FL OZ  ", "price": "12.00"},
+		},
+	}
+}
+
+// mustJSON marshals a test value to JSON
+func mustJSON(t *testing.T, v interface{}) []byte {
+	t.Helper()
+	data, err := json.Marshal(v)
+	if err != nil {
+		t.Fatalf("Error during test marshalling JSON: %v", err)
+	}
+	return data
+}
@@ -0,0 +1,55 @@
+package handlers
+
+import (
+	"net/http"
+	"strconv"
+
+	"receipt-processor-challenge-jase180/internal/codec"
+	"receipt-processor-challenge-jase180/internal/models"
+	rules "receipt-processor-challenge-jase180/internal/services"
+)
+
+// ScoreResponse is the response for POST /receipts/score, breakdown only when asked for with ?breakdown=true
+type ScoreResponse struct {
+	Points    int                 `json:"points"`              // Points the receipt would be awarded if submitted now
+	Breakdown []rules.RulePoints  `json:"breakdown,omitempty"` // Points per rule, tier bonus, campaign and cap, adds up to Points
+	Warnings  []codec.DecodeIssue `json:"warnings,omitempty"`  // Unknown and duplicate fields that were let through, as for POST /receipts/process
+}
+
+// ScoreReceiptHandler takes a POST request with /receipts/score endpoint and previews the points for a receipt
+// Validates exactly like CreateReceiptHandler but never stores the receipt, generates an ID or credits a user
+// With a userId the preview includes the user's current tier, campaigns and limits
+func (h *ReceiptHandler) ScoreReceiptHandler(w http.ResponseWriter, r *http.Request) {
+	receipt, warnings, ok := h.decodeReceipt(w, r)
+	if !ok {
+		return
+	}
+
+	receipt = h.prepareReceipt(receipt)
+	award := h.awardPoints(receipt)
+	response := ScoreResponse{Points: award.Points, Warnings: warnings}
+
+	if wantBreakdown, _ := strconv.ParseBool(r.URL.Query().Get("breakdown")); wantBreakdown {
+		response.Breakdown = h.awardBreakdown(receipt, award)
+	}
+
+	sendJSON(w, response, http.StatusOK)
+}
+
+// awardBreakdown lists the points from each rule followed by what the award added or removed on top
+// Every rule is listed, tier, campaign and cap entries only when they changed the points
+func (h *ReceiptHandler) awardBreakdown(receipt models.Receipt, award models.Award) []rules.RulePoints {
+	breakdown := h.Rules.CalculateBreakdown(receipt)
+
+	tier := rules.Tier{Name: award.Tier, Multiplier: award.Multiplier}
+	if tierBonus := tier.ApplyMultiplier(award.BasePoints) - award.BasePoints; tierBonus != 0 {
+		breakdown = append(breakdown, rules.RulePoints{Rule: "tier:" + award.Tier, Points: tierBonus})
+	}
+	for _, bonus := range award.Campaigns {
+		breakdown = append(breakdown, rules.RulePoints{Rule: "campaign:" + bonus.Name, Points: bonus.Points})
+	}
+	if award.CappedPoints != 0 {
+		breakdown = append(breakdown, rules.RulePoints{Rule: "limit:" + award.CapReason, Points: -award.CappedPoints})
+	}
+	return breakdown
+}
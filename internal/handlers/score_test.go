@@ -0,0 +1,139 @@
+package handlers
+
+import (
+	"bytes"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	rules "receipt-processor-challenge-jase180/internal/services"
+	"receipt-processor-challenge-jase180/internal/store"
+)
+
+func TestScoreReceiptHandler(t *testing.T) {
+	db := store.NewMemoryDatabase()
+	handler := NewReceiptHandler(db)
+
+	// Target README receipt is 28 points
+	targetReceipt := `{"retailer": "Target", "purchaseDate": "2022-01-01", "purchaseTime": "13:01",
+		"items": [
+			{"shortDescription": "Mountain Dew 12PK", "price": "6.49"},
+			{"shortDescription": "Emils Cheese Pizza", "price": "12.25"},
+			{"shortDescription": "Knorr Creamy Chicken", "price": "1.26"},
+			{"shortDescription": "Doritos Nacho Cheese", "price": "3.35"},
+			{"shortDescription": "   Klarbrunn 12-PK 12 FL OZ  ", "price": "12.00"}
+		], "total": "35.35"}`
+
+	tests := []struct {
+		name          string
+		url           string
+		body          string
+		responseCode  int  // corresponding response codes
+		wantPoints    int  // points if expect success
+		wantBreakdown bool // true if breakdown expected
+	}{
+		{"Valid receipt", "/receipts/score", targetReceipt, http.StatusOK, 28, false},
+		{"Valid receipt with breakdown", "/receipts/score?breakdown=true", targetReceipt, http.StatusOK, 28, true},
+		{"Invalid receipt", "/receipts/score", `{"retailer": "Target"}`, http.StatusBadRequest, 0, false},
+		{"Invalid JSON", "/receipts/score", `{"retailer": `, http.StatusBadRequest, 0, false},
+	}
+
+	for _, testCase := range tests {
+		t.Run(testCase.name, func(t *testing.T) {
+			request := httptest.NewRequest("POST", testCase.url, bytes.NewReader([]byte(testCase.body)))
+			responseRecorder := httptest.NewRecorder()
+			handler.ScoreReceiptHandler(responseRecorder, request)
+
+			if responseRecorder.Code != testCase.responseCode {
+				t.Fatalf("Result status: %d, want: %d", responseRecorder.Code, testCase.responseCode)
+			}
+			if testCase.responseCode != http.StatusOK {
+				return
+			}
+
+			var response ScoreResponse
+			if err := json.Unmarshal(responseRecorder.Body.Bytes(), &response); err != nil {
+				t.Fatalf("Error during test parsing successful result JSON: %v", err)
+			}
+			if response.Points != testCase.wantPoints {
+				t.Errorf("Result points: %d, want: %d", response.Points, testCase.wantPoints)
+			}
+			if (len(response.Breakdown) > 0) != testCase.wantBreakdown {
+				t.Errorf("Result breakdown: %v, want breakdown %v", response.Breakdown, testCase.wantBreakdown)
+			}
+
+			// Breakdown adds up to points
+			sum := 0
+			for _, rule := range response.Breakdown {
+				sum += rule.Points
+			}
+			if testCase.wantBreakdown && sum != response.Points {
+				t.Errorf("Result breakdown adds up to %d, want %d", sum, response.Points)
+			}
+		})
+	}
+
+	// Nothing was stored
+	if receipts := db.ListReceipts(); len(receipts) != 0 {
+		t.Errorf("Result %d stored receipts, want 0", len(receipts))
+	}
+}
+
+// TestScoreReceiptHandlerWithUser tests the preview includes the user's tier without crediting them
+func TestScoreReceiptHandlerWithUser(t *testing.T) {
+	db := store.NewMemoryDatabase()
+	handler := NewReceiptHandler(db)
+	handler.Rules.Tiers = rules.TierConfig{Tiers: []rules.Tier{
+		{Name: "Bronze", Multiplier: 1},
+		{Name: "Silver", MinReceipts: 1, Multiplier: 2},
+	}}
+	submitUserReceipt(t, handler, "user-1") // 109 points, now Silver
+
+	body := `{"retailer": "M&M Corner Market", "purchaseDate": "2022-03-20", "purchaseTime": "14:33", "userId": "user-1",
+		"items": [{"shortDescription": "Gatorade", "price": "2.25"}], "total": "2.25"}`
+	request := httptest.NewRequest("POST", "/receipts/score?breakdown=true", bytes.NewReader([]byte(body)))
+	responseRecorder := httptest.NewRecorder()
+	handler.ScoreReceiptHandler(responseRecorder, request)
+
+	// 14 retailer + 25 quarter + 10 time range = 49, doubled by Silver
+	var response ScoreResponse
+	json.Unmarshal(responseRecorder.Body.Bytes(), &response)
+	if response.Points != 98 {
+		t.Fatalf("Result points: %d, want: 98", response.Points)
+	}
+	last := response.Breakdown[len(response.Breakdown)-1]
+	if last.Rule != "tier:Silver" || last.Points != 49 {
+		t.Errorf("Result last breakdown entry: %+v, want tier:Silver with 49", last)
+	}
+
+	// Balance unchanged by preview
+	if balance, _ := db.GetUserBalance("user-1"); balance != 109 {
+		t.Errorf("Result balance: %d, want: 109", balance)
+	}
+}
+
+// TestScoreReceiptMatchesStoredAward tests the preview matches the retailer registry like submission does,
+// so a campaign for the canonical name applies to an alias in both
+func TestScoreReceiptMatchesStoredAward(t *testing.T) {
+	db := store.NewMemoryDatabase()
+	handler := NewReceiptHandler(db)
+	handler.Rules.Retailers.Registry = []rules.Retailer{{Name: "Target", Aliases: []string{"TGT STORE 1234"}}}
+	sendToHandler(handler.CreateCampaignHandler, "POST", `{"name": "Target bonus", "start": "2022-01-01T00:00:00Z",
+		"end": "2022-02-01T00:00:00Z", "retailers": ["Target"], "bonus": {"type": "flat", "value": 100}}`, nil)
+
+	body := `{"retailer": "TGT STORE 1234", "purchaseDate": "2022-01-01", "purchaseTime": "13:01",
+		"items": [{"shortDescription": "Pepsi - 12-oz", "price": "1.25"}], "total": "1.25"}`
+	var preview ScoreResponse
+	json.Unmarshal(sendToHandler(handler.ScoreReceiptHandler, "POST", body, nil).Body.Bytes(), &preview)
+	var created ReceiptResponse
+	json.Unmarshal(sendToHandler(handler.CreateReceiptHandler, "POST", body, nil).Body.Bytes(), &created)
+	stored, err := db.GetReceiptByID(created.ID)
+	if err != nil {
+		t.Fatalf("Receipt not stored: %v", err)
+	}
+
+	if len(stored.Award.Campaigns) != 1 || preview.Points != stored.Award.Points {
+		t.Errorf("Result preview %d points, stored award %+v, want the same points with the Target bonus", preview.Points, stored.Award)
+	}
+}
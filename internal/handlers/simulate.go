@@ -0,0 +1,51 @@
+package handlers
+
+import (
+	"encoding/json"
+	"net/http"
+
+	rules "receipt-processor-challenge-jase180/internal/services"
+)
+
+// SimulateRequest is the body for POST /admin/simulate
+type SimulateRequest struct {
+	Rules      json.RawMessage        `json:"rules"`      // Candidate rules config in the same format as the rules file
+	Filter     rules.SimulationFilter `json:"filter"`     // Optional subset of receipts to re-score
+	BucketSize int                    `json:"bucketSize"` // Histogram bucket width in points, default 25
+	Top        int                    `json:"top"`        // Largest changes to return, default 10, at most 100
+}
+
+// SimulateHandler takes a POST request with /admin/simulate endpoint and re-scores stored receipts
+// with the current and candidate rules so product can see the impact before shipping a rule change
+func (h *ReceiptHandler) SimulateHandler(w http.ResponseWriter, r *http.Request) {
+	var request SimulateRequest
+	if err := decodeJSONBody(w, r, &request); err != nil {
+		sendJSON(w, map[string]string{"error": "Invalid JSON"}, http.StatusBadRequest) // 400 response
+		return
+	}
+	if len(request.Rules) == 0 {
+		sendJSON(w, map[string]string{"error": "BadRequest: Candidate rules are required"}, http.StatusBadRequest)
+		return
+	}
+
+	// Candidate is validated exactly like a rules file so a passing simulation can be shipped as is
+	// Its expression limits are capped at the running rules' so a request cannot buy more steps or time
+	candidate, err := rules.ParseCandidateConfig(request.Rules, h.Rules)
+	if err != nil {
+		sendJSON(w, map[string]string{"error": "BadRequest: " + err.Error()}, http.StatusBadRequest)
+		return
+	}
+
+	if request.BucketSize <= 0 {
+		request.BucketSize = 25
+	}
+	if request.Top <= 0 {
+		request.Top = 10
+	}
+	if request.Top > 100 {
+		request.Top = 100
+	}
+
+	result := rules.Simulate(h.Database.ListReceipts(), h.Rules, candidate, request.Filter, request.BucketSize, request.Top)
+	sendJSON(w, result, http.StatusOK)
+}
@@ -0,0 +1,55 @@
+package handlers
+
+import (
+	"encoding/json"
+	"net/http"
+	"testing"
+
+	rules "receipt-processor-challenge-jase180/internal/services"
+	"receipt-processor-challenge-jase180/internal/store"
+)
+
+func TestSimulateHandler(t *testing.T) {
+	db := store.NewMemoryDatabase()
+	handler := NewReceiptHandler(db)
+	submitUserReceipt(t, handler, "user-1") // M&M README receipt, 109 points and 4 items
+
+	tests := []struct {
+		name         string
+		body         string
+		responseCode int // corresponding response codes
+		wantDelta    int // total points delta if expect success
+	}{
+		{"Candidate adds per item rule", `{"rules": {"expressions": {"rules": [{"name": "Per item", "expression": "itemCount * 10"}]}}}`, http.StatusOK, 40},
+		{"Candidate same as current", `{"rules": {}}`, http.StatusOK, 0},
+		{"Filter excludes receipt", `{"rules": {"expressions": {"rules": [{"name": "Per item", "expression": "itemCount * 10"}]}},
+			"filter": {"retailer": "Target"}}`, http.StatusOK, 0},
+		{"Missing rules", `{"filter": {}}`, http.StatusBadRequest, 0},
+		{"Candidate does not parse", `{"rules": {"expressions": {"rules": [{"name": "Broken", "expression": "1 +"}]}}}`, http.StatusBadRequest, 0},
+		{"Invalid JSON", `{"rules": `, http.StatusBadRequest, 0},
+		// Running rules allow 3 steps, the candidate's own limit is ignored so the 5 step rule fails and scores 0
+		{"Candidate limits capped at running limits", `{"rules": {"expressions": {"maxSteps": 1000000000,
+			"rules": [{"name": "Per item", "expression": "itemCount * 10 + 0"}]}}}`, http.StatusOK, 0},
+	}
+	handler.Rules.Expressions.MaxSteps = 3
+
+	for _, testCase := range tests {
+		t.Run(testCase.name, func(t *testing.T) {
+			responseRecorder := sendToHandler(handler.SimulateHandler, "POST", testCase.body, nil)
+			if responseRecorder.Code != testCase.responseCode {
+				t.Fatalf("Result status: %d, want: %d", responseRecorder.Code, testCase.responseCode)
+			}
+			if testCase.responseCode != http.StatusOK {
+				return
+			}
+
+			var result rules.SimulationResult
+			if err := json.Unmarshal(responseRecorder.Body.Bytes(), &result); err != nil {
+				t.Fatalf("Error during test parsing successful result JSON: %v", err)
+			}
+			if result.Delta != testCase.wantDelta {
+				t.Errorf("Result delta: %d, want: %d", result.Delta, testCase.wantDelta)
+			}
+		})
+	}
+}
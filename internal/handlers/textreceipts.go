@@ -0,0 +1,60 @@
+package handlers
+
+import (
+	"io"
+	"net/http"
+	"strings"
+
+	"receipt-processor-challenge-jase180/internal/models"
+	"receipt-processor-challenge-jase180/internal/parser"
+)
+
+// TextReceiptResponse is the response for POST /receipts/process/text
+// ID is only set when the parsed receipt was valid and stored, Error only when it was not
+type TextReceiptResponse struct {
+	ID         string            `json:"id,omitempty"`
+	Error      string            `json:"error,omitempty"`
+	Template   string            `json:"template,omitempty"` // Parser template that matched, empty if only heuristics were used
+	Receipt    models.Receipt    `json:"receipt"`            // Receipt as parsed, with the server-assigned fields when stored
+	Confidence parser.Confidence `json:"confidence"`         // How sure the parser is of each field, 0 to 1
+}
+
+// CreateTextReceiptHandler takes the plain text of a printed receipt, parses it into a receipt and processes it
+// like CreateReceiptHandler. The parsed receipt and confidences are returned either way so clients can see
+// what was read, a receipt that fails validation is 400 and is not stored
+func (h *ReceiptHandler) CreateTextReceiptHandler(w http.ResponseWriter, r *http.Request) {
+	// Size limiting to prevent DoS and resource exhaustion
+	r.Body = http.MaxBytesReader(w, r.Body, h.MaxBodyBytes) // 1 MB unless configured
+	defer r.Body.Close()
+
+	body, err := io.ReadAll(r.Body)
+	if err != nil {
+		sendJSON(w, map[string]string{"error": "Invalid request body"}, http.StatusBadRequest)
+		return
+	}
+	if strings.TrimSpace(string(body)) == "" {
+		sendJSON(w, map[string]string{"error": "BadRequest: No receipt text given"}, http.StatusBadRequest)
+		return
+	}
+
+	result := parser.Parse(string(body), h.Templates)
+	receipt := result.Receipt
+	receipt.UserID = strings.TrimSpace(r.Header.Get(userIDHeader)) // printed receipts have no user, only the header can give one
+
+	response := TextReceiptResponse{Template: result.Template, Receipt: receipt, Confidence: result.Confidence}
+	if err := h.validate(receipt); err != nil {
+		response.Error = err.Error()
+		sendJSON(w, response, http.StatusBadRequest) // 400
+		return
+	}
+
+	stored, err := h.storeReceipt(receipt)
+	if err != nil {
+		sendJSON(w, map[string]string{"error": err.Error()}, http.StatusInternalServerError) // 500 response
+		return
+	}
+
+	response.ID = stored.ID
+	response.Receipt = stored
+	sendJSON(w, response, http.StatusOK)
+}
@@ -0,0 +1,74 @@
+package handlers
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"receipt-processor-challenge-jase180/internal/store"
+)
+
+func TestCreateTextReceiptHandler(t *testing.T) {
+	// Target README receipt is 28 points
+	targetText := "TARGET\n01/01/2022 1:01 PM\nMountain Dew 12PK 6.49\nEmils Cheese Pizza 12.25\nKnorr Creamy Chicken 1.26\n" +
+		"Doritos Nacho Cheese 3.35\nKlarbrunn 12-PK 12 FL OZ 12.00\nTOTAL 35.35\n"
+
+	tests := []struct {
+		name         string
+		body         string
+		responseCode int
+		wantPoints   int
+	}{
+		{"Valid receipt text", targetText, http.StatusOK, 28},
+		{"Missing total", "TARGET\n01/01/2022 1:01 PM\n", http.StatusBadRequest, 0},
+		{"Empty body", "  \n", http.StatusBadRequest, 0},
+	}
+
+	for _, testCase := range tests {
+		t.Run(testCase.name, func(t *testing.T) {
+			db := store.NewMemoryDatabase()
+			handler := NewReceiptHandler(db)
+
+			request := httptest.NewRequest("POST", "/receipts/process/text", strings.NewReader(testCase.body))
+			request.Header.Set("Content-Type", "text/plain")
+			request.Header.Set(userIDHeader, "user-1")
+			responseRecorder := httptest.NewRecorder()
+			handler.CreateTextReceiptHandler(responseRecorder, request)
+
+			if responseRecorder.Code != testCase.responseCode {
+				t.Fatalf("Result status: %d, want: %d, body: %s", responseRecorder.Code, testCase.responseCode, responseRecorder.Body.String())
+			}
+
+			var response TextReceiptResponse
+			if err := json.Unmarshal(responseRecorder.Body.Bytes(), &response); err != nil {
+				t.Fatalf("Error during test parsing result JSON: %v", err)
+			}
+			if testCase.responseCode != http.StatusOK {
+				if response.Error == "" || response.ID != "" {
+					t.Errorf("Result error %q id %q, want an error and no id", response.Error, response.ID)
+				}
+				if receipts := db.ListReceipts(); len(receipts) != 0 {
+					t.Errorf("Result %d stored receipts, want 0", len(receipts))
+				}
+				return
+			}
+
+			// Parsed receipt is stored and awarded like a JSON submission, tied to the header user
+			stored, err := db.GetReceiptByID(response.ID)
+			if err != nil {
+				t.Fatalf("Result receipt %q not stored: %v", response.ID, err)
+			}
+			if stored.Award == nil || stored.Award.Points != testCase.wantPoints {
+				t.Errorf("Result award: %+v, want %d points", stored.Award, testCase.wantPoints)
+			}
+			if stored.UserID != "user-1" || response.Receipt.ID != response.ID {
+				t.Errorf("Result user %q receipt id %q, want user-1 and %q", stored.UserID, response.Receipt.ID, response.ID)
+			}
+			if response.Confidence.Items < 0.9 || response.Confidence.Total < 0.9 {
+				t.Errorf("Result confidence: %+v, want items and total at least 0.9", response.Confidence)
+			}
+		})
+	}
+}
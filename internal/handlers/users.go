@@ -0,0 +1,79 @@
+package handlers
+
+import (
+	"net/http"
+
+	"time"
+
+	"github.com/gorilla/mux"
+
+	"receipt-processor-challenge-jase180/internal/models"
+)
+
+// UserReceiptPoints is one receipt that contributed to a user's points
+type UserReceiptPoints struct {
+	ID     string `json:"id"`     // Receipt ID
+	Points int    `json:"points"` // Points the receipt was awarded
+	Tier   string `json:"tier"`   // Tier the user was in when the receipt was submitted
+
+	CappedPoints int    `json:"cappedPoints,omitempty"` // Points removed by earning limits
+	CapReason    string `json:"capReason,omitempty"`    // Limit that capped the points
+}
+
+// newUserReceiptPoints returns the points, tier and caps recorded on a receipt
+func (h *ReceiptHandler) newUserReceiptPoints(receipt models.Receipt) UserReceiptPoints {
+	points := UserReceiptPoints{ID: receipt.ID, Points: h.receiptPoints(receipt)}
+	if receipt.Award != nil {
+		points.Tier = receipt.Award.Tier
+		points.CappedPoints = receipt.Award.CappedPoints
+		points.CapReason = receipt.Award.CapReason
+	}
+	return points
+}
+
+// UserPointsResponse is the response for GET /users/{id}/points
+type UserPointsResponse struct {
+	UserID         string              `json:"userId"`         // User the points belong to
+	Tier           string              `json:"tier"`           // Loyalty tier the user is in now
+	Balance        int                 `json:"balance"`        // Points currently available to the user
+	LifetimePoints int                 `json:"lifetimePoints"` // Total points earned from receipts net of reversals
+	Receipts       []UserReceiptPoints `json:"receipts"`       // Receipts that contributed in submission order
+}
+
+// GetUserPointsHandler takes a GET request with /users/{id}/points endpoint, where id is the user ID given on submission
+// Points are those awarded to each receipt, balance and lifetime total come from the ledger
+func (h *ReceiptHandler) GetUserPointsHandler(w http.ResponseWriter, r *http.Request) {
+	vars := mux.Vars(r)
+	id := vars["id"]
+
+	// Check ID is present and in same format validated on submission
+	if !regexUserID.MatchString(id) {
+		sendJSON(w, map[string]string{"error": "BadRequest: Invalid user ID format"}, http.StatusBadRequest) // 400 response
+		return
+	}
+
+	// Look up receipts for user and raise error if user never submitted a receipt
+	receipts, err := h.Database.GetReceiptsByUser(id)
+	if err != nil {
+		sendJSON(w, map[string]string{"error": "No user found for that ID"}, http.StatusNotFound) // 404 response
+		return
+	}
+
+	response := UserPointsResponse{
+		UserID:   id,
+		Receipts: make([]UserReceiptPoints, 0, len(receipts)),
+	}
+
+	// Points awarded per receipt for the contributing receipts
+	for _, receipt := range receipts {
+		response.Receipts = append(response.Receipts, h.newUserReceiptPoints(receipt))
+	}
+
+	// Tier is evaluated now so demotions show up as activity leaves the window
+	response.Tier = h.Rules.Tiers.Evaluate(h.Database.GetLedgerByUser(id), time.Now().UTC()).Name
+
+	// Balance and lifetime come from the ledger so redemptions, adjustments and reversals are included
+	response.Balance, response.LifetimePoints = h.Database.GetUserBalance(id)
+
+	sendJSON(w, response, http.StatusOK)
+}
@@ -0,0 +1,186 @@
+package handlers
+
+import (
+	"bytes"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/google/uuid"
+	"github.com/gorilla/mux"
+
+	"receipt-processor-challenge-jase180/internal/models"
+	rules "receipt-processor-challenge-jase180/internal/services"
+	"receipt-processor-challenge-jase180/internal/store"
+)
+
+func TestGetUserPointsHandler(t *testing.T) {
+	// Initialize database and handler
+	db := store.NewMemoryDatabase()
+	handler := NewReceiptHandler(db)
+
+	// README receipts, Target one tied by body userId (28 points) and M&M one by header (109 points)
+	targetReceipt := `{"retailer": "Target", "purchaseDate": "2022-01-01", "purchaseTime": "13:01", "userId": "user-1",
+		"items": [
+			{"shortDescription": "Mountain Dew 12PK", "price": "6.49"},
+			{"shortDescription": "Emils Cheese Pizza", "price": "12.25"},
+			{"shortDescription": "Knorr Creamy Chicken", "price": "1.26"},
+			{"shortDescription": "Doritos Nacho Cheese", "price": "3.35"},
+			{"shortDescription": "   Klarbrunn 12-PK 12 FL OZ  ", "price": "12.00"}
+		], "total": "35.35"}`
+	mmCornerReceipt := `{"retailer": "M&M Corner Market", "purchaseDate": "2022-03-20", "purchaseTime": "14:33",
+		"items": [
+			{"shortDescription": "Gatorade", "price": "2.25"},
+			{"shortDescription": "Gatorade", "price": "2.25"},
+			{"shortDescription": "Gatorade", "price": "2.25"},
+			{"shortDescription": "Gatorade", "price": "2.25"}
+		], "total": "9.00"}`
+
+	for _, body := range []string{targetReceipt, mmCornerReceipt} {
+		request := httptest.NewRequest("POST", "/receipts/process", bytes.NewReader([]byte(body)))
+		request.Header.Set(userIDHeader, "user-1")
+		responseRecorder := httptest.NewRecorder()
+		handler.CreateReceiptHandler(responseRecorder, request)
+		if responseRecorder.Code != http.StatusOK {
+			t.Fatalf("Result status: %d, want: %d", responseRecorder.Code, http.StatusOK)
+		}
+	}
+
+	tests := []struct {
+		name         string
+		userID       string
+		responseCode int // corresponding response codes
+		wantPoints   int // lifetime and balance points if expect success
+		wantReceipts int // number of contributing receipts if expect success
+	}{
+		{"User with receipts", "user-1", http.StatusOK, 137, 2},
+		{"No such user", "user-2", http.StatusNotFound, 0, 0},
+		{"Invalid user ID", "user 1", http.StatusBadRequest, 0, 0},
+		{"Empty user ID", "", http.StatusBadRequest, 0, 0},
+	}
+
+	for _, testCase := range tests {
+		t.Run(testCase.name, func(t *testing.T) {
+			request := httptest.NewRequest("GET", "/users/points", nil)
+			request = mux.SetURLVars(request, map[string]string{"id": testCase.userID})
+
+			responseRecorder := httptest.NewRecorder()
+			handler.GetUserPointsHandler(responseRecorder, request)
+
+			// Check if it has correct response code
+			if responseRecorder.Code != testCase.responseCode {
+				t.Fatalf("Result status: %d, want: %d", responseRecorder.Code, testCase.responseCode)
+			}
+			if testCase.responseCode != http.StatusOK {
+				return
+			}
+
+			var response UserPointsResponse
+			if err := json.Unmarshal(responseRecorder.Body.Bytes(), &response); err != nil {
+				t.Fatalf("Error during test parsing successful result JSON: %v", err)
+			}
+			if response.LifetimePoints != testCase.wantPoints || response.Balance != testCase.wantPoints {
+				t.Errorf("Result lifetime %d balance %d, want %d", response.LifetimePoints, response.Balance, testCase.wantPoints)
+			}
+			if len(response.Receipts) != testCase.wantReceipts {
+				t.Errorf("Result %d receipts, want %d", len(response.Receipts), testCase.wantReceipts)
+			}
+		})
+	}
+}
+
+// TestTierMultiplierOnReceipts tests tier multipliers apply to new receipts and the tier is recorded on each receipt
+func TestTierMultiplierOnReceipts(t *testing.T) {
+	db := store.NewMemoryDatabase()
+	handler := NewReceiptHandler(db)
+	handler.Rules.Tiers = rules.TierConfig{
+		Tiers: []rules.Tier{
+			{Name: "Bronze", Multiplier: 1},
+			{Name: "Silver", MinReceipts: 1, Multiplier: 2}, // promoted after first receipt
+		},
+	}
+
+	// First receipt at Bronze earns 109, second at Silver earns 218
+	submitUserReceipt(t, handler, "user-1")
+	submitUserReceipt(t, handler, "user-1")
+
+	var response UserPointsResponse
+	json.Unmarshal(sendToHandler(handler.GetUserPointsHandler, "GET", "", map[string]string{"id": "user-1"}).Body.Bytes(), &response)
+
+	if response.Tier != "Silver" || response.LifetimePoints != 327 {
+		t.Fatalf("Result tier %s lifetime %d, want Silver and 327", response.Tier, response.LifetimePoints)
+	}
+	wantReceipts := []UserReceiptPoints{{Points: 109, Tier: "Bronze"}, {Points: 218, Tier: "Silver"}}
+	for i, want := range wantReceipts {
+		if response.Receipts[i].Points != want.Points || response.Receipts[i].Tier != want.Tier {
+			t.Errorf("Result receipt %d: %+v, want %+v", i, response.Receipts[i], want)
+		}
+	}
+
+	// Receipt endpoint returns the multiplied points too
+	result := sendToHandler(handler.GetReceiptHandler, "GET", "", map[string]string{"id": response.Receipts[1].ID})
+	var points map[string]int
+	json.Unmarshal(result.Body.Bytes(), &points)
+	if points["points"] != 218 {
+		t.Errorf("Result points %d, want 218", points["points"])
+	}
+}
+
+// TestLimitsOnReceipts tests earning limits cap points and the reason is reported in points responses
+func TestLimitsOnReceipts(t *testing.T) {
+	db := store.NewMemoryDatabase()
+	handler := NewReceiptHandler(db)
+	handler.Rules.Limits = rules.LimitConfig{MaxPointsPerRetailerPerDay: 150}
+
+	// Same M&M receipt twice on the same day, second one only has 41 points of room
+	submitUserReceipt(t, handler, "user-1")
+	submitUserReceipt(t, handler, "user-1")
+
+	var response UserPointsResponse
+	json.Unmarshal(sendToHandler(handler.GetUserPointsHandler, "GET", "", map[string]string{"id": "user-1"}).Body.Bytes(), &response)
+	if response.Balance != 150 {
+		t.Fatalf("Result balance %d, want 150", response.Balance)
+	}
+
+	// Receipt points response explains the cap
+	result := sendToHandler(handler.GetReceiptHandler, "GET", "", map[string]string{"id": response.Receipts[1].ID})
+	var points PointsResponse
+	json.Unmarshal(result.Body.Bytes(), &points)
+	if points.Points != 41 || points.CappedPoints != 68 || points.CapReason != "daily retailer limit of 150 points reached" {
+		t.Errorf("Result %+v, want 41 points with 68 capped by daily retailer limit", points)
+	}
+}
+
+// TestRetailerLimitAcrossUsers tests the retailer limit counts every user's receipts and reversed awards stop counting
+func TestRetailerLimitAcrossUsers(t *testing.T) {
+	db := store.NewMemoryDatabase()
+	handler := NewReceiptHandler(db)
+	handler.Rules.Limits = rules.LimitConfig{MaxPointsPerRetailerPerDay: 150}
+
+	// 109 point M&M receipts on the same day from different users and anonymously
+	submitUserReceipt(t, handler, "user-1")
+	submitUserReceipt(t, handler, "user-2")
+	submitUserReceipt(t, handler, "")
+
+	// Reversing user-1's award gives its points back to the retailer limit
+	if _, err := db.PostLedgerEntry(models.LedgerEntry{ID: uuid.NewString(), Type: models.LedgerReversal,
+		ReversalOf: db.GetLedgerByUser("user-1")[0].ID}); err != nil {
+		t.Fatalf("Result reversal error: %v", err)
+	}
+	submitUserReceipt(t, handler, "user-3")
+
+	receipts := db.GetReceiptsByPurchaseDate("2022-03-20")
+	expected := []int{109, 41, 0, 109}
+	if len(receipts) != len(expected) {
+		t.Fatalf("Result %d receipts, want %d", len(receipts), len(expected))
+	}
+	for i, receipt := range receipts {
+		if receipt.Award == nil || receipt.Award.Points != expected[i] {
+			t.Errorf("Result receipt %d award %+v, want %d points", i, receipt.Award, expected[i])
+		}
+	}
+	if reason := receipts[2].Award.CapReason; reason != "daily retailer limit of 150 points reached" {
+		t.Errorf("Result anonymous cap reason %q, want daily retailer limit", reason)
+	}
+}
@@ -0,0 +1,213 @@
+package metrics
+
+import (
+	"fmt"
+	"math"
+	"sort"
+	"strings"
+	"sync"
+)
+
+// DefaultBuckets are upper bounds in seconds for latency histograms, the Prometheus client defaults
+var DefaultBuckets = []float64{0.005, 0.01, 0.025, 0.05, 0.1, 0.25, 0.5, 1, 2.5, 5, 10}
+
+// StoreBuckets are upper bounds in seconds for in-memory store operations, which take microseconds
+var StoreBuckets = []float64{0.000001, 0.00001, 0.0001, 0.001, 0.01, 0.1, 1}
+
+// Registry holds metric families and writes them in the Prometheus text format
+// Families are created once at startup, series inside them are created on first use
+type Registry struct {
+	lock     sync.Mutex
+	families map[string]family
+}
+
+// family is one named metric with its series
+type family interface {
+	write(w *textWriter)
+}
+
+// NewRegistry returns an empty registry
+func NewRegistry() *Registry {
+	return &Registry{families: make(map[string]family)}
+}
+
+// register adds a family, a bad or repeated name is a programming error
+func (r *Registry) register(name string, labels []string, f family) {
+	for _, label := range append([]string{name}, labels...) {
+		if !validName(label) {
+			panic(fmt.Sprintf("metrics: invalid name %q in %s", label, name))
+		}
+	}
+	r.lock.Lock()
+	defer r.lock.Unlock()
+	if _, exists := r.families[name]; exists {
+		panic(fmt.Sprintf("metrics: %s registered twice", name))
+	}
+	r.families[name] = f
+}
+
+// series is what every family shares: its name, help and label names, and a lock for its series
+type series struct {
+	name   string
+	help   string
+	labels []string
+	lock   sync.Mutex
+}
+
+// key joins label values into a map key, panics when the count does not match the label names
+func (s *series) key(values []string) string {
+	if len(values) != len(s.labels) {
+		panic(fmt.Sprintf("metrics: %s takes %d label values, got %d", s.name, len(s.labels), len(values)))
+	}
+	return strings.Join(values, "\xff")
+}
+
+// sortedKeys returns series keys in order so output is stable between scrapes
+func sortedKeys[V any](m map[string]V) []string {
+	keys := make([]string, 0, len(m))
+	for key := range m {
+		keys = append(keys, key)
+	}
+	sort.Strings(keys)
+	return keys
+}
+
+// Counter is a value per label set that only goes up, e.g. requests served
+type Counter struct {
+	series
+	values map[string]*counterValue
+}
+
+type counterValue struct {
+	labels []string
+	value  float64
+}
+
+// NewCounter registers a counter, name should end in _total
+func (r *Registry) NewCounter(name, help string, labels ...string) *Counter {
+	c := &Counter{series: series{name: name, help: help, labels: labels}, values: make(map[string]*counterValue)}
+	r.register(name, labels, c)
+	return c
+}
+
+// Inc adds 1 to the series with the label values, given in the order of the label names
+func (c *Counter) Inc(values ...string) {
+	c.Add(1, values...)
+}
+
+// Add adds v to the series with the label values, negative values are ignored as counters never go down
+func (c *Counter) Add(v float64, values ...string) {
+	key := c.key(values)
+	if v < 0 || math.IsNaN(v) {
+		return
+	}
+	c.lock.Lock()
+	defer c.lock.Unlock()
+	value, exists := c.values[key]
+	if !exists {
+		value = &counterValue{labels: append([]string(nil), values...)}
+		c.values[key] = value
+	}
+	value.value += v
+}
+
+func (c *Counter) write(w *textWriter) {
+	c.lock.Lock()
+	defer c.lock.Unlock()
+	w.header(c.name, c.help, "counter")
+	for _, key := range sortedKeys(c.values) {
+		value := c.values[key]
+		w.sample(c.name, c.labels, value.labels, "", "", value.value)
+	}
+}
+
+// Histogram counts observations into buckets per label set, e.g. request latency in seconds
+type Histogram struct {
+	series
+	buckets []float64
+	values  map[string]*histogramValue
+}
+
+type histogramValue struct {
+	labels []string
+	counts []uint64 // per bucket, not cumulative, the last one is +Inf
+	sum    float64
+	count  uint64
+}
+
+// NewHistogram registers a histogram with bucket upper bounds in increasing order, +Inf is added
+func (r *Registry) NewHistogram(name, help string, buckets []float64, labels ...string) *Histogram {
+	if !sort.Float64sAreSorted(buckets) {
+		panic(fmt.Sprintf("metrics: %s buckets are not in increasing order", name))
+	}
+	h := &Histogram{series: series{name: name, help: help, labels: labels}, buckets: buckets, values: make(map[string]*histogramValue)}
+	r.register(name, labels, h)
+	return h
+}
+
+// Observe records v in the series with the label values
+func (h *Histogram) Observe(v float64, values ...string) {
+	key := h.key(values)
+	h.lock.Lock()
+	defer h.lock.Unlock()
+	value, exists := h.values[key]
+	if !exists {
+		value = &histogramValue{labels: append([]string(nil), values...), counts: make([]uint64, len(h.buckets)+1)}
+		h.values[key] = value
+	}
+	value.counts[sort.SearchFloat64s(h.buckets, v)]++ // first bucket with an upper bound >= v
+	value.sum += v
+	value.count++
+}
+
+func (h *Histogram) write(w *textWriter) {
+	h.lock.Lock()
+	defer h.lock.Unlock()
+	w.header(h.name, h.help, "histogram")
+	for _, key := range sortedKeys(h.values) {
+		value := h.values[key]
+		var cumulative uint64
+		for i, count := range value.counts {
+			cumulative += count
+			bound := math.Inf(1)
+			if i < len(h.buckets) {
+				bound = h.buckets[i]
+			}
+			w.sample(h.name+"_bucket", h.labels, value.labels, "le", formatFloat(bound), float64(cumulative))
+		}
+		w.sample(h.name+"_sum", h.labels, value.labels, "", "", value.sum)
+		w.sample(h.name+"_count", h.labels, value.labels, "", "", float64(value.count))
+	}
+}
+
+// GaugeFunc is a value read when scraped, e.g. the number of stored receipts
+type GaugeFunc struct {
+	series
+	read func() float64
+}
+
+// NewGaugeFunc registers a gauge without labels whose value comes from read on every scrape
+func (r *Registry) NewGaugeFunc(name, help string, read func() float64) *GaugeFunc {
+	g := &GaugeFunc{series: series{name: name, help: help}, read: read}
+	r.register(name, nil, g)
+	return g
+}
+
+func (g *GaugeFunc) write(w *textWriter) {
+	w.header(g.name, g.help, "gauge")
+	w.sample(g.name, nil, nil, "", "", g.read())
+}
+
+// validName reports whether name is a Prometheus metric or label name
+func validName(name string) bool {
+	if name == "" {
+		return false
+	}
+	for i, c := range name {
+		letter := c == '_' || c == ':' || (c >= 'a' && c <= 'z') || (c >= 'A' && c <= 'Z')
+		if !letter && (i == 0 || c < '0' || c > '9') {
+			return false
+		}
+	}
+	return true
+}
@@ -0,0 +1,122 @@
+package metrics
+
+import (
+	"bytes"
+	"testing"
+
+	"receipt-processor-challenge-jase180/internal/metrics/metricstest"
+)
+
+func TestWriteText(t *testing.T) {
+	registry := NewRegistry()
+	requests := registry.NewCounter("http_requests_total", "Requests served.", "route", "status")
+	latency := registry.NewHistogram("http_request_duration_seconds", "Request latency.", []float64{0.1, 1}, "route")
+	registry.NewGaugeFunc("store_receipts", "Stored receipts.\nLine two with a \\.", func() float64 { return 3 })
+
+	requests.Inc("/receipts/{id}", "200")
+	requests.Inc("/receipts/{id}", "200")
+	requests.Add(2.5, `say "hi"\`+"\n", "400")
+	requests.Add(-1, "/receipts/{id}", "200") // ignored, counters only go up
+	latency.Observe(0.05, "/a")
+	latency.Observe(0.1, "/a") // upper bounds are inclusive
+	latency.Observe(3, "/a")
+
+	var out bytes.Buffer
+	if err := registry.WriteText(&out); err != nil {
+		t.Fatalf("Result error: %v", err)
+	}
+	want := `# HELP http_request_duration_seconds Request latency.
+# TYPE http_request_duration_seconds histogram
+http_request_duration_seconds_bucket{route="/a",le="0.1"} 2
+http_request_duration_seconds_bucket{route="/a",le="1"} 2
+http_request_duration_seconds_bucket{route="/a",le="+Inf"} 3
+http_request_duration_seconds_sum{route="/a"} 3.15
+http_request_duration_seconds_count{route="/a"} 3
+# HELP http_requests_total Requests served.
+# TYPE http_requests_total counter
+http_requests_total{route="/receipts/{id}",status="200"} 2
+http_requests_total{route="say \"hi\"\\\n",status="400"} 2.5
+# HELP store_receipts Stored receipts.\nLine two with a \\.
+# TYPE store_receipts gauge
+store_receipts 3
+`
+	if out.String() != want {
+		t.Errorf("Result:\n%s\nwant:\n%s", out.String(), want)
+	}
+
+	// Parsing the output gives back the values and unescaped labels
+	samples, err := metricstest.Parse(&out)
+	if err != nil {
+		t.Fatalf("Result parse error: %v", err)
+	}
+	if len(samples) != 8 {
+		t.Errorf("Result %d samples, want 8", len(samples))
+	}
+	if value, ok := metricstest.Find(samples, "http_requests_total", map[string]string{"route": `say "hi"\` + "\n"}); !ok || value != 2.5 {
+		t.Errorf("Result escaped label value %v %v, want 2.5", value, ok)
+	}
+	if value, ok := metricstest.Find(samples, "http_request_duration_seconds_bucket", map[string]string{"le": "+Inf"}); !ok || value != 3 {
+		t.Errorf("Result +Inf bucket %v %v, want 3", value, ok)
+	}
+	if total := metricstest.Sum(samples, "http_requests_total", nil); total != 4.5 {
+		t.Errorf("Result sum %v, want 4.5", total)
+	}
+}
+
+func TestRegistryPanics(t *testing.T) {
+	tests := []struct {
+		name string
+		run  func(registry *Registry)
+	}{
+		{name: "registered twice", run: func(registry *Registry) {
+			registry.NewCounter("a_total", "")
+			registry.NewCounter("a_total", "")
+		}},
+		{name: "invalid metric name", run: func(registry *Registry) { registry.NewCounter("2xx", "") }},
+		{name: "invalid label name", run: func(registry *Registry) { registry.NewCounter("a_total", "", "bad-label") }},
+		{name: "unsorted buckets", run: func(registry *Registry) { registry.NewHistogram("a", "", []float64{1, 0.1}) }},
+		{name: "wrong label count", run: func(registry *Registry) { registry.NewCounter("a_total", "", "route").Inc() }},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			defer func() {
+				if recover() == nil {
+					t.Errorf("Result no panic, want panic")
+				}
+			}()
+			tt.run(NewRegistry())
+		})
+	}
+}
+
+func TestConcurrentObserve(t *testing.T) {
+	registry := NewRegistry()
+	counter := registry.NewCounter("a_total", "", "n")
+	histogram := registry.NewHistogram("b", "", DefaultBuckets)
+
+	done := make(chan struct{})
+	for i := 0; i < 8; i++ {
+		go func() {
+			for j := 0; j < 1000; j++ {
+				counter.Inc("x")
+				histogram.Observe(0.01)
+			}
+			done <- struct{}{}
+		}()
+	}
+	for i := 0; i < 8; i++ {
+		registry.WriteText(&bytes.Buffer{}) // scrapes while observing
+		<-done
+	}
+
+	var out bytes.Buffer
+	registry.WriteText(&out)
+	samples, _ := metricstest.Parse(&out)
+	if value, _ := metricstest.Find(samples, "a_total", nil); value != 8000 {
+		t.Errorf("Result counter %v, want 8000", value)
+	}
+	if value, _ := metricstest.Find(samples, "b_count", nil); value != 8000 {
+		t.Errorf("Result histogram count %v, want 8000", value)
+	}
+}
@@ -0,0 +1,177 @@
+// Package metricstest reads scrapes of the Prometheus text format back so tests can check what was recorded
+package metricstest
+
+import (
+	"bufio"
+	"errors"
+	"fmt"
+	"io"
+	"strconv"
+	"strings"
+)
+
+// Sample is one line of a scrape read back by Parse
+type Sample struct {
+	Name   string            // Metric name including a histogram suffix such as _bucket
+	Labels map[string]string // Label values, unescaped
+	Value  float64
+}
+
+// Parse reads the Prometheus text format back into samples, checking each sample's family has a TYPE
+// Timestamps and exemplars are not supported
+func Parse(r io.Reader) ([]Sample, error) {
+	types := map[string]string{}
+	var samples []Sample
+	scanner := bufio.NewScanner(r)
+	for line := 1; scanner.Scan(); line++ {
+		text := scanner.Text()
+		if text == "" {
+			continue
+		}
+		if strings.HasPrefix(text, "#") {
+			fields := strings.Fields(text)
+			if len(fields) >= 4 && fields[1] == "TYPE" {
+				if _, exists := types[fields[2]]; exists {
+					return nil, fmt.Errorf("line %d: second TYPE for %s", line, fields[2])
+				}
+				types[fields[2]] = fields[3]
+			}
+			continue
+		}
+
+		sample, err := parseSample(text)
+		if err != nil {
+			return nil, fmt.Errorf("line %d: %w", line, err)
+		}
+		if _, ok := types[familyName(sample.Name, types)]; !ok {
+			return nil, fmt.Errorf("line %d: %s has no TYPE", line, sample.Name)
+		}
+		samples = append(samples, sample)
+	}
+	return samples, scanner.Err()
+}
+
+// familyName strips the suffixes histogram samples add to their family's name
+func familyName(name string, types map[string]string) string {
+	for _, suffix := range []string{"_bucket", "_sum", "_count"} {
+		if base, ok := strings.CutSuffix(name, suffix); ok && types[base] == "histogram" {
+			return base
+		}
+	}
+	return name
+}
+
+// parseSample reads `name{label="value",...} value`
+func parseSample(text string) (Sample, error) {
+	sample := Sample{Labels: map[string]string{}}
+	end := strings.IndexAny(text, "{ ")
+	if end <= 0 {
+		return sample, errors.New("missing value")
+	}
+	sample.Name, text = text[:end], text[end:]
+	if !validName(sample.Name) {
+		return sample, fmt.Errorf("invalid metric name %q", sample.Name)
+	}
+
+	if strings.HasPrefix(text, "{") {
+		text = text[1:]
+		for !strings.HasPrefix(text, "}") {
+			name, rest, ok := strings.Cut(text, `="`)
+			if !ok || !validName(name) {
+				return sample, fmt.Errorf("invalid label in %s", sample.Name)
+			}
+			value, rest, err := readLabelValue(rest)
+			if err != nil {
+				return sample, fmt.Errorf("label %s of %s: %w", name, sample.Name, err)
+			}
+			sample.Labels[name] = value
+			text = strings.TrimPrefix(rest, ",")
+			if text == "" {
+				return sample, fmt.Errorf("unterminated labels in %s", sample.Name)
+			}
+		}
+		text = text[1:]
+	}
+
+	value, err := strconv.ParseFloat(strings.TrimSpace(text), 64)
+	if err != nil {
+		return sample, fmt.Errorf("invalid value of %s: %q", sample.Name, strings.TrimSpace(text))
+	}
+	sample.Value = value
+	return sample, nil
+}
+
+// readLabelValue reads an escaped label value up to its closing quote and returns the rest of the line
+func readLabelValue(text string) (string, string, error) {
+	var value strings.Builder
+	for i := 0; i < len(text); i++ {
+		switch text[i] {
+		case '"':
+			return value.String(), text[i+1:], nil
+		case '\\':
+			i++
+			if i == len(text) {
+				return "", "", errors.New("unterminated escape")
+			}
+			switch text[i] {
+			case 'n':
+				value.WriteByte('\n')
+			case '\\', '"':
+				value.WriteByte(text[i])
+			default:
+				return "", "", fmt.Errorf(`unknown escape \%c`, text[i])
+			}
+		default:
+			value.WriteByte(text[i])
+		}
+	}
+	return "", "", errors.New("unterminated value")
+}
+
+// Find returns the value of the first sample with the name and at least the given labels, ok is false if there is none
+func Find(samples []Sample, name string, labels map[string]string) (float64, bool) {
+	for _, sample := range samples {
+		if sample.matches(name, labels) {
+			return sample.Value, true
+		}
+	}
+	return 0, false
+}
+
+// Sum adds up the values of every sample with the name and at least the given labels
+func Sum(samples []Sample, name string, labels map[string]string) float64 {
+	var total float64
+	for _, sample := range samples {
+		if sample.matches(name, labels) {
+			total += sample.Value
+		}
+	}
+	return total
+}
+
+// matches reports whether the sample has the name and every given label value
+func (s Sample) matches(name string, labels map[string]string) bool {
+	if s.Name != name {
+		return false
+	}
+	for label, value := range labels {
+		if s.Labels[label] != value {
+			return false
+		}
+	}
+	return true
+}
+
+// validName reports whether name is a Prometheus metric or label name
+func validName(name string) bool {
+	if name == "" {
+		return false
+	}
+	for i, c := range name {
+		letter := c == '_' || c == ':' || (c >= 'a' && c <= 'z') || (c >= 'A' && c <= 'Z')
+		if !letter && (i == 0 || c < '0' || c > '9') {
+			return false
+		}
+	}
+	return true
+}
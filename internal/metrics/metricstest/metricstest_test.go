@@ -0,0 +1,34 @@
+package metricstest
+
+import (
+	"strings"
+	"testing"
+)
+
+func TestParse(t *testing.T) {
+	tests := []struct {
+		name        string
+		input       string
+		expectError string
+	}{
+		{name: "valid", input: "# TYPE a counter\na{x=\"1\",y=\"2\"} 3\n\na 1e3\n"},
+		{name: "no type", input: "a 1\n", expectError: "has no TYPE"},
+		{name: "second type", input: "# TYPE a counter\n# TYPE a gauge\n", expectError: "second TYPE"},
+		{name: "bad value", input: "# TYPE a counter\na one\n", expectError: "invalid value"},
+		{name: "unterminated label", input: "# TYPE a counter\na{x=\"1} 2\n", expectError: "unterminated"},
+		{name: "bad escape", input: "# TYPE a counter\na{x=\"\\t\"} 2\n", expectError: "unknown escape"},
+		{name: "histogram suffix of another type", input: "# TYPE a counter\na_sum 1\n", expectError: "has no TYPE"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			_, err := Parse(strings.NewReader(tt.input))
+			if tt.expectError == "" && err != nil {
+				t.Errorf("Result error: %v, want none", err)
+			}
+			if tt.expectError != "" && (err == nil || !strings.Contains(err.Error(), tt.expectError)) {
+				t.Errorf("Result error: %v, want %q", err, tt.expectError)
+			}
+		})
+	}
+}
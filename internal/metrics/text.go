@@ -0,0 +1,82 @@
+package metrics
+
+import (
+	"bufio"
+	"fmt"
+	"io"
+	"math"
+	"strconv"
+	"strings"
+)
+
+// ContentType is the Content-Type of the Prometheus text format written by WriteText
+const ContentType = "text/plain; version=0.0.4; charset=utf-8"
+
+// WriteText writes every family in the Prometheus text exposition format, families ordered by name
+func (r *Registry) WriteText(w io.Writer) error {
+	r.lock.Lock()
+	names := sortedKeys(r.families)
+	families := make([]family, len(names))
+	for i, name := range names {
+		families[i] = r.families[name]
+	}
+	r.lock.Unlock() // gauges may read metrics of their own, e.g. a store operation
+
+	text := &textWriter{w: bufio.NewWriter(w)}
+	for _, f := range families {
+		f.write(text)
+	}
+	return text.w.Flush()
+}
+
+// textWriter writes HELP, TYPE and sample lines, write errors surface on Flush
+type textWriter struct {
+	w *bufio.Writer
+}
+
+func (t *textWriter) header(name, help, kind string) {
+	fmt.Fprintf(t.w, "# HELP %s %s\n# TYPE %s %s\n", name, helpEscaper.Replace(help), name, kind)
+}
+
+// sample writes one line, extraName and extraValue add a label after the series labels such as a bucket's le
+func (t *textWriter) sample(name string, labels, values []string, extraName, extraValue string, value float64) {
+	t.w.WriteString(name)
+	if len(labels) > 0 || extraName != "" {
+		t.w.WriteByte('{')
+		for i, label := range labels {
+			if i > 0 {
+				t.w.WriteByte(',')
+			}
+			fmt.Fprintf(t.w, `%s="%s"`, label, labelEscaper.Replace(values[i]))
+		}
+		if extraName != "" {
+			if len(labels) > 0 {
+				t.w.WriteByte(',')
+			}
+			fmt.Fprintf(t.w, `%s="%s"`, extraName, extraValue)
+		}
+		t.w.WriteByte('}')
+	}
+	t.w.WriteByte(' ')
+	t.w.WriteString(formatFloat(value))
+	t.w.WriteByte('\n')
+}
+
+// Escaping of the text format, label values also escape quotes
+var (
+	helpEscaper  = strings.NewReplacer(`\`, `\\`, "\n", `\n`)
+	labelEscaper = strings.NewReplacer(`\`, `\\`, "\n", `\n`, `"`, `\"`)
+)
+
+// formatFloat writes numbers the way Prometheus reads them, infinities as +Inf and -Inf
+func formatFloat(v float64) string {
+	switch {
+	case math.IsInf(v, 1):
+		return "+Inf"
+	case math.IsInf(v, -1):
+		return "-Inf"
+	case math.IsNaN(v):
+		return "NaN"
+	}
+	return strconv.FormatFloat(v, 'g', -1, 64)
+}
@@ -1,21 +1,162 @@
 package models
 
+import "time"
+
 // This file includes the structs for the components specified in api.yml.
 // Keep raw data type for struct creation and handle needed type in handlers and rules
 
 // Receipt is a receipt that would be submitted for storing in memory
 // ID will be generated from UUID in handlers; Others are expected in incoming JSON
 type Receipt struct {
-	ID           string `json:"id"`           // Unique identifier generated by google/uuid at handler
-	Retailer     string `json:"retailer"`     // Name of retailer or store the receipt is from
-	PurchaseDate string `json:"purchaseDate"` // Date of purchase on receipt in YYYY-MM-DD format
-	PurchaseTime string `json:"purchaseTime"` // Time of purchase on receipt in 24 hour time format
-	Items        []Item `json:"items"`        // Array list of Item component defined below
-	Total        string `json:"total"`        // Total amount paid on receipt
+	ID                string     `json:"id"`                          // Unique identifier generated by google/uuid at handler
+	Retailer          string     `json:"retailer"`                    // Name of retailer or store the receipt is from
+	RetailerCanonical string     `json:"retailerCanonical,omitempty"` // Registry name the retailer matched at submission, set by handler
+	PurchaseDate      string     `json:"purchaseDate"`                // Date of purchase on receipt in YYYY-MM-DD format
+	PurchaseTime      string     `json:"purchaseTime"`                // Time of purchase on receipt in 24 hour time format
+	Items             []Item     `json:"items"`                       // Array list of Item component defined below
+	Total             string     `json:"total"`                       // Total amount paid on receipt
+	Subtotal          string     `json:"subtotal,omitempty"`          // Optional amount before tax, discounts and tip
+	Taxes             []TaxLine  `json:"taxes,omitempty"`             // Optional tax lines
+	Discounts         []Discount `json:"discounts,omitempty"`         // Optional discounts and coupons, amounts are positive
+	Tip               string     `json:"tip,omitempty"`               // Optional tip
+	PaymentMethod     string     `json:"paymentMethod,omitempty"`     // Optional, one of the Payment methods below
+	Currency          string     `json:"currency,omitempty"`          // Optional ISO 4217 code of the amounts, e.g. "USD"
+	Location          *Location  `json:"location,omitempty"`          // Optional store location
+	Timezone          string     `json:"timezone,omitempty"`          // Optional IANA zone or UTC offset of the store, e.g. "America/Chicago" or "-05:00"
+	UserID            string     `json:"userId,omitempty"`            // Optional customer the receipt belongs to, from body or X-User-ID header
+	Sender            string     `json:"sender,omitempty"`            // Email address an emailed receipt came from, set by handler
+	Award             *Award     `json:"award,omitempty"`             // Points awarded at submission, set by handler and never from incoming JSON
+}
+
+// Award records how a receipt's points were worked out when it was submitted for auditability
+// Receipts without an Award (e.g. added directly to the database) are scored by rules.CalculatePoints on read
+type Award struct {
+	BasePoints   int             `json:"basePoints"`             // Points from built-in and custom rules in the rules config
+	Tier         string          `json:"tier,omitempty"`         // Loyalty tier of the user at submission, empty without a user
+	Multiplier   float64         `json:"multiplier"`             // Tier multiplier applied to BasePoints
+	Campaigns    []CampaignBonus `json:"campaigns,omitempty"`    // Bonuses from promotional campaigns, added after the multiplier
+	CappedPoints int             `json:"cappedPoints,omitempty"` // Points removed by earning limits
+	CapReason    string          `json:"capReason,omitempty"`    // Limit that capped the points, for support
+	Points       int             `json:"points"`                 // Final points awarded to the receipt
+}
+
+// Campaign bonus types
+const (
+	BonusFlat       = "flat"       // Value points once per matching receipt
+	BonusMultiplier = "multiplier" // Base points times Value, bonus is the extra on top of base
+	BonusPerItem    = "perItem"    // Value points for every matching item
+)
+
+// Campaign bonus limits, kept well inside int so a bonus can never overflow
+const (
+	MaxBonusPoints     = 1_000_000 // Largest flat or perItem value and the most points one campaign awards a receipt
+	MaxBonusMultiplier = 100       // Largest multiplier value
+)
+
+// Campaign is a time-boxed promotion that awards bonus points to matching receipts
+// Empty matcher lists match everything, matchers are case-insensitive substrings
+type Campaign struct {
+	ID        string    `json:"id"`                  // Unique identifier generated by google/uuid at handler
+	Name      string    `json:"name"`                // Display name e.g. "Black Friday at Target"
+	Start     time.Time `json:"start"`               // Purchase date/time the campaign starts, inclusive
+	End       time.Time `json:"end"`                 // Purchase date/time the campaign ends, exclusive
+	Retailers []string  `json:"retailers,omitempty"` // Receipt retailer must contain one of these
+	Items     []string  `json:"items,omitempty"`     // At least one item description must contain one of these
+	Bonus     Bonus     `json:"bonus"`               // What the campaign awards
+}
+
+// Bonus is what a campaign awards, Type is one of the Bonus types above
+type Bonus struct {
+	Type  string  `json:"type"`  // flat, multiplier or perItem
+	Value float64 `json:"value"` // Points for flat and perItem, factor for multiplier
+}
+
+// CampaignBonus is the bonus a campaign awarded to a receipt
+type CampaignBonus struct {
+	CampaignID string `json:"campaignId"` // Campaign that matched
+	Name       string `json:"name"`       // Campaign name at time of award
+	Points     int    `json:"points"`     // Bonus points awarded
+}
+
+// Payment methods a receipt can be paid with
+const (
+	PaymentCash     = "cash"
+	PaymentCredit   = "credit"
+	PaymentDebit    = "debit"
+	PaymentGiftCard = "giftCard"
+	PaymentMobile   = "mobile"
+	PaymentOther    = "other"
+)
+
+// TaxLine is one tax charged on a receipt, amounts use the same format as Total
+type TaxLine struct {
+	Name   string `json:"name"`   // Tax name printed on the receipt, e.g. "State sales tax"
+	Amount string `json:"amount"` // Tax charged
+}
+
+// Discount is a discount or coupon taken off a receipt
+type Discount struct {
+	Description string `json:"description"`    // Discount name printed on the receipt
+	Code        string `json:"code,omitempty"` // Coupon or promotion code if any
+	Amount      string `json:"amount"`         // Amount taken off, positive
+}
+
+// Location is where the store is, coordinates are optional but come as a pair
+type Location struct {
+	Address     string   `json:"address,omitempty"`     // Street address printed on the receipt
+	StoreNumber string   `json:"storeNumber,omitempty"` // Retailer's store number
+	Latitude    *float64 `json:"latitude,omitempty"`    // Degrees north, -90 to 90
+	Longitude   *float64 `json:"longitude,omitempty"`   // Degrees east, -180 to 180
 }
 
 // Item is a product purchased and will be stored in Receipt struct in an array
 type Item struct {
-	ShortDescription string `json:"shortDescription"` // Short product description for Item
-	Price            string `json:"price"`            // Total price paid for Item
+	ShortDescription string `json:"shortDescription"`    // Short product description for Item
+	Price            string `json:"price"`               // Total price paid for Item
+	Quantity         string `json:"quantity,omitempty"`  // Optional quantity, whole or up to 3 decimals for weighed items
+	UnitPrice        string `json:"unitPrice,omitempty"` // Optional price of one unit, Price must be Quantity times UnitPrice
+	Category         string `json:"category,omitempty"`  // Category assigned by the server's item classifier
+}
+
+// User is a customer that receipts can be tied to for a running points balance
+// Users are created implicitly the first time a receipt is submitted with their ID
+type User struct {
+	ID         string   `json:"id"`         // Identifier supplied by the client, not generated
+	ReceiptIDs []string `json:"receiptIds"` // IDs of receipts submitted for the user in submission order
+}
+
+// Ledger entry types, each entry moves points between two accounts (double-entry)
+const (
+	LedgerEarn       = "earn"       // Points earned from a receipt, program account to user
+	LedgerRedemption = "redemption" // Points spent by a user, user to redemptions account
+	LedgerAdjustment = "adjustment" // Manual correction by an admin in either direction
+	LedgerReversal   = "reversal"   // Undoes an earlier entry by swapping its accounts
+)
+
+// Ledger accounts that are not users, user accounts are "user:" + user ID
+const (
+	AccountEarned      = "program:earned"      // Source of all earned points
+	AccountRedeemed    = "program:redeemed"    // Sink of all redeemed points
+	AccountAdjustments = "program:adjustments" // Source or sink of admin adjustments
+)
+
+// LedgerEntry is one movement of points from DebitAccount to CreditAccount
+// Entries are never edited after posting except to record ReversedBy, corrections are new entries
+type LedgerEntry struct {
+	ID            string    `json:"id"`                   // Unique identifier generated by google/uuid at handler
+	UserID        string    `json:"userId"`               // User whose balance the entry affects
+	Type          string    `json:"type"`                 // One of the Ledger entry types above
+	DebitAccount  string    `json:"debitAccount"`         // Account points are taken from
+	CreditAccount string    `json:"creditAccount"`        // Account points are given to
+	Points        int       `json:"points"`               // Always positive, direction comes from accounts
+	ReceiptID     string    `json:"receiptId,omitempty"`  // Receipt that earned the points for earn entries
+	ReversalOf    string    `json:"reversalOf,omitempty"` // Entry this one reverses for reversal entries
+	ReversedBy    string    `json:"reversedBy,omitempty"` // Reversal entry if this entry was reversed
+	Reference     string    `json:"reference,omitempty"`  // Free text reason or external reference
+	CreatedAt     time.Time `json:"createdAt"`            // Time the entry was posted
+}
+
+// UserAccount returns the ledger account name for a user
+func UserAccount(userID string) string {
+	return "user:" + userID
 }
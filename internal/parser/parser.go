@@ -0,0 +1,313 @@
+// Package parser turns the raw text of a printed receipt into a models.Receipt
+// Configured templates handle known POS layouts, heuristics cover the rest, and every field gets a confidence
+// from 0 (not found) to 1 so callers can decide what to trust. Validation is left to the handlers
+package parser
+
+import (
+	"math"
+	"regexp"
+	"strconv"
+	"strings"
+	"time"
+	"unicode"
+
+	"receipt-processor-challenge-jase180/internal/models"
+)
+
+// Confidence is how sure the parser is of each field, 0 when the field was not found
+type Confidence struct {
+	Retailer     float64 `json:"retailer"`
+	PurchaseDate float64 `json:"purchaseDate"`
+	PurchaseTime float64 `json:"purchaseTime"`
+	Items        float64 `json:"items"`
+	Total        float64 `json:"total"`
+}
+
+// Result is a parsed receipt, the template used if any, and the confidence of each field
+type Result struct {
+	Receipt    models.Receipt `json:"receipt"`
+	Template   string         `json:"template,omitempty"` // Name of the template that matched, empty for heuristics only
+	Confidence Confidence     `json:"confidence"`
+}
+
+// Confidence levels, templates are trusted most and guesses least
+const (
+	confidenceTemplate  = 0.95
+	confidenceStrong    = 0.9
+	confidenceLikely    = 0.8
+	confidenceAmbiguous = 0.6
+	confidenceGuess     = 0.4
+)
+
+var (
+	// Line ending in an amount, optionally with a $ and a trailing tax flag like "T" or "F"
+	regexAmountLine = regexp.MustCompile(`^(.*?)\s+\$?(\d{1,7}[.,]\d{2})(?:\s+[A-Z]{1,2})?\s*$`)
+	// Total lines, "Subtotal" is excluded by the word boundary
+	regexTotalLine = regexp.MustCompile(`(?i)^\s*(?:grand\s+total|total(?:\s+due)?|amount\s+due|balance\s+due)\b[\s:]*\$?(\d{1,7}[.,]\d{2})`)
+	// Words on payment and summary lines that are never items
+	regexNotItem = regexp.MustCompile(`(?i)\b(?:sub\s*total|subtotal|total|tax|vat|gst|hst|change|cash|tender|visa|mastercard|amex|discover|debit|credit|card|balance|due|payment|tip|gratuity|savings|you saved)\b`)
+	// "3 x Gatorade @ 2.25" and "3 x Gatorade"
+	regexQuantityFirst = regexp.MustCompile(`(?i)^(\d{1,4})\s*x\s+(.+?)(?:\s+@\s*\$?(\d{1,7}[.,]\d{2}))?$`)
+	// "Gatorade 3 @ 2.25"
+	regexQuantityLast = regexp.MustCompile(`^(.+?)\s+(\d{1,4})\s*@\s*\$?(\d{1,7}[.,]\d{2})$`)
+	// Leading SKU or item codes of 4 or more digits
+	regexItemCode = regexp.MustCompile(`^\d{4,}\s+`)
+	// Greetings printed before the store name
+	regexWelcome = regexp.MustCompile(`(?i)^welcome\s+to\s+`)
+
+	regexISODate   = regexp.MustCompile(`\b(\d{4})-(\d{2})-(\d{2})\b`)
+	regexSlashDate = regexp.MustCompile(`\b(\d{1,2})/(\d{1,2})/(\d{2}|\d{4})\b`)
+	regexDotDate   = regexp.MustCompile(`\b(\d{1,2})\.(\d{1,2})\.(\d{4})\b`)
+	regexNamedDate = regexp.MustCompile(`(?i)\b(jan|feb|mar|apr|may|jun|jul|aug|sep|oct|nov|dec)[a-z]*\.?\s+(\d{1,2}),?\s+(\d{4})\b`)
+	regexTime      = regexp.MustCompile(`\b(\d{1,2}):(\d{2})(?::\d{2})?\s*([AaPp][Mm])?\b`)
+)
+
+// Parse extracts a receipt from printed receipt text using the first matching template and heuristics
+// for anything the template does not cover. Fields that cannot be found are left empty with 0 confidence
+func Parse(text string, templates []Template) Result {
+	lines := strings.Split(strings.ReplaceAll(text, "\r\n", "\n"), "\n")
+	result := Result{}
+
+	template, matched := matchTemplate(text, templates)
+	if matched {
+		result.Template = template.Name
+	}
+
+	// Retailer
+	if matched && template.Retailer != "" {
+		result.Receipt.Retailer, result.Confidence.Retailer = template.Retailer, confidenceTemplate
+	} else {
+		result.Receipt.Retailer, result.Confidence.Retailer = findRetailer(lines)
+	}
+
+	// Date and time
+	if date, ok := template.capture(template.Date, text); ok {
+		if parsed, err := time.Parse(template.DateLayout, date); err == nil {
+			result.Receipt.PurchaseDate, result.Confidence.PurchaseDate = parsed.Format("2006-01-02"), confidenceTemplate
+		}
+	}
+	if result.Receipt.PurchaseDate == "" {
+		result.Receipt.PurchaseDate, result.Confidence.PurchaseDate = findDate(text)
+	}
+	if clock, ok := template.capture(template.Time, text); ok {
+		if parsed, err := time.Parse(template.TimeLayout, clock); err == nil {
+			result.Receipt.PurchaseTime, result.Confidence.PurchaseTime = parsed.Format("15:04"), confidenceTemplate
+		}
+	}
+	if result.Receipt.PurchaseTime == "" {
+		result.Receipt.PurchaseTime, result.Confidence.PurchaseTime = findTime(text)
+	}
+
+	// Total, then items which are checked against it
+	totalFromTemplate := false
+	if total, ok := template.capture(template.Total, text); ok {
+		result.Receipt.Total, result.Confidence.Total, totalFromTemplate = normalizeAmount(total), confidenceTemplate, true
+	} else {
+		result.Receipt.Total, result.Confidence.Total = findTotal(lines)
+	}
+
+	if matched && template.Item != "" {
+		result.Receipt.Items = template.items(lines)
+	} else {
+		result.Receipt.Items = findItems(lines)
+	}
+	result.Confidence.Items = itemsConfidence(result.Receipt.Items, result.Receipt.Total)
+
+	// Items adding up to the total confirms the total too
+	if !totalFromTemplate && result.Confidence.Items >= confidenceTemplate {
+		result.Confidence.Total = confidenceTemplate
+	}
+	if matched && template.Item != "" && len(result.Receipt.Items) > 0 {
+		result.Confidence.Items = math.Max(result.Confidence.Items, confidenceLikely)
+	}
+	return result
+}
+
+// findRetailer guesses the retailer is the first line with letters that is not a date, amount or address line
+func findRetailer(lines []string) (string, float64) {
+	for _, line := range lines {
+		line = strings.Join(strings.Fields(line), " ")
+		if line == "" || !strings.ContainsFunc(line, unicode.IsLetter) {
+			continue
+		}
+		if regexAmountLine.MatchString(line) || regexTime.MatchString(line) || unicode.IsDigit(rune(line[0])) {
+			continue // amounts, dates and times, and street addresses starting with a number
+		}
+		return strings.TrimSpace(regexWelcome.ReplaceAllString(line, "")), confidenceAmbiguous
+	}
+	return "", 0
+}
+
+// findDate returns the first date found as YYYY-MM-DD, trying unambiguous formats first
+// Slash dates are read month first like US receipts unless the first number cannot be a month
+func findDate(text string) (string, float64) {
+	if match := regexISODate.FindStringSubmatch(text); match != nil {
+		if date, ok := buildDate(match[1], match[2], match[3]); ok {
+			return date, confidenceStrong
+		}
+	}
+	if match := regexNamedDate.FindStringSubmatch(text); match != nil {
+		if parsed, err := time.Parse("Jan 2 2006", strings.ToUpper(match[1][:1])+strings.ToLower(match[1][1:])+" "+match[2]+" "+match[3]); err == nil {
+			return parsed.Format("2006-01-02"), confidenceStrong
+		}
+	}
+	if match := regexSlashDate.FindStringSubmatch(text); match != nil {
+		year := match[3]
+		if len(year) == 2 {
+			year = "20" + year
+		}
+		first, _ := strconv.Atoi(match[1])
+		second, _ := strconv.Atoi(match[2])
+		if first > 12 {
+			if date, ok := buildDate(year, match[2], match[1]); ok {
+				return date, confidenceLikely
+			}
+		}
+		if date, ok := buildDate(year, match[1], match[2]); ok {
+			if second > 12 || first == second {
+				return date, confidenceLikely
+			}
+			return date, confidenceAmbiguous // could be day first
+		}
+	}
+	if match := regexDotDate.FindStringSubmatch(text); match != nil {
+		if date, ok := buildDate(match[3], match[2], match[1]); ok {
+			return date, confidenceLikely
+		}
+	}
+	return "", 0
+}
+
+// buildDate formats year, month and day as YYYY-MM-DD if they are a real date
+func buildDate(year, month, day string) (string, bool) {
+	pad := func(s string) string {
+		if len(s) == 1 {
+			return "0" + s
+		}
+		return s
+	}
+	date := year + "-" + pad(month) + "-" + pad(day)
+	if _, err := time.Parse("2006-01-02", date); err != nil {
+		return "", false
+	}
+	return date, true
+}
+
+// findTime returns the first time found in 24 hour HH:MM
+// Times with AM/PM or an hour after 12 are certain, others could be 12 hour times without a suffix
+func findTime(text string) (string, float64) {
+	for _, match := range regexTime.FindAllStringSubmatch(text, -1) {
+		hour, _ := strconv.Atoi(match[1])
+		minute, _ := strconv.Atoi(match[2])
+		suffix := strings.ToLower(match[3])
+		if minute > 59 || hour > 23 || (suffix != "" && (hour < 1 || hour > 12)) {
+			continue
+		}
+
+		confidence := confidenceLikely
+		switch {
+		case suffix == "pm" && hour != 12:
+			hour += 12
+			confidence = confidenceStrong
+		case suffix == "am" && hour == 12:
+			hour = 0
+			confidence = confidenceStrong
+		case suffix != "" || hour > 12:
+			confidence = confidenceStrong
+		}
+		return time.Date(0, 1, 1, hour, minute, 0, 0, time.UTC).Format("15:04"), confidence
+	}
+	return "", 0
+}
+
+// findTotal returns the last total line's amount, or the largest amount on the receipt as a guess
+func findTotal(lines []string) (string, float64) {
+	total := ""
+	for _, line := range lines {
+		if match := regexTotalLine.FindStringSubmatch(line); match != nil {
+			total = normalizeAmount(match[1])
+		}
+	}
+	if total != "" {
+		return total, confidenceStrong
+	}
+
+	largest := -1.0
+	for _, line := range lines {
+		if match := regexAmountLine.FindStringSubmatch(line); match != nil {
+			amount, _ := strconv.ParseFloat(normalizeAmount(match[2]), 64)
+			if amount > largest {
+				largest, total = amount, normalizeAmount(match[2])
+			}
+		}
+	}
+	if total == "" {
+		return "", 0
+	}
+	return total, confidenceGuess
+}
+
+// findItems returns every line ending in an amount that is not a summary or payment line
+func findItems(lines []string) []models.Item {
+	items := []models.Item{}
+	for _, line := range lines {
+		match := regexAmountLine.FindStringSubmatch(strings.TrimSpace(line))
+		if match == nil || regexNotItem.MatchString(match[1]) || regexTime.MatchString(match[1]) {
+			continue
+		}
+		if item, ok := newItem(match[1], match[2]); ok {
+			items = append(items, item)
+		}
+	}
+	return items
+}
+
+// newItem builds an item from a description and line price, reading quantities like "3 x Gatorade @ 2.25"
+func newItem(description string, price string) (models.Item, bool) {
+	description = regexItemCode.ReplaceAllString(strings.Join(strings.Fields(description), " "), "")
+	item := models.Item{Price: normalizeAmount(price)}
+
+	if match := regexQuantityFirst.FindStringSubmatch(description); match != nil {
+		description, item.Quantity = match[2], match[1]
+		if match[3] != "" {
+			item.UnitPrice = normalizeAmount(match[3])
+		}
+	} else if match := regexQuantityLast.FindStringSubmatch(description); match != nil {
+		description, item.Quantity, item.UnitPrice = match[1], match[2], normalizeAmount(match[3])
+	}
+
+	item.ShortDescription = strings.TrimSpace(description)
+	if !strings.ContainsFunc(item.ShortDescription, unicode.IsLetter) {
+		return models.Item{}, false
+	}
+	return item, true
+}
+
+// itemsConfidence is high when the items add up to the total, a receipt with tax will not so it is lower but not a guess
+func itemsConfidence(items []models.Item, total string) float64 {
+	if len(items) == 0 {
+		return 0
+	}
+	sum := 0
+	for _, item := range items {
+		sum += cents(item.Price)
+	}
+	if total != "" && sum == cents(total) {
+		return confidenceTemplate
+	}
+	if total != "" && sum < cents(total) {
+		return confidenceAmbiguous
+	}
+	return confidenceGuess // items add up to more than the total, something that is not an item was picked up
+}
+
+// normalizeAmount converts a printed amount like "$6,49" to the "6.49" format receipts use
+func normalizeAmount(amount string) string {
+	return strings.Replace(strings.TrimPrefix(strings.TrimSpace(amount), "$"), ",", ".", 1)
+}
+
+// cents converts a normalized amount to cents, 0 if unparsable
+func cents(amount string) int {
+	value, _ := strconv.Atoi(strings.Replace(amount, ".", "", 1))
+	return value
+}
@@ -0,0 +1,176 @@
+package parser
+
+import (
+	"testing"
+
+	"receipt-processor-challenge-jase180/internal/models"
+)
+
+// Target README receipt as printed by a typical POS
+const targetText = `TARGET
+1234 Main St, Springfield
+01/01/2022 01:01 PM
+
+Mountain Dew 12PK        6.49
+Emils Cheese Pizza      12.25
+Knorr Creamy Chicken     1.26
+Doritos Nacho Cheese     3.35
+Klarbrunn 12-PK 12 FL OZ  12.00
+
+SUBTOTAL                35.35
+TOTAL                  $35.35
+VISA                    35.35
+`
+
+func TestParse(t *testing.T) {
+	tests := []struct {
+		name      string
+		text      string
+		want      models.Receipt
+		wantItems int
+		wantSure  Confidence // minimum confidence of each field
+	}{
+		{"Target README receipt", targetText,
+			models.Receipt{Retailer: "TARGET", PurchaseDate: "2022-01-01", PurchaseTime: "13:01", Total: "35.35"}, 5,
+			Confidence{Retailer: 0.5, PurchaseDate: 0.5, PurchaseTime: 0.9, Items: 0.9, Total: 0.9}},
+		{"ISO date, 24 hour time, tax and tax flags", "Welcome to Corner Market\n2022-03-20 14:33\n0001234 Gatorade   2.25 T\nGatorade   2.25 T\nTax   0.30\nTotal Due  4.80\nCash  5.00\nChange 0.20",
+			models.Receipt{Retailer: "Corner Market", PurchaseDate: "2022-03-20", PurchaseTime: "14:33", Total: "4.80"}, 2,
+			Confidence{Retailer: 0.5, PurchaseDate: 0.9, PurchaseTime: 0.8, Items: 0.5, Total: 0.9}},
+		{"Named month and quantities", "M&M Corner Market\nMar 20, 2022 2:33pm\n3 x Gatorade @ 2.25   6.75\nApples 2 @ 1.00   2.00\nTOTAL 8.75",
+			models.Receipt{Retailer: "M&M Corner Market", PurchaseDate: "2022-03-20", PurchaseTime: "14:33", Total: "8.75"}, 2,
+			Confidence{Retailer: 0.5, PurchaseDate: 0.9, PurchaseTime: 0.9, Items: 0.9, Total: 0.9}},
+		{"Day first date and comma amounts", "Supermarkt\n20.03.2022 09:05\nMilch   1,25\nBrot   2,50\nTOTAL   3,75",
+			models.Receipt{Retailer: "Supermarkt", PurchaseDate: "2022-03-20", PurchaseTime: "09:05", Total: "3.75"}, 2,
+			Confidence{Retailer: 0.5, PurchaseDate: 0.8, PurchaseTime: 0.8, Items: 0.9, Total: 0.9}},
+		{"No total line guesses the largest amount", "Shop\n20/03/22\nBread 2.50\nMilk 1.25",
+			models.Receipt{Retailer: "Shop", PurchaseDate: "2022-03-20", Total: "2.50"}, 2,
+			Confidence{Retailer: 0.5, PurchaseDate: 0.8, Total: 0.4}},
+		{"Nothing recognizable", "12345\n----",
+			models.Receipt{}, 0, Confidence{}},
+	}
+
+	for _, testCase := range tests {
+		t.Run(testCase.name, func(t *testing.T) {
+			result := Parse(testCase.text, nil)
+			got := result.Receipt
+
+			if got.Retailer != testCase.want.Retailer || got.PurchaseDate != testCase.want.PurchaseDate ||
+				got.PurchaseTime != testCase.want.PurchaseTime || got.Total != testCase.want.Total {
+				t.Errorf("Result %q %q %q %q, want %q %q %q %q", got.Retailer, got.PurchaseDate, got.PurchaseTime, got.Total,
+					testCase.want.Retailer, testCase.want.PurchaseDate, testCase.want.PurchaseTime, testCase.want.Total)
+			}
+			if len(got.Items) != testCase.wantItems {
+				t.Fatalf("Result items: %v, want %d items", got.Items, testCase.wantItems)
+			}
+
+			sure, confidence := testCase.wantSure, result.Confidence
+			if confidence.Retailer < sure.Retailer || confidence.PurchaseDate < sure.PurchaseDate || confidence.PurchaseTime < sure.PurchaseTime ||
+				confidence.Items < sure.Items || confidence.Total < sure.Total {
+				t.Errorf("Result confidence: %+v, want at least %+v", confidence, sure)
+			}
+			if testCase.want.Retailer == "" && confidence != (Confidence{}) {
+				t.Errorf("Result confidence: %+v, want 0 for fields not found", confidence)
+			}
+		})
+	}
+}
+
+func TestParseItems(t *testing.T) {
+	result := Parse("M&M Corner Market\n2022-03-20 14:33\n0001234 3 x Gatorade @ 2.25   6.75\nBananas 2 @ 0.50   1.00 F\nTOTAL 7.75", nil)
+	want := []models.Item{
+		{ShortDescription: "Gatorade", Price: "6.75", Quantity: "3", UnitPrice: "2.25"},
+		{ShortDescription: "Bananas", Price: "1.00", Quantity: "2", UnitPrice: "0.50"},
+	}
+
+	if len(result.Receipt.Items) != len(want) {
+		t.Fatalf("Result items: %v, want: %v", result.Receipt.Items, want)
+	}
+	for i, item := range result.Receipt.Items {
+		if item != want[i] {
+			t.Errorf("Result item %d: %+v, want: %+v", i, item, want[i])
+		}
+	}
+}
+
+func TestParseAmbiguousDate(t *testing.T) {
+	// 03/04 could be March 4 or April 3, read month first like US receipts but with less confidence
+	ambiguous := Parse("Shop\n03/04/2022\nTOTAL 1.00", nil)
+	certain := Parse("Shop\n03/14/2022\nTOTAL 1.00", nil)
+
+	if ambiguous.Receipt.PurchaseDate != "2022-03-04" || certain.Receipt.PurchaseDate != "2022-03-14" {
+		t.Errorf("Result dates %q and %q, want 2022-03-04 and 2022-03-14", ambiguous.Receipt.PurchaseDate, certain.Receipt.PurchaseDate)
+	}
+	if ambiguous.Confidence.PurchaseDate >= certain.Confidence.PurchaseDate {
+		t.Errorf("Result ambiguous confidence %v, want less than %v", ambiguous.Confidence.PurchaseDate, certain.Confidence.PurchaseDate)
+	}
+}
+
+func TestParseTemplate(t *testing.T) {
+	templates := []Template{
+		{Name: "other", Match: `(?m)^OTHER STORE$`, Retailer: "Other"},
+		{
+			Name:       "corner-market",
+			Match:      `(?m)^CORNER MKT #\d+$`,
+			Retailer:   "M&M Corner Market",
+			Date:       `DATE (\d{2}-\d{2}-\d{4})`,
+			DateLayout: "01-02-2006",
+			Time:       `TIME (\d{1,2}:\d{2} [AP]M)`,
+			TimeLayout: "3:04 PM",
+			Item:       `^ITEM (?P<quantity>\d+) (?P<description>.+?) (?P<price>\d+\.\d{2})$`,
+			Total:      `AMOUNT PAID (\d+\.\d{2})`,
+		},
+	}
+	text := "CORNER MKT #42\nDATE 03-20-2022 TIME 2:33 PM\nITEM 4 Gatorade 9.00\nITEM 1 Bananas 0.50\nAMOUNT PAID 9.50"
+
+	if err := ValidateTemplates(templates); err != nil {
+		t.Fatalf("Result error: %v, want valid templates", err)
+	}
+	result := Parse(text, templates)
+
+	if result.Template != "corner-market" {
+		t.Errorf("Result template: %q, want corner-market", result.Template)
+	}
+	want := models.Receipt{Retailer: "M&M Corner Market", PurchaseDate: "2022-03-20", PurchaseTime: "14:33", Total: "9.50"}
+	got := result.Receipt
+	if got.Retailer != want.Retailer || got.PurchaseDate != want.PurchaseDate || got.PurchaseTime != want.PurchaseTime || got.Total != want.Total {
+		t.Errorf("Result %+v, want %+v", got, want)
+	}
+	if len(got.Items) != 2 || got.Items[0] != (models.Item{ShortDescription: "Gatorade", Price: "9.00", Quantity: "4"}) {
+		t.Errorf("Result items: %+v, want Gatorade x4 and Bananas", got.Items)
+	}
+	if result.Confidence.Retailer != confidenceTemplate || result.Confidence.Total != confidenceTemplate {
+		t.Errorf("Result confidence: %+v, want template confidence", result.Confidence)
+	}
+
+	// Text matching no template uses the heuristics
+	if result := Parse(targetText, templates); result.Template != "" || result.Receipt.Retailer != "TARGET" {
+		t.Errorf("Result template %q retailer %q, want heuristics", result.Template, result.Receipt.Retailer)
+	}
+}
+
+func TestValidateTemplates(t *testing.T) {
+	tests := []struct {
+		name      string
+		templates []Template
+		wantError bool
+	}{
+		{"No templates", nil, false},
+		{"Match only", []Template{{Name: "a", Match: "A"}}, false},
+		{"No name", []Template{{Match: "A"}}, true},
+		{"No match", []Template{{Name: "a"}}, true},
+		{"Bad regex", []Template{{Name: "a", Match: "("}}, true},
+		{"Capture without group", []Template{{Name: "a", Match: "A", Total: `TOTAL \d+`}}, true},
+		{"Date without layout", []Template{{Name: "a", Match: "A", Date: `(\d+)`}}, true},
+		{"Item without price group", []Template{{Name: "a", Match: "A", Item: `(?P<description>.+)`}}, true},
+		{"Duplicate names", []Template{{Name: "a", Match: "A"}, {Name: "a", Match: "B"}}, true},
+	}
+
+	for _, testCase := range tests {
+		t.Run(testCase.name, func(t *testing.T) {
+			err := ValidateTemplates(testCase.templates)
+			if (err != nil) != testCase.wantError {
+				t.Errorf("Result error: %v, want error %v", err, testCase.wantError)
+			}
+		})
+	}
+}
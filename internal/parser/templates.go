@@ -0,0 +1,176 @@
+package parser
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"os"
+	"regexp"
+	"strings"
+
+	"receipt-processor-challenge-jase180/internal/cache"
+	"receipt-processor-challenge-jase180/internal/models"
+)
+
+// Template describes a known POS layout, fields left empty fall back to the heuristics
+// Date, Time and Total are regular expressions with one capture group, Item is matched against each line
+// and uses the named groups description and price, and optionally quantity and unitPrice
+type Template struct {
+	Name       string `json:"name"`                 // Reported in the result when the template matches
+	Match      string `json:"match"`                // Regular expression the whole text must match to use the template
+	Retailer   string `json:"retailer,omitempty"`   // Fixed retailer name for this layout
+	Date       string `json:"date,omitempty"`       // Captures the purchase date
+	DateLayout string `json:"dateLayout,omitempty"` // Go layout of the captured date, e.g. "01/02/2006"
+	Time       string `json:"time,omitempty"`       // Captures the purchase time
+	TimeLayout string `json:"timeLayout,omitempty"` // Go layout of the captured time, e.g. "3:04 PM"
+	Item       string `json:"item,omitempty"`       // Matches item lines
+	Total      string `json:"total,omitempty"`      // Captures the total
+}
+
+// compiledTemplates holds template regular expressions by source, see cache.Compiled
+var compiledTemplates = cache.NewCompiled[*regexp.Regexp](1024)
+
+// compile returns the cached regular expression for a pattern, compiling it on first use
+func compile(pattern string) (*regexp.Regexp, error) {
+	return compiledTemplates.Get(pattern, func() (*regexp.Regexp, error) {
+		return regexp.Compile(pattern)
+	})
+}
+
+// Validate checks the template has a name and match, every pattern compiles and capture patterns have a group
+func (t Template) Validate() error {
+	if strings.TrimSpace(t.Name) == "" {
+		return errors.New("template has no name")
+	}
+	if t.Match == "" {
+		return fmt.Errorf("template %q has no match pattern", t.Name)
+	}
+	if _, err := compile(t.Match); err != nil {
+		return fmt.Errorf("template %q match: %w", t.Name, err)
+	}
+
+	captures := []struct {
+		field, pattern, layout string
+		needsLayout            bool
+	}{
+		{"date", t.Date, t.DateLayout, true},
+		{"time", t.Time, t.TimeLayout, true},
+		{"total", t.Total, "", false},
+	}
+	for _, capture := range captures {
+		if capture.pattern == "" {
+			continue
+		}
+		compiled, err := compile(capture.pattern)
+		if err != nil {
+			return fmt.Errorf("template %q %s: %w", t.Name, capture.field, err)
+		}
+		if compiled.NumSubexp() < 1 {
+			return fmt.Errorf("template %q %s needs a capture group", t.Name, capture.field)
+		}
+		if capture.needsLayout && capture.layout == "" {
+			return fmt.Errorf("template %q %s needs a layout", t.Name, capture.field)
+		}
+	}
+
+	if t.Item != "" {
+		compiled, err := compile(t.Item)
+		if err != nil {
+			return fmt.Errorf("template %q item: %w", t.Name, err)
+		}
+		if compiled.SubexpIndex("description") < 0 || compiled.SubexpIndex("price") < 0 {
+			return fmt.Errorf("template %q item needs description and price groups", t.Name)
+		}
+	}
+	return nil
+}
+
+// ValidateTemplates checks every template and that names are unique
+func ValidateTemplates(templates []Template) error {
+	names := make(map[string]bool)
+	for _, template := range templates {
+		if err := template.Validate(); err != nil {
+			return err
+		}
+		if names[template.Name] {
+			return fmt.Errorf("template %q is defined twice", template.Name)
+		}
+		names[template.Name] = true
+	}
+	return nil
+}
+
+// LoadTemplates reads a JSON array of templates and validates them
+func LoadTemplates(path string) ([]Template, error) {
+	file, err := os.ReadFile(path)
+	if err != nil {
+		return nil, fmt.Errorf("cannot read templates file: %w", err)
+	}
+
+	var templates []Template
+	if err := json.Unmarshal(file, &templates); err != nil {
+		return nil, fmt.Errorf("cannot parse templates file %s: %w", path, err)
+	}
+	if err := ValidateTemplates(templates); err != nil {
+		return nil, fmt.Errorf("templates file %s: %w", path, err)
+	}
+	return templates, nil
+}
+
+// matchTemplate returns the first template whose match pattern matches the text
+func matchTemplate(text string, templates []Template) (Template, bool) {
+	for _, template := range templates {
+		if compiled, err := compile(template.Match); err == nil && template.Match != "" && compiled.MatchString(text) {
+			return template, true
+		}
+	}
+	return Template{}, false
+}
+
+// capture returns the first group of a template pattern in the text, false if the pattern is empty or does not match
+func (t Template) capture(pattern string, text string) (string, bool) {
+	if pattern == "" {
+		return "", false
+	}
+	compiled, err := compile(pattern)
+	if err != nil {
+		return "", false
+	}
+	match := compiled.FindStringSubmatch(text)
+	if len(match) < 2 || strings.TrimSpace(match[1]) == "" {
+		return "", false
+	}
+	return strings.TrimSpace(match[1]), true
+}
+
+// items returns the items on lines matching the template's item pattern
+func (t Template) items(lines []string) []models.Item {
+	items := []models.Item{}
+	compiled, err := compile(t.Item)
+	if err != nil {
+		return items
+	}
+
+	group := func(match []string, name string) string {
+		if i := compiled.SubexpIndex(name); i >= 0 {
+			return strings.TrimSpace(match[i])
+		}
+		return ""
+	}
+	for _, line := range lines {
+		match := compiled.FindStringSubmatch(strings.TrimSpace(line))
+		if match == nil {
+			continue
+		}
+		item := models.Item{
+			ShortDescription: strings.Join(strings.Fields(group(match, "description")), " "),
+			Price:            normalizeAmount(group(match, "price")),
+			Quantity:         group(match, "quantity"),
+		}
+		if unitPrice := group(match, "unitPrice"); unitPrice != "" {
+			item.UnitPrice = normalizeAmount(unitPrice)
+		}
+		items = append(items, item)
+	}
+	return items
+}
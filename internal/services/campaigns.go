@@ -0,0 +1,70 @@
+package rules
+
+import (
+	"math"
+	"strings"
+
+	"receipt-processor-challenge-jase180/internal/models"
+)
+
+// CampaignBonuses evaluates campaigns alongside the built-in rules and returns the bonus of each matching campaign
+// basePoints is what CalculatePoints returned, used by multiplier campaigns
+// Campaigns are matched on the moment of purchase in the receipt's timezone (UTC without one), not submission time
+// perItem bonuses count matching items as line items or units per counting
+func CampaignBonuses(receipt models.Receipt, basePoints int, campaigns []models.Campaign, counting ItemCounting) []models.CampaignBonus {
+	purchasedAt, err := PurchaseInstant(receipt)
+	if err != nil {
+		return nil // fail gracefully, validation already rejects bad dates
+	}
+
+	bonuses := []models.CampaignBonus{}
+	for _, campaign := range campaigns {
+		if purchasedAt.Before(campaign.Start) || !purchasedAt.Before(campaign.End) {
+			continue
+		}
+		// Either the raw or canonical retailer can match so one matcher covers every spelling in the registry
+		if !matchesAny(receipt.Retailer, campaign.Retailers) && (receipt.RetailerCanonical == "" || !matchesAny(receipt.RetailerCanonical, campaign.Retailers)) {
+			continue
+		}
+
+		// Count items matching the item matchers, every item matches if there are none
+		matchingItems := 0
+		for _, item := range receipt.Items {
+			if matchesAny(item.ShortDescription, campaign.Items) {
+				matchingItems += ItemUnits(item, counting)
+			}
+		}
+		if matchingItems == 0 {
+			continue
+		}
+
+		bonus := 0.0
+		switch campaign.Bonus.Type {
+		case models.BonusFlat:
+			bonus = campaign.Bonus.Value
+		case models.BonusMultiplier:
+			bonus = float64(basePoints)*campaign.Bonus.Value - float64(basePoints)
+		case models.BonusPerItem:
+			bonus = campaign.Bonus.Value * float64(matchingItems)
+		}
+		// Clamp before converting so campaigns stored before validation had a maximum cannot overflow int
+		points := int(math.Round(math.Max(0, math.Min(bonus, models.MaxBonusPoints))))
+
+		bonuses = append(bonuses, models.CampaignBonus{CampaignID: campaign.ID, Name: campaign.Name, Points: points})
+	}
+	return bonuses
+}
+
+// matchesAny reports if value contains any of the matchers case-insensitively, true if there are no matchers
+func matchesAny(value string, matchers []string) bool {
+	if len(matchers) == 0 {
+		return true
+	}
+	value = strings.ToLower(value)
+	for _, matcher := range matchers {
+		if strings.Contains(value, strings.ToLower(strings.TrimSpace(matcher))) {
+			return true
+		}
+	}
+	return false
+}
@@ -0,0 +1,58 @@
+package rules
+
+import (
+	"testing"
+	"time"
+
+	"receipt-processor-challenge-jase180/internal/models"
+)
+
+func TestCampaignBonuses(t *testing.T) {
+	// M&M README receipt, 109 base points, purchased 2022-03-20 14:33
+	receipt := models.Receipt{
+		Retailer:     "M&M Corner Market",
+		PurchaseDate: "2022-03-20",
+		PurchaseTime: "14:33",
+		Items: []models.Item{
+			{ShortDescription: "Gatorade", Price: "2.25"},
+			{ShortDescription: "Gatorade", Price: "2.25"},
+			{ShortDescription: "Gatorade", Price: "2.25"},
+			{ShortDescription: "Gatorade", Price: "2.25"},
+		},
+		Total: "9.00",
+	}
+	march := func(day int, hour int) time.Time { return time.Date(2022, 3, day, hour, 0, 0, 0, time.UTC) }
+
+	tests := []struct {
+		name     string
+		campaign models.Campaign
+		expected int // bonus points, -1 if campaign should not match
+	}{
+		{"Flat bonus any receipt", models.Campaign{Start: march(1, 0), End: march(31, 0), Bonus: models.Bonus{Type: models.BonusFlat, Value: 100}}, 100},
+		{"Double points", models.Campaign{Start: march(1, 0), End: march(31, 0), Bonus: models.Bonus{Type: models.BonusMultiplier, Value: 2}}, 109},
+		{"Per matching item", models.Campaign{Start: march(1, 0), End: march(31, 0), Items: []string{"gatorade"}, Bonus: models.Bonus{Type: models.BonusPerItem, Value: 5}}, 20},
+		{"Retailer matches case-insensitively", models.Campaign{Start: march(1, 0), End: march(31, 0), Retailers: []string{"m&m corner"}, Bonus: models.Bonus{Type: models.BonusFlat, Value: 10}}, 10},
+		{"Retailer does not match", models.Campaign{Start: march(1, 0), End: march(31, 0), Retailers: []string{"Target"}, Bonus: models.Bonus{Type: models.BonusFlat, Value: 10}}, -1},
+		{"Item does not match", models.Campaign{Start: march(1, 0), End: march(31, 0), Items: []string{"Doritos"}, Bonus: models.Bonus{Type: models.BonusFlat, Value: 10}}, -1},
+		{"Before window", models.Campaign{Start: march(21, 0), End: march(31, 0), Bonus: models.Bonus{Type: models.BonusFlat, Value: 10}}, -1},
+		{"End is exclusive", models.Campaign{Start: march(1, 0), End: time.Date(2022, 3, 20, 14, 33, 0, 0, time.UTC), Bonus: models.Bonus{Type: models.BonusFlat, Value: 10}}, -1},
+		{"Start is inclusive", models.Campaign{Start: time.Date(2022, 3, 20, 14, 33, 0, 0, time.UTC), End: march(21, 0), Bonus: models.Bonus{Type: models.BonusFlat, Value: 10}}, 10},
+		{"Huge flat bonus is clamped", models.Campaign{Start: march(1, 0), End: march(31, 0), Bonus: models.Bonus{Type: models.BonusFlat, Value: 1e300}}, models.MaxBonusPoints},
+		{"Huge multiplier is clamped", models.Campaign{Start: march(1, 0), End: march(31, 0), Bonus: models.Bonus{Type: models.BonusMultiplier, Value: 1e300}}, models.MaxBonusPoints},
+		{"Huge per item bonus is clamped", models.Campaign{Start: march(1, 0), End: march(31, 0), Bonus: models.Bonus{Type: models.BonusPerItem, Value: 1e300}}, models.MaxBonusPoints},
+	}
+	for _, testCase := range tests {
+		t.Run(testCase.name, func(t *testing.T) {
+			result := CampaignBonuses(receipt, 109, []models.Campaign{testCase.campaign}, CountLineItems)
+			if testCase.expected < 0 {
+				if len(result) != 0 {
+					t.Errorf("Result was %v; want no bonus", result)
+				}
+				return
+			}
+			if len(result) != 1 || result[0].Points != testCase.expected {
+				t.Errorf("Result was %v; want one bonus of %v", result, testCase.expected)
+			}
+		})
+	}
+}
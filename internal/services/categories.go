@@ -0,0 +1,154 @@
+package rules
+
+import (
+	"errors"
+	"fmt"
+	"regexp"
+	"strings"
+
+	"receipt-processor-challenge-jase180/internal/cache"
+	"receipt-processor-challenge-jase180/internal/models"
+)
+
+// Category is an item category and how item descriptions are matched to it
+// Keywords and patterns are matched against the normalized description, see NormalizeDescription
+type Category struct {
+	Name     string   `json:"name"`               // Category assigned to matching items, e.g. "produce"
+	Keywords []string `json:"keywords,omitempty"` // Whole words or phrases, e.g. "banana" or "mountain dew"
+	Patterns []string `json:"patterns,omitempty"` // Regular expressions, e.g. "^organic "
+}
+
+// CategoryRule gives points for every item in a category, e.g. 10 points per produce item
+type CategoryRule struct {
+	Category      string `json:"category"`      // Category name the rule targets
+	PointsPerItem int    `json:"pointsPerItem"` // Points for each item in the category
+}
+
+// CategoryConfig is the item classifier dictionary and the rules that target categories
+// Items get the first category in the dictionary that matches, no dictionary means the default dictionary
+type CategoryConfig struct {
+	Dictionary []Category     `json:"dictionary"`
+	Rules      []CategoryRule `json:"rules"`
+}
+
+// DefaultCategories is a small grocery dictionary, deployments replace it with their own
+// Order matters, e.g. frozen comes before dairy so "ice cream" is frozen not dairy
+func DefaultCategories() []Category {
+	return []Category{
+		{Name: "beverages", Keywords: []string{"soda", "cola", "pepsi", "coke", "mountain dew", "gatorade", "water", "juice",
+			"coffee", "tea", "beer", "wine", "klarbrunn", "sparkling", "lemonade"}},
+		{Name: "produce", Keywords: []string{"apple", "apples", "banana", "bananas", "orange", "oranges", "lettuce", "tomato",
+			"tomatoes", "potato", "potatoes", "onion", "onions", "carrot", "carrots", "avocado", "avocados", "grapes",
+			"strawberries", "blueberries", "spinach", "broccoli", "lemon", "lemons", "lime", "limes"}},
+		{Name: "frozen", Keywords: []string{"frozen", "pizza", "ice cream"}},
+		{Name: "snacks", Keywords: []string{"chips", "doritos", "cookies", "crackers", "pretzels", "popcorn", "candy", "chocolate"}},
+		{Name: "dairy", Keywords: []string{"milk", "cheese", "yogurt", "butter", "eggs", "cream"}},
+		{Name: "pantry", Keywords: []string{"soup", "pasta", "rice", "cereal", "bread", "flour", "sugar", "knorr", "beans"}},
+		{Name: "household", Keywords: []string{"paper towels", "detergent", "soap", "tissue", "tissues", "toilet paper"}},
+	}
+}
+
+// Pack sizes and units, e.g. "12PK", "12-PK", "12 FL OZ" and "2.5 lb", removed before matching
+var regexPackSize = regexp.MustCompile(`\b\d+(?:\.\d+)?\s*-?\s*(?:pk|pack|packs|ct|count|fl\s*oz|oz|lbs?|kg|g|ml|l)\b`)
+
+// Anything other than letters, digits, white space and decimal points becomes a space
+var regexPunctuation = regexp.MustCompile(`[^\p{L}\p{N}\s.]+`)
+
+// compiledPatterns holds category regular expressions by source, see cache.Compiled
+var compiledPatterns = cache.NewCompiled[*regexp.Regexp](1024)
+
+// compilePattern returns the cached regular expression for a pattern, compiling it on first use
+func compilePattern(pattern string) (*regexp.Regexp, error) {
+	return compiledPatterns.Get(pattern, func() (*regexp.Regexp, error) {
+		return regexp.Compile(pattern)
+	})
+}
+
+// NormalizeDescription lowercases a description, strips pack sizes and punctuation and collapses white space
+// e.g. "Klarbrunn 12-PK 12 FL OZ" becomes "klarbrunn"
+func NormalizeDescription(description string) string {
+	normalized := strings.ToLower(description)
+	normalized = regexPunctuation.ReplaceAllString(normalized, " ")
+	normalized = regexPackSize.ReplaceAllString(normalized, " ")
+	normalized = strings.ReplaceAll(normalized, ".", " ")
+	return strings.Join(strings.Fields(normalized), " ")
+}
+
+// dictionary returns the configured dictionary or the default dictionary
+func (c CategoryConfig) dictionary() []Category {
+	if len(c.Dictionary) == 0 {
+		return DefaultCategories()
+	}
+	return c.Dictionary
+}
+
+// Validate checks every category has a name and compiling patterns, and every rule targets a known category
+func (c CategoryConfig) Validate() error {
+	names := make(map[string]bool)
+	for i, category := range c.dictionary() {
+		if strings.TrimSpace(category.Name) == "" {
+			return fmt.Errorf("categories: category %d has no name", i)
+		}
+		if len(category.Keywords) == 0 && len(category.Patterns) == 0 {
+			return fmt.Errorf("categories: category %q has no keywords or patterns", category.Name)
+		}
+		for _, pattern := range category.Patterns {
+			if _, err := compilePattern(pattern); err != nil {
+				return fmt.Errorf("categories: category %q pattern %q: %w", category.Name, pattern, err)
+			}
+		}
+		names[category.Name] = true
+	}
+	for _, rule := range c.Rules {
+		if !names[rule.Category] {
+			return fmt.Errorf("categories: rule targets unknown category %q", rule.Category)
+		}
+		if rule.PointsPerItem < 0 {
+			return errors.New("categories: rule points cannot be negative")
+		}
+	}
+	return nil
+}
+
+// ClassifyItem returns the first category matching an item's description, "" if none match
+func (c CategoryConfig) ClassifyItem(item models.Item) string {
+	description := NormalizeDescription(item.ShortDescription)
+	padded := " " + description + " " // pad so keywords only match whole words
+
+	for _, category := range c.dictionary() {
+		for _, keyword := range category.Keywords {
+			if normalized := NormalizeDescription(keyword); normalized != "" && strings.Contains(padded, " "+normalized+" ") {
+				return category.Name
+			}
+		}
+		for _, pattern := range category.Patterns {
+			if compiled, err := compilePattern(pattern); err == nil && compiled.MatchString(description) {
+				return category.Name
+			}
+		}
+	}
+	return ""
+}
+
+// Classify returns a copy of the receipt with the category set on every item
+// Items are copied so the caller's receipt is not changed
+func (c CategoryConfig) Classify(receipt models.Receipt) models.Receipt {
+	items := make([]models.Item, len(receipt.Items))
+	for i, item := range receipt.Items {
+		item.Category = c.ClassifyItem(item)
+		items[i] = item
+	}
+	receipt.Items = items
+	return receipt
+}
+
+// PointsForCategoryRule gives the rule's points for each item in its category, items must already be classified
+func PointsForCategoryRule(rule CategoryRule, items []models.Item, counting ItemCounting) int {
+	points := 0
+	for _, item := range items {
+		if item.Category == rule.Category {
+			points += rule.PointsPerItem * ItemUnits(item, counting)
+		}
+	}
+	return points
+}
@@ -0,0 +1,115 @@
+package rules
+
+import (
+	"testing"
+
+	"receipt-processor-challenge-jase180/internal/models"
+)
+
+func TestNormalizeDescription(t *testing.T) {
+	tests := []struct {
+		name        string
+		description string
+		expected    string
+	}{
+		{"Case and white space", "  Emils   Cheese PIZZA ", "emils cheese pizza"},
+		{"Pack size", "Mountain Dew 12PK", "mountain dew"},
+		{"Hyphenated pack size and units", "   Klarbrunn 12-PK 12 FL OZ  ", "klarbrunn"},
+		{"Decimal units", "Bananas 2.5 lb", "bananas"},
+		{"Punctuation", "Pepsi - 12-oz", "pepsi"},
+		{"Numbers that are not sizes kept", "Route 66 Chips", "route 66 chips"},
+	}
+	for _, testCase := range tests {
+		t.Run(testCase.name, func(t *testing.T) {
+			if result := NormalizeDescription(testCase.description); result != testCase.expected {
+				t.Errorf("Result was %q; want %q", result, testCase.expected)
+			}
+		})
+	}
+}
+
+func TestCategoryConfigClassifyItem(t *testing.T) {
+	custom := CategoryConfig{Dictionary: []Category{
+		{Name: "organic", Patterns: []string{`^organic\b`}},
+		{Name: "produce", Keywords: []string{"Banana", "green beans"}},
+	}}
+
+	tests := []struct {
+		name        string
+		config      CategoryConfig
+		description string
+		expected    string
+	}{
+		{"Default keyword", CategoryConfig{}, "Mountain Dew 12PK", "beverages"},
+		{"Default order frozen before dairy", CategoryConfig{}, "Ben's Ice Cream 1 PACK", "frozen"},
+		{"Default no match", CategoryConfig{}, "Mystery Box", ""},
+		{"Keyword must be a whole word", custom, "Bananarama Poster", ""},
+		{"Keyword phrase", custom, "GREEN-BEANS 16 oz", "produce"},
+		{"Pattern first in order", custom, "Organic Banana", "organic"},
+	}
+	for _, testCase := range tests {
+		t.Run(testCase.name, func(t *testing.T) {
+			result := testCase.config.ClassifyItem(models.Item{ShortDescription: testCase.description, Price: "1.00"})
+			if result != testCase.expected {
+				t.Errorf("Result was %q; want %q", result, testCase.expected)
+			}
+		})
+	}
+}
+
+func TestConfigCalculatePointsWithCategoryRules(t *testing.T) {
+	receipt := models.Receipt{
+		Retailer:     "Target",
+		PurchaseDate: "2022-01-02",
+		PurchaseTime: "13:00",
+		Items: []models.Item{
+			{ShortDescription: "Bananas", Price: "1.00"},
+			{ShortDescription: "Apples 3 lb", Price: "4.00"},
+			{ShortDescription: "Doritos", Price: "3.00"},
+		},
+		Total: "8.00",
+	}
+
+	config := DefaultConfig()
+	base := config.CalculatePoints(receipt)
+
+	config.Categories.Rules = []CategoryRule{{Category: "produce", PointsPerItem: 10}}
+	if result := config.CalculatePoints(receipt); result != base+20 {
+		t.Errorf("Result was %d; want %d with 10 points per produce item", result, base+20)
+	}
+
+	// Classifying for scoring does not change the caller's receipt
+	if receipt.Items[0].Category != "" {
+		t.Errorf("Receipt item category was %q; want it unchanged", receipt.Items[0].Category)
+	}
+
+	// Expression rules can use categories too
+	config.Categories.Rules = nil
+	config.Expressions.Rules = []ExpressionRule{{Name: "snacks", Expression: `5 * count(items, it.category == "snacks")`}}
+	if result := config.CalculatePoints(receipt); result != base+5 {
+		t.Errorf("Result was %d; want %d with 5 points per snack", result, base+5)
+	}
+}
+
+func TestCategoryConfigValidate(t *testing.T) {
+	tests := []struct {
+		name    string
+		config  CategoryConfig
+		wantErr bool
+	}{
+		{"Default", CategoryConfig{}, false},
+		{"Rule on default category", CategoryConfig{Rules: []CategoryRule{{Category: "produce", PointsPerItem: 10}}}, false},
+		{"Rule on unknown category", CategoryConfig{Rules: []CategoryRule{{Category: "toys", PointsPerItem: 10}}}, true},
+		{"Negative rule points", CategoryConfig{Rules: []CategoryRule{{Category: "produce", PointsPerItem: -1}}}, true},
+		{"Category without name", CategoryConfig{Dictionary: []Category{{Keywords: []string{"toy"}}}}, true},
+		{"Category without matchers", CategoryConfig{Dictionary: []Category{{Name: "toys"}}}, true},
+		{"Bad pattern", CategoryConfig{Dictionary: []Category{{Name: "toys", Patterns: []string{"(lego"}}}}, true},
+	}
+	for _, testCase := range tests {
+		t.Run(testCase.name, func(t *testing.T) {
+			if err := testCase.config.Validate(); (err != nil) != testCase.wantErr {
+				t.Errorf("unexpected error, error was %v, want %v", err, testCase.wantErr)
+			}
+		})
+	}
+}
@@ -0,0 +1,162 @@
+package rules
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"os"
+	"path/filepath"
+
+	"receipt-processor-challenge-jase180/internal/models"
+	"receipt-processor-challenge-jase180/internal/services/text"
+)
+
+// Config is the rules configuration for scoring beyond the built-in rules in rules.go
+// Loaded from a JSON rules file, anything missing from the file keeps its default
+type Config struct {
+	Tiers        TierConfig       `json:"tiers"`        // Loyalty tiers and their points multipliers
+	Limits       LimitConfig      `json:"limits"`       // Caps on points per receipt, user and retailer
+	Expressions  ExpressionConfig `json:"expressions"`  // Custom rules in the expr language
+	Timezones    TimezoneConfig   `json:"timezones"`    // Store zones and the zone time based rules use
+	TimeWindows  TimeWindowConfig `json:"timeWindows"`  // Times of day that earn points, replaces the 2:00pm to 4:00pm rule
+	Categories   CategoryConfig   `json:"categories"`   // Item classifier dictionary and points per category
+	Retailers    RetailerConfig   `json:"retailers"`    // Canonical retailer registry and whether rules use it
+	CountMode    text.Mode        `json:"countMode"`    // How length-based rules count characters, graphemes by default
+	ItemCounting ItemCounting     `json:"itemCounting"` // Whether item-count and per-item rules count line items or units
+	Currency     CurrencyConfig   `json:"currency"`     // Base currency and exchange rates for the total rules
+}
+
+// DefaultConfig returns the configuration used when no rules file is given
+func DefaultConfig() Config {
+	return Config{
+		Tiers:  DefaultTierConfig(),
+		Limits: DefaultLimitConfig(),
+	}
+}
+
+// Validate checks every section of the configuration
+func (c Config) Validate() error {
+	if err := c.Tiers.Validate(); err != nil {
+		return err
+	}
+	if err := c.Limits.Validate(); err != nil {
+		return err
+	}
+	if err := c.Expressions.Validate(); err != nil {
+		return err
+	}
+	if err := c.Timezones.Validate(); err != nil {
+		return err
+	}
+	if err := c.TimeWindows.Validate(); err != nil {
+		return err
+	}
+	if err := c.Categories.Validate(); err != nil {
+		return err
+	}
+	if err := c.Retailers.Validate(); err != nil {
+		return err
+	}
+	if err := c.CountMode.Validate(); err != nil {
+		return fmt.Errorf("countMode: %w", err)
+	}
+	if err := c.ItemCounting.Validate(); err != nil {
+		return fmt.Errorf("itemCounting: %w", err)
+	}
+	return c.Currency.Validate()
+}
+
+// Version identifies the rule set by its content, the first 12 hex digits of the SHA-256 of its JSON
+// Servers with the same rules report the same version, so it shows which rules a server is scoring with
+func (c Config) Version() string {
+	data, err := json.Marshal(c)
+	if err != nil {
+		return "unknown"
+	}
+	sum := sha256.Sum256(data)
+	return hex.EncodeToString(sum[:])[:12]
+}
+
+// CalculatePoints computes the points from the built-in rules in rules.go plus the configured custom rules
+func (c Config) CalculatePoints(receipt models.Receipt) int {
+	points := 0
+	for _, rule := range c.CalculateBreakdown(receipt) {
+		points += rule.Points
+	}
+	return points
+}
+
+// CalculateBreakdown computes the points from each built-in rule followed by each category rule and custom rule
+// Date and time rules see the purchase in the configured evaluation zone, items are classified first
+// and the retailer is the canonical name when the rules opt in
+func (c Config) CalculateBreakdown(receipt models.Receipt) []RulePoints {
+	receipt = c.Categories.Classify(c.Timezones.Localize(c.Retailers.ForRules(receipt)))
+	breakdown := calculateBreakdown(receipt, c)
+
+	for _, rule := range c.Categories.Rules {
+		breakdown = append(breakdown, RulePoints{Rule: "category:" + rule.Category, Points: PointsForCategoryRule(rule, receipt.Items, c.ItemCounting)})
+	}
+
+	for _, rule := range c.Expressions.Rules {
+		p, _ := c.Expressions.PointsForExpressionRule(rule, receipt, c.CountMode) // failing custom rules give 0 points
+		breakdown = append(breakdown, RulePoints{Rule: "expression:" + rule.Name, Points: p})
+	}
+	return breakdown
+}
+
+// LoadConfig reads a JSON rules file over the defaults and validates it
+func LoadConfig(path string) (Config, error) {
+	file, err := os.ReadFile(path)
+	if err != nil {
+		return Config{}, fmt.Errorf("cannot read rules file: %w", err)
+	}
+
+	config, err := ParseConfig(file)
+	if err != nil {
+		return Config{}, fmt.Errorf("rules file %s: %w", path, err)
+	}
+
+	// Exchange rates can live in their own file so they can be refreshed without touching the rules
+	if ratesFile := config.Currency.RatesFile; ratesFile != "" {
+		if !filepath.IsAbs(ratesFile) {
+			ratesFile = filepath.Join(filepath.Dir(path), ratesFile)
+		}
+		if err := config.Currency.LoadRates(ratesFile); err != nil {
+			return Config{}, fmt.Errorf("rules file %s: %w", path, err)
+		}
+		if err := config.Currency.Validate(); err != nil {
+			return Config{}, fmt.Errorf("rules file %s: %w", path, err)
+		}
+	}
+	return config, nil
+}
+
+// ParseConfig parses JSON rules configuration over the defaults and validates it
+// Used for the rules file and for candidate rules sent to the simulator, never reads ratesFile so requests cannot read files
+func ParseConfig(data []byte) (Config, error) {
+	return parseConfig(data, nil)
+}
+
+// ParseCandidateConfig parses candidate rules like ParseConfig with expression limits no higher than running's
+// so a simulation cannot run rules with more steps or time than the server allows
+func ParseCandidateConfig(data []byte, running Config) (Config, error) {
+	return parseConfig(data, &running.Expressions)
+}
+
+// parseConfig parses and validates rules over the defaults, capping expression limits at bound if given
+func parseConfig(data []byte, bound *ExpressionConfig) (Config, error) {
+	config := DefaultConfig()
+
+	if err := json.Unmarshal(data, &config); err != nil {
+		return Config{}, fmt.Errorf("cannot parse rules: %w", err)
+	}
+	if bound != nil {
+		config.Expressions = config.Expressions.WithinLimits(*bound)
+	}
+
+	if err := config.Validate(); err != nil {
+		return Config{}, fmt.Errorf("invalid rules: %w", err)
+	}
+	return config, nil
+}
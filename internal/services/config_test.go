@@ -0,0 +1,62 @@
+package rules
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+func TestLoadConfig(t *testing.T) {
+	tests := []struct {
+		name      string
+		contents  string
+		wantErr   bool
+		wantTiers int // number of tiers loaded if expect success
+	}{
+		{"Empty object keeps defaults", `{}`, false, 3},
+		{"Tiers replaced", `{"tiers": {"tiers": [{"name": "Member", "multiplier": 1}], "windowDays": 90}}`, false, 1},
+		{"Invalid JSON", `{"tiers":`, true, 0},
+		{"Invalid tiers", `{"tiers": {"tiers": []}}`, true, 0},
+		{"Invalid expression rule", `{"expressions": {"rules": [{"name": "Broken", "expression": "1 +"}]}}`, true, 0},
+	}
+	for _, testCase := range tests {
+		t.Run(testCase.name, func(t *testing.T) {
+			path := filepath.Join(t.TempDir(), "rules.json")
+			if err := os.WriteFile(path, []byte(testCase.contents), 0o600); err != nil {
+				t.Fatalf("Error writing rules file: %v", err)
+			}
+
+			config, err := LoadConfig(path)
+			if (err != nil) != testCase.wantErr {
+				t.Fatalf("unexpected error, error was %v, want %v", err, testCase.wantErr)
+			}
+			if err == nil && len(config.Tiers.Tiers) != testCase.wantTiers {
+				t.Errorf("Result was %d tiers; want %d", len(config.Tiers.Tiers), testCase.wantTiers)
+			}
+		})
+	}
+
+	// Missing file
+	if _, err := LoadConfig(filepath.Join(t.TempDir(), "missing.json")); err == nil {
+		t.Errorf("No error for missing rules file")
+	}
+}
+
+func TestConfigVersion(t *testing.T) {
+	defaults, err := ParseConfig([]byte(`{}`))
+	if err != nil {
+		t.Fatalf("Error parsing rules: %v", err)
+	}
+	changed, err := ParseConfig([]byte(`{"expressions": {"rules": [{"name": "Weekend", "expression": "1"}]}}`))
+	if err != nil {
+		t.Fatalf("Error parsing rules: %v", err)
+	}
+
+	// Same rules give the same version, different rules a different one
+	if version := defaults.Version(); len(version) != 12 || version != DefaultConfig().Version() {
+		t.Errorf("Result was %q and %q; want the same 12 digit version", version, DefaultConfig().Version())
+	}
+	if defaults.Version() == changed.Version() {
+		t.Errorf("Result was %q for different rules; want different versions", changed.Version())
+	}
+}
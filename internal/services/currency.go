@@ -0,0 +1,191 @@
+package rules
+
+import (
+	"encoding/json"
+	"fmt"
+	"math"
+	"os"
+	"regexp"
+	"strconv"
+	"strings"
+
+	"receipt-processor-challenge-jase180/internal/models"
+)
+
+// DefaultCurrency is the currency of receipts without one, the implicit dollar of api.yml
+const DefaultCurrency = "USD"
+
+// Where the round dollar and quarter rules evaluate the total
+const (
+	EvaluateInNative = "native" // In the receipt's currency, e.g. a round euro
+	EvaluateInBase   = "base"   // Converted to the base currency with the exchange-rate table
+)
+
+// minorUnits is the number of decimals of each ISO 4217 currency accepted on receipts
+var minorUnits = map[string]int{
+	"AED": 2, "ARS": 2, "AUD": 2, "BGN": 2, "BHD": 3, "BRL": 2, "CAD": 2, "CHF": 2, "CLP": 0, "CNY": 2,
+	"COP": 2, "CZK": 2, "DKK": 2, "EGP": 2, "EUR": 2, "GBP": 2, "HKD": 2, "HUF": 2, "IDR": 2, "ILS": 2,
+	"INR": 2, "ISK": 0, "JOD": 3, "JPY": 0, "KRW": 0, "KWD": 3, "MXN": 2, "MYR": 2, "NOK": 2, "NZD": 2,
+	"OMR": 3, "PHP": 2, "PLN": 2, "RON": 2, "RUB": 2, "SAR": 2, "SEK": 2, "SGD": 2, "THB": 2, "TND": 3,
+	"TRY": 2, "TWD": 2, "UAH": 2, "USD": 2, "VND": 0, "ZAR": 2,
+}
+
+// MinorUnits returns the decimals of a currency, "" is the default currency, false if it is not a known ISO 4217 code
+func MinorUnits(currency string) (int, bool) {
+	if currency == "" {
+		currency = DefaultCurrency
+	}
+	units, ok := minorUnits[currency]
+	return units, ok
+}
+
+// Amount formats by minor units, 2 is the "^\\d+\\.\\d{2}$" pattern in api.yml
+var amountPatterns = map[int]*regexp.Regexp{
+	0: regexp.MustCompile(`^\d+$`),
+	2: regexp.MustCompile(`^\d+\.\d{2}$`),
+	3: regexp.MustCompile(`^\d+\.\d{3}$`),
+}
+
+// ValidAmount reports if an amount has exactly the currency's minor-unit precision, e.g. "6.49" USD or "1000" JPY
+func ValidAmount(amount string, currency string) bool {
+	units, ok := MinorUnits(currency)
+	if !ok {
+		return false
+	}
+	return amountPatterns[units].MatchString(amount)
+}
+
+// Money is an amount in minor units of a currency, e.g. 649 USD is $6.49
+type Money struct {
+	Minor    int64
+	Currency string
+}
+
+// ParseMoney parses an amount with the currency's precision, "" is the default currency
+func ParseMoney(amount string, currency string) (Money, error) {
+	if currency == "" {
+		currency = DefaultCurrency
+	}
+	if !ValidAmount(amount, currency) {
+		return Money{}, fmt.Errorf("amount %q is not a %s amount", amount, currency)
+	}
+	minor, err := strconv.ParseInt(strings.Replace(amount, ".", "", 1), 10, 64)
+	if err != nil {
+		return Money{}, fmt.Errorf("cannot convert amount: %s", amount)
+	}
+	return Money{Minor: minor, Currency: currency}, nil
+}
+
+// perMajor returns the minor units in one major unit, e.g. 100 cents in a dollar and 1 for yen
+func (m Money) perMajor() int64 {
+	units, _ := MinorUnits(m.Currency)
+	return int64(math.Pow10(units))
+}
+
+// CurrencyConfig is the base currency, the exchange-rate table and where the total based rules evaluate
+// Rates are how many units of the base currency one unit of each currency is worth, e.g. "CAD": 0.74 with a USD base
+type CurrencyConfig struct {
+	Base       string             `json:"base"`                // Currency rules normalize to, DefaultCurrency if empty
+	EvaluateIn string             `json:"evaluateIn"`          // EvaluateInNative (default) or EvaluateInBase
+	Rates      map[string]float64 `json:"rates,omitempty"`     // Exchange rates to the base currency
+	RatesFile  string             `json:"ratesFile,omitempty"` // JSON file of rates merged into Rates by LoadConfig, relative to the rules file
+}
+
+// base returns the configured base currency or the default
+func (c CurrencyConfig) base() string {
+	if c.Base == "" {
+		return DefaultCurrency
+	}
+	return c.Base
+}
+
+// Validate checks the base and every rate are known currencies and rates are positive
+func (c CurrencyConfig) Validate() error {
+	if _, ok := MinorUnits(c.base()); !ok {
+		return fmt.Errorf("currency: base %q is not an ISO 4217 code", c.Base)
+	}
+	switch c.EvaluateIn {
+	case "", EvaluateInNative, EvaluateInBase:
+	default:
+		return fmt.Errorf("currency: evaluateIn must be %q or %q", EvaluateInNative, EvaluateInBase)
+	}
+	for currency, rate := range c.Rates {
+		if _, ok := MinorUnits(currency); !ok || currency == "" {
+			return fmt.Errorf("currency: rate for %q which is not an ISO 4217 code", currency)
+		}
+		if rate <= 0 || math.IsInf(rate, 0) || math.IsNaN(rate) {
+			return fmt.Errorf("currency: rate for %s must be positive", currency)
+		}
+	}
+	return nil
+}
+
+// LoadRates reads a JSON exchange-rate file of currency to rate into the config, rates in the file win
+// Rates are local only, nothing is fetched from the network
+func (c *CurrencyConfig) LoadRates(path string) error {
+	file, err := os.ReadFile(path)
+	if err != nil {
+		return fmt.Errorf("cannot read rates file: %w", err)
+	}
+
+	var rates map[string]float64
+	if err := json.Unmarshal(file, &rates); err != nil {
+		return fmt.Errorf("cannot parse rates file %s: %w", path, err)
+	}
+	if c.Rates == nil {
+		c.Rates = make(map[string]float64)
+	}
+	for currency, rate := range rates {
+		c.Rates[strings.ToUpper(currency)] = rate
+	}
+	return nil
+}
+
+// Convert converts money to the base currency rounded to the base's minor units
+// Returns an error if there is no rate for the currency
+func (c CurrencyConfig) Convert(money Money) (Money, error) {
+	base := Money{Currency: c.base()}
+	if money.Currency == base.Currency {
+		return money, nil
+	}
+	rate, ok := c.Rates[money.Currency]
+	if !ok {
+		return Money{}, fmt.Errorf("no exchange rate for %s", money.Currency)
+	}
+	major := float64(money.Minor) / float64(money.perMajor()) * rate
+	base.Minor = int64(math.Round(major * float64(base.perMajor())))
+	return base, nil
+}
+
+// TotalForRules returns the receipt total the round dollar and quarter rules evaluate
+// In base mode totals are converted, currencies without a rate stay native so a missing rate never loses points
+func (c CurrencyConfig) TotalForRules(receipt models.Receipt) (Money, error) {
+	total, err := ParseMoney(receipt.Total, receipt.Currency)
+	if err != nil {
+		return Money{}, err
+	}
+	if c.EvaluateIn != EvaluateInBase {
+		return total, nil
+	}
+	if converted, err := c.Convert(total); err == nil {
+		return converted, nil
+	}
+	return total, nil
+}
+
+// PointsForRoundAmount is the round dollar rule for any currency: 50 points if the total has no minor units
+// Currencies without minor units, like JPY, are always round in native mode
+func PointsForRoundAmount(total Money) int {
+	if total.Minor%total.perMajor() == 0 {
+		return 50
+	}
+	return 0
+}
+
+// PointsForQuarterAmount is the quarter rule for any currency: 25 points if the total is a multiple of a quarter unit
+func PointsForQuarterAmount(total Money) int {
+	if (total.Minor*4)%total.perMajor() == 0 {
+		return 25
+	}
+	return 0
+}
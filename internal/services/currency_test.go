@@ -0,0 +1,121 @@
+package rules
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+
+	"receipt-processor-challenge-jase180/internal/models"
+)
+
+func TestValidAmount(t *testing.T) {
+	tests := []struct {
+		name     string
+		amount   string
+		currency string
+		expected bool
+	}{
+		{"Dollars without currency", "6.49", "", true},
+		{"Dollars without cents", "6", "", false},
+		{"Euros", "6.49", "EUR", true},
+		{"Yen has no minor units", "1000", "JPY", true},
+		{"Yen with decimals", "1000.00", "JPY", false},
+		{"Dinar has 3 decimals", "1.250", "BHD", true},
+		{"Dinar with 2 decimals", "1.25", "BHD", false},
+		{"Unknown currency", "6.49", "XYZ", false},
+		{"Lowercase code", "6.49", "usd", false},
+	}
+	for _, testCase := range tests {
+		t.Run(testCase.name, func(t *testing.T) {
+			if result := ValidAmount(testCase.amount, testCase.currency); result != testCase.expected {
+				t.Errorf("Result was %v; want %v", result, testCase.expected)
+			}
+		})
+	}
+}
+
+func TestCurrencyTotalRules(t *testing.T) {
+	rates := map[string]float64{"CAD": 0.75, "JPY": 0.0067, "EUR": 1.10}
+	native := CurrencyConfig{Rates: rates}
+	base := CurrencyConfig{EvaluateIn: EvaluateInBase, Rates: rates}
+
+	tests := []struct {
+		name         string
+		config       CurrencyConfig
+		total        string
+		currency     string
+		wantRound    int
+		wantQuarter  int
+		wantParseErr bool
+	}{
+		{"Native dollars", native, "35.00", "", 50, 25, false},
+		{"Native euros", native, "12.25", "EUR", 0, 25, false},
+		{"Native yen is always round", native, "1234", "JPY", 50, 25, false},
+		{"Native dinar quarter", native, "1.250", "BHD", 0, 25, false},
+		{"Base converts CAD", base, "20.00", "CAD", 50, 25, false},   // 15.00 USD
+		{"Base converts yen", base, "1000", "JPY", 0, 0, false},      // 6.70 USD
+		{"Base converts euros", base, "10.00", "EUR", 50, 25, false}, // 11.00 USD
+		{"Base without rate stays native", base, "4.25", "GBP", 0, 25, false},
+		{"Wrong precision", native, "10.00", "JPY", 0, 0, true},
+	}
+	for _, testCase := range tests {
+		t.Run(testCase.name, func(t *testing.T) {
+			total, err := testCase.config.TotalForRules(models.Receipt{Total: testCase.total, Currency: testCase.currency})
+			if (err != nil) != testCase.wantParseErr {
+				t.Fatalf("unexpected error, error was %v, want %v", err, testCase.wantParseErr)
+			}
+			if err != nil {
+				return
+			}
+			if round, quarter := PointsForRoundAmount(total), PointsForQuarterAmount(total); round != testCase.wantRound || quarter != testCase.wantQuarter {
+				t.Errorf("Result was %d and %d; want %d and %d", round, quarter, testCase.wantRound, testCase.wantQuarter)
+			}
+		})
+	}
+}
+
+func TestLoadConfigRatesFile(t *testing.T) {
+	dir := t.TempDir()
+	os.WriteFile(filepath.Join(dir, "rates.json"), []byte(`{"CAD": 0.75, "eur": 1.10}`), 0o600)
+	os.WriteFile(filepath.Join(dir, "rules.json"), []byte(`{"currency": {"evaluateIn": "base", "ratesFile": "rates.json", "rates": {"CAD": 0.70}}}`), 0o600)
+	os.WriteFile(filepath.Join(dir, "bad.json"), []byte(`{"currency": {"ratesFile": "missing.json"}}`), 0o600)
+
+	config, err := LoadConfig(filepath.Join(dir, "rules.json"))
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if config.Currency.Rates["CAD"] != 0.75 || config.Currency.Rates["EUR"] != 1.10 {
+		t.Errorf("Result was %v; want rates from the file", config.Currency.Rates)
+	}
+
+	if _, err := LoadConfig(filepath.Join(dir, "bad.json")); err == nil {
+		t.Errorf("Missing rates file loaded; want error")
+	}
+
+	// Candidate rules never read files
+	if config, err := ParseConfig([]byte(`{"currency": {"ratesFile": "rates.json"}}`)); err != nil || len(config.Currency.Rates) != 0 {
+		t.Errorf("Result was %v with error %v; want no rates", config.Currency.Rates, err)
+	}
+}
+
+func TestCurrencyConfigValidate(t *testing.T) {
+	tests := []struct {
+		name    string
+		config  CurrencyConfig
+		wantErr bool
+	}{
+		{"Default", CurrencyConfig{}, false},
+		{"Euro base", CurrencyConfig{Base: "EUR", EvaluateIn: EvaluateInBase, Rates: map[string]float64{"USD": 0.91}}, false},
+		{"Unknown base", CurrencyConfig{Base: "ABC"}, true},
+		{"Unknown evaluateIn", CurrencyConfig{EvaluateIn: "local"}, true},
+		{"Unknown rate currency", CurrencyConfig{Rates: map[string]float64{"ABC": 1}}, true},
+		{"Zero rate", CurrencyConfig{Rates: map[string]float64{"CAD": 0}}, true},
+	}
+	for _, testCase := range tests {
+		t.Run(testCase.name, func(t *testing.T) {
+			if err := testCase.config.Validate(); (err != nil) != testCase.wantErr {
+				t.Errorf("unexpected error, error was %v, want %v", err, testCase.wantErr)
+			}
+		})
+	}
+}
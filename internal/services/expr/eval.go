@@ -0,0 +1,506 @@
+package expr
+
+import (
+	"errors"
+	"fmt"
+	"math"
+	"strings"
+	"time"
+
+	"receipt-processor-challenge-jase180/internal/services/text"
+)
+
+// Value is the result of evaluating an expression or a variable passed in
+// Only float64, string, bool, []Value and map[string]Value are used
+type Value interface{}
+
+// Limits bound the work an expression can do so a bad rule cannot stall scoring
+type Limits struct {
+	MaxSteps int           // Nodes evaluated, list functions count one step per element
+	MaxDepth int           // Nesting of the expression tree, checked when parsing and evaluating
+	Timeout  time.Duration // Wall clock time allowed per evaluation
+}
+
+// DefaultLimits are used for any limit left at zero
+var DefaultLimits = Limits{MaxSteps: 10000, MaxDepth: 64, Timeout: 10 * time.Millisecond}
+
+// Defined evaluation errors for reusability
+var (
+	ErrStepLimit = errors.New("expression exceeded step limit")
+	ErrTimeout   = errors.New("expression exceeded time limit")
+	ErrDepth     = errors.New("expression exceeded depth limit")
+)
+
+// MaxSourceLength is the longest expression accepted
+const MaxSourceLength = 4096
+
+// Program is a parsed expression ready to be evaluated many times
+type Program struct {
+	Source string
+	root   node
+	limits Limits
+}
+
+// Compile parses an expression, any limit left at zero takes its default
+func Compile(source string, limits Limits) (*Program, error) {
+	if limits.MaxSteps <= 0 {
+		limits.MaxSteps = DefaultLimits.MaxSteps
+	}
+	if limits.MaxDepth <= 0 {
+		limits.MaxDepth = DefaultLimits.MaxDepth
+	}
+	if limits.Timeout <= 0 {
+		limits.Timeout = DefaultLimits.Timeout
+	}
+	if len(source) > MaxSourceLength {
+		return nil, fmt.Errorf("expression longer than %d characters", MaxSourceLength)
+	}
+
+	tokens, err := lex(source)
+	if err != nil {
+		return nil, err
+	}
+	p := &parser{tokens: tokens, maxDepth: limits.MaxDepth}
+	root, err := p.parseExpression()
+	if err != nil {
+		return nil, err
+	}
+	if current := p.tokens[p.pos]; current.kind != tokenEOF {
+		return nil, fmt.Errorf("unexpected %q at %d", current.text, current.pos)
+	}
+	return &Program{Source: source, root: root, limits: limits}, nil
+}
+
+// Eval evaluates the program with the given variables, len counts string characters with mode
+func (p *Program) Eval(variables map[string]Value, mode text.Mode) (Value, error) {
+	e := &evaluator{
+		variables: variables,
+		countMode: mode,
+		limits:    p.limits,
+		deadline:  time.Now().Add(p.limits.Timeout),
+	}
+	return e.eval(p.root)
+}
+
+// EvalNumber evaluates the program and requires a number result
+func (p *Program) EvalNumber(variables map[string]Value, mode text.Mode) (float64, error) {
+	result, err := p.Eval(variables, mode)
+	if err != nil {
+		return 0, err
+	}
+	number, ok := result.(float64)
+	if !ok {
+		return 0, fmt.Errorf("expression result is %s, want number", typeName(result))
+	}
+	return number, nil
+}
+
+// evaluator holds state for one evaluation
+type evaluator struct {
+	variables map[string]Value
+	countMode text.Mode
+	limits    Limits
+	deadline  time.Time
+	steps     int
+	depth     int
+}
+
+// step counts work done and enforces step and time limits
+func (e *evaluator) step() error {
+	e.steps++
+	if e.steps > e.limits.MaxSteps {
+		return ErrStepLimit
+	}
+	// Checking the clock every step is costly, every 64 steps is still well under a millisecond
+	if e.steps%64 == 0 && time.Now().After(e.deadline) {
+		return ErrTimeout
+	}
+	return nil
+}
+
+// eval evaluates a node
+func (e *evaluator) eval(n node) (Value, error) {
+	if err := e.step(); err != nil {
+		return nil, err
+	}
+	e.depth++
+	defer func() { e.depth-- }()
+	if e.depth > e.limits.MaxDepth {
+		return nil, ErrDepth
+	}
+
+	switch n := n.(type) {
+	case literalNode:
+		return n.value, nil
+
+	case identNode:
+		value, exists := e.variables[n.name]
+		if !exists {
+			return nil, fmt.Errorf("unknown variable %q", n.name)
+		}
+		return value, nil
+
+	case memberNode:
+		object, err := e.eval(n.object)
+		if err != nil {
+			return nil, err
+		}
+		fields, ok := object.(map[string]Value)
+		if !ok {
+			return nil, fmt.Errorf("cannot read field %q of %s", n.field, typeName(object))
+		}
+		value, exists := fields[n.field]
+		if !exists {
+			return nil, fmt.Errorf("unknown field %q", n.field)
+		}
+		return value, nil
+
+	case unaryNode:
+		operand, err := e.eval(n.operand)
+		if err != nil {
+			return nil, err
+		}
+		if n.operator == "!" {
+			boolean, err := asBool(operand)
+			return !boolean, err
+		}
+		number, err := asNumber(operand)
+		return -number, err
+
+	case binaryNode:
+		return e.evalBinary(n)
+
+	case ternaryNode:
+		condition, err := e.eval(n.condition)
+		if err != nil {
+			return nil, err
+		}
+		boolean, err := asBool(condition)
+		if err != nil {
+			return nil, err
+		}
+		if boolean {
+			return e.eval(n.ifTrue)
+		}
+		return e.eval(n.ifFalse)
+
+	case callNode:
+		if isListFunction(n.function) {
+			return e.evalListFunction(n)
+		}
+		args := make([]Value, len(n.args))
+		for i, arg := range n.args {
+			value, err := e.eval(arg)
+			if err != nil {
+				return nil, err
+			}
+			args[i] = value
+		}
+		return functions[n.function](args, e.countMode)
+	}
+	return nil, fmt.Errorf("unknown expression node %T", n)
+}
+
+// evalBinary evaluates binary operators, && and || short circuit
+func (e *evaluator) evalBinary(n binaryNode) (Value, error) {
+	left, err := e.eval(n.left)
+	if err != nil {
+		return nil, err
+	}
+
+	if n.operator == "&&" || n.operator == "||" {
+		leftBool, err := asBool(left)
+		if err != nil {
+			return nil, err
+		}
+		if (n.operator == "&&") != leftBool {
+			return leftBool, nil // false && x is false, true || x is true
+		}
+		right, err := e.eval(n.right)
+		if err != nil {
+			return nil, err
+		}
+		return asBool(right)
+	}
+
+	right, err := e.eval(n.right)
+	if err != nil {
+		return nil, err
+	}
+
+	switch n.operator {
+	case "==":
+		return equal(left, right), nil
+	case "!=":
+		return !equal(left, right), nil
+	}
+
+	// String concatenation and comparison
+	leftString, leftIsString := left.(string)
+	rightString, rightIsString := right.(string)
+	if leftIsString && rightIsString {
+		switch n.operator {
+		case "+":
+			return leftString + rightString, nil
+		case "<":
+			return leftString < rightString, nil
+		case "<=":
+			return leftString <= rightString, nil
+		case ">":
+			return leftString > rightString, nil
+		case ">=":
+			return leftString >= rightString, nil
+		}
+	}
+
+	leftNumber, err := asNumber(left)
+	if err != nil {
+		return nil, err
+	}
+	rightNumber, err := asNumber(right)
+	if err != nil {
+		return nil, err
+	}
+
+	switch n.operator {
+	case "+":
+		return leftNumber + rightNumber, nil
+	case "-":
+		return leftNumber - rightNumber, nil
+	case "*":
+		return leftNumber * rightNumber, nil
+	case "/":
+		if rightNumber == 0 {
+			return nil, errors.New("division by zero")
+		}
+		return leftNumber / rightNumber, nil
+	case "%":
+		if rightNumber == 0 {
+			return nil, errors.New("division by zero")
+		}
+		return math.Mod(leftNumber, rightNumber), nil
+	case "<":
+		return leftNumber < rightNumber, nil
+	case "<=":
+		return leftNumber <= rightNumber, nil
+	case ">":
+		return leftNumber > rightNumber, nil
+	case ">=":
+		return leftNumber >= rightNumber, nil
+	}
+	return nil, fmt.Errorf("unknown operator %q", n.operator)
+}
+
+// evalListFunction evaluates count, sum, any and all, which evaluate their second argument once per
+// element of the list in the first with the element bound to "it"
+func (e *evaluator) evalListFunction(n callNode) (Value, error) {
+	listValue, err := e.eval(n.args[0])
+	if err != nil {
+		return nil, err
+	}
+	list, ok := listValue.([]Value)
+	if !ok {
+		return nil, fmt.Errorf("%s needs a list, got %s", n.function, typeName(listValue))
+	}
+
+	// Bind "it" in a copy of the variables so the outer scope is untouched
+	scope := make(map[string]Value, len(e.variables)+1)
+	for name, value := range e.variables {
+		scope[name] = value
+	}
+	outer := e.variables
+	e.variables = scope
+	defer func() { e.variables = outer }()
+
+	count, sum := 0.0, 0.0
+	for _, element := range list {
+		scope["it"] = element
+		result, err := e.eval(n.args[1])
+		if err != nil {
+			return nil, err
+		}
+
+		if n.function == "sum" {
+			number, err := asNumber(result)
+			if err != nil {
+				return nil, err
+			}
+			sum += number
+			continue
+		}
+
+		matched, err := asBool(result)
+		if err != nil {
+			return nil, err
+		}
+		if matched {
+			count++
+		}
+		if n.function == "any" && matched {
+			return true, nil
+		}
+		if n.function == "all" && !matched {
+			return false, nil
+		}
+	}
+
+	switch n.function {
+	case "sum":
+		return sum, nil
+	case "any":
+		return false, nil
+	case "all":
+		return true, nil
+	}
+	return count, nil
+}
+
+// isListFunction reports if a function evaluates an expression per list element
+func isListFunction(name string) bool {
+	return name == "count" || name == "sum" || name == "any" || name == "all"
+}
+
+// function is a helper function, mode is how the program was asked to count characters
+type function func(args []Value, mode text.Mode) (Value, error)
+
+// functions are the helper functions available to expressions, all are pure
+var functions = map[string]function{
+	"len": func(args []Value, mode text.Mode) (Value, error) {
+		if len(args) != 1 {
+			return nil, errors.New("len takes 1 argument")
+		}
+		switch value := args[0].(type) {
+		case string:
+			return float64(text.Count(value, mode)), nil
+		case []Value:
+			return float64(len(value)), nil
+		}
+		return nil, fmt.Errorf("len needs a string or list, got %s", typeName(args[0]))
+	},
+	"round": numberFunction(math.Round),
+	"ceil":  numberFunction(math.Ceil),
+	"floor": numberFunction(math.Floor),
+	"abs":   numberFunction(math.Abs),
+	"min": func(args []Value, _ text.Mode) (Value, error) {
+		return foldNumbers("min", args, math.Min)
+	},
+	"max": func(args []Value, _ text.Mode) (Value, error) {
+		return foldNumbers("max", args, math.Max)
+	},
+	"lower": stringFunction(strings.ToLower),
+	"upper": stringFunction(strings.ToUpper),
+	"trim":  stringFunction(strings.TrimSpace),
+	"contains": func(args []Value, _ text.Mode) (Value, error) {
+		return stringPredicate("contains", args, strings.Contains)
+	},
+	"startsWith": func(args []Value, _ text.Mode) (Value, error) {
+		return stringPredicate("startsWith", args, strings.HasPrefix)
+	},
+	"endsWith": func(args []Value, _ text.Mode) (Value, error) {
+		return stringPredicate("endsWith", args, strings.HasSuffix)
+	},
+}
+
+// numberFunction wraps a one argument math function
+func numberFunction(function func(float64) float64) function {
+	return func(args []Value, _ text.Mode) (Value, error) {
+		if len(args) != 1 {
+			return nil, errors.New("function takes 1 argument")
+		}
+		number, err := asNumber(args[0])
+		if err != nil {
+			return nil, err
+		}
+		return function(number), nil
+	}
+}
+
+// stringFunction wraps a one argument string function
+func stringFunction(function func(string) string) function {
+	return func(args []Value, _ text.Mode) (Value, error) {
+		if len(args) != 1 {
+			return nil, errors.New("function takes 1 argument")
+		}
+		text, ok := args[0].(string)
+		if !ok {
+			return nil, fmt.Errorf("function needs a string, got %s", typeName(args[0]))
+		}
+		return function(text), nil
+	}
+}
+
+// stringPredicate checks two string arguments with a predicate
+func stringPredicate(name string, args []Value, predicate func(string, string) bool) (Value, error) {
+	if len(args) != 2 {
+		return nil, fmt.Errorf("%s takes 2 arguments", name)
+	}
+	text, textOk := args[0].(string)
+	sub, subOk := args[1].(string)
+	if !textOk || !subOk {
+		return nil, fmt.Errorf("%s needs 2 strings", name)
+	}
+	return predicate(text, sub), nil
+}
+
+// foldNumbers combines one or more number arguments
+func foldNumbers(name string, args []Value, combine func(float64, float64) float64) (Value, error) {
+	if len(args) == 0 {
+		return nil, fmt.Errorf("%s needs at least 1 argument", name)
+	}
+	result, err := asNumber(args[0])
+	if err != nil {
+		return nil, err
+	}
+	for _, arg := range args[1:] {
+		number, err := asNumber(arg)
+		if err != nil {
+			return nil, err
+		}
+		result = combine(result, number)
+	}
+	return result, nil
+}
+
+// asNumber converts a value to a number, there is no implicit conversion from strings or bools
+func asNumber(value Value) (float64, error) {
+	number, ok := value.(float64)
+	if !ok {
+		return 0, fmt.Errorf("expected number, got %s", typeName(value))
+	}
+	return number, nil
+}
+
+// asBool converts a value to a bool, there is no truthiness
+func asBool(value Value) (bool, error) {
+	boolean, ok := value.(bool)
+	if !ok {
+		return false, fmt.Errorf("expected bool, got %s", typeName(value))
+	}
+	return boolean, nil
+}
+
+// equal compares two values of the same scalar type, different types are never equal
+func equal(left Value, right Value) bool {
+	switch left.(type) {
+	case float64, string, bool:
+		return left == right
+	}
+	return false
+}
+
+// typeName names a value's type for error messages
+func typeName(value Value) string {
+	switch value.(type) {
+	case float64:
+		return "number"
+	case string:
+		return "string"
+	case bool:
+		return "bool"
+	case []Value:
+		return "list"
+	case map[string]Value:
+		return "object"
+	}
+	return fmt.Sprintf("%T", value)
+}
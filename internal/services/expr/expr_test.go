@@ -0,0 +1,166 @@
+package expr
+
+import (
+	"strings"
+	"testing"
+	"time"
+
+	"receipt-processor-challenge-jase180/internal/services/text"
+)
+
+func TestEval(t *testing.T) {
+	variables := map[string]Value{
+		"retailer": "Target",
+		"total":    35.35,
+		"weekend":  true,
+		"items": []Value{
+			map[string]Value{"description": "Mountain Dew 12PK", "price": 6.49},
+			map[string]Value{"description": "Emils Cheese Pizza", "price": 12.25},
+			map[string]Value{"description": "Knorr Creamy Chicken", "price": 1.26},
+		},
+	}
+
+	tests := []struct {
+		name       string
+		expression string
+		expected   Value
+	}{
+		{"Arithmetic precedence", "1 + 2 * 3 - 4 / 2", 5.0},
+		{"Parentheses", "(1 + 2) * 3", 9.0},
+		{"Unary minus", "-2 * -3", 6.0},
+		{"Modulo", "7 % 3", 1.0},
+		{"Comparison", "total > 30 && total <= 35.35", true},
+		{"Short circuit or", "true || unknownVariable", true},
+		{"Short circuit and", "false && unknownVariable", false},
+		{"Not", "!weekend", false},
+		{"Ternary", "weekend ? 10 : 0", 10.0},
+		{"Nested ternary", "total > 100 ? 3 : total > 10 ? 2 : 1", 2.0},
+		{"String equality", "retailer == 'Target'", true},
+		{"String concatenation", `"a" + 'b'`, "ab"},
+		{"Different types not equal", "retailer == 1", false},
+		{"Member access", "items.x", nil}, // error case below, lists have no fields
+		{"Function calls", "ceil(max(1.2, 3.4, 2) * min(2, 5))", 7.0},
+		{"String functions", "contains(lower(retailer), 'targ') && startsWith(upper(trim(' ab ')), 'A')", true},
+		{"Len of string and list", "len(retailer) + len(items)", 9.0},
+		{"Count items over price", "count(items, it.price > 5)", 2.0},
+		{"Sum item prices", "round(sum(items, it.price))", 20.0},
+		{"Any and all", "any(items, contains(it.description, 'Pizza')) && !all(items, it.price > 5)", true},
+		{"Weekend rule from product", "weekend ? 3 * count(items, it.price > 5) : 0", 6.0},
+	}
+	for _, testCase := range tests {
+		t.Run(testCase.name, func(t *testing.T) {
+			program, err := Compile(testCase.expression, Limits{})
+			if err != nil {
+				t.Fatalf("unexpected compile error: %v", err)
+			}
+			result, err := program.Eval(variables, text.Graphemes)
+			if testCase.expected == nil {
+				if err == nil {
+					t.Errorf("Result was %v; want error", result)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("unexpected eval error: %v", err)
+			}
+			if result != testCase.expected {
+				t.Errorf("Result was %v; want %v", result, testCase.expected)
+			}
+		})
+	}
+}
+
+func TestLenCountMode(t *testing.T) {
+	// e plus a combining accent and a flag emoji, 6 graphemes, 7 code points after NFC and 15 bytes
+	variables := map[string]Value{"retailer": "Cafe\u0301 \U0001F1FA\U0001F1F8"}
+	program, err := Compile("len(retailer)", Limits{})
+	if err != nil {
+		t.Fatalf("unexpected compile error: %v", err)
+	}
+
+	tests := []struct {
+		mode     text.Mode
+		expected float64
+	}{
+		{"", 6},
+		{text.Graphemes, 6},
+		{text.Runes, 7},
+		{text.Legacy, 15},
+	}
+	for _, testCase := range tests {
+		t.Run(string(testCase.mode), func(t *testing.T) {
+			if result, err := program.EvalNumber(variables, testCase.mode); err != nil || result != testCase.expected {
+				t.Errorf("Result was %v, %v; want %v", result, err, testCase.expected)
+			}
+		})
+	}
+}
+
+func TestCompileErrors(t *testing.T) {
+	tests := []struct {
+		name       string
+		expression string
+	}{
+		{"Empty", ""},
+		{"Dangling operator", "1 +"},
+		{"Unclosed parenthesis", "(1 + 2"},
+		{"Unterminated string", "'abc"},
+		{"Unknown character", "1 # 2"},
+		{"Unknown function", "exec('rm')"},
+		{"List function needs two arguments", "count(items)"},
+		{"Trailing tokens", "1 2"},
+		{"Missing ternary else", "true ? 1"},
+		{"Too deeply nested", strings.Repeat("(", 100) + "1" + strings.Repeat(")", 100)},
+		{"Too long", strings.Repeat("1 + ", MaxSourceLength) + "1"},
+	}
+	for _, testCase := range tests {
+		t.Run(testCase.name, func(t *testing.T) {
+			if _, err := Compile(testCase.expression, Limits{}); err == nil {
+				t.Errorf("No error compiling %q", testCase.expression)
+			}
+		})
+	}
+}
+
+func TestEvalLimits(t *testing.T) {
+	// 1000 items each evaluated with a 3 node body is well over 100 steps
+	items := make([]Value, 1000)
+	for i := range items {
+		items[i] = map[string]Value{"price": 1.0}
+	}
+	variables := map[string]Value{"items": items}
+
+	program, err := Compile("count(items, it.price > 0)", Limits{MaxSteps: 100})
+	if err != nil {
+		t.Fatalf("unexpected compile error: %v", err)
+	}
+	if _, err := program.Eval(variables, text.Graphemes); err != ErrStepLimit {
+		t.Errorf("Result was %v; want %v", err, ErrStepLimit)
+	}
+
+	// Nested list functions multiply work so a tiny timeout trips first
+	program, err = Compile("sum(items, count(items, it.price > 0))", Limits{MaxSteps: 100000000, Timeout: time.Microsecond})
+	if err != nil {
+		t.Fatalf("unexpected compile error: %v", err)
+	}
+	if _, err := program.Eval(variables, text.Graphemes); err != ErrTimeout {
+		t.Errorf("Result was %v; want %v", err, ErrTimeout)
+	}
+}
+
+func TestEvalNumber(t *testing.T) {
+	program, _ := Compile("'points'", Limits{})
+	if _, err := program.EvalNumber(nil, text.Graphemes); err == nil {
+		t.Errorf("No error for string result")
+	}
+
+	program, _ = Compile("unknown + 1", Limits{})
+	if _, err := program.EvalNumber(nil, text.Graphemes); err == nil {
+		t.Errorf("No error for unknown variable")
+	}
+
+	program, _ = Compile("1 / 0", Limits{})
+	if _, err := program.EvalNumber(nil, text.Graphemes); err == nil {
+		t.Errorf("No error for division by zero")
+	}
+}
@@ -0,0 +1,106 @@
+// Package expr is a small sandboxed expression language for custom scoring rules
+// Expressions are parsed into a tree once and evaluated against variables with step, time and depth limits
+// There are no assignments, loops or user defined functions so every expression terminates
+package expr
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+)
+
+// tokenKind is the type of a lexed token
+type tokenKind int
+
+const (
+	tokenEOF tokenKind = iota
+	tokenNumber
+	tokenString
+	tokenIdent
+	tokenOperator
+)
+
+// token is one lexed piece of an expression, pos is the byte offset for error messages
+type token struct {
+	kind tokenKind
+	text string
+	num  float64
+	pos  int
+}
+
+// operators in longest first order so "<=" is not lexed as "<" then "="
+var operators = []string{"&&", "||", "==", "!=", "<=", ">=", "+", "-", "*", "/", "%", "<", ">", "!", "(", ")", ",", ".", "?", ":"}
+
+// lex splits an expression into tokens, ending with a tokenEOF
+func lex(source string) ([]token, error) {
+	tokens := []token{}
+	for pos := 0; pos < len(source); {
+		char := source[pos]
+
+		switch {
+		case char == ' ' || char == '\t' || char == '\n' || char == '\r':
+			pos++
+
+		case isDigit(char):
+			start := pos
+			for pos < len(source) && (isDigit(source[pos]) || source[pos] == '.') {
+				pos++
+			}
+			num, err := strconv.ParseFloat(source[start:pos], 64)
+			if err != nil {
+				return nil, fmt.Errorf("invalid number %q at %d", source[start:pos], start)
+			}
+			tokens = append(tokens, token{kind: tokenNumber, text: source[start:pos], num: num, pos: start})
+
+		case char == '"' || char == '\'':
+			// Strings use either quote, backslash escapes the next character
+			start := pos
+			pos++
+			var text strings.Builder
+			for pos < len(source) && source[pos] != char {
+				if source[pos] == '\\' && pos+1 < len(source) {
+					pos++
+				}
+				text.WriteByte(source[pos])
+				pos++
+			}
+			if pos >= len(source) {
+				return nil, fmt.Errorf("unterminated string at %d", start)
+			}
+			pos++ // closing quote
+			tokens = append(tokens, token{kind: tokenString, text: text.String(), pos: start})
+
+		case isLetter(char):
+			start := pos
+			for pos < len(source) && (isLetter(source[pos]) || isDigit(source[pos])) {
+				pos++
+			}
+			tokens = append(tokens, token{kind: tokenIdent, text: source[start:pos], pos: start})
+
+		default:
+			matched := false
+			for _, operator := range operators {
+				if strings.HasPrefix(source[pos:], operator) {
+					tokens = append(tokens, token{kind: tokenOperator, text: operator, pos: pos})
+					pos += len(operator)
+					matched = true
+					break
+				}
+			}
+			if !matched {
+				return nil, fmt.Errorf("unexpected character %q at %d", char, pos)
+			}
+		}
+	}
+	return append(tokens, token{kind: tokenEOF, pos: len(source)}), nil
+}
+
+// isDigit reports if a byte is an ASCII digit
+func isDigit(char byte) bool {
+	return char >= '0' && char <= '9'
+}
+
+// isLetter reports if a byte can start an identifier, identifiers are ASCII only
+func isLetter(char byte) bool {
+	return (char >= 'a' && char <= 'z') || (char >= 'A' && char <= 'Z') || char == '_'
+}
@@ -0,0 +1,245 @@
+package expr
+
+import (
+	"fmt"
+)
+
+// node is a parsed expression tree node
+type node interface{}
+
+// literalNode is a number, string or bool literal
+type literalNode struct {
+	value Value
+}
+
+// identNode is a variable lookup
+type identNode struct {
+	name string
+}
+
+// memberNode is object.field
+type memberNode struct {
+	object node
+	field  string
+}
+
+// unaryNode is -operand or !operand
+type unaryNode struct {
+	operator string
+	operand  node
+}
+
+// binaryNode is left operator right
+type binaryNode struct {
+	operator    string
+	left, right node
+}
+
+// ternaryNode is condition ? ifTrue : ifFalse
+type ternaryNode struct {
+	condition, ifTrue, ifFalse node
+}
+
+// callNode is function(args...)
+type callNode struct {
+	function string
+	args     []node
+}
+
+// Binding power of binary operators, higher binds tighter
+var precedence = map[string]int{
+	"||": 1,
+	"&&": 2,
+	"==": 3, "!=": 3,
+	"<": 4, "<=": 4, ">": 4, ">=": 4,
+	"+": 5, "-": 5,
+	"*": 6, "/": 6, "%": 6,
+}
+
+// parser is a precedence climbing parser over lexed tokens
+type parser struct {
+	tokens   []token
+	pos      int
+	depth    int // current nesting depth
+	maxDepth int // nesting allowed before giving up, keeps deeply nested input from exhausting the stack
+}
+
+// next returns the current token and moves past it
+func (p *parser) next() token {
+	current := p.tokens[p.pos]
+	if current.kind != tokenEOF {
+		p.pos++
+	}
+	return current
+}
+
+// peekOperator reports if the current token is the given operator
+func (p *parser) peekOperator(operator string) bool {
+	current := p.tokens[p.pos]
+	return current.kind == tokenOperator && current.text == operator
+}
+
+// expect consumes the given operator or returns an error
+func (p *parser) expect(operator string) error {
+	current := p.next()
+	if current.kind != tokenOperator || current.text != operator {
+		return fmt.Errorf("expected %q at %d", operator, current.pos)
+	}
+	return nil
+}
+
+// parseExpression parses a full expression including the ternary operator
+func (p *parser) parseExpression() (node, error) {
+	p.depth++
+	defer func() { p.depth-- }()
+	if p.depth > p.maxDepth {
+		return nil, fmt.Errorf("expression nested deeper than %d at %d", p.maxDepth, p.tokens[p.pos].pos)
+	}
+
+	condition, err := p.parseBinary(1)
+	if err != nil {
+		return nil, err
+	}
+	if !p.peekOperator("?") {
+		return condition, nil
+	}
+	p.next()
+
+	ifTrue, err := p.parseExpression()
+	if err != nil {
+		return nil, err
+	}
+	if err := p.expect(":"); err != nil {
+		return nil, err
+	}
+	ifFalse, err := p.parseExpression()
+	if err != nil {
+		return nil, err
+	}
+	return ternaryNode{condition: condition, ifTrue: ifTrue, ifFalse: ifFalse}, nil
+}
+
+// parseBinary parses binary operators binding at least as tight as minPrecedence
+func (p *parser) parseBinary(minPrecedence int) (node, error) {
+	left, err := p.parseUnary()
+	if err != nil {
+		return nil, err
+	}
+
+	for {
+		current := p.tokens[p.pos]
+		operatorPrecedence, isBinary := precedence[current.text]
+		if current.kind != tokenOperator || !isBinary || operatorPrecedence < minPrecedence {
+			return left, nil
+		}
+		p.next()
+
+		// Left associative so the right side must bind tighter
+		right, err := p.parseBinary(operatorPrecedence + 1)
+		if err != nil {
+			return nil, err
+		}
+		left = binaryNode{operator: current.text, left: left, right: right}
+	}
+}
+
+// parseUnary parses prefix - and ! operators
+func (p *parser) parseUnary() (node, error) {
+	if p.peekOperator("-") || p.peekOperator("!") {
+		operator := p.next().text
+
+		p.depth++
+		defer func() { p.depth-- }()
+		if p.depth > p.maxDepth {
+			return nil, fmt.Errorf("expression nested deeper than %d at %d", p.maxDepth, p.tokens[p.pos].pos)
+		}
+
+		operand, err := p.parseUnary()
+		if err != nil {
+			return nil, err
+		}
+		return unaryNode{operator: operator, operand: operand}, nil
+	}
+	return p.parsePostfix()
+}
+
+// parsePostfix parses a primary followed by any .field accesses
+func (p *parser) parsePostfix() (node, error) {
+	result, err := p.parsePrimary()
+	if err != nil {
+		return nil, err
+	}
+	for p.peekOperator(".") {
+		p.next()
+		field := p.next()
+		if field.kind != tokenIdent {
+			return nil, fmt.Errorf("expected field name at %d", field.pos)
+		}
+		result = memberNode{object: result, field: field.text}
+	}
+	return result, nil
+}
+
+// parsePrimary parses literals, variables, function calls and parentheses
+func (p *parser) parsePrimary() (node, error) {
+	current := p.next()
+	switch current.kind {
+	case tokenNumber:
+		return literalNode{value: current.num}, nil
+	case tokenString:
+		return literalNode{value: current.text}, nil
+	case tokenIdent:
+		switch current.text {
+		case "true":
+			return literalNode{value: true}, nil
+		case "false":
+			return literalNode{value: false}, nil
+		}
+		if !p.peekOperator("(") {
+			return identNode{name: current.text}, nil
+		}
+		return p.parseCall(current)
+	case tokenOperator:
+		if current.text == "(" {
+			inner, err := p.parseExpression()
+			if err != nil {
+				return nil, err
+			}
+			if err := p.expect(")"); err != nil {
+				return nil, err
+			}
+			return inner, nil
+		}
+	case tokenEOF:
+		return nil, fmt.Errorf("unexpected end of expression at %d", current.pos)
+	}
+	return nil, fmt.Errorf("unexpected %q at %d", current.text, current.pos)
+}
+
+// parseCall parses the argument list of a function call, the name must be a known function
+func (p *parser) parseCall(name token) (node, error) {
+	if _, known := functions[name.text]; !known && !isListFunction(name.text) {
+		return nil, fmt.Errorf("unknown function %q at %d", name.text, name.pos)
+	}
+	p.next() // opening parenthesis
+
+	call := callNode{function: name.text}
+	for !p.peekOperator(")") {
+		if len(call.args) > 0 {
+			if err := p.expect(","); err != nil {
+				return nil, err
+			}
+		}
+		arg, err := p.parseExpression()
+		if err != nil {
+			return nil, err
+		}
+		call.args = append(call.args, arg)
+	}
+	p.next() // closing parenthesis
+
+	if isListFunction(name.text) && len(call.args) != 2 {
+		return nil, fmt.Errorf("%s takes a list and an expression at %d", name.text, name.pos)
+	}
+	return call, nil
+}
@@ -0,0 +1,180 @@
+package rules
+
+import (
+	"errors"
+	"fmt"
+	"math"
+	"strconv"
+	"strings"
+	"time"
+
+	"receipt-processor-challenge-jase180/internal/cache"
+	"receipt-processor-challenge-jase180/internal/models"
+	"receipt-processor-challenge-jase180/internal/services/expr"
+	"receipt-processor-challenge-jase180/internal/services/text"
+)
+
+// ExpressionRule is a custom scoring rule written in the expr language, its result is the points earned
+// e.g. "3 points per item over $5 on weekends" is `weekend ? 3 * count(items, it.price > 5) : 0`
+type ExpressionRule struct {
+	Name       string `json:"name"`       // Name shown when reporting rule errors
+	Expression string `json:"expression"` // Must evaluate to a number, rounded to the nearest point
+}
+
+// ExpressionConfig is the custom rules and the limits each evaluation runs under, 0 limits take expr defaults
+type ExpressionConfig struct {
+	Rules         []ExpressionRule `json:"rules"`         // Evaluated in order after the built-in rules
+	MaxSteps      int              `json:"maxSteps"`      // Nodes evaluated per rule
+	MaxDepth      int              `json:"maxDepth"`      // Nesting allowed per rule
+	TimeoutMillis int              `json:"timeoutMillis"` // Wall clock time per rule
+}
+
+// compiledExpressions holds parsed programs by limits and source, see cache.Compiled
+var compiledExpressions = cache.NewCompiled[*expr.Program](1024)
+
+// limits converts the configured limits for the expr package
+func (c ExpressionConfig) limits() expr.Limits {
+	return expr.Limits{
+		MaxSteps: c.MaxSteps,
+		MaxDepth: c.MaxDepth,
+		Timeout:  time.Duration(c.TimeoutMillis) * time.Millisecond,
+	}
+}
+
+// compile returns the cached program for a rule, parsing it on first use
+func (c ExpressionConfig) compile(rule ExpressionRule) (*expr.Program, error) {
+	key := fmt.Sprintf("%d/%d/%d/%s", c.MaxSteps, c.MaxDepth, c.TimeoutMillis, rule.Expression)
+	return compiledExpressions.Get(key, func() (*expr.Program, error) {
+		return expr.Compile(rule.Expression, c.limits())
+	})
+}
+
+// WithinLimits returns c with each limit lowered to at most the same limit of bound, 0 limits count as the expr defaults
+// Negative limits are kept so Validate still rejects them
+func (c ExpressionConfig) WithinLimits(bound ExpressionConfig) ExpressionConfig {
+	c.MaxSteps = atMost(c.MaxSteps, bound.MaxSteps, expr.DefaultLimits.MaxSteps)
+	c.MaxDepth = atMost(c.MaxDepth, bound.MaxDepth, expr.DefaultLimits.MaxDepth)
+	c.TimeoutMillis = atMost(c.TimeoutMillis, bound.TimeoutMillis, int(expr.DefaultLimits.Timeout/time.Millisecond))
+	return c
+}
+
+// atMost returns limit capped at bound, either taking fallback when 0
+func atMost(limit, bound, fallback int) int {
+	if limit < 0 {
+		return limit
+	}
+	if limit == 0 {
+		limit = fallback
+	}
+	if bound <= 0 {
+		bound = fallback
+	}
+	return min(limit, bound)
+}
+
+// Validate checks limits are not negative and every rule has a name and parses
+func (c ExpressionConfig) Validate() error {
+	if c.MaxSteps < 0 || c.MaxDepth < 0 || c.TimeoutMillis < 0 {
+		return errors.New("expressions: limits cannot be negative")
+	}
+	for i, rule := range c.Rules {
+		if strings.TrimSpace(rule.Name) == "" {
+			return fmt.Errorf("expressions: rule %d has no name", i)
+		}
+		if _, err := c.compile(rule); err != nil {
+			return fmt.Errorf("expressions: rule %q: %w", rule.Name, err)
+		}
+	}
+	return nil
+}
+
+// PointsForExpressionRule evaluates one custom rule against a receipt
+// Errors (bad config, limits exceeded, wrong result type) give 0 points like the built-in rules
+// Negative results also give 0 so a custom rule can never take points away
+// mode is how len counts characters, the same as the built-in length rules
+func (c ExpressionConfig) PointsForExpressionRule(rule ExpressionRule, receipt models.Receipt, mode text.Mode) (int, error) {
+	program, err := c.compile(rule)
+	if err != nil {
+		return 0, err
+	}
+
+	result, err := program.EvalNumber(ReceiptVariables(receipt), mode)
+	if err != nil {
+		return 0, fmt.Errorf("rule %q: %w", rule.Name, err)
+	}
+	if result <= 0 || math.IsNaN(result) || math.IsInf(result, 0) {
+		return 0, nil
+	}
+	return int(math.Round(result)), nil
+}
+
+// ReceiptVariables exposes a receipt to expressions
+// Amounts are numbers, dates and times are split into parts, descriptions are trimmed
+// Coordinates are left out without a location so rules using them fail gracefully
+func ReceiptVariables(receipt models.Receipt) map[string]expr.Value {
+	total, _ := strconv.ParseFloat(receipt.Total, 64)
+
+	items := make([]expr.Value, 0, len(receipt.Items))
+	for _, item := range receipt.Items {
+		price, _ := strconv.ParseFloat(item.Price, 64)
+		quantity := ItemQuantity(item)
+		items = append(items, map[string]expr.Value{
+			"description": strings.TrimSpace(item.ShortDescription),
+			"price":       price,
+			"category":    item.Category,
+			"quantity":    quantity,
+			"unitPrice":   price / quantity,
+		})
+	}
+
+	variables := map[string]expr.Value{
+		"retailer":     receipt.Retailer,
+		"total":        total,
+		"items":        items,
+		"itemCount":    float64(len(receipt.Items)),
+		"unitCount":    float64(CountItems(receipt.Items, CountUnits)),
+		"purchaseDate": receipt.PurchaseDate,
+		"purchaseTime": receipt.PurchaseTime,
+	}
+
+	// Optional finance fields, amounts are 0 and text is "" when the receipt does not have them
+	subtotal, _ := strconv.ParseFloat(receipt.Subtotal, 64)
+	tip, _ := strconv.ParseFloat(receipt.Tip, 64)
+	tax, discount := 0.0, 0.0
+	for _, line := range receipt.Taxes {
+		amount, _ := strconv.ParseFloat(line.Amount, 64)
+		tax += amount
+	}
+	for _, line := range receipt.Discounts {
+		amount, _ := strconv.ParseFloat(line.Amount, 64)
+		discount += amount
+	}
+	variables["subtotal"] = subtotal
+	variables["tax"] = tax
+	variables["discount"] = discount
+	variables["tip"] = tip
+	variables["paymentMethod"] = receipt.PaymentMethod
+	variables["currency"] = receipt.Currency
+	variables["storeNumber"] = ""
+	if location := receipt.Location; location != nil {
+		variables["storeNumber"] = location.StoreNumber
+		if location.Latitude != nil && location.Longitude != nil {
+			variables["latitude"] = *location.Latitude
+			variables["longitude"] = *location.Longitude
+		}
+	}
+
+	// Date and time parts, left out if unparsable so rules using them fail gracefully
+	if date, err := time.Parse("2006-01-02", receipt.PurchaseDate); err == nil {
+		variables["year"] = float64(date.Year())
+		variables["month"] = float64(date.Month())
+		variables["day"] = float64(date.Day())
+		variables["weekday"] = float64(date.Weekday()) // 0 is Sunday
+		variables["weekend"] = date.Weekday() == time.Saturday || date.Weekday() == time.Sunday
+	}
+	if purchaseTime, err := time.Parse("15:04", receipt.PurchaseTime); err == nil {
+		variables["hour"] = float64(purchaseTime.Hour())
+		variables["minute"] = float64(purchaseTime.Minute())
+	}
+	return variables
+}
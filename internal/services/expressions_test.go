@@ -0,0 +1,175 @@
+package rules
+
+import (
+	"math"
+	"testing"
+
+	"receipt-processor-challenge-jase180/internal/models"
+	"receipt-processor-challenge-jase180/internal/services/text"
+)
+
+func TestPointsForExpressionRule(t *testing.T) {
+	// Target README receipt, purchased on a Saturday with 3 items over $5
+	receipt := models.Receipt{
+		Retailer:     "Target",
+		PurchaseDate: "2022-01-01",
+		PurchaseTime: "13:01",
+		Items: []models.Item{
+			{ShortDescription: "Mountain Dew 12PK", Price: "6.49"},
+			{ShortDescription: "Emils Cheese Pizza", Price: "12.25"},
+			{ShortDescription: "Knorr Creamy Chicken", Price: "1.26"},
+			{ShortDescription: "Doritos Nacho Cheese", Price: "3.35"},
+			{ShortDescription: "   Klarbrunn 12-PK 12 FL OZ  ", Price: "12.00"},
+		},
+		Total: "35.35",
+	}
+
+	tests := []struct {
+		name       string
+		expression string
+		expected   int
+		evalErr    bool // true if we want an error to show up
+	}{
+		{"3 points per item over $5 on weekends", "weekend ? 3 * count(items, it.price > 5) : 0", 9, false},
+		{"Date and time parts", "year == 2022 && month == 1 && day == 1 && weekday == 6 && hour == 13 && minute == 1 ? 1 : 0", 1, false},
+		{"Rounded to nearest point", "total / 10", 4, false},
+		{"Trimmed descriptions", "count(items, startsWith(it.description, 'Klarbrunn'))", 1, false},
+		{"Negative result gives nothing", "-10", 0, false},
+		{"Bool result is an error", "weekend", 0, true},
+		{"Unknown variable is an error", "tier * 2", 0, true},
+		{"Missing finance fields are empty", "subtotal + tax + discount + tip == 0 && paymentMethod == '' && currency == '' ? 1 : 0", 1, false},
+		{"Missing coordinates are an error", "latitude > 0 ? 1 : 0", 0, true},
+	}
+	for _, testCase := range tests {
+		t.Run(testCase.name, func(t *testing.T) {
+			result, err := ExpressionConfig{}.PointsForExpressionRule(ExpressionRule{Name: testCase.name, Expression: testCase.expression}, receipt, text.Graphemes)
+			if (err != nil) != testCase.evalErr {
+				t.Errorf("unexpected error, error was %v, want %v", err, testCase.evalErr)
+			}
+			if result != testCase.expected {
+				t.Errorf("Result was %v; want %v", result, testCase.expected)
+			}
+		})
+	}
+}
+
+func TestConfigCalculatePoints(t *testing.T) {
+	// M&M README receipt is 109 points from built-in rules
+	receipt := models.Receipt{
+		Retailer:     "M&M Corner Market",
+		PurchaseDate: "2022-03-20",
+		PurchaseTime: "14:33",
+		Items: []models.Item{
+			{ShortDescription: "Gatorade", Price: "2.25"},
+			{ShortDescription: "Gatorade", Price: "2.25"},
+			{ShortDescription: "Gatorade", Price: "2.25"},
+			{ShortDescription: "Gatorade", Price: "2.25"},
+		},
+		Total: "9.00",
+	}
+
+	config := DefaultConfig()
+	if result := config.CalculatePoints(receipt); result != 109 {
+		t.Fatalf("Result was %v; want 109 with no custom rules", result)
+	}
+
+	// One working rule and one failing rule that is skipped
+	config.Expressions.Rules = []ExpressionRule{
+		{Name: "Gatorade lovers", Expression: "count(items, contains(lower(it.description), 'gatorade')) * 5"},
+		{Name: "Broken", Expression: "unknown + 1"},
+	}
+	if result := config.CalculatePoints(receipt); result != 129 {
+		t.Errorf("Result was %v; want 129", result)
+	}
+
+	// len counts with the configured mode, e plus a combining accent is 6 bytes
+	receipt.Retailer = "Cafe\u0301"
+	config.Expressions.Rules = []ExpressionRule{{Name: "Length", Expression: "len(retailer)"}}
+	for mode, expected := range map[text.Mode]int{text.Graphemes: 4, text.Legacy: 6} {
+		config.CountMode = mode
+		if result := config.CalculateBreakdown(receipt); result[len(result)-1].Points != expected {
+			t.Errorf("Result was %v in %s mode; want %v", result[len(result)-1].Points, mode, expected)
+		}
+	}
+}
+
+// TestExpressionConfigWithinLimits tests candidate limits are capped at the bound, 0 meaning the expr defaults
+func TestExpressionConfigWithinLimits(t *testing.T) {
+	tests := []struct {
+		name     string
+		config   ExpressionConfig
+		bound    ExpressionConfig
+		expected ExpressionConfig
+	}{
+		{"Defaults stay defaults", ExpressionConfig{}, ExpressionConfig{}, ExpressionConfig{MaxSteps: 10000, MaxDepth: 64, TimeoutMillis: 10}},
+		{"Huge limits capped at defaults", ExpressionConfig{MaxSteps: 1e9, MaxDepth: 1e6, TimeoutMillis: 1e9}, ExpressionConfig{},
+			ExpressionConfig{MaxSteps: 10000, MaxDepth: 64, TimeoutMillis: 10}},
+		{"Capped at running limits", ExpressionConfig{MaxSteps: 1e9}, ExpressionConfig{MaxSteps: 500, MaxDepth: 8, TimeoutMillis: 5},
+			ExpressionConfig{MaxSteps: 500, MaxDepth: 8, TimeoutMillis: 5}},
+		{"Lower limits kept", ExpressionConfig{MaxSteps: 100, MaxDepth: 4, TimeoutMillis: 1}, ExpressionConfig{MaxSteps: 500},
+			ExpressionConfig{MaxSteps: 100, MaxDepth: 4, TimeoutMillis: 1}},
+		{"Negative kept for Validate", ExpressionConfig{MaxSteps: -1}, ExpressionConfig{}, ExpressionConfig{MaxSteps: -1, MaxDepth: 64, TimeoutMillis: 10}},
+	}
+	for _, testCase := range tests {
+		t.Run(testCase.name, func(t *testing.T) {
+			result := testCase.config.WithinLimits(testCase.bound)
+			if result.MaxSteps != testCase.expected.MaxSteps || result.MaxDepth != testCase.expected.MaxDepth || result.TimeoutMillis != testCase.expected.TimeoutMillis {
+				t.Errorf("Result was %+v; want %+v", result, testCase.expected)
+			}
+		})
+	}
+}
+
+func TestExpressionConfigValidate(t *testing.T) {
+	tests := []struct {
+		name    string
+		config  ExpressionConfig
+		wantErr bool
+	}{
+		{"No rules", ExpressionConfig{}, false},
+		{"Valid rule", ExpressionConfig{Rules: []ExpressionRule{{Name: "Weekend", Expression: "weekend ? 5 : 0"}}}, false},
+		{"Rule does not parse", ExpressionConfig{Rules: []ExpressionRule{{Name: "Broken", Expression: "weekend ? 5"}}}, true},
+		{"Rule without name", ExpressionConfig{Rules: []ExpressionRule{{Expression: "1"}}}, true},
+		{"Negative limit", ExpressionConfig{MaxSteps: -1}, true},
+	}
+	for _, testCase := range tests {
+		t.Run(testCase.name, func(t *testing.T) {
+			if err := testCase.config.Validate(); (err != nil) != testCase.wantErr {
+				t.Errorf("unexpected error, error was %v, want %v", err, testCase.wantErr)
+			}
+		})
+	}
+}
+
+func TestReceiptVariablesFinanceFields(t *testing.T) {
+	latitude, longitude := 41.88, -87.63
+	receipt := models.Receipt{
+		Retailer:      "Target",
+		PurchaseDate:  "2022-01-01",
+		PurchaseTime:  "13:01",
+		Items:         []models.Item{{ShortDescription: "Mountain Dew 12PK", Price: "6.49"}},
+		Total:         "7.50",
+		Subtotal:      "6.49",
+		Taxes:         []models.TaxLine{{Name: "State", Amount: "0.40"}, {Name: "City", Amount: "0.11"}},
+		Discounts:     []models.Discount{{Description: "Coupon", Code: "DEW", Amount: "0.50"}},
+		Tip:           "1.00",
+		PaymentMethod: models.PaymentCredit,
+		Currency:      "USD",
+		Location:      &models.Location{StoreNumber: "T-1234", Latitude: &latitude, Longitude: &longitude},
+	}
+
+	variables := ReceiptVariables(receipt)
+	expected := map[string]interface{}{
+		"subtotal": 6.49, "tax": 0.51, "discount": 0.5, "tip": 1.0, "paymentMethod": "credit",
+		"currency": "USD", "storeNumber": "T-1234", "latitude": 41.88, "longitude": -87.63,
+	}
+	for name, want := range expected {
+		got := variables[name]
+		if number, ok := got.(float64); ok {
+			got = math.Round(number*100) / 100 // tax lines add up in floating point
+		}
+		if got != want {
+			t.Errorf("Variable %s was %v; want %v", name, got, want)
+		}
+	}
+}
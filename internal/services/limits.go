@@ -0,0 +1,72 @@
+package rules
+
+import (
+	"errors"
+	"fmt"
+)
+
+// LimitConfig caps points to stop users farming points by splitting purchases, 0 means no limit
+// Days and weeks are the purchase date and ISO week printed on the receipt
+type LimitConfig struct {
+	MaxPointsPerReceipt        int `json:"maxPointsPerReceipt"`        // Cap on any single receipt
+	MaxPointsPerUserPerDay     int `json:"maxPointsPerUserPerDay"`     // Cap on a user's receipts purchased the same day
+	MaxPointsPerUserPerWeek    int `json:"maxPointsPerUserPerWeek"`    // Cap on a user's receipts purchased the same ISO week
+	MaxPointsPerRetailerPerDay int `json:"maxPointsPerRetailerPerDay"` // Cap on every receipt from one retailer the same day, any user
+}
+
+// LimitUsage is the points already earned that count towards each limit for a new receipt
+type LimitUsage struct {
+	Anonymous   bool // Receipt has no user, user limits do not apply
+	UserDay     int  // Points on the user's receipts purchased the same day
+	UserWeek    int  // Points on the user's receipts purchased the same ISO week
+	RetailerDay int  // Points on every receipt from the same retailer the same day
+}
+
+// DefaultLimitConfig returns no limits so behavior is unchanged unless configured
+func DefaultLimitConfig() LimitConfig {
+	return LimitConfig{}
+}
+
+// Validate checks no limit is negative
+func (c LimitConfig) Validate() error {
+	if c.MaxPointsPerReceipt < 0 || c.MaxPointsPerUserPerDay < 0 || c.MaxPointsPerUserPerWeek < 0 || c.MaxPointsPerRetailerPerDay < 0 {
+		return errors.New("limits: limits cannot be negative")
+	}
+	return nil
+}
+
+// Apply caps points for a new receipt and returns the points awarded, the points removed and why
+// When several limits apply the most restrictive one is reported
+// Pass nil usage to apply only the per receipt limit
+func (c LimitConfig) Apply(points int, usage *LimitUsage) (awarded int, capped int, reason string) {
+	awarded = points
+
+	// capTo lowers awarded to the room left under a limit and records why
+	capTo := func(limit int, used int, description string) {
+		if limit <= 0 {
+			return
+		}
+		room := limit - used
+		if room < 0 {
+			room = 0
+		}
+		if awarded > room {
+			awarded = room
+			reason = fmt.Sprintf("%s of %d points reached", description, limit)
+		}
+	}
+
+	capTo(c.MaxPointsPerReceipt, 0, "per receipt limit")
+	if usage != nil && !usage.Anonymous {
+		capTo(c.MaxPointsPerUserPerWeek, usage.UserWeek, "weekly user limit")
+		capTo(c.MaxPointsPerUserPerDay, usage.UserDay, "daily user limit")
+	}
+	if usage != nil {
+		capTo(c.MaxPointsPerRetailerPerDay, usage.RetailerDay, "daily retailer limit")
+	}
+
+	if awarded < 0 {
+		awarded = 0 // negative points are never awarded, e.g. nothing to cap
+	}
+	return awarded, points - awarded, reason
+}
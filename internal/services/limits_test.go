@@ -0,0 +1,50 @@
+package rules
+
+import (
+	"testing"
+)
+
+func TestLimitConfigApply(t *testing.T) {
+	config := LimitConfig{
+		MaxPointsPerReceipt:        200,
+		MaxPointsPerUserPerDay:     300,
+		MaxPointsPerUserPerWeek:    1000,
+		MaxPointsPerRetailerPerDay: 150,
+	}
+
+	tests := []struct {
+		name        string
+		config      LimitConfig
+		points      int
+		usage       *LimitUsage
+		wantAwarded int
+		wantCapped  int
+		wantReason  string
+	}{
+		{"No limits", LimitConfig{}, 5000, &LimitUsage{UserDay: 5000}, 5000, 0, ""},
+		{"Under every limit", config, 100, &LimitUsage{}, 100, 0, ""},
+		{"Per receipt limit", config, 250, nil, 200, 50, "per receipt limit of 200 points reached"},
+		{"Retailer limit is most restrictive", config, 100, &LimitUsage{UserDay: 100, RetailerDay: 100}, 50, 50, "daily retailer limit of 150 points reached"},
+		{"Daily limit", config, 100, &LimitUsage{UserDay: 250}, 50, 50, "daily user limit of 300 points reached"},
+		{"Weekly limit already used up", config, 100, &LimitUsage{UserWeek: 1200}, 0, 100, "weekly user limit of 1000 points reached"},
+		{"Anonymous receipt ignores user limits", config, 100, &LimitUsage{Anonymous: true, UserDay: 300}, 100, 0, ""},
+		{"Anonymous receipt keeps retailer limit", config, 100, &LimitUsage{Anonymous: true, RetailerDay: 120}, 30, 70, "daily retailer limit of 150 points reached"},
+	}
+	for _, testCase := range tests {
+		t.Run(testCase.name, func(t *testing.T) {
+			awarded, capped, reason := testCase.config.Apply(testCase.points, testCase.usage)
+			if awarded != testCase.wantAwarded || capped != testCase.wantCapped || reason != testCase.wantReason {
+				t.Errorf("Result was %d, %d, %q; want %d, %d, %q", awarded, capped, reason, testCase.wantAwarded, testCase.wantCapped, testCase.wantReason)
+			}
+		})
+	}
+}
+
+func TestLimitConfigValidate(t *testing.T) {
+	if err := DefaultLimitConfig().Validate(); err != nil {
+		t.Errorf("unexpected error for default limits: %v", err)
+	}
+	if err := (LimitConfig{MaxPointsPerUserPerDay: -1}).Validate(); err == nil {
+		t.Errorf("No error for negative limit")
+	}
+}
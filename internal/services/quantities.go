@@ -0,0 +1,61 @@
+package rules
+
+import (
+	"fmt"
+	"math"
+	"strconv"
+
+	"receipt-processor-challenge-jase180/internal/models"
+)
+
+// ItemCounting is whether item-count and per-item rules count line items or units
+type ItemCounting string
+
+// Item counting modes, the zero value counts line items like the original rules
+const (
+	CountLineItems ItemCounting = "lineItems" // "3 x Gatorade" is 1 item
+	CountUnits     ItemCounting = "units"     // "3 x Gatorade" is 3 items
+)
+
+// Validate checks the counting is one of the item counting modes or empty for the default
+func (c ItemCounting) Validate() error {
+	switch c {
+	case "", CountLineItems, CountUnits:
+		return nil
+	}
+	return fmt.Errorf("unknown item counting %q, use %q or %q", c, CountLineItems, CountUnits)
+}
+
+// ItemQuantity returns the quantity of a line item, 1 without a quantity or if unparsable
+func ItemQuantity(item models.Item) float64 {
+	if item.Quantity == "" {
+		return 1
+	}
+	quantity, err := strconv.ParseFloat(item.Quantity, 64)
+	if err != nil || quantity <= 0 {
+		return 1
+	}
+	return quantity
+}
+
+// ItemUnits returns how many items a line item counts as
+// Whole quantities count as that many units, weighed items like 1.25 lb count as 1
+func ItemUnits(item models.Item, counting ItemCounting) int {
+	if counting != CountUnits {
+		return 1
+	}
+	quantity := ItemQuantity(item)
+	if quantity != math.Trunc(quantity) {
+		return 1
+	}
+	return int(quantity)
+}
+
+// CountItems returns the number of items on a receipt
+func CountItems(items []models.Item, counting ItemCounting) int {
+	count := 0
+	for _, item := range items {
+		count += ItemUnits(item, counting)
+	}
+	return count
+}
@@ -0,0 +1,71 @@
+package rules
+
+import (
+	"testing"
+	"time"
+
+	"receipt-processor-challenge-jase180/internal/models"
+)
+
+func TestCountItems(t *testing.T) {
+	items := []models.Item{
+		{ShortDescription: "Gatorade", Price: "6.75", Quantity: "3", UnitPrice: "2.25"},
+		{ShortDescription: "Bananas", Price: "0.94", Quantity: "1.25", UnitPrice: "0.75"}, // weighed
+		{ShortDescription: "Doritos", Price: "3.35"},
+	}
+
+	tests := []struct {
+		name     string
+		counting ItemCounting
+		expected int
+	}{
+		{"Default counts line items", "", 3},
+		{"Line items", CountLineItems, 3},
+		{"Units, weighed items count once", CountUnits, 5},
+	}
+	for _, testCase := range tests {
+		t.Run(testCase.name, func(t *testing.T) {
+			if result := CountItems(items, testCase.counting); result != testCase.expected {
+				t.Errorf("Result was %d; want %d", result, testCase.expected)
+			}
+		})
+	}
+}
+
+func TestConfigCalculatePointsWithUnits(t *testing.T) {
+	// One line of 4 Gatorade, 0 points for every two items as line items and 10 as units
+	receipt := models.Receipt{
+		Retailer:     "Target",
+		PurchaseDate: "2022-01-02",
+		PurchaseTime: "13:00",
+		Items:        []models.Item{{ShortDescription: "Gatorade", Price: "9.00", Quantity: "4", UnitPrice: "2.25"}},
+		Total:        "9.00",
+	}
+
+	config := DefaultConfig()
+	config.Categories.Rules = []CategoryRule{{Category: "beverages", PointsPerItem: 1}}
+	lineItems := config.CalculatePoints(receipt)
+
+	config.ItemCounting = CountUnits
+	if result := config.CalculatePoints(receipt); result != lineItems+10+3 {
+		t.Errorf("Result was %d; want %d counting units", result, lineItems+10+3)
+	}
+
+	// Campaign per item bonuses count units too
+	campaign := models.Campaign{ID: "c1", Name: "Gatorade", Items: []string{"Gatorade"}, Bonus: models.Bonus{Type: models.BonusPerItem, Value: 5},
+		Start: time.Date(2022, 1, 1, 0, 0, 0, 0, time.UTC), End: time.Date(2022, 1, 3, 0, 0, 0, 0, time.UTC)}
+	if bonuses := CampaignBonuses(receipt, 0, []models.Campaign{campaign}, CountUnits); len(bonuses) != 1 || bonuses[0].Points != 20 {
+		t.Errorf("Result was %v; want 20 bonus points for 4 units", bonuses)
+	}
+}
+
+func TestItemCountingValidate(t *testing.T) {
+	for _, counting := range []ItemCounting{"", CountLineItems, CountUnits} {
+		if err := counting.Validate(); err != nil {
+			t.Errorf("Counting %q was invalid: %v", counting, err)
+		}
+	}
+	if err := ItemCounting("lines").Validate(); err == nil {
+		t.Errorf("Counting %q was valid; want error", "lines")
+	}
+}
@@ -0,0 +1,162 @@
+package rules
+
+import (
+	"errors"
+	"fmt"
+	"sort"
+	"strings"
+	"unicode"
+
+	"receipt-processor-challenge-jase180/internal/models"
+)
+
+// Retailer is a canonical retailer name and the other names it appears as on receipts
+type Retailer struct {
+	Name    string   `json:"name"`              // Canonical name used in reports and, if opted in, rules
+	Aliases []string `json:"aliases,omitempty"` // Other spellings e.g. "M & M CORNER MKT"
+}
+
+// RetailerConfig is the retailer registry receipts are matched against at submission
+// Names match exactly after normalization, otherwise the closest name scoring at least MatchThreshold
+type RetailerConfig struct {
+	Registry         []Retailer `json:"registry"`
+	MatchThreshold   float64    `json:"matchThreshold"`   // Similarity from 0 to 1 a fuzzy match needs, 0 means the default
+	UseCanonicalName bool       `json:"useCanonicalName"` // Score rules with the canonical name instead of the raw name
+}
+
+// defaultMatchThreshold is close enough for a typo or two in a store name but not a different store
+const defaultMatchThreshold = 0.85
+
+// Common receipt abbreviations expanded before matching, and words that do not tell stores apart
+var (
+	retailerAbbreviations = map[string]string{"mkt": "market", "mrkt": "market", "mkts": "markets", "ctr": "center", "sq": "square"}
+	retailerStopWords     = map[string]bool{"the": true, "and": true, "inc": true, "llc": true}
+)
+
+// NormalizeRetailer lowercases a retailer name, splits it into words on anything not a letter or digit,
+// expands abbreviations and drops stop words, e.g. "M & M CORNER MKT" becomes "m m corner market"
+func NormalizeRetailer(name string) string {
+	words := strings.FieldsFunc(strings.ToLower(name), func(r rune) bool {
+		return !unicode.IsLetter(r) && !unicode.IsDigit(r)
+	})
+
+	normalized := make([]string, 0, len(words))
+	for _, word := range words {
+		if expanded, ok := retailerAbbreviations[word]; ok {
+			word = expanded
+		}
+		if !retailerStopWords[word] {
+			normalized = append(normalized, word)
+		}
+	}
+	return strings.Join(normalized, " ")
+}
+
+// RetailerSimilarity scores two normalized names from 0 to 1 by edit distance, ignoring spaces so
+// "trader joe s" matches "traderjoes", and ignoring word order so "corner market" matches "market corner"
+func RetailerSimilarity(a string, b string) float64 {
+	compact := func(s string) string { return strings.ReplaceAll(s, " ", "") }
+	sorted := func(s string) string {
+		words := strings.Fields(s)
+		sort.Strings(words)
+		return strings.Join(words, "")
+	}
+	return max(editSimilarity(compact(a), compact(b)), editSimilarity(sorted(a), sorted(b)))
+}
+
+// editSimilarity is 1 minus the Levenshtein distance over the longer length, in runes
+func editSimilarity(a string, b string) float64 {
+	first, second := []rune(a), []rune(b)
+	longest := max(len(first), len(second))
+	if longest == 0 {
+		return 1
+	}
+
+	// Two row dynamic programming Levenshtein distance
+	previous := make([]int, len(second)+1)
+	current := make([]int, len(second)+1)
+	for j := range previous {
+		previous[j] = j
+	}
+	for i := 1; i <= len(first); i++ {
+		current[0] = i
+		for j := 1; j <= len(second); j++ {
+			substitution := previous[j-1]
+			if first[i-1] != second[j-1] {
+				substitution++
+			}
+			current[j] = min(previous[j]+1, current[j-1]+1, substitution)
+		}
+		previous, current = current, previous
+	}
+	return 1 - float64(previous[len(second)])/float64(longest)
+}
+
+// threshold returns the configured threshold or the default
+func (c RetailerConfig) threshold() float64 {
+	if c.MatchThreshold == 0 {
+		return defaultMatchThreshold
+	}
+	return c.MatchThreshold
+}
+
+// Validate checks the threshold is a similarity and every retailer has a name that normalizes to something
+func (c RetailerConfig) Validate() error {
+	if c.MatchThreshold < 0 || c.MatchThreshold > 1 {
+		return errors.New("retailers: matchThreshold must be between 0 and 1")
+	}
+	for i, retailer := range c.Registry {
+		if NormalizeRetailer(retailer.Name) == "" {
+			return fmt.Errorf("retailers: retailer %d has no name", i)
+		}
+		for _, alias := range retailer.Aliases {
+			if NormalizeRetailer(alias) == "" {
+				return fmt.Errorf("retailers: retailer %q has an empty alias", retailer.Name)
+			}
+		}
+	}
+	return nil
+}
+
+// Resolve returns the canonical name for a raw retailer name, "" if nothing in the registry is close enough
+// Exact matches on the normalized name or an alias win, then the highest similarity, first in the registry on ties
+func (c RetailerConfig) Resolve(raw string) string {
+	normalized := NormalizeRetailer(raw)
+	if normalized == "" {
+		return ""
+	}
+
+	best, bestScore := "", 0.0
+	for _, retailer := range c.Registry {
+		for _, name := range append([]string{retailer.Name}, retailer.Aliases...) {
+			candidate := NormalizeRetailer(name)
+			if candidate == normalized {
+				return retailer.Name
+			}
+			if score := RetailerSimilarity(normalized, candidate); score > bestScore {
+				best, bestScore = retailer.Name, score
+			}
+		}
+	}
+	if bestScore >= c.threshold() {
+		return best
+	}
+	return ""
+}
+
+// Canonicalize returns a copy of the receipt with the canonical retailer set, the raw retailer is kept
+func (c RetailerConfig) Canonicalize(receipt models.Receipt) models.Receipt {
+	receipt.RetailerCanonical = c.Resolve(receipt.Retailer)
+	return receipt
+}
+
+// ForRules returns the receipt the rules should score, with the canonical retailer name if opted in and known
+func (c RetailerConfig) ForRules(receipt models.Receipt) models.Receipt {
+	if !c.UseCanonicalName {
+		return receipt
+	}
+	if canonical := c.Resolve(receipt.Retailer); canonical != "" {
+		receipt.Retailer = canonical
+	}
+	return receipt
+}
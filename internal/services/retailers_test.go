@@ -0,0 +1,106 @@
+package rules
+
+import (
+	"testing"
+
+	"receipt-processor-challenge-jase180/internal/models"
+)
+
+func TestNormalizeRetailer(t *testing.T) {
+	tests := []struct {
+		name     string
+		retailer string
+		expected string
+	}{
+		{"Ampersand", "M&M Corner Market", "m m corner market"},
+		{"Spaced ampersand and abbreviation", "M & M CORNER MKT", "m m corner market"},
+		{"Stop words", "The Corner Store, Inc.", "corner store"},
+		{"Unicode letters", "Café Müller", "café müller"},
+		{"Only punctuation", " - & - ", ""},
+	}
+	for _, testCase := range tests {
+		t.Run(testCase.name, func(t *testing.T) {
+			if result := NormalizeRetailer(testCase.retailer); result != testCase.expected {
+				t.Errorf("Result was %q; want %q", result, testCase.expected)
+			}
+		})
+	}
+}
+
+func TestRetailerConfigResolve(t *testing.T) {
+	config := RetailerConfig{Registry: []Retailer{
+		{Name: "M&M Corner Market"},
+		{Name: "Target", Aliases: []string{"Target Store #1234"}},
+		{Name: "Trader Joe's"},
+		{Name: "Walmart", Aliases: []string{"Wal-Mart Supercenter"}},
+	}}
+
+	tests := []struct {
+		name     string
+		config   RetailerConfig
+		raw      string
+		expected string
+	}{
+		{"Exact after normalization", config, "M & M CORNER MKT", "M&M Corner Market"},
+		{"Alias", config, "TARGET STORE #1234", "Target"},
+		{"Typo", config, "Tarrget", "Target"},
+		{"Spacing", config, "TraderJoes", "Trader Joe's"},
+		{"Word order", config, "Corner Market M&M", "M&M Corner Market"},
+		{"Fuzzy alias", config, "Wal Mart Supercentre", "Walmart"},
+		{"Different store", config, "Costco", ""},
+		{"Stricter threshold", RetailerConfig{Registry: config.Registry, MatchThreshold: 0.99}, "Tarrget", ""},
+		{"Empty registry", RetailerConfig{}, "Target", ""},
+	}
+	for _, testCase := range tests {
+		t.Run(testCase.name, func(t *testing.T) {
+			if result := testCase.config.Resolve(testCase.raw); result != testCase.expected {
+				t.Errorf("Result was %q; want %q", result, testCase.expected)
+			}
+		})
+	}
+}
+
+func TestConfigCalculatePointsWithCanonicalRetailer(t *testing.T) {
+	receipt := models.Receipt{
+		Retailer:     "M & M CORNER MKT",
+		PurchaseDate: "2022-03-20",
+		PurchaseTime: "14:33",
+		Items:        []models.Item{{ShortDescription: "Gatorade", Price: "2.25"}},
+		Total:        "2.25",
+	}
+
+	config := DefaultConfig()
+	config.Retailers.Registry = []Retailer{{Name: "M&M Corner Market"}}
+	raw := config.CalculatePoints(receipt)
+
+	// "MMCORNERMKT" is 11 points, "MMCornerMarket" is 14
+	config.Retailers.UseCanonicalName = true
+	if result := config.CalculatePoints(receipt); result != raw+3 {
+		t.Errorf("Result was %d; want %d with the canonical name", result, raw+3)
+	}
+	if canonical := config.Retailers.Canonicalize(receipt); canonical.Retailer != receipt.Retailer || canonical.RetailerCanonical != "M&M Corner Market" {
+		t.Errorf("Result was %q and %q; want raw and canonical retailer", canonical.Retailer, canonical.RetailerCanonical)
+	}
+}
+
+func TestRetailerConfigValidate(t *testing.T) {
+	tests := []struct {
+		name    string
+		config  RetailerConfig
+		wantErr bool
+	}{
+		{"Default", RetailerConfig{}, false},
+		{"Registry", RetailerConfig{Registry: []Retailer{{Name: "Target", Aliases: []string{"Tgt"}}}, MatchThreshold: 0.9}, false},
+		{"Threshold too high", RetailerConfig{MatchThreshold: 1.5}, true},
+		{"Negative threshold", RetailerConfig{MatchThreshold: -0.1}, true},
+		{"No name", RetailerConfig{Registry: []Retailer{{Name: " & "}}}, true},
+		{"Empty alias", RetailerConfig{Registry: []Retailer{{Name: "Target", Aliases: []string{""}}}}, true},
+	}
+	for _, testCase := range tests {
+		t.Run(testCase.name, func(t *testing.T) {
+			if err := testCase.config.Validate(); (err != nil) != testCase.wantErr {
+				t.Errorf("unexpected error, error was %v, want %v", err, testCase.wantErr)
+			}
+		})
+	}
+}
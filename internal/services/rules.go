@@ -3,13 +3,29 @@ package rules
 import (
 	"fmt"
 	"math"
-	"regexp"
 	"strconv"
 	"strings"
 	"time"
-	"unicode"
 
 	"receipt-processor-challenge-jase180/internal/models"
+	"receipt-processor-challenge-jase180/internal/services/text"
+)
+
+// RulePoints is the points one rule contributed to a receipt
+type RulePoints struct {
+	Rule   string `json:"rule"`   // Rule name, custom rules are prefixed with "expression:"
+	Points int    `json:"points"` // Points from the rule, 0 if it did not apply
+}
+
+// Names of the built-in rules as reported in a breakdown
+const (
+	RuleRetailerName    = "retailerName"
+	RuleRoundTotal      = "roundTotal"
+	RuleQuarterMultiple = "quarterMultiple"
+	RuleEveryTwoItems   = "everyTwoItems"
+	RuleItemDescription = "itemDescription"
+	RuleOddDay          = "oddDay"
+	RuleTimeRange       = "timeRange"
 )
 
 // CalculatePoints computes the total points by calling all rules functions
@@ -17,109 +33,106 @@ import (
 // Functions handle the argument in its original JSON data type, conversion and error handling
 func CalculatePoints(receipt models.Receipt) int {
 	points := 0
-
-	points += PointsForRetailerName(receipt.Retailer)
-
-	if p, err := PointsForRoundTotal(receipt.Total); err == nil {
-		points += p
+	for _, rule := range CalculateBreakdown(receipt) {
+		points += rule.Points
 	}
+	return points
+}
 
-	if p, err := PointsForQuarterMultiple(receipt.Total); err == nil {
-		points += p
-	}
+// CalculateBreakdown computes the points from each rule, one entry per rule in a fixed order
+// Item description points are added up across items into one entry
+func CalculateBreakdown(receipt models.Receipt) []RulePoints {
+	return calculateBreakdown(receipt, Config{})
+}
 
-	points += PointsForEveryTwoItems(receipt.Items)
+// helper function for CalculateBreakdown with the configuration for the time range rule and character counting
+func calculateBreakdown(receipt models.Receipt, c Config) []RulePoints {
+	breakdown := []RulePoints{
+		{Rule: RuleRetailerName, Points: pointsForRetailerName(receipt.Retailer, c.CountMode)},
+	}
 
-	for _, item := range receipt.Items {
-		if p, err := PointsForItemDescription(item); err == nil {
-			points += p
+	// Rules that can fail give 0 points for that rule
+	withError := func(p int, err error) int {
+		if err != nil {
+			return 0
 		}
+		return p
 	}
 
-	if p, err := PointsForOddDay(receipt.PurchaseDate); err == nil {
-		points += p
+	// Total rules in the receipt's currency or the base currency, a total that cannot be parsed gives 0 for both
+	roundTotal, quarterMultiple := 0, 0
+	if total, err := c.Currency.TotalForRules(receipt); err == nil {
+		roundTotal, quarterMultiple = PointsForRoundAmount(total), PointsForQuarterAmount(total)
 	}
 
-	if p, err := PointsForTimeRange(receipt.PurchaseTime); err == nil {
-		points += p
+	breakdown = append(breakdown,
+		RulePoints{Rule: RuleRoundTotal, Points: roundTotal},
+		RulePoints{Rule: RuleQuarterMultiple, Points: quarterMultiple},
+		RulePoints{Rule: RuleEveryTwoItems, Points: CountItems(receipt.Items, c.ItemCounting) / 2 * 5},
+	)
+
+	itemPoints := 0
+	for _, item := range receipt.Items {
+		itemPoints += withError(pointsForItemDescription(item, c.CountMode))
 	}
 
-	return points
+	breakdown = append(breakdown,
+		RulePoints{Rule: RuleItemDescription, Points: itemPoints},
+		RulePoints{Rule: RuleOddDay, Points: withError(PointsForOddDay(receipt.PurchaseDate))},
+		RulePoints{Rule: RuleTimeRange, Points: withError(c.TimeWindows.Points(receipt.PurchaseDate, receipt.PurchaseTime))},
+	)
+	return breakdown
 }
 
 // Rule: One point for every alphanumeric character in the retailer name.
-// Utilizes "unicode" to check character for clarity, alternative is range based e.g. char >= 'a' && char <= 'z'
+// Counts user-perceived characters so "Café" scores the same however the é is encoded
 func PointsForRetailerName(retailer string) int {
-	points := 0
-	for _, char := range retailer {
-		if unicode.IsLetter(char) || unicode.IsDigit(char) {
-			points++
-		}
-	}
-	return points
+	return pointsForRetailerName(retailer, text.Graphemes)
+}
+
+// helper function for PointsForRetailerName with the configured counting mode
+// Utilizes "unicode" to check character for clarity, alternative is range based e.g. char >= 'a' && char <= 'z'
+func pointsForRetailerName(retailer string, mode text.Mode) int {
+	return text.CountAlphanumeric(retailer, mode)
 }
 
 // Rule: 50 points if the total is a round dollar amount with no cents.
-// Pattern provided is "^\\d+\\.\\d{2}$"
-// Since there can only be 2 decimals, check by multiply by 100 to avoid floating point errors, alternative is an epsilon
+// Totals are USD amounts, CalculateBreakdown scores every currency with PointsForRoundAmount
 func PointsForRoundTotal(total string) (int, error) {
-	// Extra defensive programming to make sure dollar is in pattern provided
-	if !regexp.MustCompile(`^\d+\.\d{2}$`).MatchString(total) {
-		return 0, nil // fail gracefully and just return 0
-	}
-
-	// Convert total from string to float, error handling
-	totalFloat, err := strconv.ParseFloat(total, 64)
+	money, err := ParseMoney(total, DefaultCurrency)
 	if err != nil {
-		return 0, fmt.Errorf("cannot convert total to float: %s", total)
-	}
-
-	// Multiply by 100 to get an integer, round to avoid floating point error
-	totalCents := int(math.Round(totalFloat * 100))
-
-	// Check if round number
-	if totalCents%100 == 0 {
-		return 50, nil
+		return 0, nil // fail gracefully and just return 0
 	}
-	return 0, nil
+	return PointsForRoundAmount(money), nil
 }
 
 // Rule: 25 points if the total is a multiple of 0.25.
-// simiar implementation to CalculateRoundTotalPoints
-// Pattern provided is "^\\d+\\.\\d{2}$"
-// Since there can only be 2 decimals, check by multiply by 100 to avoid floating point errors, alternative is an epsilon
+// Totals are USD amounts, CalculateBreakdown scores every currency with PointsForQuarterAmount
 func PointsForQuarterMultiple(total string) (int, error) {
-	// Extra defensive programming to make sure dollar is in pattern provided
-	if !regexp.MustCompile(`^\d+\.\d{2}$`).MatchString(total) {
-		return 0, nil // fail gracefully and just return 0
-	}
-	// Convert total from string to float, error handling
-	totalFloat, err := strconv.ParseFloat(total, 64)
+	money, err := ParseMoney(total, DefaultCurrency)
 	if err != nil {
-		return 0, fmt.Errorf("cannot convert total to float: %s", total)
-	}
-
-	// Multiply by 100 to get an integer, round to avoid floating point error
-	totalCents := int(math.Round(totalFloat * 100))
-
-	// Check if is multiple of 0.25
-	if totalCents%25 == 0 {
-		return 25, nil
+		return 0, nil // fail gracefully and just return 0
 	}
-	return 0, nil
+	return PointsForQuarterAmount(money), nil
 }
 
 // Rule: 5 points for every two items on the receipt.
-// Base division handles odd numbers
+// Base division handles odd numbers, counts line items, configured counting is applied in CalculateBreakdown
 func PointsForEveryTwoItems(items []models.Item) int {
 	return len(items) / 2 * 5
 }
 
 // Rule: If the trimmed length of the item description is a multiple of 3,
 // multiply the price by 0.2 and round up to the nearest integer. The result is the number of points earned.
+// Length is in user-perceived characters, "Café Latte" is 10 like "Cafe Latte"
 func PointsForItemDescription(item models.Item) (int, error) {
+	return pointsForItemDescription(item, text.Graphemes)
+}
+
+// helper function for PointsForItemDescription with the configured counting mode
+func pointsForItemDescription(item models.Item, mode text.Mode) (int, error) {
 	// Trim with "strings" function for simplicity and readability
-	trimmedLen := len(strings.TrimSpace(item.ShortDescription))
+	trimmedLen := text.Count(strings.TrimSpace(item.ShortDescription), mode)
 
 	// Check if trimmed length is multiple of 3
 	if trimmedLen%3 != 0 || trimmedLen == 0 {
@@ -156,21 +169,7 @@ func PointsForOddDay(purchaseDate string) (int, error) {
 }
 
 // Rule: 10 points if the time of purchase is after 2:00pm and before 4:00pm.
-// Assume this means range including 14:01 and 15:59
+// Assume this means range including 14:01 and 15:59, the default time window, deployments can configure others
 func PointsForTimeRange(purchaseTime string) (int, error) {
-	// Parse time with "time" methods
-	purchaseTimeParsed, err := time.Parse("15:04", purchaseTime)
-	if err != nil {
-		return 0, fmt.Errorf("cannot convert time string to time: %s", purchaseTime)
-	}
-
-	// Parse start time and end time required
-	startTime, _ := time.Parse("15:04", "14:00")
-	endTime, _ := time.Parse("15:04", "16:00")
-
-	// Check if purchase time is within time range
-	if purchaseTimeParsed.After(startTime) && purchaseTimeParsed.Before(endTime) {
-		return 10, nil
-	}
-	return 0, nil
+	return TimeWindowConfig{}.Points("", purchaseTime)
 }
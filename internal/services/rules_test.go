@@ -1,9 +1,14 @@
 package rules
 
 import (
+	"encoding/json"
+	"os"
 	"testing"
 
+	"golang.org/x/text/unicode/norm"
+
 	"receipt-processor-challenge-jase180/internal/models"
+	"receipt-processor-challenge-jase180/internal/services/text"
 )
 
 func TestPointsForRetailerName(t *testing.T) {
@@ -240,3 +245,84 @@ func TestCalculatePoints(t *testing.T) {
 		})
 	}
 }
+
+func TestCalculateBreakdown(t *testing.T) {
+	// M&M README receipt breakdown
+	receipt := models.Receipt{
+		Retailer:     "M&M Corner Market",
+		PurchaseDate: "2022-03-20",
+		PurchaseTime: "14:33",
+		Items: []models.Item{
+			{ShortDescription: "Gatorade", Price: "2.25"},
+			{ShortDescription: "Gatorade", Price: "2.25"},
+			{ShortDescription: "Gatorade", Price: "2.25"},
+			{ShortDescription: "Gatorade", Price: "2.25"},
+		},
+		Total: "9.00",
+	}
+	expected := []RulePoints{
+		{RuleRetailerName, 14},
+		{RuleRoundTotal, 50},
+		{RuleQuarterMultiple, 25},
+		{RuleEveryTwoItems, 10},
+		{RuleItemDescription, 0},
+		{RuleOddDay, 0},
+		{RuleTimeRange, 10},
+	}
+
+	result := CalculateBreakdown(receipt)
+	if len(result) != len(expected) {
+		t.Fatalf("Result was %v; want %v", result, expected)
+	}
+	for i := range expected {
+		if result[i] != expected[i] {
+			t.Errorf("Result was %v; want %v", result[i], expected[i])
+		}
+	}
+}
+
+func TestMultilingualReceipts(t *testing.T) {
+	// Corpus of receipts in several scripts with the points in the default graphemes mode
+	data, err := os.ReadFile("testdata/multilingual_receipts.json")
+	if err != nil {
+		t.Fatalf("Error reading corpus: %v", err)
+	}
+	var corpus []struct {
+		Name    string         `json:"name"`
+		Points  int            `json:"points"`
+		Receipt models.Receipt `json:"receipt"`
+	}
+	if err := json.Unmarshal(data, &corpus); err != nil {
+		t.Fatalf("Error parsing corpus: %v", err)
+	}
+
+	// decompose returns the receipt with every accent as a separate combining code point (NFD)
+	decompose := func(receipt models.Receipt) models.Receipt {
+		receipt.Retailer = norm.NFD.String(receipt.Retailer)
+		items := make([]models.Item, len(receipt.Items))
+		for i, item := range receipt.Items {
+			item.ShortDescription = norm.NFD.String(item.ShortDescription)
+			items[i] = item
+		}
+		receipt.Items = items
+		return receipt
+	}
+
+	for _, entry := range corpus {
+		t.Run(entry.Name, func(t *testing.T) {
+			if result := CalculatePoints(entry.Receipt); result != entry.Points {
+				t.Errorf("Result was %d; want %d", result, entry.Points)
+			}
+
+			// Encoding must not change points in the normalized modes
+			for _, mode := range []text.Mode{text.Graphemes, text.Runes} {
+				config := DefaultConfig()
+				config.CountMode = mode
+				composed, decomposed := config.CalculatePoints(entry.Receipt), config.CalculatePoints(decompose(entry.Receipt))
+				if composed != decomposed {
+					t.Errorf("Result in %s mode was %d composed and %d decomposed; want equal", mode, composed, decomposed)
+				}
+			}
+		})
+	}
+}
@@ -0,0 +1,152 @@
+package rules
+
+import (
+	"sort"
+
+	"receipt-processor-challenge-jase180/internal/models"
+)
+
+// SimulationFilter narrows which receipts are re-scored, empty fields match every receipt
+// Dates are YYYY-MM-DD purchase dates and inclusive, they compare as strings since the format is fixed
+type SimulationFilter struct {
+	Retailer string `json:"retailer"` // Case-insensitive substring of the raw or canonical retailer name
+	UserID   string `json:"userId"`   // Only receipts tied to this user
+	From     string `json:"from"`     // Earliest purchase date
+	To       string `json:"to"`       // Latest purchase date
+}
+
+// Matches reports if a receipt passes the filter
+func (f SimulationFilter) Matches(receipt models.Receipt) bool {
+	if f.Retailer != "" && !matchesAny(receipt.Retailer, []string{f.Retailer}) && !matchesAny(receipt.RetailerCanonical, []string{f.Retailer}) {
+		return false
+	}
+	if f.UserID != "" && receipt.UserID != f.UserID {
+		return false
+	}
+	if f.From != "" && receipt.PurchaseDate < f.From {
+		return false
+	}
+	if f.To != "" && receipt.PurchaseDate > f.To {
+		return false
+	}
+	return true
+}
+
+// HistogramBucket counts receipts scoring from Min to Max points inclusive
+type HistogramBucket struct {
+	Min   int `json:"min"`
+	Max   int `json:"max"`
+	Count int `json:"count"`
+}
+
+// ReceiptChange is one receipt's points under both configurations
+type ReceiptChange struct {
+	ID        string `json:"id"`
+	Retailer  string `json:"retailer"`
+	Current   int    `json:"current"`
+	Candidate int    `json:"candidate"`
+	Delta     int    `json:"delta"`
+}
+
+// SimulationResult compares the points receipts score under the current and candidate configurations
+type SimulationResult struct {
+	Receipts           int               `json:"receipts"`           // Receipts re-scored after filtering
+	CurrentTotal       int               `json:"currentTotal"`       // Sum of points under current rules
+	CandidateTotal     int               `json:"candidateTotal"`     // Sum of points under candidate rules
+	Delta              int               `json:"delta"`              // CandidateTotal minus CurrentTotal
+	Changed            int               `json:"changed"`            // Receipts whose points changed
+	CurrentHistogram   []HistogramBucket `json:"currentHistogram"`   // Distribution of points under current rules
+	CandidateHistogram []HistogramBucket `json:"candidateHistogram"` // Same buckets under candidate rules
+	LargestChanges     []ReceiptChange   `json:"largestChanges"`     // Receipts with the largest absolute change first
+}
+
+// Simulate re-scores receipts with Config.CalculatePoints under both configurations
+// Only the rules are compared, tier multipliers, campaigns and limits depend on history and are left out
+// bucketSize is the width of each histogram bucket and top is how many largest changes to return
+func Simulate(receipts []models.Receipt, current Config, candidate Config, filter SimulationFilter, bucketSize int, top int) SimulationResult {
+	result := SimulationResult{LargestChanges: []ReceiptChange{}}
+	changes := []ReceiptChange{}
+	currentPoints, candidatePoints := []int{}, []int{}
+
+	for _, receipt := range receipts {
+		if !filter.Matches(receipt) {
+			continue
+		}
+		change := ReceiptChange{
+			ID:        receipt.ID,
+			Retailer:  receipt.Retailer,
+			Current:   current.CalculatePoints(receipt),
+			Candidate: candidate.CalculatePoints(receipt),
+		}
+		change.Delta = change.Candidate - change.Current
+
+		result.Receipts++
+		result.CurrentTotal += change.Current
+		result.CandidateTotal += change.Candidate
+		currentPoints = append(currentPoints, change.Current)
+		candidatePoints = append(candidatePoints, change.Candidate)
+		if change.Delta != 0 {
+			result.Changed++
+			changes = append(changes, change)
+		}
+	}
+	result.Delta = result.CandidateTotal - result.CurrentTotal
+	result.CurrentHistogram, result.CandidateHistogram = histograms(currentPoints, candidatePoints, bucketSize)
+
+	// Largest absolute change first, ID breaks ties so output is stable
+	sort.Slice(changes, func(i, j int) bool {
+		if abs(changes[i].Delta) != abs(changes[j].Delta) {
+			return abs(changes[i].Delta) > abs(changes[j].Delta)
+		}
+		return changes[i].ID < changes[j].ID
+	})
+	if top > len(changes) {
+		top = len(changes)
+	}
+	result.LargestChanges = append(result.LargestChanges, changes[:top]...)
+	return result
+}
+
+// maxHistogramBuckets is the most buckets a histogram will have
+const maxHistogramBuckets = 100
+
+// histograms buckets both point lists into the same buckets from 0 up to the highest score
+func histograms(current []int, candidate []int, bucketSize int) ([]HistogramBucket, []HistogramBucket) {
+	if bucketSize <= 0 {
+		bucketSize = 1
+	}
+	highest := 0
+	for _, points := range append(append([]int{}, current...), candidate...) {
+		if points > highest {
+			highest = points
+		}
+	}
+
+	// Widen buckets rather than return thousands of them for a few outliers
+	for highest/bucketSize >= maxHistogramBuckets {
+		bucketSize *= 2
+	}
+
+	build := func(points []int) []HistogramBucket {
+		buckets := make([]HistogramBucket, highest/bucketSize+1)
+		for i := range buckets {
+			buckets[i] = HistogramBucket{Min: i * bucketSize, Max: (i+1)*bucketSize - 1}
+		}
+		for _, p := range points {
+			if p < 0 {
+				p = 0 // scores are never negative, guard anyway
+			}
+			buckets[p/bucketSize].Count++
+		}
+		return buckets
+	}
+	return build(current), build(candidate)
+}
+
+// abs returns the absolute value of an int
+func abs(value int) int {
+	if value < 0 {
+		return -value
+	}
+	return value
+}
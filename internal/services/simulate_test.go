@@ -0,0 +1,91 @@
+package rules
+
+import (
+	"testing"
+
+	"receipt-processor-challenge-jase180/internal/models"
+)
+
+func TestSimulate(t *testing.T) {
+	// README receipts, Target is 28 points and M&M is 109 points under default rules
+	receipts := []models.Receipt{
+		{
+			ID: "a", Retailer: "Target", PurchaseDate: "2022-01-01", PurchaseTime: "13:01", UserID: "user-1",
+			Items: []models.Item{
+				{ShortDescription: "Mountain Dew 12PK", Price: "6.49"},
+				{ShortDescription: "Emils Cheese Pizza", Price: "12.25"},
+				{ShortDescription: "Knorr Creamy Chicken", Price: "1.26"},
+				{ShortDescription: "Doritos Nacho Cheese", Price: "3.35"},
+				{ShortDescription: "   Klarbrunn 12-PK 12 FL OZ  ", Price: "12.00"},
+			},
+			Total: "35.35",
+		},
+		{
+			ID: "b", Retailer: "M&M Corner Market", PurchaseDate: "2022-03-20", PurchaseTime: "14:33",
+			Items: []models.Item{
+				{ShortDescription: "Gatorade", Price: "2.25"},
+				{ShortDescription: "Gatorade", Price: "2.25"},
+				{ShortDescription: "Gatorade", Price: "2.25"},
+				{ShortDescription: "Gatorade", Price: "2.25"},
+			},
+			Total: "9.00",
+		},
+	}
+
+	// Candidate adds 10 points per item so Target gains 50 and M&M gains 40
+	current := DefaultConfig()
+	candidate := DefaultConfig()
+	candidate.Expressions.Rules = []ExpressionRule{{Name: "Per item", Expression: "itemCount * 10"}}
+
+	tests := []struct {
+		name          string
+		filter        SimulationFilter
+		wantReceipts  int
+		wantDelta     int
+		wantLargestID string
+	}{
+		{"All receipts", SimulationFilter{}, 2, 90, "a"},
+		{"Retailer filter", SimulationFilter{Retailer: "corner"}, 1, 40, "b"},
+		{"User filter", SimulationFilter{UserID: "user-1"}, 1, 50, "a"},
+		{"Date filter", SimulationFilter{From: "2022-02-01", To: "2022-03-20"}, 1, 40, "b"},
+		{"Nothing matches", SimulationFilter{To: "2021-12-31"}, 0, 0, ""},
+	}
+	for _, testCase := range tests {
+		t.Run(testCase.name, func(t *testing.T) {
+			result := Simulate(receipts, current, candidate, testCase.filter, 25, 10)
+			if result.Receipts != testCase.wantReceipts || result.Delta != testCase.wantDelta {
+				t.Errorf("Result was %d receipts delta %d; want %d and %d", result.Receipts, result.Delta, testCase.wantReceipts, testCase.wantDelta)
+			}
+			if testCase.wantLargestID == "" {
+				if len(result.LargestChanges) != 0 {
+					t.Errorf("Result was %v; want no changes", result.LargestChanges)
+				}
+				return
+			}
+			if len(result.LargestChanges) == 0 || result.LargestChanges[0].ID != testCase.wantLargestID {
+				t.Errorf("Result was %v; want %s first", result.LargestChanges, testCase.wantLargestID)
+			}
+		})
+	}
+
+	// Histograms share buckets, 28 and 109 currently, 78 and 149 under candidate
+	result := Simulate(receipts, current, candidate, SimulationFilter{}, 50, 1)
+	wantCurrent := []int{1, 0, 1}
+	wantCandidate := []int{0, 1, 1}
+	if len(result.CurrentHistogram) != 3 || len(result.CandidateHistogram) != 3 {
+		t.Fatalf("Result was %d and %d buckets; want 3", len(result.CurrentHistogram), len(result.CandidateHistogram))
+	}
+	for i := range wantCurrent {
+		if result.CurrentHistogram[i].Count != wantCurrent[i] || result.CandidateHistogram[i].Count != wantCandidate[i] {
+			t.Errorf("Bucket %d was %d and %d; want %d and %d", i, result.CurrentHistogram[i].Count, result.CandidateHistogram[i].Count, wantCurrent[i], wantCandidate[i])
+		}
+	}
+	if len(result.LargestChanges) != 1 {
+		t.Errorf("Result was %d changes; want top 1", len(result.LargestChanges))
+	}
+
+	// Tiny buckets are widened instead of returning thousands
+	if result := Simulate(receipts, current, candidate, SimulationFilter{}, 1, 10); len(result.CurrentHistogram) > maxHistogramBuckets {
+		t.Errorf("Result was %d buckets; want at most %d", len(result.CurrentHistogram), maxHistogramBuckets)
+	}
+}
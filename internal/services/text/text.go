@@ -0,0 +1,69 @@
+// Package text counts characters the way a person reading a receipt would, shared by the rules and expr packages
+// so every length-based rule agrees. "Café" is 4 characters whether the é is one code point or e plus an accent
+package text
+
+import (
+	"fmt"
+	"unicode"
+	"unicode/utf8"
+
+	"github.com/rivo/uniseg"
+	"golang.org/x/text/unicode/norm"
+)
+
+// Mode is how characters are counted
+type Mode string
+
+// Counting modes, the zero value counts graphemes
+const (
+	Graphemes Mode = "graphemes" // User-perceived characters after NFC normalization, e.g. a flag emoji is 1
+	Runes     Mode = "runes"     // Unicode code points after NFC normalization
+	Legacy    Mode = "legacy"    // How the rules originally counted, lengths in UTF-8 bytes and letters in code points, no normalization
+)
+
+// Validate checks the mode is one of the counting modes or empty for the default
+func (m Mode) Validate() error {
+	switch m {
+	case "", Graphemes, Runes, Legacy:
+		return nil
+	}
+	return fmt.Errorf("unknown counting mode %q, use %q, %q or %q", m, Graphemes, Runes, Legacy)
+}
+
+// Count returns the number of characters in s
+func Count(s string, mode Mode) int {
+	switch mode {
+	case Legacy:
+		return len(s)
+	case Runes:
+		return utf8.RuneCountInString(norm.NFC.String(s))
+	}
+	return uniseg.GraphemeClusterCount(norm.NFC.String(s))
+}
+
+// CountAlphanumeric returns the number of letters and digits in s
+// In graphemes mode a character counts if its base is a letter or digit, so accents never count on their own
+func CountAlphanumeric(s string, mode Mode) int {
+	isAlphanumeric := func(r rune) bool { return unicode.IsLetter(r) || unicode.IsDigit(r) }
+
+	count := 0
+	switch mode {
+	case Legacy, Runes:
+		if mode == Runes {
+			s = norm.NFC.String(s)
+		}
+		for _, char := range s {
+			if isAlphanumeric(char) {
+				count++
+			}
+		}
+	default:
+		graphemes := uniseg.NewGraphemes(norm.NFC.String(s))
+		for graphemes.Next() {
+			if runes := graphemes.Runes(); len(runes) > 0 && isAlphanumeric(runes[0]) {
+				count++
+			}
+		}
+	}
+	return count
+}
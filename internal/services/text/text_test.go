@@ -0,0 +1,67 @@
+package text
+
+import (
+	"testing"
+)
+
+func TestCount(t *testing.T) {
+	tests := []struct {
+		name     string
+		s        string
+		mode     Mode
+		expected int
+	}{
+		{"ASCII graphemes", "Cafe Latte", Graphemes, 10},
+		{"Composed accent graphemes", "Caf\u00e9 Latte", Graphemes, 10},
+		{"Decomposed accent graphemes", "Cafe\u0301 Latte", Graphemes, 10},
+		{"Decomposed accent runes", "Cafe\u0301 Latte", Runes, 10},
+		{"Decomposed accent legacy", "Cafe\u0301 Latte", Legacy, 12},
+		{"Flag graphemes", "🇮🇹", Graphemes, 1},
+		{"Flag runes", "🇮🇹", Runes, 2},
+		{"Skin tone emoji graphemes", "👍🏽", Graphemes, 1},
+		{"Devanagari vowel sign graphemes", "\u091a\u093e\u092f", Graphemes, 2},
+		{"Devanagari vowel sign runes", "\u091a\u093e\u092f", Runes, 3},
+		{"Empty", "", Graphemes, 0},
+	}
+	for _, testCase := range tests {
+		t.Run(testCase.name, func(t *testing.T) {
+			if result := Count(testCase.s, testCase.mode); result != testCase.expected {
+				t.Errorf("Result was %d; want %d", result, testCase.expected)
+			}
+		})
+	}
+}
+
+func TestCountAlphanumeric(t *testing.T) {
+	tests := []struct {
+		name     string
+		s        string
+		mode     Mode
+		expected int
+	}{
+		{"ASCII", "M&M Corner Market", Graphemes, 14},
+		{"Decomposed accent graphemes", "Cafe\u0301", Graphemes, 4},
+		{"Decomposed accent legacy", "Cafe\u0301", Legacy, 4},
+		{"Japanese", "ファミリーマート", Graphemes, 8},
+		{"Emoji are not letters", "Pizza 🍕🇮🇹", Graphemes, 5},
+		{"Digits", "7-Eleven 1234", Runes, 11},
+	}
+	for _, testCase := range tests {
+		t.Run(testCase.name, func(t *testing.T) {
+			if result := CountAlphanumeric(testCase.s, testCase.mode); result != testCase.expected {
+				t.Errorf("Result was %d; want %d", result, testCase.expected)
+			}
+		})
+	}
+}
+
+func TestModeValidate(t *testing.T) {
+	for _, mode := range []Mode{"", Graphemes, Runes, Legacy} {
+		if err := mode.Validate(); err != nil {
+			t.Errorf("Mode %q was invalid: %v", mode, err)
+		}
+	}
+	if err := Mode("words").Validate(); err == nil {
+		t.Errorf("Mode %q was valid; want error", "words")
+	}
+}
@@ -0,0 +1,109 @@
+package rules
+
+import (
+	"errors"
+	"fmt"
+	"math"
+	"time"
+
+	"receipt-processor-challenge-jase180/internal/models"
+)
+
+// Tier is a loyalty tier and what it takes to reach it within the rolling window
+// A user qualifies if they meet either threshold, a tier with both thresholds at 0 is the base tier
+type Tier struct {
+	Name              string  `json:"name"`              // Display name recorded on receipts e.g. "Gold"
+	MinLifetimePoints int     `json:"minLifetimePoints"` // Points earned within the window to qualify
+	MinReceipts       int     `json:"minReceipts"`       // Receipts submitted within the window to qualify
+	Multiplier        float64 `json:"multiplier"`        // Applied to points from CalculatePoints for new receipts
+}
+
+// TierConfig is the ordered list of tiers from lowest to highest and the rolling window they are evaluated in
+type TierConfig struct {
+	Tiers      []Tier `json:"tiers"`      // Lowest tier first, first tier must be the base tier
+	WindowDays int    `json:"windowDays"` // Days of activity counted, 0 counts all activity
+}
+
+// DefaultTierConfig returns Bronze/Silver/Gold tiers over a one year window
+// Every multiplier is 1 so points are unchanged until an operator sets multipliers in the rules file
+func DefaultTierConfig() TierConfig {
+	return TierConfig{
+		Tiers: []Tier{
+			{Name: "Bronze", Multiplier: 1},
+			{Name: "Silver", MinLifetimePoints: 1000, MinReceipts: 10, Multiplier: 1},
+			{Name: "Gold", MinLifetimePoints: 5000, MinReceipts: 40, Multiplier: 1},
+		},
+		WindowDays: 365,
+	}
+}
+
+// Validate checks tiers are usable: base tier first, positive multipliers and increasing thresholds
+func (c TierConfig) Validate() error {
+	if len(c.Tiers) == 0 {
+		return errors.New("tiers: at least one tier is required")
+	}
+	if c.Tiers[0].MinLifetimePoints != 0 || c.Tiers[0].MinReceipts != 0 {
+		return fmt.Errorf("tiers: first tier %q must have no thresholds", c.Tiers[0].Name)
+	}
+	if c.WindowDays < 0 {
+		return errors.New("tiers: windowDays cannot be negative")
+	}
+
+	for i, tier := range c.Tiers {
+		if tier.Name == "" {
+			return fmt.Errorf("tiers: tier %d has no name", i)
+		}
+		if tier.Multiplier <= 0 {
+			return fmt.Errorf("tiers: tier %q multiplier must be positive", tier.Name)
+		}
+		if i > 0 && (tier.MinLifetimePoints < c.Tiers[i-1].MinLifetimePoints || tier.MinReceipts < c.Tiers[i-1].MinReceipts) {
+			return fmt.Errorf("tiers: tier %q thresholds must not be lower than tier %q", tier.Name, c.Tiers[i-1].Name)
+		}
+	}
+	return nil
+}
+
+// Evaluate returns the highest tier the user qualifies for from their ledger entries as of a time
+// Only earn entries that were not reversed and fall within the window count, so users are promoted
+// as they earn and demoted automatically as old activity leaves the window
+func (c TierConfig) Evaluate(entries []models.LedgerEntry, asOf time.Time) Tier {
+	if len(c.Tiers) == 0 {
+		return Tier{Multiplier: 1} // no tiers configured, behave like no multiplier
+	}
+
+	// Add up activity within the window
+	windowStart := time.Time{}
+	if c.WindowDays > 0 {
+		windowStart = asOf.AddDate(0, 0, -c.WindowDays)
+	}
+	points, receipts := 0, 0
+	for _, entry := range entries {
+		if entry.Type != models.LedgerEarn || entry.ReversedBy != "" {
+			continue
+		}
+		if entry.CreatedAt.Before(windowStart) || entry.CreatedAt.After(asOf) {
+			continue
+		}
+		points += entry.Points
+		receipts++
+	}
+
+	// Walk up the tiers and keep the highest one qualified for
+	current := c.Tiers[0]
+	for _, tier := range c.Tiers[1:] {
+		qualifiesByPoints := tier.MinLifetimePoints > 0 && points >= tier.MinLifetimePoints
+		qualifiesByReceipts := tier.MinReceipts > 0 && receipts >= tier.MinReceipts
+		if qualifiesByPoints || qualifiesByReceipts {
+			current = tier
+		}
+	}
+	return current
+}
+
+// ApplyMultiplier returns points multiplied by the tier multiplier, rounded to the nearest point
+func (t Tier) ApplyMultiplier(points int) int {
+	if t.Multiplier <= 0 {
+		return points
+	}
+	return int(math.Round(float64(points) * t.Multiplier))
+}
@@ -0,0 +1,113 @@
+package rules
+
+import (
+	"testing"
+	"time"
+
+	"receipt-processor-challenge-jase180/internal/models"
+)
+
+func TestTierConfigEvaluate(t *testing.T) {
+	config := TierConfig{
+		Tiers: []Tier{
+			{Name: "Bronze", Multiplier: 1},
+			{Name: "Silver", MinLifetimePoints: 100, MinReceipts: 3, Multiplier: 1.5},
+			{Name: "Gold", MinLifetimePoints: 500, Multiplier: 2},
+		},
+		WindowDays: 30,
+	}
+	asOf := time.Date(2025, 3, 31, 12, 0, 0, 0, time.UTC)
+	daysAgo := func(days int) time.Time { return asOf.AddDate(0, 0, -days) }
+
+	tests := []struct {
+		name     string
+		entries  []models.LedgerEntry
+		expected string
+	}{
+		{"No activity", nil, "Bronze"},
+		{"Promoted by points", []models.LedgerEntry{
+			{Type: models.LedgerEarn, Points: 120, CreatedAt: daysAgo(1)},
+		}, "Silver"},
+		{"Promoted by receipt count", []models.LedgerEntry{
+			{Type: models.LedgerEarn, Points: 1, CreatedAt: daysAgo(1)},
+			{Type: models.LedgerEarn, Points: 1, CreatedAt: daysAgo(2)},
+			{Type: models.LedgerEarn, Points: 1, CreatedAt: daysAgo(3)},
+		}, "Silver"},
+		{"Gold has no receipt threshold", []models.LedgerEntry{
+			{Type: models.LedgerEarn, Points: 600, CreatedAt: daysAgo(1)},
+		}, "Gold"},
+		{"Demoted once activity leaves window", []models.LedgerEntry{
+			{Type: models.LedgerEarn, Points: 600, CreatedAt: daysAgo(31)},
+		}, "Bronze"},
+		{"Reversed earnings do not count", []models.LedgerEntry{
+			{Type: models.LedgerEarn, Points: 600, CreatedAt: daysAgo(1), ReversedBy: "reversal"},
+		}, "Bronze"},
+		{"Redemptions do not count", []models.LedgerEntry{
+			{Type: models.LedgerRedemption, Points: 600, CreatedAt: daysAgo(1)},
+		}, "Bronze"},
+	}
+	for _, testCase := range tests {
+		t.Run(testCase.name, func(t *testing.T) {
+			result := config.Evaluate(testCase.entries, asOf)
+			if result.Name != testCase.expected {
+				t.Errorf("Result was %v; want %v", result.Name, testCase.expected)
+			}
+		})
+	}
+}
+
+func TestTierApplyMultiplier(t *testing.T) {
+	tests := []struct {
+		name       string
+		multiplier float64
+		points     int
+		expected   int
+	}{
+		{"No bonus", 1, 28, 28},
+		{"Round half up", 1.5, 109, 164}, // 163.5 rounds to 164
+		{"Round down", 1.25, 28, 35},
+		{"Unset multiplier", 0, 28, 28},
+	}
+	for _, testCase := range tests {
+		t.Run(testCase.name, func(t *testing.T) {
+			result := Tier{Multiplier: testCase.multiplier}.ApplyMultiplier(testCase.points)
+			if result != testCase.expected {
+				t.Errorf("Result was %v; want %v", result, testCase.expected)
+			}
+		})
+	}
+}
+
+func TestDefaultTierConfigKeepsPoints(t *testing.T) {
+	// Multipliers are opt-in, the default tiers never change points
+	for _, tier := range DefaultTierConfig().Tiers {
+		if tier.Multiplier != 1 {
+			t.Errorf("Result %s multiplier %v, want 1", tier.Name, tier.Multiplier)
+		}
+	}
+}
+
+func TestTierConfigValidate(t *testing.T) {
+	tests := []struct {
+		name    string
+		config  TierConfig
+		wantErr bool
+	}{
+		{"Default", DefaultTierConfig(), false},
+		{"No tiers", TierConfig{}, true},
+		{"Base tier with threshold", TierConfig{Tiers: []Tier{{Name: "Bronze", MinReceipts: 1, Multiplier: 1}}}, true},
+		{"Zero multiplier", TierConfig{Tiers: []Tier{{Name: "Bronze"}}}, true},
+		{"Decreasing thresholds", TierConfig{Tiers: []Tier{
+			{Name: "Bronze", Multiplier: 1},
+			{Name: "Gold", MinLifetimePoints: 500, Multiplier: 2},
+			{Name: "Silver", MinLifetimePoints: 100, Multiplier: 1.5},
+		}}, true},
+	}
+	for _, testCase := range tests {
+		t.Run(testCase.name, func(t *testing.T) {
+			if err := testCase.config.Validate(); (err != nil) != testCase.wantErr {
+				t.Errorf("unexpected error, error was %v, want %v", err, testCase.wantErr)
+			}
+		})
+	}
+}
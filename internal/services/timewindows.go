@@ -0,0 +1,131 @@
+package rules
+
+import (
+	"errors"
+	"fmt"
+	"strings"
+	"time"
+)
+
+// TimeWindow is a part of the day that earns points, boundaries are "15:04" times
+// A window whose end is before its start runs past midnight, e.g. 22:00 to 02:00
+type TimeWindow struct {
+	Start          string   `json:"start"`              // Start of the window
+	End            string   `json:"end"`                // End of the window
+	StartInclusive bool     `json:"startInclusive"`     // True if a purchase exactly at Start is in the window
+	EndInclusive   bool     `json:"endInclusive"`       // True if a purchase exactly at End is in the window
+	Weekdays       []string `json:"weekdays,omitempty"` // Days of the purchase date the window applies, e.g. "saturday" or "sat", empty is every day
+	Points         int      `json:"points"`             // Points for a purchase in the window
+}
+
+// TimeWindowConfig is the time of day rule, every window a purchase falls in adds its points
+// No windows means the default window so a rules file without this section keeps current behavior
+type TimeWindowConfig struct {
+	Windows []TimeWindow `json:"windows"`
+}
+
+// DefaultTimeWindows is the challenge rule, 10 points if the time of purchase is after 2:00pm and before 4:00pm
+// Both boundaries are exclusive so the range is 14:01 to 15:59
+func DefaultTimeWindows() []TimeWindow {
+	return []TimeWindow{{Start: "14:00", End: "16:00", Points: 10}}
+}
+
+// Accepted weekday names, full or the first three letters
+var weekdayNames = map[string]time.Weekday{
+	"sunday": time.Sunday, "monday": time.Monday, "tuesday": time.Tuesday, "wednesday": time.Wednesday,
+	"thursday": time.Thursday, "friday": time.Friday, "saturday": time.Saturday,
+	"sun": time.Sunday, "mon": time.Monday, "tue": time.Tuesday, "wed": time.Wednesday,
+	"thu": time.Thursday, "fri": time.Friday, "sat": time.Saturday,
+}
+
+// windows returns the configured windows or the default window
+func (c TimeWindowConfig) windows() []TimeWindow {
+	if len(c.Windows) == 0 {
+		return DefaultTimeWindows()
+	}
+	return c.Windows
+}
+
+// Validate checks every window has valid times, weekdays and points
+func (c TimeWindowConfig) Validate() error {
+	for i, window := range c.Windows {
+		start, err := time.Parse("15:04", window.Start)
+		if err != nil {
+			return fmt.Errorf("time windows: window %d start %q is not a HH:MM time", i, window.Start)
+		}
+		end, err := time.Parse("15:04", window.End)
+		if err != nil {
+			return fmt.Errorf("time windows: window %d end %q is not a HH:MM time", i, window.End)
+		}
+		if start.Equal(end) {
+			return fmt.Errorf("time windows: window %d starts and ends at %s", i, window.Start)
+		}
+		if window.Points < 0 {
+			return fmt.Errorf("time windows: window %d points cannot be negative", i)
+		}
+		for _, day := range window.Weekdays {
+			if _, ok := weekdayNames[strings.ToLower(strings.TrimSpace(day))]; !ok {
+				return fmt.Errorf("time windows: window %d weekday %q is not a day of the week", i, day)
+			}
+		}
+	}
+	return nil
+}
+
+// Points returns the points from every window the purchase falls in
+// The date is only needed by windows limited to some weekdays
+func (c TimeWindowConfig) Points(purchaseDate string, purchaseTime string) (int, error) {
+	purchase, err := time.Parse("15:04", purchaseTime)
+	if err != nil {
+		return 0, fmt.Errorf("cannot convert time string to time: %s", purchaseTime)
+	}
+
+	points := 0
+	for _, window := range c.windows() {
+		if len(window.Weekdays) > 0 {
+			date, err := time.Parse("2006-01-02", purchaseDate)
+			if err != nil {
+				return 0, fmt.Errorf("cannot convert date string to date: %s", purchaseDate)
+			}
+			if !window.onWeekday(date.Weekday()) {
+				continue
+			}
+		}
+		in, err := window.contains(purchase)
+		if err != nil {
+			return 0, err
+		}
+		if in {
+			points += window.Points
+		}
+	}
+	return points, nil
+}
+
+// contains reports if a purchase time is within the window's boundaries
+func (w TimeWindow) contains(purchase time.Time) (bool, error) {
+	start, startErr := time.Parse("15:04", w.Start)
+	end, endErr := time.Parse("15:04", w.End)
+	if startErr != nil || endErr != nil {
+		return false, errors.New("time window boundaries are not HH:MM times")
+	}
+
+	afterStart := purchase.After(start) || (w.StartInclusive && purchase.Equal(start))
+	beforeEnd := purchase.Before(end) || (w.EndInclusive && purchase.Equal(end))
+
+	// Overnight windows cover the late part of one day and the early part of the next
+	if end.Before(start) {
+		return afterStart || beforeEnd, nil
+	}
+	return afterStart && beforeEnd, nil
+}
+
+// onWeekday reports if the window applies on a day of the week
+func (w TimeWindow) onWeekday(day time.Weekday) bool {
+	for _, name := range w.Weekdays {
+		if weekdayNames[strings.ToLower(strings.TrimSpace(name))] == day {
+			return true
+		}
+	}
+	return false
+}
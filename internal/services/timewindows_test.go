@@ -0,0 +1,94 @@
+package rules
+
+import (
+	"testing"
+
+	"receipt-processor-challenge-jase180/internal/models"
+)
+
+func TestTimeWindowConfigPoints(t *testing.T) {
+	lunch := TimeWindow{Start: "11:30", End: "13:30", StartInclusive: true, EndInclusive: true, Points: 5}
+	weekendAfternoon := TimeWindow{Start: "14:00", End: "16:00", StartInclusive: true, Weekdays: []string{"Saturday", "sun"}, Points: 20}
+	lateNight := TimeWindow{Start: "22:00", End: "02:00", Points: 15}
+
+	tests := []struct {
+		name          string
+		config        TimeWindowConfig
+		date          string
+		time          string
+		expected      int
+		conversionErr bool // true if we want an error to show up
+	}{
+		{"Default window within", TimeWindowConfig{}, "2022-01-01", "14:01", 10, false},
+		{"Default window start excluded", TimeWindowConfig{}, "2022-01-01", "14:00", 0, false},
+		{"Default window end excluded", TimeWindowConfig{}, "2022-01-01", "16:00", 0, false},
+		{"Inclusive start", TimeWindowConfig{Windows: []TimeWindow{lunch}}, "2022-01-03", "11:30", 5, false},
+		{"Inclusive end", TimeWindowConfig{Windows: []TimeWindow{lunch}}, "2022-01-03", "13:30", 5, false},
+		{"Before window", TimeWindowConfig{Windows: []TimeWindow{lunch}}, "2022-01-03", "11:29", 0, false},
+		{"Weekday matches", TimeWindowConfig{Windows: []TimeWindow{weekendAfternoon}}, "2022-01-01", "14:00", 20, false}, // Saturday
+		{"Weekday does not match", TimeWindowConfig{Windows: []TimeWindow{weekendAfternoon}}, "2022-01-03", "14:30", 0, false},
+		{"Multiple windows add up", TimeWindowConfig{Windows: []TimeWindow{lunch, weekendAfternoon, {Start: "12:00", End: "15:00", Points: 1}}},
+			"2022-01-02", "14:30", 21, false},
+		{"Overnight before midnight", TimeWindowConfig{Windows: []TimeWindow{lateNight}}, "2022-01-03", "23:15", 15, false},
+		{"Overnight after midnight", TimeWindowConfig{Windows: []TimeWindow{lateNight}}, "2022-01-03", "01:59", 15, false},
+		{"Overnight outside", TimeWindowConfig{Windows: []TimeWindow{lateNight}}, "2022-01-03", "02:00", 0, false},
+		{"Invalid time", TimeWindowConfig{}, "2022-01-01", "42.42", 0, true},
+		{"Invalid date for weekday window", TimeWindowConfig{Windows: []TimeWindow{weekendAfternoon}}, "01/01/2022", "14:30", 0, true},
+	}
+	for _, testCase := range tests {
+		t.Run(testCase.name, func(t *testing.T) {
+			result, err := testCase.config.Points(testCase.date, testCase.time)
+			if (err != nil) != testCase.conversionErr {
+				t.Errorf("unexpected error, error was %v, want %v", err, testCase.conversionErr)
+			}
+			if result != testCase.expected {
+				t.Errorf("Result was %v; want %v", result, testCase.expected)
+			}
+		})
+	}
+}
+
+func TestConfigCalculatePointsWithTimeWindows(t *testing.T) {
+	// 14:00 exactly, outside the default window
+	receipt := models.Receipt{
+		Retailer:     "Target",
+		PurchaseDate: "2022-01-02",
+		PurchaseTime: "14:00",
+		Items:        []models.Item{{ShortDescription: "Pepsi - 12-oz", Price: "1.25"}},
+		Total:        "1.25",
+	}
+
+	config := DefaultConfig()
+	defaultPoints := config.CalculatePoints(receipt)
+	if defaultPoints != CalculatePoints(receipt) {
+		t.Fatalf("Result was %d; want %d with default windows", defaultPoints, CalculatePoints(receipt))
+	}
+
+	config.TimeWindows = TimeWindowConfig{Windows: []TimeWindow{{Start: "14:00", End: "16:00", StartInclusive: true, Points: 10}}}
+	if result := config.CalculatePoints(receipt); result != defaultPoints+10 {
+		t.Errorf("Result was %d; want %d with inclusive start", result, defaultPoints+10)
+	}
+}
+
+func TestTimeWindowConfigValidate(t *testing.T) {
+	tests := []struct {
+		name    string
+		config  TimeWindowConfig
+		wantErr bool
+	}{
+		{"Default", TimeWindowConfig{}, false},
+		{"Valid window", TimeWindowConfig{Windows: []TimeWindow{{Start: "09:00", End: "10:00", Weekdays: []string{"Mon", "friday"}, Points: 5}}}, false},
+		{"Bad start", TimeWindowConfig{Windows: []TimeWindow{{Start: "9am", End: "10:00", Points: 5}}}, true},
+		{"Bad end", TimeWindowConfig{Windows: []TimeWindow{{Start: "09:00", End: "25:00", Points: 5}}}, true},
+		{"Empty window", TimeWindowConfig{Windows: []TimeWindow{{Start: "09:00", End: "09:00", Points: 5}}}, true},
+		{"Negative points", TimeWindowConfig{Windows: []TimeWindow{{Start: "09:00", End: "10:00", Points: -5}}}, true},
+		{"Bad weekday", TimeWindowConfig{Windows: []TimeWindow{{Start: "09:00", End: "10:00", Weekdays: []string{"Funday"}, Points: 5}}}, true},
+	}
+	for _, testCase := range tests {
+		t.Run(testCase.name, func(t *testing.T) {
+			if err := testCase.config.Validate(); (err != nil) != testCase.wantErr {
+				t.Errorf("unexpected error, error was %v, want %v", err, testCase.wantErr)
+			}
+		})
+	}
+}
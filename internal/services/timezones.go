@@ -0,0 +1,142 @@
+package rules
+
+import (
+	"errors"
+	"fmt"
+	"regexp"
+	"strconv"
+	"strings"
+	"time"
+	_ "time/tzdata" // embed the tz database so zones load in the Alpine image which has none
+
+	"receipt-processor-challenge-jase180/internal/models"
+)
+
+// Where time based rules are evaluated
+const (
+	EvaluateInLocal     = "local"     // Store's own time as printed on the receipt, the original behavior
+	EvaluateInReference = "reference" // Converted to the Reference zone e.g. HQ time for promotions
+)
+
+// TimezoneConfig says which zone each store is in and which zone time based rules are evaluated in
+// Receipts without a timezone and with no retailer default are treated as UTC, as PurchaseInstant does for campaigns
+type TimezoneConfig struct {
+	RetailerDefaults map[string]string `json:"retailerDefaults"` // Retailer name (case-insensitive) to zone for receipts without one
+	EvaluateIn       string            `json:"evaluateIn"`       // local or reference, empty is local
+	Reference        string            `json:"reference"`        // Zone for evaluateIn reference e.g. "America/Chicago"
+}
+
+// regexOffset matches UTC offsets like "+05:30", "-0800" or "UTC+01:00"
+var regexOffset = regexp.MustCompile(`^(?:UTC)?([+-])(\d{2}):?(\d{2})$`)
+
+// LoadZone parses an IANA zone name like "America/New_York" or a UTC offset like "-05:00"
+func LoadZone(name string) (*time.Location, error) {
+	name = strings.TrimSpace(name)
+	if name == "" {
+		return nil, errors.New("timezone is empty")
+	}
+
+	if match := regexOffset.FindStringSubmatch(name); match != nil {
+		hours, _ := strconv.Atoi(match[2])
+		minutes, _ := strconv.Atoi(match[3])
+		if hours > 14 || minutes > 59 {
+			return nil, fmt.Errorf("timezone offset %s is out of range", name)
+		}
+		offset := hours*3600 + minutes*60
+		if match[1] == "-" {
+			offset = -offset
+		}
+		return time.FixedZone(name, offset), nil
+	}
+
+	// time.LoadLocation treats "" and "Local" as the server zone, neither makes sense for a receipt
+	if name == "Local" {
+		return nil, errors.New("timezone Local is not allowed")
+	}
+	location, err := time.LoadLocation(name)
+	if err != nil {
+		return nil, fmt.Errorf("unknown timezone %s", name)
+	}
+	return location, nil
+}
+
+// Validate checks every configured zone loads and evaluateIn is known
+func (c TimezoneConfig) Validate() error {
+	switch c.EvaluateIn {
+	case "", EvaluateInLocal:
+	case EvaluateInReference:
+		if _, err := LoadZone(c.Reference); err != nil {
+			return fmt.Errorf("timezones: reference: %w", err)
+		}
+	default:
+		return fmt.Errorf("timezones: evaluateIn must be %s or %s", EvaluateInLocal, EvaluateInReference)
+	}
+	for retailer, zone := range c.RetailerDefaults {
+		if _, err := LoadZone(zone); err != nil {
+			return fmt.Errorf("timezones: retailer %q: %w", retailer, err)
+		}
+	}
+	return nil
+}
+
+// ZoneName returns the receipt's own timezone, or the retailer default, or empty if neither is known
+// The default is looked up by the canonical retailer name, then the retailer as printed
+func (c TimezoneConfig) ZoneName(receipt models.Receipt) string {
+	if receipt.Timezone != "" {
+		return receipt.Timezone
+	}
+	// Canonical name first like the retailer limits, so an alias gets its retailer's zone
+	for _, name := range []string{receipt.RetailerCanonical, receipt.Retailer} {
+		if strings.TrimSpace(name) == "" {
+			continue
+		}
+		for retailer, zone := range c.RetailerDefaults {
+			if strings.EqualFold(strings.TrimSpace(retailer), strings.TrimSpace(name)) {
+				return zone
+			}
+		}
+	}
+	return ""
+}
+
+// WithZone returns the receipt with Timezone filled in from the retailer default if it had none
+func (c TimezoneConfig) WithZone(receipt models.Receipt) models.Receipt {
+	receipt.Timezone = c.ZoneName(receipt)
+	return receipt
+}
+
+// Localize returns the receipt with purchase date and time rewritten in the zone rules are evaluated in
+// Unchanged when evaluating in local time, a store with no known zone is converted from UTC
+func (c TimezoneConfig) Localize(receipt models.Receipt) models.Receipt {
+	if c.EvaluateIn != EvaluateInReference {
+		return receipt
+	}
+
+	reference, err := LoadZone(c.Reference)
+	if err != nil {
+		return receipt // fail gracefully, Validate rejects this at load
+	}
+	purchasedAt, err := PurchaseInstant(c.WithZone(receipt))
+	if err != nil {
+		return receipt // fail gracefully, validation already rejects bad dates and zones
+	}
+
+	converted := purchasedAt.In(reference)
+	receipt.PurchaseDate = converted.Format("2006-01-02")
+	receipt.PurchaseTime = converted.Format("15:04")
+	receipt.Timezone = c.Reference
+	return receipt
+}
+
+// PurchaseInstant returns the moment of purchase using the receipt's timezone, UTC if it has none
+func PurchaseInstant(receipt models.Receipt) (time.Time, error) {
+	location := time.UTC
+	if receipt.Timezone != "" {
+		zone, err := LoadZone(receipt.Timezone)
+		if err != nil {
+			return time.Time{}, err
+		}
+		location = zone
+	}
+	return time.ParseInLocation("2006-01-02 15:04", receipt.PurchaseDate+" "+receipt.PurchaseTime, location)
+}
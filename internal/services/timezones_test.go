@@ -0,0 +1,165 @@
+package rules
+
+import (
+	"testing"
+	"time"
+
+	"receipt-processor-challenge-jase180/internal/models"
+)
+
+func TestLoadZone(t *testing.T) {
+	tests := []struct {
+		name       string
+		zone       string
+		wantOffset int // seconds east of UTC on 2022-01-01 if expect success
+		wantErr    bool
+	}{
+		{"IANA zone", "America/Chicago", -6 * 3600, false},
+		{"UTC", "UTC", 0, false},
+		{"Offset with colon", "+05:30", 5*3600 + 30*60, false},
+		{"Offset without colon", "-0800", -8 * 3600, false},
+		{"Offset with UTC prefix", "UTC+01:00", 3600, false},
+		{"Offset out of range", "+15:00", 0, true},
+		{"Unknown zone", "Mars/Olympus_Mons", 0, true},
+		{"Server local zone", "Local", 0, true},
+		{"Empty", " ", 0, true},
+	}
+	for _, testCase := range tests {
+		t.Run(testCase.name, func(t *testing.T) {
+			location, err := LoadZone(testCase.zone)
+			if (err != nil) != testCase.wantErr {
+				t.Fatalf("unexpected error, error was %v, want %v", err, testCase.wantErr)
+			}
+			if err != nil {
+				return
+			}
+			instant, _ := PurchaseInstant(models.Receipt{PurchaseDate: "2022-01-01", PurchaseTime: "12:00", Timezone: testCase.zone})
+			if _, offset := instant.In(location).Zone(); offset != testCase.wantOffset {
+				t.Errorf("Result offset was %d; want %d", offset, testCase.wantOffset)
+			}
+		})
+	}
+}
+
+func TestTimezoneConfigLocalize(t *testing.T) {
+	// Purchased 13:30 on Jan 1st in New York, 12:30 in Chicago HQ and 18:30 UTC
+	receipt := models.Receipt{Retailer: "Target", PurchaseDate: "2022-01-01", PurchaseTime: "13:30"}
+	alias := models.Receipt{Retailer: "TGT STORE 1234", RetailerCanonical: "Target", PurchaseDate: "2022-01-01", PurchaseTime: "13:30"}
+
+	tests := []struct {
+		name     string
+		config   TimezoneConfig
+		receipt  models.Receipt
+		wantDate string
+		wantTime string
+	}{
+		{"Local evaluation is unchanged", TimezoneConfig{}, withTimezone(receipt, "America/New_York"), "2022-01-01", "13:30"},
+		{"Reference zone converts", TimezoneConfig{EvaluateIn: EvaluateInReference, Reference: "America/Chicago"},
+			withTimezone(receipt, "America/New_York"), "2022-01-01", "12:30"},
+		{"Retailer default used without receipt zone", TimezoneConfig{EvaluateIn: EvaluateInReference, Reference: "UTC",
+			RetailerDefaults: map[string]string{"target": "America/New_York"}}, receipt, "2022-01-01", "18:30"},
+		{"Retailer default found by canonical name", TimezoneConfig{EvaluateIn: EvaluateInReference, Reference: "UTC",
+			RetailerDefaults: map[string]string{"target": "America/New_York"}}, alias, "2022-01-01", "18:30"},
+		{"Canonical name before printed name", TimezoneConfig{EvaluateIn: EvaluateInReference, Reference: "UTC",
+			RetailerDefaults: map[string]string{"TGT STORE 1234": "America/Chicago", "Target": "America/New_York"}}, alias, "2022-01-01", "18:30"},
+		{"Conversion can change the day", TimezoneConfig{EvaluateIn: EvaluateInReference, Reference: "Asia/Tokyo"},
+			withTimezone(receipt, "America/New_York"), "2022-01-02", "03:30"},
+		{"Unknown store zone is converted from UTC", TimezoneConfig{EvaluateIn: EvaluateInReference, Reference: "America/Chicago"},
+			receipt, "2022-01-01", "07:30"},
+	}
+	for _, testCase := range tests {
+		t.Run(testCase.name, func(t *testing.T) {
+			result := testCase.config.Localize(testCase.receipt)
+			if result.PurchaseDate != testCase.wantDate || result.PurchaseTime != testCase.wantTime {
+				t.Errorf("Result was %s %s; want %s %s", result.PurchaseDate, result.PurchaseTime, testCase.wantDate, testCase.wantTime)
+			}
+		})
+	}
+}
+
+func TestConfigCalculatePointsInReferenceZone(t *testing.T) {
+	// 13:30 in New York is outside 14:00-16:00 locally, but 14:30 in Halifax HQ time
+	receipt := models.Receipt{
+		Retailer:     "Target",
+		PurchaseDate: "2022-01-02",
+		PurchaseTime: "13:30",
+		Timezone:     "America/New_York",
+		Items:        []models.Item{{ShortDescription: "Pepsi - 12-oz", Price: "1.25"}},
+		Total:        "1.25",
+	}
+
+	config := DefaultConfig()
+	local := config.CalculatePoints(receipt)
+
+	config.Timezones = TimezoneConfig{EvaluateIn: EvaluateInReference, Reference: "America/Halifax"}
+	if result := config.CalculatePoints(receipt); result != local+10 {
+		t.Errorf("Result was %d; want %d with time range points", result, local+10)
+	}
+}
+
+// TestZonelessReceiptIsUTC tests time windows and campaign windows both read a receipt without a zone as UTC
+func TestZonelessReceiptIsUTC(t *testing.T) {
+	// 20:30 UTC is 15:30 in New York, inside 14:00-16:00 in New York but not in UTC
+	receipt := models.Receipt{
+		Retailer:     "Target",
+		PurchaseDate: "2022-01-02",
+		PurchaseTime: "20:30",
+		Items:        []models.Item{{ShortDescription: "Pepsi - 12-oz", Price: "1.25"}},
+		Total:        "1.25",
+	}
+
+	config := DefaultConfig()
+	local := config.CalculatePoints(receipt)
+	config.Timezones = TimezoneConfig{EvaluateIn: EvaluateInReference, Reference: "America/New_York"}
+	if result := config.CalculatePoints(receipt); result != local+10 {
+		t.Errorf("Result was %d; want %d with time range points", result, local+10)
+	}
+
+	// Campaign windows compare the same instant
+	newYork, _ := LoadZone("America/New_York")
+	tests := []struct {
+		name      string
+		start     time.Time
+		wantBonus bool
+	}{
+		{"Window in UTC matches", time.Date(2022, 1, 2, 20, 0, 0, 0, time.UTC), true},
+		{"Same window in New York does not match", time.Date(2022, 1, 2, 20, 0, 0, 0, newYork), false},
+		{"Window in New York time of the UTC purchase matches", time.Date(2022, 1, 2, 15, 0, 0, 0, newYork), true},
+	}
+	for _, testCase := range tests {
+		t.Run(testCase.name, func(t *testing.T) {
+			campaign := models.Campaign{Start: testCase.start, End: testCase.start.Add(time.Hour), Bonus: models.Bonus{Type: models.BonusFlat, Value: 10}}
+			bonuses := CampaignBonuses(config.Timezones.WithZone(receipt), local, []models.Campaign{campaign}, CountLineItems)
+			if (len(bonuses) == 1) != testCase.wantBonus {
+				t.Errorf("Result was %v; want bonus %v", bonuses, testCase.wantBonus)
+			}
+		})
+	}
+}
+
+func TestTimezoneConfigValidate(t *testing.T) {
+	tests := []struct {
+		name    string
+		config  TimezoneConfig
+		wantErr bool
+	}{
+		{"Default", TimezoneConfig{}, false},
+		{"Reference zone", TimezoneConfig{EvaluateIn: EvaluateInReference, Reference: "America/Chicago"}, false},
+		{"Reference without zone", TimezoneConfig{EvaluateIn: EvaluateInReference}, true},
+		{"Unknown evaluateIn", TimezoneConfig{EvaluateIn: "hq"}, true},
+		{"Bad retailer default", TimezoneConfig{RetailerDefaults: map[string]string{"Target": "Nowhere"}}, true},
+	}
+	for _, testCase := range tests {
+		t.Run(testCase.name, func(t *testing.T) {
+			if err := testCase.config.Validate(); (err != nil) != testCase.wantErr {
+				t.Errorf("unexpected error, error was %v, want %v", err, testCase.wantErr)
+			}
+		})
+	}
+}
+
+// helper function that returns a copy of the receipt in a timezone
+func withTimezone(receipt models.Receipt, zone string) models.Receipt {
+	receipt.Timezone = zone
+	return receipt
+}
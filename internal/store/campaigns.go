@@ -0,0 +1,86 @@
+package store
+
+import (
+	"errors"
+	"sort"
+	"time"
+
+	"receipt-processor-challenge-jase180/internal/models"
+)
+
+// Defined campaign errors for reusability
+var (
+	ErrCampaignAlreadyExists = errors.New("campaign already exists in database")
+	ErrCampaignNotInDatabase = errors.New("no such campaign exists in database")
+)
+
+// AddCampaign adds a campaign into the memory database after checking if a campaign with the same ID exists already
+func (db *MemoryDatabase) AddCampaign(campaign models.Campaign) error {
+	defer db.observe("AddCampaign", time.Now())
+	db.lock.Lock()
+	defer db.lock.Unlock()
+
+	if _, exists := db.campaigns[campaign.ID]; exists {
+		return ErrCampaignAlreadyExists
+	}
+	db.campaigns[campaign.ID] = campaign
+	return nil
+}
+
+// UpdateCampaign replaces a stored campaign with the same ID
+func (db *MemoryDatabase) UpdateCampaign(campaign models.Campaign) error {
+	defer db.observe("UpdateCampaign", time.Now())
+	db.lock.Lock()
+	defer db.lock.Unlock()
+
+	if _, exists := db.campaigns[campaign.ID]; !exists {
+		return ErrCampaignNotInDatabase
+	}
+	db.campaigns[campaign.ID] = campaign
+	return nil
+}
+
+// DeleteCampaign removes a campaign, receipts that already earned its bonus keep it
+func (db *MemoryDatabase) DeleteCampaign(id string) error {
+	defer db.observe("DeleteCampaign", time.Now())
+	db.lock.Lock()
+	defer db.lock.Unlock()
+
+	if _, exists := db.campaigns[id]; !exists {
+		return ErrCampaignNotInDatabase
+	}
+	delete(db.campaigns, id)
+	return nil
+}
+
+// GetCampaignByID retrieves the campaign with the ID after checking if ID exists
+func (db *MemoryDatabase) GetCampaignByID(id string) (models.Campaign, error) {
+	defer db.observe("GetCampaignByID", time.Now())
+	db.lock.RLock()
+	defer db.lock.RUnlock()
+
+	campaign, exists := db.campaigns[id]
+	if !exists {
+		return models.Campaign{}, ErrCampaignNotInDatabase
+	}
+	return campaign, nil
+}
+
+// ListCampaigns returns all campaigns ordered by start time then name so responses are stable
+func (db *MemoryDatabase) ListCampaigns() []models.Campaign {
+	defer db.observe("ListCampaigns", time.Now())
+	db.lock.RLock()
+	defer db.lock.RUnlock()
+
+	campaigns := make([]models.Campaign, 0, len(db.campaigns))
+	for _, campaign := range db.campaigns {
+		campaigns = append(campaigns, campaign)
+	}
+	sort.Slice(campaigns, func(i, j int) bool {
+		if !campaigns[i].Start.Equal(campaigns[j].Start) {
+			return campaigns[i].Start.Before(campaigns[j].Start)
+		}
+		return campaigns[i].Name < campaigns[j].Name
+	})
+	return campaigns
+}
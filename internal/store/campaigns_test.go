@@ -0,0 +1,57 @@
+package store
+
+import (
+	"testing"
+	"time"
+
+	"github.com/google/uuid"
+
+	"receipt-processor-challenge-jase180/internal/models"
+)
+
+// TestMemoryDatabaseCampaigns tests campaign CRUD functions and errors
+func TestMemoryDatabaseCampaigns(t *testing.T) {
+	db := NewMemoryDatabase()
+
+	later := models.Campaign{ID: uuid.NewString(), Name: "Black Friday", Start: time.Date(2025, 11, 24, 0, 0, 0, 0, time.UTC)}
+	earlier := models.Campaign{ID: uuid.NewString(), Name: "Summer", Start: time.Date(2025, 6, 1, 0, 0, 0, 0, time.UTC)}
+
+	// Test AddCampaign and ErrCampaignAlreadyExists
+	for _, campaign := range []models.Campaign{later, earlier} {
+		if err := db.AddCampaign(campaign); err != nil {
+			t.Fatalf("Result: %v; want Success Add", err)
+		}
+	}
+	if err := db.AddCampaign(later); err != ErrCampaignAlreadyExists {
+		t.Fatalf("Result: %v; want error %v", err, ErrCampaignAlreadyExists)
+	}
+
+	// Test ListCampaigns is ordered by start
+	campaigns := db.ListCampaigns()
+	if len(campaigns) != 2 || campaigns[0].ID != earlier.ID {
+		t.Fatalf("Result: %v; want earlier campaign first", campaigns)
+	}
+
+	// Test UpdateCampaign and GetCampaignByID
+	later.Name = "Black Friday Week"
+	if err := db.UpdateCampaign(later); err != nil {
+		t.Fatalf("Result: %v; want Success Update", err)
+	}
+	if stored, err := db.GetCampaignByID(later.ID); err != nil || stored.Name != "Black Friday Week" {
+		t.Fatalf("Result: %v, %v; want updated campaign", stored, err)
+	}
+
+	// Test DeleteCampaign then errors for missing campaign
+	if err := db.DeleteCampaign(later.ID); err != nil {
+		t.Fatalf("Result: %v; want Success Delete", err)
+	}
+	if _, err := db.GetCampaignByID(later.ID); err != ErrCampaignNotInDatabase {
+		t.Fatalf("Result: %v; want error %v", err, ErrCampaignNotInDatabase)
+	}
+	if err := db.UpdateCampaign(later); err != ErrCampaignNotInDatabase {
+		t.Fatalf("Result: %v; want error %v", err, ErrCampaignNotInDatabase)
+	}
+	if err := db.DeleteCampaign(later.ID); err != ErrCampaignNotInDatabase {
+		t.Fatalf("Result: %v; want error %v", err, ErrCampaignNotInDatabase)
+	}
+}
@@ -0,0 +1,143 @@
+package store
+
+import (
+	"errors"
+	"time"
+
+	"receipt-processor-challenge-jase180/internal/models"
+)
+
+// Defined ledger errors for reusability
+var (
+	ErrInsufficientPoints   = errors.New("insufficient points for user")
+	ErrEntryNotInLedger     = errors.New("no such entry exists in ledger")
+	ErrEntryAlreadyReversed = errors.New("entry has already been reversed")
+	ErrEntryIsReversal      = errors.New("reversal entries cannot be reversed")
+	ErrEntryAlreadyExists   = errors.New("entry already exists in ledger")
+	ErrInvalidEntry         = errors.New("ledger entry is invalid")
+)
+
+// PostLedgerEntry atomically validates and posts an entry, filling in its accounts from the type
+// For LedgerAdjustment a negative Points debits the user, stored Points is always positive
+// For LedgerReversal only ReversalOf is needed, points and user are copied from the original
+// Any entry that debits a user account fails with ErrInsufficientPoints instead of going negative
+func (db *MemoryDatabase) PostLedgerEntry(entry models.LedgerEntry) (models.LedgerEntry, error) {
+	defer db.observe("PostLedgerEntry", time.Now())
+	// Hold the write lock across check and post so concurrent redemptions cannot double spend
+	db.lock.Lock()
+	defer db.lock.Unlock()
+	return db.postLedgerEntry(entry)
+}
+
+// postLedgerEntry validates and posts an entry, nothing changes if it fails. Caller must hold the write lock
+func (db *MemoryDatabase) postLedgerEntry(entry models.LedgerEntry) (models.LedgerEntry, error) {
+	if _, exists := db.ledgerIndex[entry.ID]; exists {
+		return models.LedgerEntry{}, ErrEntryAlreadyExists
+	}
+
+	// Fill in accounts for the type
+	var original *models.LedgerEntry
+	switch entry.Type {
+	case models.LedgerEarn:
+		entry.DebitAccount, entry.CreditAccount = models.AccountEarned, models.UserAccount(entry.UserID)
+	case models.LedgerRedemption:
+		entry.DebitAccount, entry.CreditAccount = models.UserAccount(entry.UserID), models.AccountRedeemed
+	case models.LedgerAdjustment:
+		if entry.Points < 0 {
+			entry.Points = -entry.Points
+			entry.DebitAccount, entry.CreditAccount = models.UserAccount(entry.UserID), models.AccountAdjustments
+		} else {
+			entry.DebitAccount, entry.CreditAccount = models.AccountAdjustments, models.UserAccount(entry.UserID)
+		}
+	case models.LedgerReversal:
+		index, exists := db.ledgerIndex[entry.ReversalOf]
+		if !exists {
+			return models.LedgerEntry{}, ErrEntryNotInLedger
+		}
+		original = &db.ledger[index]
+		if original.Type == models.LedgerReversal {
+			return models.LedgerEntry{}, ErrEntryIsReversal
+		}
+		if original.ReversedBy != "" {
+			return models.LedgerEntry{}, ErrEntryAlreadyReversed
+		}
+		entry.UserID, entry.Points = original.UserID, original.Points
+		entry.DebitAccount, entry.CreditAccount = original.CreditAccount, original.DebitAccount
+	default:
+		return models.LedgerEntry{}, ErrInvalidEntry
+	}
+
+	if entry.UserID == "" || entry.Points < 0 || (entry.Points == 0 && entry.Type != models.LedgerEarn) {
+		return models.LedgerEntry{}, ErrInvalidEntry
+	}
+
+	// Check the debited account can afford it, program accounts are allowed to go negative
+	if entry.DebitAccount == models.UserAccount(entry.UserID) && db.balances[entry.DebitAccount] < entry.Points {
+		return models.LedgerEntry{}, ErrInsufficientPoints
+	}
+
+	// Post both sides and keep lifetime earned points net of reversed earnings
+	db.balances[entry.DebitAccount] -= entry.Points
+	db.balances[entry.CreditAccount] += entry.Points
+	if entry.Type == models.LedgerEarn {
+		db.lifetimePoints[entry.UserID] += entry.Points
+	}
+	if original != nil {
+		original.ReversedBy = entry.ID
+		if original.Type == models.LedgerEarn {
+			db.lifetimePoints[entry.UserID] -= entry.Points
+			if original.ReceiptID != "" {
+				db.reversed[original.ReceiptID] = true
+			}
+		}
+	}
+
+	db.ledgerIndex[entry.ID] = len(db.ledger)
+	db.ledger = append(db.ledger, entry)
+	return entry, nil
+}
+
+// IsReceiptReversed reports whether the points a receipt earned were reversed in the ledger
+func (db *MemoryDatabase) IsReceiptReversed(receiptID string) bool {
+	defer db.observe("IsReceiptReversed", time.Now())
+	db.lock.RLock()
+	defer db.lock.RUnlock()
+	return db.reversed[receiptID]
+}
+
+// GetLedgerEntryByID retrieves a ledger entry with the ID after checking if ID exists
+func (db *MemoryDatabase) GetLedgerEntryByID(id string) (models.LedgerEntry, error) {
+	defer db.observe("GetLedgerEntryByID", time.Now())
+	db.lock.RLock()
+	defer db.lock.RUnlock()
+
+	index, exists := db.ledgerIndex[id]
+	if !exists {
+		return models.LedgerEntry{}, ErrEntryNotInLedger
+	}
+	return db.ledger[index], nil
+}
+
+// GetLedgerByUser retrieves all ledger entries for a user in posting order
+func (db *MemoryDatabase) GetLedgerByUser(userID string) []models.LedgerEntry {
+	defer db.observe("GetLedgerByUser", time.Now())
+	db.lock.RLock()
+	defer db.lock.RUnlock()
+
+	entries := []models.LedgerEntry{}
+	for _, entry := range db.ledger {
+		if entry.UserID == userID {
+			entries = append(entries, entry)
+		}
+	}
+	return entries
+}
+
+// GetUserBalance returns the current balance and lifetime earned points for a user
+func (db *MemoryDatabase) GetUserBalance(userID string) (balance int, lifetime int) {
+	defer db.observe("GetUserBalance", time.Now())
+	db.lock.RLock()
+	defer db.lock.RUnlock()
+
+	return db.balances[models.UserAccount(userID)], db.lifetimePoints[userID]
+}
@@ -0,0 +1,206 @@
+package store
+
+import (
+	"sync"
+	"testing"
+
+	"github.com/google/uuid"
+
+	"receipt-processor-challenge-jase180/internal/models"
+)
+
+// helper function that posts an entry with a fresh ID and fails the test on error
+func mustPost(t *testing.T, db *MemoryDatabase, entry models.LedgerEntry) models.LedgerEntry {
+	t.Helper()
+	entry.ID = uuid.NewString()
+	posted, err := db.PostLedgerEntry(entry)
+	if err != nil {
+		t.Fatalf("Result: %v; want Success Post of %+v", err, entry)
+	}
+	return posted
+}
+
+// TestLedgerFunctions tests earn, redeem, adjust and reverse entries and their errors
+func TestLedgerFunctions(t *testing.T) {
+	db := NewMemoryDatabase()
+
+	// Earn 100 points
+	earn := mustPost(t, db, models.LedgerEntry{UserID: "user-1", Type: models.LedgerEarn, Points: 100, ReceiptID: uuid.NewString()})
+	if earn.DebitAccount != models.AccountEarned || earn.CreditAccount != models.UserAccount("user-1") {
+		t.Fatalf("Result accounts %s -> %s; want %s -> %s", earn.DebitAccount, earn.CreditAccount, models.AccountEarned, models.UserAccount("user-1"))
+	}
+
+	// Redeem 60 points then fail to redeem 60 more
+	redemption := mustPost(t, db, models.LedgerEntry{UserID: "user-1", Type: models.LedgerRedemption, Points: 60})
+	_, err := db.PostLedgerEntry(models.LedgerEntry{ID: uuid.NewString(), UserID: "user-1", Type: models.LedgerRedemption, Points: 60})
+	if err != ErrInsufficientPoints {
+		t.Fatalf("Result: %v; want error %v", err, ErrInsufficientPoints)
+	}
+
+	// Negative adjustment debits user and is stored positive
+	adjustment := mustPost(t, db, models.LedgerEntry{UserID: "user-1", Type: models.LedgerAdjustment, Points: -10, Reference: "goodwill correction"})
+	if adjustment.Points != 10 || adjustment.DebitAccount != models.UserAccount("user-1") {
+		t.Fatalf("Result: %+v; want 10 points debited from user", adjustment)
+	}
+
+	// Reversing the redemption gives the 60 points back
+	mustPost(t, db, models.LedgerEntry{Type: models.LedgerReversal, ReversalOf: redemption.ID})
+	balance, lifetime := db.GetUserBalance("user-1")
+	if balance != 90 || lifetime != 100 {
+		t.Fatalf("Result balance %d lifetime %d; want 90 and 100", balance, lifetime)
+	}
+
+	// Reverse errors
+	reversalErrors := []struct {
+		name       string
+		reversalOf string
+		want       error
+	}{
+		{"Already reversed", redemption.ID, ErrEntryAlreadyReversed},
+		{"No such entry", uuid.NewString(), ErrEntryNotInLedger},
+	}
+	for _, testCase := range reversalErrors {
+		_, err := db.PostLedgerEntry(models.LedgerEntry{ID: uuid.NewString(), Type: models.LedgerReversal, ReversalOf: testCase.reversalOf})
+		if err != testCase.want {
+			t.Errorf("%s result: %v; want error %v", testCase.name, err, testCase.want)
+		}
+	}
+
+	// Reversing the earn needs the full 100 points back so fails until topped up
+	_, err = db.PostLedgerEntry(models.LedgerEntry{ID: uuid.NewString(), Type: models.LedgerReversal, ReversalOf: earn.ID})
+	if err != ErrInsufficientPoints {
+		t.Fatalf("Result: %v; want error %v", err, ErrInsufficientPoints)
+	}
+	mustPost(t, db, models.LedgerEntry{UserID: "user-1", Type: models.LedgerAdjustment, Points: 10, Reference: "undo correction"})
+
+	// Reversing the earn removes it from lifetime total
+	mustPost(t, db, models.LedgerEntry{Type: models.LedgerReversal, ReversalOf: earn.ID})
+	balance, lifetime = db.GetUserBalance("user-1")
+	if balance != 0 || lifetime != 0 {
+		t.Fatalf("Result balance %d lifetime %d; want 0 and 0", balance, lifetime)
+	}
+
+	// Original entry is marked reversed
+	stored, err := db.GetLedgerEntryByID(earn.ID)
+	if err != nil || stored.ReversedBy == "" {
+		t.Fatalf("Result: %+v, %v; want earn marked reversed", stored, err)
+	}
+
+	// Every entry for the user is kept in order
+	if entries := db.GetLedgerByUser("user-1"); len(entries) != 6 || entries[0].ID != earn.ID {
+		t.Fatalf("Result %d entries; want 6 starting with earn", len(entries))
+	}
+}
+
+// TestLedgerInvalidEntries tests entries that should never be posted
+func TestLedgerInvalidEntries(t *testing.T) {
+	db := NewMemoryDatabase()
+
+	tests := []struct {
+		name  string
+		entry models.LedgerEntry
+	}{
+		{"Unknown type", models.LedgerEntry{UserID: "user-1", Type: "gift", Points: 10}},
+		{"Missing user", models.LedgerEntry{Type: models.LedgerEarn, Points: 10}},
+		{"Zero redemption", models.LedgerEntry{UserID: "user-1", Type: models.LedgerRedemption}},
+		{"Negative earn", models.LedgerEntry{UserID: "user-1", Type: models.LedgerEarn, Points: -10}},
+	}
+	for _, testCase := range tests {
+		t.Run(testCase.name, func(t *testing.T) {
+			testCase.entry.ID = uuid.NewString()
+			if _, err := db.PostLedgerEntry(testCase.entry); err != ErrInvalidEntry {
+				t.Errorf("Result: %v; want error %v", err, ErrInvalidEntry)
+			}
+		})
+	}
+}
+
+// Tests concurrent redemptions with WaitGroup never spend more than the balance
+func TestLedgerConcurrentRedemptions(t *testing.T) {
+	db := NewMemoryDatabase()
+	mustPost(t, db, models.LedgerEntry{UserID: "user-1", Type: models.LedgerEarn, Points: 100})
+
+	// Initiate a waitgroup
+	var waitGroup sync.WaitGroup
+	numConcurrentTasks := 50 // 50 redemptions of 10 against a balance of 100, only 10 can succeed
+
+	var successLock sync.Mutex
+	successes := 0
+
+	for i := 0; i < numConcurrentTasks; i++ {
+		waitGroup.Add(1)
+		go func() {
+			defer waitGroup.Done()
+			_, err := db.PostLedgerEntry(models.LedgerEntry{ID: uuid.NewString(), UserID: "user-1", Type: models.LedgerRedemption, Points: 10})
+			if err == nil {
+				successLock.Lock()
+				successes++
+				successLock.Unlock()
+			} else if err != ErrInsufficientPoints {
+				t.Errorf("Error in concurrent redemption: %v", err)
+			}
+		}()
+	}
+
+	// Concurrent balance reads while redeeming must never see a negative balance
+	for i := 0; i < numConcurrentTasks; i++ {
+		waitGroup.Add(1)
+		go func() {
+			defer waitGroup.Done()
+			if balance, _ := db.GetUserBalance("user-1"); balance < 0 {
+				t.Errorf("Negative balance in concurrent READ: %d", balance)
+			}
+		}()
+	}
+
+	waitGroup.Wait() // this ensures all go routines finish
+
+	balance, _ := db.GetUserBalance("user-1")
+	if successes != 10 || balance != 0 {
+		t.Fatalf("Result %d successful redemptions and balance %d; want 10 and 0", successes, balance)
+	}
+}
+
+// TestAddReceiptWithEarning tests a receipt and its earn entry are stored together or not at all
+func TestAddReceiptWithEarning(t *testing.T) {
+	db := NewMemoryDatabase()
+	taken := mustPost(t, db, models.LedgerEntry{UserID: "user-1", Type: models.LedgerEarn, Points: 5, ReceiptID: uuid.NewString()})
+
+	receiptID := uuid.NewString()
+	failures := []struct {
+		name string
+		earn models.LedgerEntry
+		want error
+	}{
+		{"Entry ID taken", models.LedgerEntry{ID: taken.ID, UserID: "user-1", Type: models.LedgerEarn, Points: 10, ReceiptID: receiptID}, ErrEntryAlreadyExists},
+		{"Invalid entry", models.LedgerEntry{ID: uuid.NewString(), UserID: "user-1", Type: models.LedgerEarn, Points: -10, ReceiptID: receiptID}, ErrInvalidEntry},
+	}
+	for _, test := range failures {
+		t.Run(test.name, func(t *testing.T) {
+			_, err := db.AddReceiptWithEarning(models.Receipt{ID: receiptID, UserID: "user-1", PurchaseDate: "2022-01-01"}, test.earn)
+			if err != test.want {
+				t.Fatalf("Result: %v; want error %v", err, test.want)
+			}
+			// A failed ledger post must leave no receipt behind
+			if _, err := db.GetReceiptByID(receiptID); err != ErrReceiptNotInDatabase {
+				t.Fatalf("Result: %v; want receipt not stored", err)
+			}
+			if balance, _ := db.GetUserBalance("user-1"); balance != 5 {
+				t.Fatalf("Result balance %d; want 5", balance)
+			}
+		})
+	}
+
+	// Success stores both, then a repeat receipt posts nothing
+	earn := models.LedgerEntry{ID: uuid.NewString(), UserID: "user-1", Type: models.LedgerEarn, Points: 10, ReceiptID: receiptID}
+	if _, err := db.AddReceiptWithEarning(models.Receipt{ID: receiptID, UserID: "user-1", PurchaseDate: "2022-01-01"}, earn); err != nil {
+		t.Fatalf("Result: %v; want Success", err)
+	}
+	earn.ID = uuid.NewString()
+	if _, err := db.AddReceiptWithEarning(models.Receipt{ID: receiptID, UserID: "user-1", PurchaseDate: "2022-01-01"}, earn); err != ErrReceiptAlreadyExists {
+		t.Fatalf("Result: %v; want error %v", err, ErrReceiptAlreadyExists)
+	}
+	if balance, _ := db.GetUserBalance("user-1"); balance != 15 {
+		t.Fatalf("Result balance %d; want 15", balance)
+	}
+}
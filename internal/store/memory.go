@@ -2,7 +2,9 @@ package store
 
 import (
 	"errors"
+	"sort"
 	"sync"
+	"time"
 
 	"receipt-processor-challenge-jase180/internal/models"
 )
@@ -11,42 +13,92 @@ import (
 var (
 	ErrReceiptAlreadyExists = errors.New("receipt already exists in database")
 	ErrReceiptNotInDatabase = errors.New("no such receipt exists in database")
+	ErrUserNotInDatabase    = errors.New("no such user exists in database")
+	ErrDatabaseNotReady     = errors.New("database is not initialized")
 )
 
 // MemoryDatabase provides an in-memory storage for receipts
 // Use sync.RWMutex to ensure write safety (sync.Map is alternative)
 type MemoryDatabase struct {
-	lock     sync.RWMutex              // lock ensures thread safety
-	receipts map[string]models.Receipt // Stores receipts in memory
+	lock      sync.RWMutex               // lock ensures thread safety
+	receipts  map[string]models.Receipt  // Stores receipts in memory
+	users     map[string]models.User     // Stores users keyed by user ID, created on first receipt
+	campaigns map[string]models.Campaign // Stores promotional campaigns keyed by campaign ID
+	byDate    map[string][]string        // Receipt IDs by purchase date in submission order, for daily limits
+
+	ledger         []models.LedgerEntry // Append-only points ledger in posting order
+	ledgerIndex    map[string]int       // Ledger entry ID to position in ledger
+	balances       map[string]int       // Running balance per ledger account
+	lifetimePoints map[string]int       // Earned points per user net of reversed earnings
+	reversed       map[string]bool      // Receipt IDs whose earn entry was reversed
+
+	// Observe, if set, is called after each operation with its method name and how long it took including lock waits
+	// Set it before the database is shared between goroutines
+	Observe func(operation string, elapsed time.Duration)
 }
 
 // NewMemoryDatabase initializes and returns a new in-memory database
 func NewMemoryDatabase() *MemoryDatabase {
 	db := &MemoryDatabase{}                       // initiates a db
 	db.receipts = make(map[string]models.Receipt) // makes a map with the Receipt() struct from models
+	db.users = make(map[string]models.User)       // makes a map with the User() struct from models
+	db.campaigns = make(map[string]models.Campaign)
+	db.byDate = make(map[string][]string)
+	db.ledgerIndex = make(map[string]int)
+	db.balances = make(map[string]int)
+	db.lifetimePoints = make(map[string]int)
+	db.reversed = make(map[string]bool)
 
 	return db
 }
 
 // AddReceipt adds a receipt into the memory database after checking if a receipt with the same ID exists already
 func (db *MemoryDatabase) AddReceipt(receipt models.Receipt) error {
+	defer db.observe("AddReceipt", time.Now())
 	// Manual lock/unlock to ensure go concurrency, only one goroutine allowed access at a time
 	db.lock.Lock()
 	defer db.lock.Unlock()
 
 	// Check if receipt for ID exists already
-	_, exists := db.receipts[receipt.ID]
-	if exists {
+	if _, exists := db.receipts[receipt.ID]; exists {
 		return ErrReceiptAlreadyExists
 	}
+	db.addReceipt(receipt)
+	return nil
+}
+
+// AddReceiptWithEarning adds a receipt and posts the earn entry crediting its user under one write lock
+// Nothing is stored if either fails, so a stored receipt's points and the ledger never disagree
+func (db *MemoryDatabase) AddReceiptWithEarning(receipt models.Receipt, earn models.LedgerEntry) (models.LedgerEntry, error) {
+	defer db.observe("AddReceiptWithEarning", time.Now())
+	db.lock.Lock()
+	defer db.lock.Unlock()
+
+	if _, exists := db.receipts[receipt.ID]; exists {
+		return models.LedgerEntry{}, ErrReceiptAlreadyExists
+	}
+	// Post first, adding the receipt cannot fail once the ID is known to be free
+	posted, err := db.postLedgerEntry(earn)
+	if err != nil {
+		return models.LedgerEntry{}, err
+	}
+	db.addReceipt(receipt)
+	return posted, nil
+}
 
-	// Add receipt into the MemoryDatabase
+// addReceipt stores a receipt whose ID is not taken and ties it to its user
+// Caller must hold the write lock so balances never see a half-added receipt
+func (db *MemoryDatabase) addReceipt(receipt models.Receipt) {
 	db.receipts[receipt.ID] = receipt
-	return nil
+	db.byDate[receipt.PurchaseDate] = append(db.byDate[receipt.PurchaseDate], receipt.ID)
+	if receipt.UserID != "" {
+		db.addReceiptToUser(receipt.UserID, receipt.ID)
+	}
 }
 
 // GetReceiptByID retrieves the receipt from the memory database with the ID after checking if ID exists
 func (db *MemoryDatabase) GetReceiptByID(id string) (models.Receipt, error) {
+	defer db.observe("GetReceiptByID", time.Now())
 	// Manual lock/unlock to ensure go concurrency, only one goroutine allowed access at a time
 	db.lock.RLock()
 	defer db.lock.RUnlock()
@@ -59,3 +111,71 @@ func (db *MemoryDatabase) GetReceiptByID(id string) (models.Receipt, error) {
 
 	return receipt, nil
 }
+
+// ListReceipts returns every stored receipt ordered by ID so results are stable between calls
+func (db *MemoryDatabase) ListReceipts() []models.Receipt {
+	defer db.observe("ListReceipts", time.Now())
+	db.lock.RLock()
+	defer db.lock.RUnlock()
+
+	receipts := make([]models.Receipt, 0, len(db.receipts))
+	for _, receipt := range db.receipts {
+		receipts = append(receipts, receipt)
+	}
+	sort.Slice(receipts, func(i, j int) bool { return receipts[i].ID < receipts[j].ID })
+	return receipts
+}
+
+// GetReceiptsByPurchaseDate retrieves all receipts purchased on a date, any user, in submission order
+func (db *MemoryDatabase) GetReceiptsByPurchaseDate(date string) []models.Receipt {
+	defer db.observe("GetReceiptsByPurchaseDate", time.Now())
+	db.lock.RLock()
+	defer db.lock.RUnlock()
+
+	receipts := make([]models.Receipt, 0, len(db.byDate[date]))
+	for _, id := range db.byDate[date] {
+		receipts = append(receipts, db.receipts[id])
+	}
+	return receipts
+}
+
+// ListReceiptIDs returns the ID of every stored receipt in the order of ListReceipts
+// Lets callers walk receipts one at a time with GetReceiptByID without copying them all
+func (db *MemoryDatabase) ListReceiptIDs() []string {
+	defer db.observe("ListReceiptIDs", time.Now())
+	db.lock.RLock()
+	defer db.lock.RUnlock()
+
+	ids := make([]string, 0, len(db.receipts))
+	for id := range db.receipts {
+		ids = append(ids, id)
+	}
+	sort.Strings(ids)
+	return ids
+}
+
+// CountReceipts returns the number of stored receipts
+func (db *MemoryDatabase) CountReceipts() int {
+	defer db.observe("CountReceipts", time.Now())
+	db.lock.RLock()
+	defer db.lock.RUnlock()
+	return len(db.receipts)
+}
+
+// Ping checks the database can be read, used by readiness probes
+// Fails for a database not created with NewMemoryDatabase, and blocks while a write holds the lock like any read would
+func (db *MemoryDatabase) Ping() error {
+	db.lock.RLock()
+	defer db.lock.RUnlock()
+	if db.receipts == nil || db.ledgerIndex == nil {
+		return ErrDatabaseNotReady
+	}
+	return nil
+}
+
+// observe reports an operation started at start to Observe, deferred at the top of each operation
+func (db *MemoryDatabase) observe(operation string, start time.Time) {
+	if db.Observe != nil {
+		db.Observe(operation, time.Since(start))
+	}
+}
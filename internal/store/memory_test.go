@@ -1,8 +1,10 @@
 package store
 
 import (
+	"strings"
 	"sync"
 	"testing"
+	"time"
 
 	"github.com/google/uuid"
 
@@ -85,6 +87,23 @@ func TestMemoryDatabaseFunctions(t *testing.T) {
 	if testReceiptMorning.Retailer != receiptMorning.Retailer {
 		t.Fatalf("Incorrect receipt (retailer) retrieved, Result: %v; want %v", testReceiptMorning.Retailer, receiptMorning.Retailer)
 	}
+
+	// Test ListReceiptIDs in the same order as ListReceipts
+	ids, receipts := db.ListReceiptIDs(), db.ListReceipts()
+	if len(ids) != 2 || ids[0] != receipts[0].ID || ids[1] != receipts[1].ID {
+		t.Fatalf("Result IDs: %v; want IDs of %d receipts in ListReceipts order", ids, len(receipts))
+	}
+
+	// Test CountReceipts and Ping
+	if count := db.CountReceipts(); count != 2 {
+		t.Errorf("Result count: %d; want 2", count)
+	}
+	if err := db.Ping(); err != nil {
+		t.Errorf("Result: %v; want ping success", err)
+	}
+	if err := (&MemoryDatabase{}).Ping(); err != ErrDatabaseNotReady {
+		t.Errorf("Result: %v; want %v for uninitialized database", err, ErrDatabaseNotReady)
+	}
 }
 
 // Tests concurrency with WaitGroup to read and write at the same time
@@ -151,3 +170,28 @@ func TestMemoryDatabaseConcurrency(t *testing.T) {
 
 	waitGroup.Wait() // this ensures all go routines finish
 }
+
+// TestMemoryDatabaseObserve tests every operation is reported to Observe with its name
+func TestMemoryDatabaseObserve(t *testing.T) {
+	db := NewMemoryDatabase()
+	var operations []string
+	db.Observe = func(operation string, elapsed time.Duration) {
+		if elapsed < 0 {
+			t.Errorf("Result %s took %v, want not negative", operation, elapsed)
+		}
+		operations = append(operations, operation)
+	}
+
+	receipt := models.Receipt{ID: uuid.NewString(), UserID: "user-1"}
+	db.AddReceipt(receipt)
+	db.AddReceipt(receipt) // failed operations are reported too
+	db.GetReceiptByID("missing")
+	db.CountReceipts()
+	db.GetReceiptsByUser("user-1")
+	db.Ping() // health checks are not store traffic
+
+	want := "AddReceipt,AddReceipt,GetReceiptByID,CountReceipts,GetReceiptsByUser"
+	if got := strings.Join(operations, ","); got != want {
+		t.Errorf("Result operations %s, want %s", got, want)
+	}
+}
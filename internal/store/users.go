@@ -0,0 +1,51 @@
+package store
+
+import (
+	"receipt-processor-challenge-jase180/internal/models"
+	"time"
+)
+
+// addReceiptToUser appends a receipt ID to the user, creating the user if first seen
+// Caller must hold the write lock
+func (db *MemoryDatabase) addReceiptToUser(userID string, receiptID string) {
+	user, exists := db.users[userID]
+	if !exists {
+		user = models.User{ID: userID}
+	}
+	user.ReceiptIDs = append(user.ReceiptIDs, receiptID)
+	db.users[userID] = user
+}
+
+// GetUserByID retrieves the user from the memory database with the ID after checking if ID exists
+func (db *MemoryDatabase) GetUserByID(id string) (models.User, error) {
+	defer db.observe("GetUserByID", time.Now())
+	db.lock.RLock()
+	defer db.lock.RUnlock()
+
+	user, exists := db.users[id]
+	if !exists {
+		return models.User{}, ErrUserNotInDatabase
+	}
+
+	// Copy receipt IDs so callers cannot modify the stored slice
+	user.ReceiptIDs = append([]string(nil), user.ReceiptIDs...)
+	return user, nil
+}
+
+// GetReceiptsByUser retrieves all receipts submitted for a user in submission order
+func (db *MemoryDatabase) GetReceiptsByUser(userID string) ([]models.Receipt, error) {
+	defer db.observe("GetReceiptsByUser", time.Now())
+	db.lock.RLock()
+	defer db.lock.RUnlock()
+
+	user, exists := db.users[userID]
+	if !exists {
+		return nil, ErrUserNotInDatabase
+	}
+
+	receipts := make([]models.Receipt, 0, len(user.ReceiptIDs))
+	for _, id := range user.ReceiptIDs {
+		receipts = append(receipts, db.receipts[id])
+	}
+	return receipts, nil
+}
@@ -0,0 +1,53 @@
+package store
+
+import (
+	"testing"
+
+	"github.com/google/uuid"
+
+	"receipt-processor-challenge-jase180/internal/models"
+)
+
+// TestMemoryDatabaseUsers tests users are created from receipts and receipts are retrieved per user
+func TestMemoryDatabaseUsers(t *testing.T) {
+	db := NewMemoryDatabase()
+
+	// Two receipts for same user and one anonymous receipt
+	userReceipts := []models.Receipt{
+		{ID: uuid.NewString(), Retailer: "Walgreens", UserID: "user-1"},
+		{ID: uuid.NewString(), Retailer: "Target", UserID: "user-1"},
+	}
+	anonymousReceipt := models.Receipt{ID: uuid.NewString(), Retailer: "Target"}
+
+	for _, receipt := range append(userReceipts, anonymousReceipt) {
+		if err := db.AddReceipt(receipt); err != nil {
+			t.Fatalf("Result: %v; want Success Add", err)
+		}
+	}
+
+	// Test GetUserByID has both receipt IDs in submission order
+	user, err := db.GetUserByID("user-1")
+	if err != nil {
+		t.Fatalf("Result: %v; want Success Retrieve", err)
+	}
+	if len(user.ReceiptIDs) != 2 || user.ReceiptIDs[0] != userReceipts[0].ID || user.ReceiptIDs[1] != userReceipts[1].ID {
+		t.Fatalf("Result: %v; want receipt IDs in submission order", user.ReceiptIDs)
+	}
+
+	// Test GetReceiptsByUser returns full receipts
+	receipts, err := db.GetReceiptsByUser("user-1")
+	if err != nil {
+		t.Fatalf("Result: %v; want Success Retrieve", err)
+	}
+	if len(receipts) != 2 || receipts[1].Retailer != "Target" {
+		t.Fatalf("Result: %v; want both user receipts", receipts)
+	}
+
+	// Test no such user - ErrUserNotInDatabase
+	if _, err := db.GetUserByID("user-2"); err != ErrUserNotInDatabase {
+		t.Fatalf("Result: %v; want error %v", err, ErrUserNotInDatabase)
+	}
+	if _, err := db.GetReceiptsByUser("user-2"); err != ErrUserNotInDatabase {
+		t.Fatalf("Result: %v; want error %v", err, ErrUserNotInDatabase)
+	}
+}